@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/db"
+	"github.com/enchant97/note-mark/backend/services"
+)
+
+const auditExportPageSize = 1000
+
+// Write matching audit entries as JSON lines, oldest first.
+func commandAuditExport(
+	dao *db.DAO,
+	outputPath string,
+	event string,
+	actor string,
+	username string,
+	since string,
+	until string,
+) error {
+	filter := core.AuditFilter{
+		Actor:    optionalString(actor),
+		Username: optionalString(username),
+	}
+	if event != "" {
+		auditEvent := core.AuditEvent(event)
+		filter.Event = &auditEvent
+	}
+	var err error
+	if filter.Since, err = parseOptionalTime("since", since); err != nil {
+		return err
+	}
+	if filter.Until, err = parseOptionalTime("until", until); err != nil {
+		return err
+	}
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+	auditService := services.AuditService{}.New(dao)
+	filter.Limit = auditExportPageSize
+	count := 0
+	for {
+		entries, err := auditService.GetEntries(filter)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		count += len(entries)
+		if len(entries) < auditExportPageSize {
+			break
+		}
+		filter.AfterId = entries[len(entries)-1].Id
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	slog.Info("exported audit entries", "count", count, "output", outputPath)
+	return f.Close()
+}
+
+func parseOptionalTime(name string, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid '%s', expected RFC 3339 format: %w", name, err)
+	}
+	return &t, nil
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -12,6 +12,8 @@ import (
 	"github.com/enchant97/note-mark/backend/core"
 	"github.com/enchant97/note-mark/backend/db"
 	"github.com/enchant97/note-mark/backend/db/migrations"
+	"github.com/enchant97/note-mark/backend/importer"
+	"github.com/enchant97/note-mark/backend/signingkeys"
 	"github.com/enchant97/note-mark/backend/storage"
 	"github.com/enchant97/note-mark/backend/tree"
 	"github.com/go-chi/httplog/v3"
@@ -25,6 +27,9 @@ func Entrypoint(appVersion string) error {
 	validate.RegisterValidation("username", func(fl validator.FieldLevel) bool {
 		return core.IsValidUsername(fl.Field().String())
 	})
+	validate.RegisterValidation("group_name", func(fl validator.FieldLevel) bool {
+		return core.IsValidGroupName(fl.Field().String())
+	})
 	validate.RegisterValidation("slug_full", func(fl validator.FieldLevel) bool {
 		return core.IsValidFullSlug(fl.Field().String())
 	})
@@ -77,6 +82,11 @@ func Entrypoint(appVersion string) error {
 	if err := tc.Load(); err != nil {
 		return err
 	}
+	keyStore := signingkeys.Store{}.New(filepath.Join(appConfig.DataPath, "signing-keys"))
+	passwordPolicy := core.PasswordPolicy{
+		MinLength:    int(appConfig.PasswordPolicy.MinLength),
+		RejectCommon: appConfig.PasswordPolicy.RejectCommon,
+	}
 	// Do CLI
 	app := &cli.Command{
 		Version:               appVersion,
@@ -87,7 +97,7 @@ func Entrypoint(appVersion string) error {
 				Name:  "serve",
 				Usage: "run the api server",
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					return commandServe(logger, validate, appConfig, &dao, &tc)
+					return commandServe(logger, validate, appConfig, &dao, &keyStore, &tc)
 				},
 			},
 			{
@@ -133,6 +143,245 @@ func Entrypoint(appVersion string) error {
 					},
 				},
 			},
+			{
+				Name:  "keys",
+				Usage: "access token signing key management",
+				Description: "keys are stored in the data path, the newest key is used for signing. " +
+					"Any running instances of Note Mark will need to be restarted" +
+					" before key changes are taken into effect.",
+				Commands: []*cli.Command{
+					{
+						Name:  "list",
+						Usage: "list all signing keys, newest first",
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return commandKeysList(&keyStore)
+						},
+					},
+					{
+						Name:  "rotate",
+						Usage: "create a new signing key, removing retired keys once their tokens have expired",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "algorithm",
+								Aliases: []string{"a"},
+								Value:   signingkeys.AlgorithmEdDSA,
+								Usage:   "either EdDSA or RS256",
+							},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return commandKeysRotate(appConfig, &keyStore, cmd.String("algorithm"))
+						},
+					},
+					{
+						Name:  "remove",
+						Usage: "remove a signing key, tokens signed by it will stop working",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "id", Required: true},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return commandKeysRemove(&keyStore, cmd.String("id"))
+						},
+					},
+				},
+			},
+			{
+				Name:  "audit",
+				Usage: "audit log management",
+				Commands: []*cli.Command{
+					{
+						Name:  "export",
+						Usage: "export audit entries as JSON lines",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Required: true},
+							&cli.StringFlag{Name: "event", Usage: "only include a specific event"},
+							&cli.StringFlag{Name: "actor", Usage: "only include actions by a username"},
+							&cli.StringFlag{Name: "username", Aliases: []string{"u"}, Usage: "only include nodes owned by a username"},
+							&cli.StringFlag{Name: "since", Usage: "only include entries at or after a RFC 3339 time"},
+							&cli.StringFlag{Name: "until", Usage: "only include entries before a RFC 3339 time"},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return commandAuditExport(
+								&dao,
+								cmd.String("output"),
+								cmd.String("event"),
+								cmd.String("actor"),
+								cmd.String("username"),
+								cmd.String("since"),
+								cmd.String("until"),
+							)
+						},
+					},
+				},
+			},
+			{
+				Name:  "publish",
+				Usage: "publish a users public notes as a static site",
+				Description: "publish renders every note that is publicly readable into" +
+					" a self-contained static HTML site. " +
+					"Any existing content in the output directory will be replaced.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "username", Aliases: []string{"u"}, Required: true},
+					&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Required: true},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					username := cmd.String("username")
+					outputPath := cmd.String("output")
+					return commandPublish(&tc, username, outputPath)
+				},
+			},
+			{
+				Name:  "import",
+				Usage: "import notes from other apps",
+				Description: "import writes notes straight to storage. " +
+					"Any running instances of Note Mark will need to be restarted" +
+					" before imported notes are shown.",
+				Commands: []*cli.Command{
+					{
+						Name:  "obsidian",
+						Usage: "import a Obsidian vault directory",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "username", Aliases: []string{"u"}, Required: true},
+							&cli.StringFlag{Name: "path", Aliases: []string{"p"}, Required: true},
+							&cli.StringFlag{
+								Name:     "slug",
+								Aliases:  []string{"s"},
+								Required: false,
+								Usage:    "import under a note, instead of the root",
+							},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							username := cmd.String("username")
+							vaultPath := cmd.String("path")
+							slug := cmd.String("slug")
+							limits := importer.Limits{
+								MaxFileSize:  int64(appConfig.FileSizeLimit),
+								MaxFiles:     appConfig.ImportMaxFiles,
+								MaxTotalSize: int64(appConfig.ImportExtractedSizeLimit),
+							}
+							return commandImportObsidian(&tc, limits, username, vaultPath, slug)
+						},
+					},
+				},
+			},
+			{
+				Name:  "group",
+				Usage: "group management",
+				Commands: []*cli.Command{
+					{
+						Name:  "list",
+						Usage: "list all groups and their members",
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return commandGroupList(&dao)
+						},
+					},
+					{
+						Name:  "add",
+						Usage: "add a new group",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "name", Aliases: []string{"n"}, Required: true},
+							&cli.StringFlag{
+								Name:     "owner",
+								Aliases:  []string{"o"},
+								Required: false,
+								Usage:    "user allowed to manage the group",
+							},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							name := cmd.String("name")
+							owner := cmd.String("owner")
+							return commandGroupAdd(&dao, name, owner)
+						},
+					},
+					{
+						Name:  "remove",
+						Usage: "remove a existing group",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "name", Aliases: []string{"n"}, Required: true},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							name := cmd.String("name")
+							return commandGroupRemove(&dao, name)
+						},
+					},
+					{
+						Name:  "add-member",
+						Usage: "add a user to a existing group",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "name", Aliases: []string{"n"}, Required: true},
+							&cli.StringFlag{Name: "username", Aliases: []string{"u"}, Required: true},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							name := cmd.String("name")
+							username := cmd.String("username")
+							return commandGroupAddMember(&dao, name, username)
+						},
+					},
+					{
+						Name:  "remove-member",
+						Usage: "remove a user from a existing group",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "name", Aliases: []string{"n"}, Required: true},
+							&cli.StringFlag{Name: "username", Aliases: []string{"u"}, Required: true},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							name := cmd.String("name")
+							username := cmd.String("username")
+							return commandGroupRemoveMember(&dao, name, username)
+						},
+					},
+				},
+			},
+			{
+				Name:  "invite",
+				Usage: "invite management, for when signup is invite only",
+				Commands: []*cli.Command{
+					{
+						Name:  "list",
+						Usage: "list all invites",
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return commandInviteList(&dao, &tc)
+						},
+					},
+					{
+						Name:  "create",
+						Usage: "create a new invite, printing its code",
+						Flags: []cli.Flag{
+							&cli.Int64Flag{Name: "uses", Value: 1, Usage: "number of users that can signup with the invite"},
+							&cli.DurationFlag{Name: "expires", Value: core.DefaultInviteExpiry, Usage: "how long until the invite expires"},
+							&cli.StringFlag{Name: "group", Usage: "group to add users to"},
+							&cli.StringFlag{Name: "created-by", Usage: "user creating the invite, required when sharing a note"},
+							&cli.StringFlag{Name: "share-slug", Usage: "note of the creator to share with users"},
+							&cli.StringFlag{
+								Name:  "share-mode",
+								Value: string(core.AccessControlReadMode),
+								Usage: "access given to the shared note (read, comment or write)",
+							},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return commandInviteCreate(
+								&dao,
+								&tc,
+								cmd.Int64("uses"),
+								cmd.Duration("expires"),
+								cmd.String("group"),
+								cmd.String("created-by"),
+								cmd.String("share-slug"),
+								cmd.String("share-mode"),
+							)
+						},
+					},
+					{
+						Name:  "remove",
+						Usage: "revoke a existing invite",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "uid", Required: true},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return commandInviteRemove(&dao, cmd.String("uid"))
+						},
+					},
+				},
+			},
 			{
 				Name:  "user",
 				Usage: "user management",
@@ -147,7 +396,7 @@ func Entrypoint(appVersion string) error {
 						Action: func(ctx context.Context, cmd *cli.Command) error {
 							username := cmd.String("username")
 							password := cmd.String("password")
-							return commandUserAdd(&dao, &tc, username, password)
+							return commandUserAdd(&dao, &tc, passwordPolicy, username, password)
 						},
 					},
 					{
@@ -157,6 +406,9 @@ func Entrypoint(appVersion string) error {
 							&cli.StringFlag{Name: "username", Aliases: []string{"u"}, Required: true},
 						},
 						Action: func(ctx context.Context, cmd *cli.Command) error {
+							if err := ensureServerStopped(appConfig); err != nil {
+								return err
+							}
 							username := cmd.String("username")
 							return commandUserRemove(&dao, username)
 						},
@@ -171,7 +423,7 @@ func Entrypoint(appVersion string) error {
 						Action: func(ctx context.Context, cmd *cli.Command) error {
 							username := cmd.String("username")
 							password := cmd.String("password")
-							return commandUserSetPassword(&dao, username, password)
+							return commandUserSetPassword(&dao, passwordPolicy, username, password)
 						},
 					},
 					{
@@ -185,17 +437,66 @@ func Entrypoint(appVersion string) error {
 							return commandUserRemovePassword(&dao, username)
 						},
 					},
+					{
+						Name:  "set-admin",
+						Usage: "grant or revoke a existing users administrator role",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "username", Aliases: []string{"u"}, Required: true},
+							&cli.BoolFlag{Name: "revoke", Usage: "revoke the administrator role"},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							if cmd.Bool("revoke") {
+								if err := ensureServerStopped(appConfig); err != nil {
+									return err
+								}
+							}
+							username := cmd.String("username")
+							return commandUserSetAdmin(&dao, username, !cmd.Bool("revoke"))
+						},
+					},
+					{
+						Name:  "rename",
+						Usage: "change a existing users username, the old username will redirect to the new one",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "username", Aliases: []string{"u"}, Required: true},
+							&cli.StringFlag{Name: "new-username", Required: true},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							if err := ensureServerStopped(appConfig); err != nil {
+								return err
+							}
+							username := cmd.String("username")
+							newUsername := cmd.String("new-username")
+							return commandUserRename(&dao, &tc, username, newUsername)
+						},
+					},
+					{
+						Name:  "reset-2fa",
+						Usage: "remove a existing users two-factor authentication, for when they are locked out",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "username", Aliases: []string{"u"}, Required: true},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							username := cmd.String("username")
+							return commandUserReset2FA(&dao, username)
+						},
+					},
 					{
 						Name:  "add-oidc-mapping",
 						Usage: "set a existing users oidc mapping",
 						Flags: []cli.Flag{
 							&cli.StringFlag{Name: "username", Required: true},
 							&cli.StringFlag{Name: "user-sub", Required: true},
+							&cli.StringFlag{
+								Name:  "provider",
+								Usage: "provider name, optional when only one oidc provider is configured",
+							},
 						},
 						Action: func(ctx context.Context, cmd *cli.Command) error {
 							username := cmd.String("username")
 							userSub := cmd.String("user-sub")
-							return commandUserAddOidcMapping(appConfig, &dao, username, userSub)
+							providerName := cmd.String("provider")
+							return commandUserAddOidcMapping(appConfig, &dao, providerName, username, userSub)
 						},
 					},
 				},
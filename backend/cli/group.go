@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/db"
+	"github.com/google/uuid"
+)
+
+func commandGroupAdd(
+	dao *db.DAO,
+	name string,
+	owner string,
+) error {
+	if !core.IsValidGroupName(name) {
+		return fmt.Errorf("invalid group name '%s'", name)
+	}
+	var ownerUid uuid.NullUUID
+	if owner != "" {
+		uid, err := core.WrapDbErrorWithValue(dao.Queries.GetUserUidByUsername(context.Background(), owner))
+		if err != nil {
+			return err
+		}
+		ownerUid = uuid.NullUUID{UUID: uid, Valid: true}
+	}
+	if _, err := dao.Queries.InsertGroup(context.Background(), db.InsertGroupParams{
+		Name:     name,
+		OwnerUid: ownerUid,
+	}); err != nil {
+		return core.WrapDbError(err)
+	}
+	fmt.Printf("Group '%s' created\n", name)
+	return nil
+}
+
+func commandGroupRemove(
+	dao *db.DAO,
+	name string,
+) error {
+	group, err := core.WrapDbErrorWithValue(dao.Queries.GetGroupByName(context.Background(), name))
+	if err != nil {
+		return err
+	}
+	return dao.Queries.DeleteGroup(context.Background(), group.ID)
+}
+
+func commandGroupAddMember(
+	dao *db.DAO,
+	name string,
+	username string,
+) error {
+	group, err := core.WrapDbErrorWithValue(dao.Queries.GetGroupByName(context.Background(), name))
+	if err != nil {
+		return err
+	}
+	userUid, err := core.WrapDbErrorWithValue(dao.Queries.GetUserUidByUsername(context.Background(), username))
+	if err != nil {
+		return err
+	}
+	return core.WrapDbError(dao.Queries.InsertGroupMember(context.Background(), db.InsertGroupMemberParams{
+		GroupID: group.ID,
+		UserUid: userUid,
+	}))
+}
+
+func commandGroupRemoveMember(
+	dao *db.DAO,
+	name string,
+	username string,
+) error {
+	group, err := core.WrapDbErrorWithValue(dao.Queries.GetGroupByName(context.Background(), name))
+	if err != nil {
+		return err
+	}
+	userUid, err := core.WrapDbErrorWithValue(dao.Queries.GetUserUidByUsername(context.Background(), username))
+	if err != nil {
+		return err
+	}
+	return dao.Queries.DeleteGroupMember(context.Background(), db.DeleteGroupMemberParams{
+		GroupID: group.ID,
+		UserUid: userUid,
+	})
+}
+
+func commandGroupList(dao *db.DAO) error {
+	groups, err := dao.Queries.GetGroups(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		members, err := dao.Queries.GetGroupMemberUsernames(context.Background(), group.ID)
+		if err != nil {
+			return err
+		}
+		owner := "-"
+		if group.OwnerUsername.Valid {
+			owner = group.OwnerUsername.String
+		}
+		fmt.Printf("%s (owner: %s): %s\n", group.Name, owner, strings.Join(members, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/importer"
+	"github.com/enchant97/note-mark/backend/tree"
+)
+
+func commandImportObsidian(
+	tc *tree.TreeController,
+	limits importer.Limits,
+	username string,
+	vaultPath string,
+	slug string,
+) error {
+	if info, err := os.Stat(vaultPath); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return errors.New("vault path must be a directory")
+	}
+	var parentSlug core.NodeSlug
+	if slug != "" {
+		parentSlug = core.NodeSlug(path.Clean(slug))
+		if !core.IsValidNodeSlug(string(parentSlug), core.NoteNode) {
+			return core.ErrSlugInvalid
+		}
+	}
+	obsidianImporter := importer.ObsidianImporter{}.New(tc, limits)
+	report, err := obsidianImporter.ImportVault(os.DirFS(vaultPath), core.Username(username), parentSlug)
+	for _, renamed := range report.Renamed {
+		fmt.Printf("renamed '%s' to '%s'\n", renamed.Path, renamed.Slug)
+	}
+	for _, skipped := range report.Skipped {
+		fmt.Printf("skipped '%s': %s\n", skipped.Path, skipped.Reason)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d notes and %d assets for '%s'\n", report.Notes, report.Assets, username)
+	return nil
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/db"
+	"github.com/enchant97/note-mark/backend/services"
+	"github.com/enchant97/note-mark/backend/tree"
+	"github.com/google/uuid"
+)
+
+func commandInviteCreate(
+	dao *db.DAO,
+	tc *tree.TreeController,
+	maxUses int64,
+	expiresIn time.Duration,
+	group string,
+	createdBy string,
+	shareSlug string,
+	shareMode string,
+) error {
+	toCreate := core.CreateInvite{MaxUses: maxUses}
+	if expiresIn != 0 {
+		expiresAt := time.Now().Add(expiresIn)
+		toCreate.ExpiresAt = &expiresAt
+	}
+	if group != "" {
+		name := core.GroupName(group)
+		toCreate.Group = &name
+	}
+	if shareSlug != "" {
+		if !core.IsValidFullSlug(shareSlug) {
+			return fmt.Errorf("invalid slug '%s'", shareSlug)
+		}
+		switch core.AccessControlMode(shareMode) {
+		case core.AccessControlReadMode, core.AccessControlCommentMode, core.AccessControlWriteMode:
+		default:
+			return fmt.Errorf("invalid share mode '%s'", shareMode)
+		}
+		toCreate.Share = &core.InviteShare{
+			Slug: core.NodeSlug(shareSlug),
+			Mode: core.AccessControlMode(shareMode),
+		}
+	}
+	var creator *core.AuthenticatedUser
+	if createdBy != "" {
+		user, err := core.WrapDbErrorWithValue(dao.Queries.GetUserByUsername(context.Background(), createdBy))
+		if err != nil {
+			return err
+		}
+		// run by an administrator, so any group can be used
+		creator = &core.AuthenticatedUser{
+			UserUID:  user.Uid,
+			Username: user.Username,
+			IsAdmin:  true,
+		}
+	} else if toCreate.Share != nil {
+		return errors.New("created-by must be given when sharing a note")
+	}
+	invitesService := services.InvitesService{}.New(dao, tc, nil)
+	invite, err := invitesService.CreateInvite(creator, toCreate)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Invite '%s' created, expires at %s\n", invite.Uid, invite.ExpiresAt.Format(time.RFC3339))
+	fmt.Printf("Code: %s\n", invite.Code)
+	return nil
+}
+
+func commandInviteList(dao *db.DAO, tc *tree.TreeController) error {
+	invitesService := services.InvitesService{}.New(dao, tc, nil)
+	invites, err := invitesService.GetAllInvites()
+	if err != nil {
+		return err
+	}
+	for _, invite := range invites {
+		createdBy := "-"
+		if invite.CreatedBy != nil {
+			createdBy = *invite.CreatedBy
+		}
+		fmt.Printf(
+			"%s (created by: %s) used %d/%d, expires %s",
+			invite.Uid,
+			createdBy,
+			invite.UseCount,
+			invite.MaxUses,
+			invite.ExpiresAt.Format(time.RFC3339),
+		)
+		if invite.Group != nil {
+			fmt.Printf(", group: %s", *invite.Group)
+		}
+		if invite.Share != nil {
+			fmt.Printf(", shares: %s (%s)", invite.Share.Slug, invite.Share.Mode)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func commandInviteRemove(dao *db.DAO, uid string) error {
+	parsed, err := uuid.Parse(uid)
+	if err != nil {
+		return err
+	}
+	count, err := dao.Queries.AdminDeleteInvite(context.Background(), parsed)
+	if err != nil {
+		return core.WrapDbError(err)
+	} else if count == 0 {
+		return fmt.Errorf("invite '%s' not found", uid)
+	}
+	fmt.Printf("Invite '%s' removed\n", uid)
+	return nil
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/enchant97/note-mark/backend/config"
+	"github.com/enchant97/note-mark/backend/signingkeys"
+)
+
+func commandKeysList(keyStore *signingkeys.Store) error {
+	keys, err := keyStore.Load()
+	if err != nil {
+		return err
+	}
+	for i, key := range keys {
+		status := "verifying"
+		if i == 0 {
+			status = "signing"
+		} else if key.RetiredAt != nil {
+			status = "retired " + key.RetiredAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%s %s (created %s, %s)\n", key.ID, key.Algorithm, key.CreatedAt.Format(time.RFC3339), status)
+	}
+	return nil
+}
+
+func commandKeysRotate(
+	appConfig config.AppConfig,
+	keyStore *signingkeys.Store,
+	algorithm string,
+) error {
+	maxTokenAge := time.Duration(appConfig.AuthToken.Expiry) * time.Second
+	key, removed, err := keyStore.Rotate(algorithm, maxTokenAge, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, removedKey := range removed {
+		fmt.Printf("Key '%s' removed, as its tokens have expired\n", removedKey.ID)
+	}
+	fmt.Printf("Key '%s' created, restart Note Mark to start signing with it\n", key.ID)
+	return nil
+}
+
+func commandKeysRemove(keyStore *signingkeys.Store, keyID string) error {
+	if err := keyStore.Remove(keyID); err != nil {
+		return err
+	}
+	fmt.Printf("Key '%s' removed\n", keyID)
+	return nil
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/enchant97/note-mark/backend/config"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/publish"
+	"github.com/enchant97/note-mark/backend/tree"
+)
+
+func commandPublish(
+	tc *tree.TreeController,
+	username string,
+	outputPath string,
+) error {
+	if err := publish.ValidateOutputPath(outputPath); err != nil {
+		return err
+	}
+	publisher := publish.SitePublisher{}.New(tc)
+	slog.Info("publish site", "username", username, "output", outputPath)
+	return publisher.PublishUser(core.Username(username), outputPath)
+}
+
+// Periodically publish sites for configured users, until the process exits.
+func startPublishJob(
+	publishConfig config.PublishConfig,
+	tc *tree.TreeController,
+) {
+	publisher := publish.SitePublisher{}.New(tc)
+	publishAll := func() {
+		for _, username := range publishConfig.Usernames {
+			outputPath := filepath.Join(publishConfig.OutputPath, username)
+			slog.Info("publish site", "username", username, "output", outputPath)
+			if err := publisher.PublishUser(core.Username(username), outputPath); err != nil {
+				slog.Error("failed to publish site", "username", username, "err", err)
+			}
+		}
+	}
+	go func() {
+		publishAll()
+		if publishConfig.Interval == 0 {
+			return
+		}
+		ticker := time.NewTicker(publishConfig.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			publishAll()
+		}
+	}()
+}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
@@ -8,10 +9,12 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/enchant97/note-mark/backend/config"
 	"github.com/enchant97/note-mark/backend/db"
 	"github.com/enchant97/note-mark/backend/handlers"
+	"github.com/enchant97/note-mark/backend/signingkeys"
 	"github.com/enchant97/note-mark/backend/tree"
 	"github.com/go-playground/validator/v10"
 )
@@ -21,17 +24,36 @@ func commandServe(
 	validate *validator.Validate,
 	appConfig config.AppConfig,
 	dao *db.DAO,
+	keyStore *signingkeys.Store,
 	tc *tree.TreeController,
 ) error {
+	keys, err := keyStore.Load()
+	if err != nil {
+		return err
+	}
+	keyring, err := signingkeys.Keyring{}.New(keys, appConfig.AuthToken.Secret)
+	if errors.Is(err, signingkeys.ErrNoKeys) {
+		return errors.New("no signing keys, either create one using 'keys rotate' or set AUTH_TOKEN__SECRET")
+	} else if err != nil {
+		return err
+	}
+	if len(keys) != 0 && len(appConfig.AuthToken.Secret) != 0 {
+		slog.Info("signing with newest key, AUTH_TOKEN__SECRET is only used to verify existing tokens")
+	}
 	if mux, err := handlers.SetupHandlers(
 		logger,
 		validate,
 		appConfig,
 		dao,
+		&keyring,
 		tc,
 	); err != nil {
 		return err
 	} else {
+		// Start background jobs
+		if len(appConfig.Publish.Usernames) != 0 {
+			startPublishJob(appConfig.Publish, tc)
+		}
 		// Start server
 		if appConfig.Bind.UnixSocket == "" {
 			slog.Warn(fmt.Sprintf("Serving on http://%s", appConfig.Bind.AsAddress()))
@@ -52,3 +74,22 @@ func commandServe(
 		}
 	}
 }
+
+// Error when a server is running, for commands that would leave it with stale state.
+//
+// Only detects servers using the same bind address.
+func ensureServerStopped(appConfig config.AppConfig) error {
+	network, address := "tcp", appConfig.Bind.AsAddress()
+	if appConfig.Bind.UnixSocket != "" {
+		network, address = "unix", appConfig.Bind.UnixSocket
+	} else if ip := net.ParseIP(appConfig.Bind.Host); ip != nil && ip.IsUnspecified() {
+		// listening on every address, so it can be reached locally
+		address = net.JoinHostPort("localhost", fmt.Sprint(appConfig.Bind.Port))
+	}
+	conn, err := net.DialTimeout(network, address, time.Second)
+	if err != nil {
+		return nil
+	}
+	conn.Close()
+	return fmt.Errorf("a server is running on '%s', stop it first so it does not keep using old user details", address)
+}
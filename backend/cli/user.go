@@ -4,20 +4,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
 
 	"github.com/enchant97/note-mark/backend/config"
 	"github.com/enchant97/note-mark/backend/core"
 	"github.com/enchant97/note-mark/backend/db"
+	"github.com/enchant97/note-mark/backend/services"
 	"github.com/enchant97/note-mark/backend/tree"
 )
 
 func commandUserAdd(
 	dao *db.DAO,
 	tc *tree.TreeController,
+	passwordPolicy core.PasswordPolicy,
 	username string,
 	password string,
 ) error {
+	if err := passwordPolicy.Check(username, password); err != nil {
+		return err
+	}
 	if uid, err := dao.Queries.InsertUserWithPassword(
 		context.Background(),
 		db.InsertUserWithPasswordParams{
@@ -30,7 +34,7 @@ func commandUserAdd(
 		if err := tc.RegisterNewUser(core.Username(username)); err != nil && !errors.Is(err, core.ErrConflict) {
 			return err
 		}
-		fmt.Printf("User '%s' created with ID '%s'", username, uid)
+		fmt.Printf("User '%s' created with ID '%s'", username, uid.Uid)
 		return nil
 	}
 }
@@ -44,15 +48,26 @@ func commandUserRemove(
 
 func commandUserSetPassword(
 	dao *db.DAO,
+	passwordPolicy core.PasswordPolicy,
 	username string,
 	password string,
 ) error {
-	return dao.Queries.UpdateUserPasswordByUsername(
+	if err := passwordPolicy.Check(username, password); err != nil {
+		return err
+	}
+	userUid, err := core.WrapDbErrorWithValue(dao.Queries.GetUserUidByUsername(context.Background(), username))
+	if err != nil {
+		return err
+	}
+	if err := dao.Queries.UpdateUserPasswordByUsername(
 		context.Background(),
 		db.UpdateUserPasswordByUsernameParams{
 			Username:     username,
 			PasswordHash: core.HashPassword(password),
-		})
+		}); err != nil {
+		return err
+	}
+	return services.RevokeUserTokens(dao, userUid)
 }
 
 func commandUserRemovePassword(
@@ -65,17 +80,97 @@ func commandUserRemovePassword(
 	)
 }
 
+func commandUserSetAdmin(
+	dao *db.DAO,
+	username string,
+	isAdmin bool,
+) error {
+	count, err := dao.Queries.AdminSetUserAdmin(
+		context.Background(),
+		db.AdminSetUserAdminParams{
+			Username: username,
+			IsAdmin:  isAdmin,
+		})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("user '%s' not found", username)
+	}
+	return nil
+}
+
+func commandUserRename(
+	dao *db.DAO,
+	tc *tree.TreeController,
+	username string,
+	newUsername string,
+) error {
+	if _, err := services.RenameUser(dao, tc, core.Username(username), core.Username(newUsername)); err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return fmt.Errorf("user '%s' not found", username)
+		} else if errors.Is(err, core.ErrConflict) {
+			return fmt.Errorf("user '%s' already exists", newUsername)
+		}
+		return err
+	}
+	auditService := services.AuditService{}.New(dao)
+	oldUsername := core.Username(username)
+	auditService.Record(core.CreateAuditEntry{
+		Event:    core.AuditUserRename,
+		Username: &oldUsername,
+		Details:  map[string]string{"newUsername": newUsername},
+	})
+	fmt.Printf("User '%s' renamed to '%s'\n", username, newUsername)
+	return nil
+}
+
+func commandUserReset2FA(
+	dao *db.DAO,
+	username string,
+) error {
+	userUid, err := dao.Queries.GetUserUidByUsername(context.Background(), username)
+	if err != nil {
+		if errors.Is(core.WrapDbError(err), core.ErrNotFound) {
+			return fmt.Errorf("user '%s' not found", username)
+		}
+		return err
+	}
+	if err := services.ResetUserTotp(dao, userUid); err != nil {
+		return err
+	}
+	auditService := services.AuditService{}.New(dao)
+	auditService.Record(core.CreateAuditEntry{
+		Event:   core.AuditTotpDisabled,
+		Details: map[string]string{"username": username, "reason": "reset"},
+	})
+	return nil
+}
+
 func commandUserAddOidcMapping(
 	appConfig config.AppConfig,
 	dao *db.DAO,
+	providerName string,
 	username string,
 	userSub string,
 ) error {
+	if providerName == "" {
+		if len(appConfig.OidcProviders) == 0 {
+			return errors.New("no oidc providers are configured")
+		} else if len(appConfig.OidcProviders) != 1 {
+			return errors.New("provider must be given when multiple oidc providers are configured")
+		}
+		providerName = appConfig.OidcProviders[0].ProviderName
+	}
+	provider, exists := appConfig.GetOidcProvider(providerName)
+	if !exists {
+		return fmt.Errorf("oidc provider '%s' not configured", providerName)
+	}
 	return dao.Queries.InsertOidcUserMapping(
 		context.Background(),
 		db.InsertOidcUserMappingParams{
 			Username:     username,
 			UserSub:      userSub,
-			ProviderName: strings.ToLower(appConfig.OIDC.ProviderName),
+			ProviderName: provider.ProviderName,
 		})
 }
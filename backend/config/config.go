@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 )
 
 type BindConfig struct {
@@ -19,12 +20,61 @@ type OidcConfig struct {
 	ProviderName       string `env:"PROVIDER_NAME" validate:"required"`
 	IssuerUrl          string `env:"ISSUER_URL" validate:"required,http_url"`
 	ClientID           string `env:"CLIENT_ID" validate:"required"`
+	ClientSecret       string `env:"CLIENT_SECRET"`
 	EnableUserCreation bool   `env:"ENABLE_USER_CREATION,notEmpty" envDefault:"true"`
+	// claim holding the users groups, nested claims are separated by "."
+	GroupsClaim    string   `env:"GROUPS_CLAIM" envDefault:"groups"`
+	RequiredGroups []string `env:"REQUIRED_GROUPS"`
+	AdminGroups    []string `env:"ADMIN_GROUPS"`
+	// provider group to Note Mark group, e.g. "staff:team,editors:writers"
+	GroupMappings map[string]string `env:"GROUP_MAPPINGS" validate:"dive,keys,required,endkeys,group_name"`
 }
 
 type AuthTokenConfig struct {
-	Secret Base64Decoded `env:"SECRET,notEmpty" validate:"gte=32"`
+	// optional once signing keys have been created
+	Secret Base64Decoded `env:"SECRET" validate:"omitempty,gte=32"`
 	Expiry int64         `env:"EXPIRY" envDefault:"259200"`
+	// refresh tokens are not issued when 0
+	RefreshExpiry int64 `env:"REFRESH_EXPIRY" envDefault:"2592000" validate:"gte=0"`
+}
+
+type JournalConfig struct {
+	SlugPattern string `env:"SLUG_PATTERN" envDefault:"journal/{YYYY}/{MM}/{DD}" validate:"required"`
+}
+
+type PublishConfig struct {
+	OutputPath string        `env:"OUTPUT_PATH" validate:"required_with=Usernames"`
+	Usernames  []string      `env:"USERNAMES" validate:"dive,username"`
+	Interval   time.Duration `env:"INTERVAL" envDefault:"1h" validate:"gte=0"`
+}
+
+type LoginRateLimitConfig struct {
+	Enable      bool          `env:"ENABLE,notEmpty" envDefault:"true"`
+	MaxFailures uint          `env:"MAX_FAILURES" envDefault:"5" validate:"gt=0"`
+	Lockout     time.Duration `env:"LOCKOUT" envDefault:"1m" validate:"gt=0"`
+	MaxLockout  time.Duration `env:"MAX_LOCKOUT" envDefault:"1h" validate:"gtefield=Lockout"`
+	Window      time.Duration `env:"WINDOW" envDefault:"15m" validate:"gt=0"`
+	Persist     bool          `env:"PERSIST" envDefault:"false"`
+}
+
+type UserCacheConfig struct {
+	// users kept in memory for authenticated requests, 0 disables caching
+	Size uint          `env:"SIZE" envDefault:"1000"`
+	TTL  time.Duration `env:"TTL" envDefault:"1m" validate:"gte=0"`
+}
+
+type PasswordPolicyConfig struct {
+	MinLength uint `env:"MIN_LENGTH" envDefault:"8" validate:"gt=0,lte=128"`
+	// reject passwords found in the bundled list of common and breached passwords
+	RejectCommon bool `env:"REJECT_COMMON" envDefault:"true"`
+}
+
+type WebAuthnConfig struct {
+	Enable bool `env:"ENABLE,notEmpty" envDefault:"true"`
+	// defaults to the host of the public url
+	RPID string `env:"RP_ID"`
+	// defaults to the public url
+	RPOrigins []string `env:"RP_ORIGINS" validate:"dive,http_url"`
 }
 
 type LoggingConfig struct {
@@ -33,16 +83,38 @@ type LoggingConfig struct {
 }
 
 type AppConfig struct {
-	Bind                      BindConfig      `envPrefix:"BIND__"`
-	AuthToken                 AuthTokenConfig `envPrefix:"AUTH_TOKEN__"`
-	DataPath                  string          `env:"DATA_PATH,notEmpty" validate:"dirpath,required"`
-	StaticPath                string          `env:"STATIC_PATH" validate:"omitempty,dirpath"`
-	PublicUrl                 string          `env:"PUBLIC_URL,notEmpty" validate:"http_url,endsnotwith=/,required"`
-	EnableInternalSignup      bool            `env:"ENABLE_INTERNAL_SIGNUP,notEmpty" envDefault:"true"`
-	EnableInternalLogin       bool            `env:"ENABLE_INTERNAL_LOGIN,notEmpty" envDefault:"true"`
-	EnableAnonymousUserSearch bool            `env:"ENABLE_ANONYMOUS_USER_SEARCH,notEmpty" envDefault:"true"`
-	FileSizeLimit             Bytes           `env:"FILE_SIZE_LIMIT,notEmpty" envDefault:"12M"`
-	OIDC                      *OidcConfig     `envPrefix:"OIDC__" env:",init" validate:"omitempty,required"`
-	Logging                   LoggingConfig   `envPrefix:"LOGGING__"`
-	EnvMode                   string          `env:"ENV_MODE" envDefault:"production" validate:"oneof=production development"`
+	Bind                      BindConfig           `envPrefix:"BIND__"`
+	AuthToken                 AuthTokenConfig      `envPrefix:"AUTH_TOKEN__"`
+	DataPath                  string               `env:"DATA_PATH,notEmpty" validate:"dirpath,required"`
+	StaticPath                string               `env:"STATIC_PATH" validate:"omitempty,dirpath"`
+	PublicUrl                 string               `env:"PUBLIC_URL,notEmpty" validate:"http_url,endsnotwith=/,required"`
+	EnableInternalSignup      bool                 `env:"ENABLE_INTERNAL_SIGNUP,notEmpty" envDefault:"true"`
+	InviteOnlySignup          bool                 `env:"INVITE_ONLY_SIGNUP" envDefault:"false"`
+	EnableInternalLogin       bool                 `env:"ENABLE_INTERNAL_LOGIN,notEmpty" envDefault:"true"`
+	EnableAnonymousUserSearch bool                 `env:"ENABLE_ANONYMOUS_USER_SEARCH,notEmpty" envDefault:"true"`
+	FileSizeLimit             Bytes                `env:"FILE_SIZE_LIMIT,notEmpty" envDefault:"12M"`
+	ImportSizeLimit           Bytes                `env:"IMPORT_SIZE_LIMIT,notEmpty" envDefault:"256M"`
+	ImportMaxFiles            int                  `env:"IMPORT_MAX_FILES,notEmpty" envDefault:"10000"`
+	ImportExtractedSizeLimit  Bytes                `env:"IMPORT_EXTRACTED_SIZE_LIMIT,notEmpty" envDefault:"1G"`
+	OIDC                      *OidcConfig          `envPrefix:"OIDC__" env:",init" validate:"omitempty,required"`
+	OidcProviders             []OidcConfig         `envPrefix:"OIDC_PROVIDERS__" validate:"unique=ProviderName,dive"`
+	LoginRateLimit            LoginRateLimitConfig `envPrefix:"LOGIN_RATE_LIMIT__"`
+	PasswordPolicy            PasswordPolicyConfig `envPrefix:"PASSWORD_POLICY__"`
+	UserCache                 UserCacheConfig      `envPrefix:"USER_CACHE__"`
+	WebAuthn                  WebAuthnConfig       `envPrefix:"WEBAUTHN__"`
+	TrustProxyHeaders         bool                 `env:"TRUST_PROXY_HEADERS" envDefault:"false"`
+	Journal                   JournalConfig        `envPrefix:"JOURNAL__"`
+	Publish                   PublishConfig        `envPrefix:"PUBLISH__"`
+	Logging                   LoggingConfig        `envPrefix:"LOGGING__"`
+	EnvMode                   string               `env:"ENV_MODE" envDefault:"production" validate:"oneof=production development"`
+}
+
+// Get a configured OIDC provider by its provider name.
+func (c *AppConfig) GetOidcProvider(providerName string) (OidcConfig, bool) {
+	for _, provider := range c.OidcProviders {
+		if provider.ProviderName == providerName {
+			return provider, true
+		}
+	}
+	return OidcConfig{}, false
 }
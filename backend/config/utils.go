@@ -17,7 +17,12 @@ func (appConfig *AppConfig) ParseConfig(validate *validator.Validate) error {
 	if err := env.Parse(appConfig); err != nil {
 		return err
 	}
-	if reflect.DeepEqual(appConfig.OIDC, &OidcConfig{EnableUserCreation: true}) {
+	if reflect.DeepEqual(appConfig.OIDC, &OidcConfig{EnableUserCreation: true, GroupsClaim: "groups"}) {
+		appConfig.OIDC = nil
+	}
+	// single provider config is still supported, used as the first provider
+	if appConfig.OIDC != nil {
+		appConfig.OidcProviders = append([]OidcConfig{*appConfig.OIDC}, appConfig.OidcProviders...)
 		appConfig.OIDC = nil
 	}
 	return validate.Struct(appConfig)
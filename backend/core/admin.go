@@ -0,0 +1,32 @@
+package core
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AdminUser struct {
+	Uid        uuid.UUID  `json:"uid"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	Username   string     `json:"username"`
+	Name       *string    `json:"name"`
+	IsAdmin    bool       `json:"isAdmin"`
+	DisabledAt *time.Time `json:"disabledAt"`
+}
+
+type AdminResetPassword struct {
+	NewPassword string `json:"newPassword" maxLength:"128"`
+}
+
+type AdminRenameUser struct {
+	NewUsername Username `json:"newUsername" minLength:"3" maxLength:"30" pattern:"^[a-zA-Z0-9]+$"`
+}
+
+type StorageUsage struct {
+	Username   Username `json:"username"`
+	NoteCount  int      `json:"noteCount"`
+	AssetCount int      `json:"assetCount"`
+	Bytes      int64    `json:"bytes"`
+	TrashBytes int64    `json:"trashBytes"`
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditEvent string
+
+const (
+	AuditLogin               AuditEvent = "auth.login"
+	AuditLoginFailed         AuditEvent = "auth.login_failed"
+	AuditLoginLockout        AuditEvent = "auth.lockout"
+	AuditTokenCreated        AuditEvent = "auth.token_created"
+	AuditTotpEnabled         AuditEvent = "auth.totp_enabled"
+	AuditTotpDisabled        AuditEvent = "auth.totp_disabled"
+	AuditWebAuthnAdded       AuditEvent = "auth.webauthn_added"
+	AuditWebAuthnRemoved     AuditEvent = "auth.webauthn_removed"
+	AuditUserRename          AuditEvent = "user.rename"
+	AuditNodeWrite           AuditEvent = "node.write"
+	AuditNodeRename          AuditEvent = "node.rename"
+	AuditNodeDelete          AuditEvent = "node.delete"
+	AuditAccessControlChange AuditEvent = "node.access_control_change"
+)
+
+// Who performed an audited action, all fields are blank for anonymous actions.
+type AuditActor struct {
+	UserUid      *uuid.UUID
+	Username     *string
+	ShareLinkUid *uuid.UUID
+}
+
+func NewAuditActor(optionalAuthUser *AuthenticatedUser, optionalShareGrant *ShareGrant) AuditActor {
+	actor := AuditActor{}
+	if optionalAuthUser != nil {
+		actor.UserUid = &optionalAuthUser.UserUID
+		if optionalAuthUser.Username != "" {
+			actor.Username = &optionalAuthUser.Username
+		}
+	}
+	if optionalShareGrant != nil {
+		actor.ShareLinkUid = &optionalShareGrant.LinkUid
+	}
+	return actor
+}
+
+type CreateAuditEntry struct {
+	Event AuditEvent
+	Actor AuditActor
+	// owner of the node acted on, if any
+	Username *Username
+	Slug     *NodeSlug
+	// marshalled as JSON
+	Details any
+}
+
+type AuditEntry struct {
+	Id            int64           `json:"id"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	Event         AuditEvent      `json:"event"`
+	ActorUid      *uuid.UUID      `json:"actorUid"`
+	ActorUsername *string         `json:"actorUsername"`
+	ShareLinkUid  *uuid.UUID      `json:"shareLinkUid,omitempty"`
+	Username      *string         `json:"username"`
+	Slug          *string         `json:"slug"`
+	Details       json.RawMessage `json:"details,omitempty"`
+}
+
+type AuditFilter struct {
+	AfterId  int64
+	Event    *AuditEvent
+	Actor    *string
+	Username *string
+	// matches the node and its descendants
+	Slug  *string
+	Since *time.Time
+	Until *time.Time
+	Limit int64
+}
+
+type AccessControlChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+type AccessControlDiff struct {
+	Old     *AccessControl        `json:"old"`
+	New     *AccessControl        `json:"new"`
+	Changes []AccessControlChange `json:"changes"`
+}
+
+// Get the differences between two access controls, nil when both are equivalent.
+//
+// Changes are ordered by field name, missing values are left blank.
+func DiffAccessControl(oldAc *AccessControl, newAc *AccessControl) *AccessControlDiff {
+	oldFields := flattenAccessControl(oldAc)
+	newFields := flattenAccessControl(newAc)
+	changes := []AccessControlChange{}
+	for field, oldValue := range oldFields {
+		if newValue := newFields[field]; newValue != oldValue {
+			changes = append(changes, AccessControlChange{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+	for field, newValue := range newFields {
+		if _, exists := oldFields[field]; !exists {
+			changes = append(changes, AccessControlChange{Field: field, New: newValue})
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	slices.SortFunc(changes, func(a, b AccessControlChange) int {
+		return strings.Compare(a.Field, b.Field)
+	})
+	return &AccessControlDiff{
+		Old:     oldAc,
+		New:     newAc,
+		Changes: changes,
+	}
+}
+
+// Flatten access control into field names and values, skipping defaults.
+func flattenAccessControl(ac *AccessControl) map[string]string {
+	fields := map[string]string{}
+	if ac == nil {
+		return fields
+	}
+	if !ac.Inherits() {
+		fields["inherit"] = "false"
+	}
+	if ac.PublicRead {
+		fields["publicRead"] = "true"
+	}
+	for username, mode := range ac.Users {
+		fields[fmt.Sprintf("users.%s", username)] = string(mode)
+	}
+	for groupName, mode := range ac.Groups {
+		fields[fmt.Sprintf("groups.%s", groupName)] = string(mode)
+	}
+	if ac.Deny != nil {
+		for _, username := range ac.Deny.Users {
+			fields[fmt.Sprintf("deny.users.%s", username)] = "deny"
+		}
+		for _, groupName := range ac.Deny.Groups {
+			fields[fmt.Sprintf("deny.groups.%s", groupName)] = "deny"
+		}
+	}
+	return fields
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiffAccessControl(t *testing.T) {
+	noInherit := false
+	tests := []struct {
+		oldAc  *AccessControl
+		newAc  *AccessControl
+		expect []AccessControlChange
+	}{
+		{nil, nil, nil},
+		{nil, &AccessControl{}, nil},
+		{
+			&AccessControl{Users: map[Username]AccessControlMode{"leo": AccessControlReadMode}},
+			&AccessControl{Users: map[Username]AccessControlMode{"leo": AccessControlReadMode}},
+			nil,
+		},
+		{
+			nil,
+			&AccessControl{PublicRead: true, Users: map[Username]AccessControlMode{"leo": AccessControlWriteMode}},
+			[]AccessControlChange{
+				{Field: "publicRead", New: "true"},
+				{Field: "users.leo", New: "write"},
+			},
+		},
+		{
+			&AccessControl{
+				Users:  map[Username]AccessControlMode{"leo": AccessControlReadMode},
+				Groups: map[GroupName]AccessControlMode{"team": AccessControlCommentMode},
+			},
+			&AccessControl{
+				Inherit: &noInherit,
+				Users:   map[Username]AccessControlMode{"leo": AccessControlWriteMode},
+				Deny:    &AccessControlDeny{Users: []Username{"steve"}},
+			},
+			[]AccessControlChange{
+				{Field: "deny.users.steve", New: "deny"},
+				{Field: "groups.team", Old: "comment"},
+				{Field: "inherit", New: "false"},
+				{Field: "users.leo", Old: "read", New: "write"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			diff := DiffAccessControl(tt.oldAc, tt.newAc)
+			if tt.expect == nil {
+				if diff != nil {
+					t.Errorf("actual '%v' expect '%v'", diff.Changes, nil)
+				}
+				return
+			}
+			if diff == nil {
+				t.Fatalf("actual '%v' expect '%v'", nil, tt.expect)
+			}
+			if !slices.Equal(diff.Changes, tt.expect) {
+				t.Errorf("actual '%v' expect '%v'", diff.Changes, tt.expect)
+			}
+		})
+	}
+}
@@ -1,28 +1,32 @@
 package core
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
 	"errors"
 	"log"
 	"time"
 
+	"github.com/enchant97/note-mark/backend/signingkeys"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 var (
-	JWTClaimsNotValidError  = errors.New("invalid jwt claims")
-	DefaultJwtSigningMethod = jwt.SigningMethodHS256
+	JWTClaimsNotValidError = errors.New("invalid jwt claims")
 )
 
 type AuthenticatedUser struct {
 	UserUID  uuid.UUID
 	Username string
+	IsAdmin  bool
 }
 
 func (u *AuthenticatedUser) IntoClaims(expiresAt time.Time) JWTClaims {
 	return JWTClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject:   u.UserUID.String(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 		},
 	}
@@ -42,14 +46,17 @@ func (c *JWTClaims) GetUserUID() (uuid.UUID, error) {
 
 // OAuth2.0 Access Token, following: RFC6750 & RFC6749
 type AccessToken struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   uint   `json:"expires_in"`
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    uint   `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty" doc:"Single use, exchange for a new access token with the refresh_token grant"`
 }
 
 type PasswordGrant struct {
 	Username string `json:"username" required:"false" validate:"required"`
 	Password string `json:"password" required:"false" validate:"required"`
+	// TOTP or recovery code, required when the user has enabled TOTP
+	Code string `json:"code,omitempty" required:"false" maxLength:"16"`
 }
 
 type TokenExchangeGrant struct {
@@ -61,12 +68,18 @@ type TokenExchangeGrant struct {
 	SubjectTokenType   string `json:"subject_token_type" required:"false" validate:"eq=urn:ietf:params:oauth:token-type:access_token"`
 	ActorToken         string `json:"actor_token,omitempty" validate:"required_with=ActorTokenType"`
 	ActorTokenType     string `json:"actor_token_type,omitempty" validate:"required_with=ActorToken,eq=urn:ietf:params:oauth:token-type:id_token"`
+	ProviderName       string `json:"provider_name,omitempty" doc:"OIDC provider that issued the subject token, optional when only one is configured"`
+}
+
+type RefreshTokenGrant struct {
+	RefreshToken string `json:"refresh_token" required:"false" validate:"required"`
 }
 
 // OAuth2.0 Access Token Request, following: RFC6749 + RFC8693
 type AccessTokenRequest struct {
-	GrantType           string `json:"grant_type" validate:"oneof=password urn:ietf:params:oauth:grant-type:token-exchange"`
+	GrantType           string `json:"grant_type" validate:"oneof=password refresh_token urn:ietf:params:oauth:grant-type:token-exchange"`
 	*PasswordGrant      `validate:"required_if=GrantType password"`
+	*RefreshTokenGrant  `validate:"required_if=GrantType refresh_token"`
 	*TokenExchangeGrant `validate:"required_if=GrantType urn:ietf:params:oauth:grant-type:token-exchange"`
 }
 
@@ -80,13 +93,12 @@ type UserInfoResponse struct {
 // Create token for authentication
 func CreateAuthenticationToken(
 	user AuthenticatedUser,
-	secretKey []byte,
+	keyring *signingkeys.Keyring,
 	expiresDuration time.Duration,
 ) (AccessToken, error) {
 	expiresAt := time.Now().Add(expiresDuration)
 	claims := user.IntoClaims(expiresAt)
-	token := jwt.NewWithClaims(DefaultJwtSigningMethod, claims)
-	rawToken, err := token.SignedString(secretKey)
+	rawToken, err := keyring.Sign(claims)
 	if err != nil {
 		return AccessToken{}, err
 	}
@@ -97,19 +109,34 @@ func CreateAuthenticationToken(
 	}, nil
 }
 
-// Parse a token and convert into a authenticated user
-func ParseAuthenticationToken(tokenString string, secretKey []byte) (uuid.UUID, error) {
-	if token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(t *jwt.Token) (any, error) {
-		return secretKey, nil
-	},
-		jwt.WithValidMethods([]string{DefaultJwtSigningMethod.Alg()}),
-		jwt.WithExpirationRequired()); err != nil {
-		return uuid.Nil, err
+// Create a new random refresh token, returning the token and it's hash for storage.
+func NewRefreshToken() (string, []byte) {
+	token := rand.Text()
+	return token, HashRefreshToken(token)
+}
+
+func HashRefreshToken(token string) []byte {
+	h := sha256.Sum256([]byte(token))
+	return h[:]
+}
+
+// Parse a token and convert into a authenticated user, along with when it was issued.
+//
+// Tokens without an issued at time give a zero time.
+func ParseAuthenticationToken(tokenString string, keyring *signingkeys.Keyring) (uuid.UUID, time.Time, error) {
+	if token, err := keyring.Parse(tokenString, &JWTClaims{}, jwt.WithExpirationRequired()); err != nil {
+		return uuid.Nil, time.Time{}, err
 	} else {
-		if claims, ok := token.Claims.(*JWTClaims); !ok {
-			return uuid.Nil, JWTClaimsNotValidError
+		if claims, ok := token.Claims.(*JWTClaims); !ok || len(claims.Audience) != 0 {
+			// access tokens never have an audience, unlike share unlock grants
+			return uuid.Nil, time.Time{}, JWTClaimsNotValidError
 		} else {
-			return claims.GetUserUID()
+			var issuedAt time.Time
+			if claims.IssuedAt != nil {
+				issuedAt = claims.IssuedAt.Time
+			}
+			userUid, err := claims.GetUserUID()
+			return userUid, issuedAt, err
 		}
 	}
 }
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A range of text in a note a comment refers to.
+type CommentAnchor struct {
+	Start int64  `json:"start" minimum:"0"`
+	End   int64  `json:"end" minimum:"0"`
+	Text  string `json:"text" maxLength:"1024"`
+}
+
+type Comment struct {
+	Uid        uuid.UUID      `json:"uid"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+	ParentUid  *uuid.UUID     `json:"parentUid,omitempty"`
+	Author     *string        `json:"author"`
+	Anchor     *CommentAnchor `json:"anchor,omitempty"`
+	Content    string         `json:"content"`
+	ResolvedAt *time.Time     `json:"resolvedAt,omitempty"`
+	ResolvedBy *string        `json:"resolvedBy,omitempty"`
+	Replies    []Comment      `json:"replies,omitempty"`
+}
+
+type CreateComment struct {
+	Content   string         `json:"content" minLength:"1" maxLength:"10000"`
+	ParentUid *uuid.UUID     `json:"parentUid,omitempty" required:"false" doc:"Comment to reply to"`
+	Anchor    *CommentAnchor `json:"anchor,omitempty" required:"false" doc:"Only allowed when not a reply"`
+}
+
+type UpdateComment struct {
+	Content string `json:"content" minLength:"1" maxLength:"10000"`
+}
+
+// Group comments into threads, keeping their order.
+//
+// Replies whose parent cannot be found are treated as a thread of their own.
+func ThreadComments(comments []Comment) []Comment {
+	threadIndexes := map[uuid.UUID]int{}
+	threads := []Comment{}
+	for _, comment := range comments {
+		if comment.ParentUid != nil {
+			if i, exists := threadIndexes[*comment.ParentUid]; exists {
+				threads[i].Replies = append(threads[i].Replies, comment)
+				continue
+			}
+		}
+		threadIndexes[comment.Uid] = len(threads)
+		threads = append(threads, comment)
+	}
+	return threads
+}
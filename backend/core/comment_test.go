@@ -0,0 +1,46 @@
+package core
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestThreadComments(t *testing.T) {
+	uids := []uuid.UUID{MustNewUID(), MustNewUID(), MustNewUID(), MustNewUID(), MustNewUID()}
+	missingUid := MustNewUID()
+	comments := []Comment{
+		{Uid: uids[0]},
+		{Uid: uids[1]},
+		{Uid: uids[2], ParentUid: &uids[0]},
+		{Uid: uids[3], ParentUid: &missingUid},
+		{Uid: uids[4], ParentUid: &uids[0]},
+	}
+	threads := ThreadComments(comments)
+	tests := []struct {
+		uid     uuid.UUID
+		replies []uuid.UUID
+	}{
+		{uids[0], []uuid.UUID{uids[2], uids[4]}},
+		{uids[1], []uuid.UUID{}},
+		{uids[3], []uuid.UUID{}},
+	}
+	if len(threads) != len(tests) {
+		t.Fatalf("actual '%v' expect '%v'", len(threads), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			if threads[i].Uid != tt.uid {
+				t.Errorf("actual '%v' expect '%v'", threads[i].Uid, tt.uid)
+			}
+			replies := []uuid.UUID{}
+			for _, reply := range threads[i].Replies {
+				replies = append(replies, reply.Uid)
+			}
+			if !slices.Equal(replies, tt.replies) {
+				t.Errorf("actual '%v' expect '%v'", replies, tt.replies)
+			}
+		})
+	}
+}
@@ -14,6 +14,8 @@ var ErrParsingContent = errors.New("failed to parse content")
 var ErrInvalidCredentials = errors.New("invalid credentials")
 var ErrFeatureDisabled = errors.New("feature is disabled")
 var ErrSlugInvalid = errors.New("slug invalid")
+var ErrInvalidTimezone = errors.New("invalid timezone")
+var ErrTotpRequired = errors.New("totp code required")
 
 // / wrap a database error with a specific service error
 func WrapDbError(err error) error {
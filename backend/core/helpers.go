@@ -2,35 +2,17 @@ package core
 
 import (
 	"database/sql"
-	"log"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
-var NullPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("null"), bcrypt.DefaultCost)
-
 func MustNewUID() uuid.UUID {
 	uid, err := uuid.NewV7()
 	return uuid.Must(uid, err)
 }
 
-func HashPassword(plainPassword string) []byte {
-	hashedPw, err := bcrypt.GenerateFromPassword(
-		[]byte(plainPassword),
-		bcrypt.DefaultCost,
-	)
-	if err != nil {
-		log.Panicln(err)
-	}
-	return hashedPw
-}
-
-func DoesPasswordMatchHashed(plain string, hashed []byte) bool {
-	return bcrypt.CompareHashAndPassword(hashed, []byte(plain)) == nil
-}
-
 func StringPtrToNullString(v *string) sql.NullString {
 	if v == nil {
 		return sql.NullString{Valid: false}
@@ -55,3 +37,18 @@ func TimeIntoHTTPFormat(t time.Time) string {
 		return t.In(loc).Format(time.RFC1123)
 	}
 }
+
+// Load a IANA timezone, falling back to UTC when none is given.
+func LoadTimezone(name *string) (*time.Location, error) {
+	if name == nil || *name == "" {
+		return time.UTC, nil
+	}
+	if *name == "Local" {
+		return nil, ErrInvalidTimezone
+	}
+	if loc, err := time.LoadLocation(*name); err != nil {
+		return nil, errors.Join(err, ErrInvalidTimezone)
+	} else {
+		return loc, nil
+	}
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How long an invite lasts when no expiry is given.
+const DefaultInviteExpiry = 7 * 24 * time.Hour
+
+// A note, and its descendants, shared with users signing up with an invite.
+type InviteShare struct {
+	Slug NodeSlug          `json:"slug" validate:"slug_full"`
+	Mode AccessControlMode `json:"mode" enum:"read,comment,write"`
+}
+
+type Invite struct {
+	Uid       uuid.UUID    `json:"uid"`
+	CreatedAt time.Time    `json:"createdAt"`
+	MaxUses   int64        `json:"maxUses"`
+	UseCount  int64        `json:"useCount"`
+	ExpiresAt time.Time    `json:"expiresAt"`
+	Group     *GroupName   `json:"group" doc:"Group users are added to"`
+	Share     *InviteShare `json:"share"`
+	// only set when listing every invite
+	CreatedBy *string `json:"createdBy,omitempty" required:"false"`
+}
+
+type CreatedInvite struct {
+	Invite
+	Code string `json:"code" doc:"Secret code, only shown once"`
+}
+
+type CreateInvite struct {
+	MaxUses   int64        `json:"maxUses,omitempty" required:"false" minimum:"1" maximum:"1000" default:"1"`
+	ExpiresAt *time.Time   `json:"expiresAt,omitempty" required:"false" doc:"Defaults to 7 days from now"`
+	Group     *GroupName   `json:"group,omitempty" required:"false" validate:"omitempty,group_name" doc:"Group to add users to, must be owned by you"`
+	Share     *InviteShare `json:"share,omitempty" required:"false" doc:"One of your notes to share with users"`
+}
+
+// Create a new random invite code, returning the code and it's hash for storage.
+func NewInviteCode() (string, []byte) {
+	code := rand.Text()
+	return code, HashInviteCode(code)
+}
+
+func HashInviteCode(code string) []byte {
+	h := sha256.Sum256([]byte(code))
+	return h[:]
+}
+
+// Give a user access to a note, keeping any other access control.
+func (s InviteShare) AddToAccessControl(ac *AccessControl, username Username) *AccessControl {
+	updated := AccessControl{}
+	if ac != nil {
+		updated = *ac
+	}
+	users := make(map[Username]AccessControlMode, len(updated.Users)+1)
+	for existingUsername, mode := range updated.Users {
+		users[existingUsername] = mode
+	}
+	users[username] = s.Mode
+	updated.Users = users
+	return &updated
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInviteShareAddToAccessControl(t *testing.T) {
+	existing := &AccessControl{
+		PublicRead: true,
+		Users:      map[Username]AccessControlMode{"leo": AccessControlWriteMode},
+	}
+	tests := []struct {
+		share    InviteShare
+		ac       *AccessControl
+		username Username
+		expect   *AccessControl
+	}{
+		{
+			InviteShare{Mode: AccessControlReadMode},
+			nil,
+			"steve",
+			&AccessControl{Users: map[Username]AccessControlMode{"steve": AccessControlReadMode}},
+		},
+		{
+			InviteShare{Mode: AccessControlCommentMode},
+			existing,
+			"steve",
+			&AccessControl{
+				PublicRead: true,
+				Users: map[Username]AccessControlMode{
+					"leo":   AccessControlWriteMode,
+					"steve": AccessControlCommentMode,
+				},
+			},
+		},
+		{
+			InviteShare{Mode: AccessControlReadMode},
+			existing,
+			"leo",
+			&AccessControl{
+				PublicRead: true,
+				Users:      map[Username]AccessControlMode{"leo": AccessControlReadMode},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := tt.share.AddToAccessControl(tt.ac, tt.username)
+			if !reflect.DeepEqual(actual, tt.expect) {
+				t.Errorf("actual '%v' expect '%v'", actual, tt.expect)
+			}
+		})
+	}
+	// the existing access control must not be changed
+	if len(existing.Users) != 1 || existing.Users["leo"] != AccessControlWriteMode {
+		t.Errorf("actual '%v' expect '%v'", existing.Users, map[Username]AccessControlMode{"leo": AccessControlWriteMode})
+	}
+}
@@ -0,0 +1,21 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const JournalDateFormat = "2006-01-02"
+
+// Format a journal slug pattern using the given date.
+//
+// Supported placeholders: `{YYYY}`, `{MM}` & `{DD}`.
+func FormatJournalSlug(pattern string, date time.Time) NodeSlug {
+	replacer := strings.NewReplacer(
+		"{YYYY}", fmt.Sprintf("%04d", date.Year()),
+		"{MM}", fmt.Sprintf("%02d", date.Month()),
+		"{DD}", fmt.Sprintf("%02d", date.Day()),
+	)
+	return NodeSlug(replacer.Replace(pattern))
+}
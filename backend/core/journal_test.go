@@ -0,0 +1,27 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatJournalSlug(t *testing.T) {
+	date := time.Date(2026, time.October, 8, 23, 30, 0, 0, time.UTC)
+	tests := []struct {
+		pattern string
+		expect  NodeSlug
+	}{
+		{"journal/{YYYY}/{MM}/{DD}", "journal/2026/10/08"},
+		{"daily/{YYYY}-{MM}-{DD}", "daily/2026-10-08"},
+		{"{YYYY}/{YYYY}{MM}", "2026/202610"},
+		{"journal/today", "journal/today"},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := FormatJournalSlug(tt.pattern, date)
+			if actual != tt.expect {
+				t.Errorf("actual '%v' expect '%v' (pattern '%s')", actual, tt.expect, tt.pattern)
+			}
+		})
+	}
+}
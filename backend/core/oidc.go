@@ -0,0 +1,85 @@
+package core
+
+import (
+	"slices"
+	"strings"
+)
+
+// Maps the groups given by an OIDC provider to Note Mark permissions.
+type OidcClaimMapping struct {
+	// claim holding the users groups or roles, nested claims are separated by "."
+	GroupsClaim string
+	// when set, only users in one of these groups can login
+	RequiredGroups []string
+	// when set, admin status follows membership of one of these groups
+	AdminGroups []string
+	// provider group to Note Mark group
+	GroupMappings map[string]string
+}
+
+type OidcMappedPermissions struct {
+	// nil when admin status is not managed by the provider
+	IsAdmin *bool
+	// Note Mark group names, with whether the user should be a member
+	Groups map[string]bool
+}
+
+// Get the groups from claims, the claim can be a list of strings or a single string.
+func GetOidcClaimGroups(claims map[string]any, claim string) []string {
+	if claim == "" {
+		return []string{}
+	}
+	var value any = claims
+	for _, name := range strings.Split(claim, ".") {
+		nested, ok := value.(map[string]any)
+		if !ok {
+			return []string{}
+		}
+		value = nested[name]
+	}
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		groups := make([]string, 0, len(v))
+		for _, group := range v {
+			if group, ok := group.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+		return groups
+	}
+	return []string{}
+}
+
+// Whether a user with the given groups is allowed to login.
+func (m OidcClaimMapping) IsAllowed(groups []string) bool {
+	return len(m.RequiredGroups) == 0 || hasAnyGroup(groups, m.RequiredGroups)
+}
+
+// Get the permissions a user with the given groups should have.
+func (m OidcClaimMapping) Map(groups []string) OidcMappedPermissions {
+	permissions := OidcMappedPermissions{
+		Groups: map[string]bool{},
+	}
+	if len(m.AdminGroups) != 0 {
+		isAdmin := hasAnyGroup(groups, m.AdminGroups)
+		permissions.IsAdmin = &isAdmin
+	}
+	for providerGroup, groupName := range m.GroupMappings {
+		// many provider groups can map to the same group
+		permissions.Groups[groupName] = permissions.Groups[groupName] || slices.Contains(groups, providerGroup)
+	}
+	return permissions
+}
+
+func hasAnyGroup(groups []string, wanted []string) bool {
+	for _, group := range wanted {
+		if slices.Contains(groups, group) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetOidcClaimGroups(t *testing.T) {
+	claims := map[string]any{
+		"groups":      []any{"staff", "editors", 1},
+		"role":        "admin",
+		"realm_roles": map[string]any{"roles": []any{"reviewer"}},
+	}
+	tests := []struct {
+		claim  string
+		expect []string
+	}{
+		{"groups", []string{"staff", "editors"}},
+		{"role", []string{"admin"}},
+		{"realm_roles.roles", []string{"reviewer"}},
+		{"realm_roles.missing", []string{}},
+		{"role.nested", []string{}},
+		{"missing", []string{}},
+		{"", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := GetOidcClaimGroups(claims, tt.claim)
+			if !reflect.DeepEqual(actual, tt.expect) {
+				t.Errorf("actual '%v' expect '%v' (claim '%s')", actual, tt.expect, tt.claim)
+			}
+		})
+	}
+}
+
+func TestOidcClaimMappingIsAllowed(t *testing.T) {
+	restricted := OidcClaimMapping{RequiredGroups: []string{"staff", "contractors"}}
+	tests := []struct {
+		mapping OidcClaimMapping
+		groups  []string
+		expect  bool
+	}{
+		{OidcClaimMapping{}, []string{}, true},
+		{restricted, []string{"contractors"}, true},
+		{restricted, []string{"editors", "staff"}, true},
+		{restricted, []string{"editors"}, false},
+		{restricted, []string{}, false},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := tt.mapping.IsAllowed(tt.groups)
+			if actual != tt.expect {
+				t.Errorf("actual '%v' expect '%v' (groups '%v')", actual, tt.expect, tt.groups)
+			}
+		})
+	}
+}
+
+func TestOidcClaimMappingMap(t *testing.T) {
+	isAdmin, isNotAdmin := true, false
+	mapping := OidcClaimMapping{
+		AdminGroups: []string{"admins"},
+		GroupMappings: map[string]string{
+			"staff":       "team",
+			"contractors": "team",
+			"editors":     "writers",
+		},
+	}
+	tests := []struct {
+		mapping OidcClaimMapping
+		groups  []string
+		expect  OidcMappedPermissions
+	}{
+		{
+			OidcClaimMapping{},
+			[]string{"admins"},
+			OidcMappedPermissions{Groups: map[string]bool{}},
+		},
+		{
+			mapping,
+			[]string{"admins", "contractors"},
+			OidcMappedPermissions{IsAdmin: &isAdmin, Groups: map[string]bool{"team": true, "writers": false}},
+		},
+		{
+			mapping,
+			[]string{"editors"},
+			OidcMappedPermissions{IsAdmin: &isNotAdmin, Groups: map[string]bool{"team": false, "writers": true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := tt.mapping.Map(tt.groups)
+			if !reflect.DeepEqual(actual, tt.expect) {
+				t.Errorf("actual '%v' expect '%v' (groups '%v')", actual, tt.expect, tt.groups)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrPasswordPolicy = errors.New("password does not meet policy")
+
+// Parameters for new argon2id hashes, following the OWASP recommendation.
+const (
+	argon2idMemory  uint32 = 19 * 1024
+	argon2idTime    uint32 = 2
+	argon2idThreads uint8  = 1
+	argon2idSaltLen        = 16
+	argon2idKeyLen  uint32 = 32
+	argon2idPrefix         = "$argon2id$"
+)
+
+// Compared against so a missing user takes as long as a wrong password.
+var NullPasswordHash = HashPassword("null")
+
+// Hash a password using argon2id, encoded in the PHC string format.
+func HashPassword(plainPassword string) []byte {
+	salt := make([]byte, argon2idSaltLen)
+	rand.Read(salt)
+	key := argon2.IDKey([]byte(plainPassword), salt, argon2idTime, argon2idMemory, argon2idThreads, argon2idKeyLen)
+	return fmt.Appendf(nil,
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		argon2idMemory,
+		argon2idTime,
+		argon2idThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+// Check a password against a argon2id or bcrypt hash.
+func DoesPasswordMatchHashed(plain string, hashed []byte) bool {
+	if !strings.HasPrefix(string(hashed), argon2idPrefix) {
+		return bcrypt.CompareHashAndPassword(hashed, []byte(plain)) == nil
+	}
+	params, salt, key, err := parseArgon2idHash(string(hashed))
+	if err != nil {
+		return false
+	}
+	otherKey := argon2.IDKey([]byte(plain), salt, params.time, params.memory, params.threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, otherKey) == 1
+}
+
+// Whether a hash was made with an older algorithm or parameters,
+// so should be replaced once the password is known.
+func DoesPasswordNeedRehash(hashed []byte) bool {
+	params, _, key, err := parseArgon2idHash(string(hashed))
+	return err != nil ||
+		params.memory != argon2idMemory ||
+		params.time != argon2idTime ||
+		params.threads != argon2idThreads ||
+		uint32(len(key)) != argon2idKeyLen
+}
+
+type argon2idParams struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+func parseArgon2idHash(hashed string) (argon2idParams, []byte, []byte, error) {
+	// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return argon2idParams{}, nil, nil, errors.New("not a argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2idParams{}, nil, nil, errors.New("unsupported argon2id version")
+	}
+	var params argon2idParams
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return argon2idParams{}, nil, nil, err
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return argon2idParams{}, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return argon2idParams{}, nil, nil, err
+	}
+	return params, salt, key, nil
+}
+
+//go:embed common_passwords.txt
+var rawCommonPasswords string
+
+var commonPasswords = func() map[string]struct{} {
+	passwords := map[string]struct{}{}
+	scanner := bufio.NewScanner(strings.NewReader(rawCommonPasswords))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			passwords[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	return passwords
+}()
+
+// Rules a new password must follow.
+type PasswordPolicy struct {
+	MinLength int
+	// reject passwords found in the bundled list of common and breached passwords
+	RejectCommon bool
+}
+
+// Check a new password for a user, errors wrap `ErrPasswordPolicy`.
+func (p PasswordPolicy) Check(username string, password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("%w, must be at least %d characters", ErrPasswordPolicy, p.MinLength)
+	}
+	if strings.EqualFold(password, username) {
+		return fmt.Errorf("%w, must not be the same as the username", ErrPasswordPolicy)
+	}
+	if p.RejectCommon {
+		if _, exists := commonPasswords[strings.ToLower(password)]; exists {
+			return fmt.Errorf("%w, too common", ErrPasswordPolicy)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordHashing(t *testing.T) {
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	oldArgon2idHash := []byte("$argon2id$v=19$m=4096,t=1,p=1$c29tZXNhbHRzb21lc2FsdA$ziLTM4ey4SCeCcvWnA0azB1cK5x8ANNoW9ot2z6LC9k")
+	tests := []struct {
+		hashed       []byte
+		plain        string
+		expectMatch  bool
+		expectRehash bool
+	}{
+		{HashPassword("correct horse"), "correct horse", true, false},
+		{HashPassword("correct horse"), "wrong horse", false, false},
+		{HashPassword(""), "", true, false},
+		{bcryptHash, "correct horse", true, true},
+		{bcryptHash, "wrong horse", false, true},
+		{oldArgon2idHash, "wrong horse", false, true},
+		{[]byte("$argon2id$v=19$m=4096"), "correct horse", false, true},
+		{nil, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			if actual := DoesPasswordMatchHashed(tt.plain, tt.hashed); actual != tt.expectMatch {
+				t.Errorf("actual '%v' expect '%v' (hash '%s')", actual, tt.expectMatch, tt.hashed)
+			}
+			if actual := DoesPasswordNeedRehash(tt.hashed); actual != tt.expectRehash {
+				t.Errorf("actual '%v' expect '%v' (hash '%s')", actual, tt.expectRehash, tt.hashed)
+			}
+		})
+	}
+}
+
+func TestPasswordHashUsesRandomSalt(t *testing.T) {
+	if a, b := HashPassword("correct horse"), HashPassword("correct horse"); string(a) == string(b) {
+		t.Errorf("actual '%s' expect different to '%s'", a, b)
+	}
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, RejectCommon: true}
+	tests := []struct {
+		policy   PasswordPolicy
+		username string
+		password string
+		valid    bool
+	}{
+		{policy, "leo", "correct horse", true},
+		{policy, "leo", "short", false},
+		// counted in characters, not bytes
+		{policy, "leo", "ééééééé", false},
+		{policy, "leo", "éééééééé", true},
+		{policy, "leonardo", "leonardo", false},
+		{policy, "leonardo", "LEONARDO", false},
+		{policy, "leo", "password", false},
+		{policy, "leo", "Password123", false},
+		{PasswordPolicy{MinLength: 8}, "leo", "password", true},
+		{PasswordPolicy{MinLength: 1}, "leo", "a", true},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			err := tt.policy.Check(tt.username, tt.password)
+			if (err == nil) != tt.valid {
+				t.Errorf("actual '%v' expect valid '%v' (password '%s')", err, tt.valid, tt.password)
+			}
+			if err != nil && !errors.Is(err, ErrPasswordPolicy) {
+				t.Errorf("actual '%v' expect '%v'", err, ErrPasswordPolicy)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"strings"
+	"time"
+
+	"github.com/enchant97/note-mark/backend/signingkeys"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type ShareLink struct {
+	Uid                uuid.UUID         `json:"uid"`
+	CreatedAt          time.Time         `json:"createdAt"`
+	Slug               NodeSlug          `json:"slug"`
+	IncludeDescendants bool              `json:"includeDescendants"`
+	Mode               AccessControlMode `json:"mode"`
+	ExpiresAt          *time.Time        `json:"expiresAt"`
+	HasPassword        bool              `json:"hasPassword"`
+	RequestCount       int64             `json:"requestCount" doc:"Number of API requests made with the link, a single visit can make several"`
+	LastUsedAt         *time.Time        `json:"lastUsedAt"`
+}
+
+type CreatedShareLink struct {
+	ShareLink
+	Token string `json:"token" doc:"Secret token, only shown once"`
+}
+
+type CreateShareLink struct {
+	Slug               NodeSlug          `json:"slug" validate:"slug_full"`
+	IncludeDescendants bool              `json:"includeDescendants,omitempty" required:"false"`
+	Mode               AccessControlMode `json:"mode" enum:"read,comment,write"`
+	ExpiresAt          *time.Time        `json:"expiresAt,omitempty" required:"false"`
+	Password           *string           `json:"password,omitempty" required:"false" minLength:"1" maxLength:"128"`
+}
+
+type UnlockShareLink struct {
+	Password string `json:"password" minLength:"1" maxLength:"128"`
+}
+
+// Access given by a valid share link.
+type ShareGrant struct {
+	LinkUid            uuid.UUID
+	Owner              Username
+	Slug               NodeSlug
+	IncludeDescendants bool
+	Mode               AccessControlMode
+}
+
+// Get the mode a share grant gives for a node, blank when none is given.
+//
+// Nodes in the trash are never shared.
+func (g *ShareGrant) ModeForNode(username Username, fullSlug NodeSlug) AccessControlMode {
+	if g.Owner != username || strings.HasPrefix(string(fullSlug), ".trash/") {
+		return ""
+	}
+	if fullSlug == g.Slug {
+		return g.Mode
+	}
+	if g.IncludeDescendants && strings.HasPrefix(string(fullSlug), string(g.Slug)+"/") {
+		return g.Mode
+	}
+	return ""
+}
+
+// Create a new random share token, returning the token and it's hash for storage.
+func NewShareToken() (string, []byte) {
+	token := rand.Text()
+	return token, HashShareToken(token)
+}
+
+func HashShareToken(token string) []byte {
+	h := sha256.Sum256([]byte(token))
+	return h[:]
+}
+
+// Audience of share unlock grants, so they can't be mistaken for access tokens.
+const shareUnlockAudience = "share-link"
+
+// A password protected share link that has been unlocked.
+type ShareUnlock struct {
+	Grant     string    `json:"grant" doc:"Send as X-Share-Grant along with the share token, instead of the password"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Create a signed grant for a share link, given once its password has been checked.
+func CreateShareUnlockGrant(
+	linkUid uuid.UUID,
+	keyring *signingkeys.Keyring,
+	expiresDuration time.Duration,
+) (ShareUnlock, error) {
+	expiresAt := time.Now().Add(expiresDuration)
+	grant, err := keyring.Sign(jwt.RegisteredClaims{
+		Subject:   linkUid.String(),
+		Audience:  jwt.ClaimStrings{shareUnlockAudience},
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	})
+	if err != nil {
+		return ShareUnlock{}, err
+	}
+	return ShareUnlock{
+		Grant:     grant,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// Parse a share unlock grant, returning the uid of the share link it unlocks.
+func ParseShareUnlockGrant(grant string, keyring *signingkeys.Keyring) (uuid.UUID, error) {
+	claims := jwt.RegisteredClaims{}
+	if _, err := keyring.Parse(
+		grant,
+		&claims,
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(shareUnlockAudience),
+	); err != nil {
+		return uuid.Nil, err
+	}
+	return uuid.Parse(claims.Subject)
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/enchant97/note-mark/backend/signingkeys"
+	"github.com/google/uuid"
+)
+
+func TestShareGrantModeForNode(t *testing.T) {
+	grant := ShareGrant{
+		Owner: "leo",
+		Slug:  "notes/project",
+		Mode:  AccessControlReadMode,
+	}
+	grantWithDescendants := grant
+	grantWithDescendants.IncludeDescendants = true
+	tests := []struct {
+		grant    ShareGrant
+		username Username
+		fullSlug NodeSlug
+		expect   AccessControlMode
+	}{
+		{grant, "leo", "notes/project", AccessControlReadMode},
+		{grant, "steve", "notes/project", ""},
+		{grant, "leo", "notes", ""},
+		{grant, "leo", "notes/project/child", ""},
+		{grantWithDescendants, "leo", "notes/project/child", AccessControlReadMode},
+		{grantWithDescendants, "leo", "notes/project/image.png", AccessControlReadMode},
+		{grantWithDescendants, "leo", "notes/project-two", ""},
+		{grantWithDescendants, "leo", ".trash/20260101T000000-000Z/notes/project", ""},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := tt.grant.ModeForNode(tt.username, tt.fullSlug)
+			if actual != tt.expect {
+				t.Errorf("actual '%v' expect '%v' (slug '%s')", actual, tt.expect, tt.fullSlug)
+			}
+		})
+	}
+}
+
+func TestParseShareUnlockGrant(t *testing.T) {
+	keyring, _ := signingkeys.Keyring{}.New(nil, []byte("testing-secret-testing-secret-00"))
+	otherKeyring, _ := signingkeys.Keyring{}.New(nil, []byte("another-secret-another-secret-00"))
+	linkUid := uuid.New()
+	unlock, _ := CreateShareUnlockGrant(linkUid, &keyring, time.Hour)
+	expired, _ := CreateShareUnlockGrant(linkUid, &keyring, -time.Minute)
+	accessToken, _ := CreateAuthenticationToken(AuthenticatedUser{UserUID: linkUid}, &keyring, time.Hour)
+	tests := []struct {
+		grant     string
+		keyring   *signingkeys.Keyring
+		expectErr bool
+	}{
+		{unlock.Grant, &keyring, false},
+		{unlock.Grant, &otherKeyring, true},
+		{expired.Grant, &keyring, true},
+		{accessToken.AccessToken, &keyring, true},
+		{"", &keyring, true},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual, err := ParseShareUnlockGrant(tt.grant, tt.keyring)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("actual '%v' expect error '%v'", err, tt.expectErr)
+			} else if err == nil && actual != linkUid {
+				t.Errorf("actual '%v' expect '%v'", actual, linkUid)
+			}
+		})
+	}
+	if _, _, err := ParseAuthenticationToken(unlock.Grant, &keyring); err == nil {
+		t.Errorf("actual '%v' expect '%v'", err, JWTClaimsNotValidError)
+	}
+}
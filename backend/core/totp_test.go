@@ -0,0 +1,92 @@
+package core
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC6238 appendix B, using the SHA1 key.
+var totpTestSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTotpCodeAt(t *testing.T) {
+	testCases := []struct {
+		Unix     int64
+		Expected string
+	}{
+		{Unix: 59, Expected: "287082"},
+		{Unix: 1111111109, Expected: "081804"},
+		{Unix: 1234567890, Expected: "005924"},
+		{Unix: 20000000000, Expected: "353130"},
+	}
+	for _, testCase := range testCases {
+		t.Run("", func(t *testing.T) {
+			actual, err := TotpCodeAt(totpTestSecret, TotpStep(time.Unix(testCase.Unix, 0)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != testCase.Expected {
+				t.Errorf("actual '%v' expect '%v'", actual, testCase.Expected)
+			}
+		})
+	}
+}
+
+func TestValidateTotpCode(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	step := TotpStep(now)
+	testCases := []struct {
+		Code         string
+		LastUsedStep int64
+		ExpectedStep int64
+		ExpectedOk   bool
+	}{
+		{Code: "081804", LastUsedStep: 0, ExpectedStep: step, ExpectedOk: true},
+		{Code: " 081804 ", LastUsedStep: 0, ExpectedStep: step, ExpectedOk: true},
+		// previous step allowed for clock skew
+		{Code: "731029", LastUsedStep: 0, ExpectedStep: step - 1, ExpectedOk: true},
+		// beyond allowed skew
+		{Code: "266759", LastUsedStep: 0, ExpectedOk: false},
+		// replayed code
+		{Code: "081804", LastUsedStep: step, ExpectedOk: false},
+		{Code: "000000", LastUsedStep: 0, ExpectedOk: false},
+		{Code: "0818", LastUsedStep: 0, ExpectedOk: false},
+		{Code: "", LastUsedStep: 0, ExpectedOk: false},
+	}
+	for _, testCase := range testCases {
+		t.Run("", func(t *testing.T) {
+			actualStep, actualOk := ValidateTotpCode(totpTestSecret, testCase.Code, now, testCase.LastUsedStep)
+			if actualOk != testCase.ExpectedOk {
+				t.Errorf("actual '%v' expect '%v'", actualOk, testCase.ExpectedOk)
+			}
+			if actualStep != testCase.ExpectedStep {
+				t.Errorf("actual '%v' expect '%v'", actualStep, testCase.ExpectedStep)
+			}
+		})
+	}
+}
+
+func TestTotpProvisioningURI(t *testing.T) {
+	expected := "otpauth://totp/Note%20Mark:alice?algorithm=SHA1&digits=6&issuer=Note+Mark&period=30&secret=ABC"
+	actual := TotpProvisioningURI("alice", "ABC")
+	if actual != expected {
+		t.Errorf("actual '%v' expect '%v'", actual, expected)
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	codes := GenerateRecoveryCodes()
+	if len(codes) != TotpRecoveryCodeCount {
+		t.Errorf("actual '%v' expect '%v'", len(codes), TotpRecoveryCodeCount)
+	}
+	for _, code := range codes {
+		t.Run("", func(t *testing.T) {
+			expected := HashRecoveryCode(code)
+			variant := strings.ToUpper(strings.ReplaceAll(code, "-", " "))
+			if actual := HashRecoveryCode(variant); actual != expected {
+				t.Errorf("actual '%v' expect '%v'", actual, expected)
+			}
+		})
+	}
+}
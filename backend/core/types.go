@@ -8,14 +8,19 @@ import (
 
 type NodeType string
 type Username string
+type GroupName string
 type AccessControlMode string
 
+// Name shown to users by other apps, such as authenticators and password managers.
+const AppName = "Note Mark"
+
 const (
 	NoteNode  = "note"
 	AssetNode = "asset"
 
-	AccessControlReadMode  AccessControlMode = "read"
-	AccessControlWriteMode AccessControlMode = "write"
+	AccessControlReadMode    AccessControlMode = "read"
+	AccessControlCommentMode AccessControlMode = "comment"
+	AccessControlWriteMode   AccessControlMode = "write"
 )
 
 type NodeEntry struct {
@@ -40,12 +45,42 @@ type NodeSlug string
 type NodeTree map[NodeSlug]*Node
 
 type AccessControl struct {
-	PublicRead bool                           `json:"publicRead,omitempty" yaml:"publicRead"`
-    Users      map[Username]AccessControlMode `json:"users,omitempty" yaml:"users,omitempty"`
+	// When false, permissions from parent nodes are not applied (defaults to true)
+	Inherit    *bool                           `json:"inherit,omitempty" yaml:"inherit,omitempty"`
+	PublicRead bool                            `json:"publicRead,omitempty" yaml:"publicRead"`
+	Users      map[Username]AccessControlMode  `json:"users,omitempty" yaml:"users,omitempty"`
+	Groups     map[GroupName]AccessControlMode `json:"groups,omitempty" yaml:"groups,omitempty"`
+	Deny       *AccessControlDeny              `json:"deny,omitempty" yaml:"deny,omitempty"`
+}
+
+// Whether permissions from parent nodes should be applied.
+func (ac AccessControl) Inherits() bool {
+	return ac.Inherit == nil || *ac.Inherit
+}
+
+// Users and groups that are never given access, overriding any permissions given.
+type AccessControlDeny struct {
+	Users  []Username  `json:"users,omitempty" yaml:"users,omitempty"`
+	Groups []GroupName `json:"groups,omitempty" yaml:"groups,omitempty"`
+}
+
+// A user trying to access nodes, along with the groups they are a member of.
+type Accessor struct {
+	Username Username
+	Groups   []GroupName
+}
+
+// A node another user has given access to.
+type SharedNode struct {
+	Owner   Username          `json:"owner"`
+	Slug    NodeSlug          `json:"slug"`
+	Title   string            `json:"title,omitempty"`
+	Mode    AccessControlMode `json:"mode"`
+	ModTime time.Time         `json:"modTime"`
 }
 
 type FrontMatter struct {
-    Title         string         `json:"title,omitempty" yaml:"title"`
+	Title         string         `json:"title,omitempty" yaml:"title"`
 	AccessControl *AccessControl `json:"accessControl,omitempty" yaml:"accessControl,omitempty"`
 }
 
@@ -58,31 +93,40 @@ type Node struct {
 	Slug    NodeSlug  `json:"slug"`
 	Type    NodeType  `json:"type"`
 	ModTime time.Time `json:"modTime"`
+	// Set when the node cannot be read, but is needed to reach a readable descendant
+	Placeholder bool `json:"placeholder,omitempty"`
 	*NoteNodeFields
 }
 
 type OidcProviderInfo struct {
-	DisplayName string `json:"displayName"`
-	IssuerURL   string `json:"issuerUrl"`
-	ClientID    string `json:"clientId"`
+	ProviderName string `json:"providerName"`
+	DisplayName  string `json:"displayName"`
+	IssuerURL    string `json:"issuerUrl"`
+	ClientID     string `json:"clientId"`
 }
 
 type ServerInfo struct {
-	MinSupportedVersion       string            `json:"minSupportedVersion"`
-	AllowInternalSignup       bool              `json:"allowInternalSignup"`
-	AllowInternalLogin        bool              `json:"allowInternalLogin"`
-	EnableAnonymousUserSearch bool              `json:"enableAnonymousUserSearch"`
-	OidcProvider              *OidcProviderInfo `json:"oidcProvider,omitempty"`
+	MinSupportedVersion       string             `json:"minSupportedVersion"`
+	AllowInternalSignup       bool               `json:"allowInternalSignup"`
+	InviteOnlySignup          bool               `json:"inviteOnlySignup"`
+	PasswordMinLength         uint               `json:"passwordMinLength"`
+	AllowInternalLogin        bool               `json:"allowInternalLogin"`
+	AllowWebAuthnLogin        bool               `json:"allowWebAuthnLogin"`
+	EnableAnonymousUserSearch bool               `json:"enableAnonymousUserSearch"`
+	OidcProvider              *OidcProviderInfo  `json:"oidcProvider,omitempty" doc:"First of the OIDC providers, for older clients"`
+	OidcProviders             []OidcProviderInfo `json:"oidcProviders"`
 }
 
 type CreateUserWithPassword struct {
-	Username string  `json:"username" minLength:"3" maxLength:"30" pattern:"^[a-zA-Z0-9]+$"`
-	Name     *string `json:"name" require:"false" minLength:"3" maxLength:"128"`
-	Password string  `json:"password" maxLength:"128"`
+	Username   string  `json:"username" minLength:"3" maxLength:"30" pattern:"^[a-zA-Z0-9]+$"`
+	Name       *string `json:"name" require:"false" minLength:"3" maxLength:"128"`
+	Password   string  `json:"password" maxLength:"128"`
+	InviteCode *string `json:"inviteCode,omitempty" required:"false" maxLength:"64" doc:"Required when signup is invite only"`
 }
 
 type UpdateUser struct {
-	Name *string `json:"name" minLength:"3" maxLength:"128"`
+	Name     *string `json:"name" minLength:"3" maxLength:"128"`
+	Timezone *string `json:"timezone,omitempty" required:"false" maxLength:"64" doc:"IANA timezone name, blank to unset"`
 }
 
 type UpdateUserPassword struct {
@@ -100,4 +144,39 @@ type User struct {
 	Uid      uuid.UUID `json:"uid"`
 	Username string    `json:"username"`
 	Name     *string   `json:"name"`
+	Timezone *string   `json:"timezone"`
+}
+
+type JournalEntry struct {
+	Date    string    `json:"date" example:"2026-10-18"`
+	Slug    NodeSlug  `json:"slug"`
+	Title   string    `json:"title,omitempty"`
+	ModTime time.Time `json:"modTime"`
+}
+
+type ImportRenamed struct {
+	Path string   `json:"path" example:"Projects/Q3 (draft).md"`
+	Slug NodeSlug `json:"slug" example:"Projects/Q3 -draft"`
+}
+
+type ImportSkipped struct {
+	Path   string `json:"path" example:".obsidian"`
+	Reason string `json:"reason" example:"hidden file"`
+}
+
+type ImportReport struct {
+	Notes   int             `json:"notes"`
+	Assets  int             `json:"assets"`
+	Renamed []ImportRenamed `json:"renamed"`
+	Skipped []ImportSkipped `json:"skipped"`
+}
+
+type Group struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Name      GroupName `json:"name"`
+	Owner     *string   `json:"owner"`
+}
+
+type CreateGroup struct {
+	Name GroupName `json:"name" minLength:"1" maxLength:"64" pattern:"^[a-zA-Z0-9_-]+$"`
 }
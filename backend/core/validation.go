@@ -1,24 +1,48 @@
 package core
 
 import (
+	"net/url"
 	"path"
 	"regexp"
 	"strings"
 )
 
 var (
-	validUsernameRegex = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
-	validFullSlugRegex = regexp.MustCompile(`^(?:[0-9a-zA-Z- _]+)(?:(?:\/[0-9a-zA-Z- _]+)+(?:\.[0-9a-zA-Z- _]+)*)?$`)
+	validUsernameRegex  = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+	validGroupNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+	validFullSlugRegex  = regexp.MustCompile(`^(?:[0-9a-zA-Z- _]+)(?:(?:\/[0-9a-zA-Z- _]+)+(?:\.[0-9a-zA-Z- _]+)*)?$`)
 )
 
 func IsValidUsername(v string) bool {
 	return validUsernameRegex.Match([]byte(v))
 }
 
+func IsValidGroupName(v string) bool {
+	return validGroupNameRegex.Match([]byte(v))
+}
+
 func IsValidFullSlug(v string) bool {
 	return validFullSlugRegex.Match([]byte(v))
 }
 
+// Whether v is a path on this site, so it's safe to redirect to.
+//
+// Rejects absolute and protocol-relative urls, which would allow an open redirect.
+// Backslashes and control characters are also rejected,
+// as browsers may ignore or convert them into slashes.
+func IsLocalRedirectPath(v string) bool {
+	if !strings.HasPrefix(v, "/") || strings.HasPrefix(v, "//") || strings.ContainsRune(v, '\\') {
+		return false
+	}
+	for _, c := range v {
+		if c < 0x20 || c == 0x7f {
+			return false
+		}
+	}
+	u, err := url.Parse(v)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
 // Validate if node's full slug is valid for given nodeType.
 //
 // - Slug is valid
@@ -33,6 +33,34 @@ func TestIsValidUsername(t *testing.T) {
 	}
 }
 
+func TestIsValidGroupName(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect bool
+	}{
+		{"engineering", true},
+		{"team-a", true},
+		{"team_b", true},
+		{"Team1", true},
+		{"", false},
+		{"team a", false},
+		{"team/a", false},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := IsValidGroupName(tt.name)
+			if actual != tt.expect {
+				t.Errorf(
+					"actual '%v' expect '%v' (name '%s')",
+					actual,
+					tt.expect,
+					tt.name,
+				)
+			}
+		})
+	}
+}
+
 func TestIsValidFullSlug(t *testing.T) {
 	tests := []struct {
 		slug   string
@@ -100,3 +128,32 @@ func TestIsValidNodeSlug(t *testing.T) {
 		})
 	}
 }
+
+func TestIsLocalRedirectPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		expect bool
+	}{
+		{"/", true},
+		{"/leo/notes", true},
+		{"/leo/notes?edit=1", true},
+		{"", false},
+		{"leo/notes", false},
+		{"//evil.example.com", false},
+		{"/\\evil.example.com", false},
+		{"https://evil.example.com", false},
+		{"/leo\r\nLocation: https://evil.example.com", false},
+		{"/\t/evil.example.com", false},
+		{"/\x00/evil.example.com", false},
+		{"/\x7f/evil.example.com", false},
+		{"/leo/notés", true},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := IsLocalRedirectPath(tt.path)
+			if actual != tt.expect {
+				t.Errorf("actual '%v' expect '%v' (path '%s')", actual, tt.expect, tt.path)
+			}
+		})
+	}
+}
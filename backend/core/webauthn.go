@@ -0,0 +1,39 @@
+package core
+
+import (
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+)
+
+type WebAuthnCredential struct {
+	Id         string     `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+// Options to pass to `navigator.credentials.create()`,
+// the session id must be given when finishing the registration.
+type WebAuthnRegistration struct {
+	SessionId string                       `json:"sessionId"`
+	Options   *protocol.CredentialCreation `json:"options"`
+}
+
+// Options to pass to `navigator.credentials.get()`,
+// the session id must be given when finishing the login.
+type WebAuthnLogin struct {
+	SessionId string                        `json:"sessionId"`
+	Options   *protocol.CredentialAssertion `json:"options"`
+}
+
+// The result of `PublicKeyCredential.toJSON()` in the browser,
+// only used for validation as the raw body is passed on for verification.
+type PublicKeyCredentialJSON struct {
+	Id                      string         `json:"id"`
+	RawId                   string         `json:"rawId"`
+	Type                    string         `json:"type" enum:"public-key"`
+	Response                map[string]any `json:"response"`
+	AuthenticatorAttachment string         `json:"authenticatorAttachment,omitempty" required:"false"`
+	ClientExtensionResults  map[string]any `json:"clientExtensionResults,omitempty" required:"false"`
+}
@@ -0,0 +1,17 @@
+CREATE TABLE groups (
+  id INTEGER PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  name TEXT NOT NULL,
+  owner_uid BLOB,
+  FOREIGN KEY (owner_uid) REFERENCES users(uid) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX idx_groups_name ON groups(name);
+
+CREATE TABLE group_members (
+  group_id INTEGER NOT NULL,
+  user_uid BLOB NOT NULL,
+  PRIMARY KEY (group_id, user_uid),
+  FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE CASCADE,
+  FOREIGN KEY (user_uid) REFERENCES users(uid) ON DELETE CASCADE
+);
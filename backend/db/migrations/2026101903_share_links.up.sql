@@ -0,0 +1,18 @@
+CREATE TABLE share_links (
+  uid BLOB PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  owner_uid BLOB NOT NULL,
+  token_hash BLOB NOT NULL,
+  slug TEXT NOT NULL,
+  include_descendants BOOLEAN NOT NULL DEFAULT FALSE,
+  mode TEXT NOT NULL,
+  expires_at TIMESTAMP,
+  password_hash BLOB,
+  request_count INTEGER NOT NULL DEFAULT 0,
+  last_used_at TIMESTAMP,
+  FOREIGN KEY (owner_uid) REFERENCES users(uid) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_share_links_token_hash ON share_links(token_hash);
+
+CREATE INDEX idx_share_links_owner ON share_links(owner_uid);
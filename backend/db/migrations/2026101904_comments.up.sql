@@ -0,0 +1,23 @@
+CREATE TABLE comments (
+  uid BLOB PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  owner_uid BLOB NOT NULL,
+  slug TEXT NOT NULL,
+  author_uid BLOB,
+  parent_uid BLOB,
+  anchor_start INTEGER,
+  anchor_end INTEGER,
+  anchor_text TEXT,
+  content TEXT NOT NULL,
+  resolved_at TIMESTAMP,
+  resolved_by_uid BLOB,
+  FOREIGN KEY (owner_uid) REFERENCES users(uid) ON DELETE CASCADE,
+  FOREIGN KEY (author_uid) REFERENCES users(uid) ON DELETE SET NULL,
+  FOREIGN KEY (parent_uid) REFERENCES comments(uid) ON DELETE CASCADE,
+  FOREIGN KEY (resolved_by_uid) REFERENCES users(uid) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_comments_owner_slug ON comments(owner_uid, slug);
+
+CREATE INDEX idx_comments_parent ON comments(parent_uid);
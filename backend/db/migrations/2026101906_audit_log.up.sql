@@ -0,0 +1,25 @@
+CREATE TABLE audit_log (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  event TEXT NOT NULL,
+  actor_uid BLOB,
+  actor_username TEXT,
+  share_link_uid BLOB,
+  username TEXT,
+  slug TEXT,
+  details TEXT
+);
+
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
+
+CREATE INDEX idx_audit_log_event ON audit_log(event);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+  SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+  SELECT RAISE(ABORT, 'audit log is append-only');
+END;
@@ -0,0 +1,16 @@
+CREATE TABLE user_totp (
+  user_uid BLOB PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  secret TEXT NOT NULL,
+  enabled_at TIMESTAMP,
+  last_used_step INTEGER NOT NULL DEFAULT 0,
+  FOREIGN KEY (user_uid) REFERENCES users(uid) ON DELETE CASCADE
+);
+
+CREATE TABLE user_recovery_codes (
+  user_uid BLOB NOT NULL,
+  code_hash TEXT NOT NULL,
+  used_at TIMESTAMP,
+  PRIMARY KEY (user_uid, code_hash),
+  FOREIGN KEY (user_uid) REFERENCES users(uid) ON DELETE CASCADE
+);
@@ -0,0 +1,11 @@
+CREATE TABLE webauthn_credentials (
+  id BLOB PRIMARY KEY,
+  user_uid BLOB NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  last_used_at TIMESTAMP,
+  name TEXT NOT NULL,
+  credential BLOB NOT NULL,
+  FOREIGN KEY (user_uid) REFERENCES users(uid) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webauthn_credentials_user ON webauthn_credentials(user_uid);
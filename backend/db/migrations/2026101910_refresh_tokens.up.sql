@@ -0,0 +1,11 @@
+CREATE TABLE refresh_tokens (
+  token_hash BLOB PRIMARY KEY,
+  family_uid BLOB NOT NULL,
+  user_uid BLOB NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP,
+  FOREIGN KEY (user_uid) REFERENCES users(uid) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens(family_uid);
@@ -0,0 +1,18 @@
+CREATE TABLE invites (
+  uid BLOB PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  creator_uid BLOB,
+  code_hash BLOB NOT NULL,
+  max_uses INTEGER NOT NULL DEFAULT 1,
+  use_count INTEGER NOT NULL DEFAULT 0,
+  expires_at TIMESTAMP NOT NULL,
+  group_id INTEGER,
+  share_slug TEXT,
+  share_mode TEXT,
+  FOREIGN KEY (creator_uid) REFERENCES users(uid) ON DELETE CASCADE,
+  FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX idx_invites_code_hash ON invites(code_hash);
+
+CREATE INDEX idx_invites_creator ON invites(creator_uid);
@@ -0,0 +1,8 @@
+CREATE TABLE username_redirects (
+  username TEXT PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  user_uid BLOB NOT NULL,
+  FOREIGN KEY (user_uid) REFERENCES users(uid) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_username_redirects_user ON username_redirects(user_uid);
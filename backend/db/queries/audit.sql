@@ -0,0 +1,17 @@
+-- name: InsertAuditEntry :exec
+INSERT INTO audit_log (
+  event, actor_uid, actor_username, share_link_uid, username, slug, details
+) VALUES (?,?,?,?,?,?,?);
+
+-- name: GetAuditEntries :many
+SELECT id,created_at,event,actor_uid,actor_username,share_link_uid,username,slug,details
+FROM audit_log
+WHERE id > sqlc.arg(after_id)
+  AND (CAST(sqlc.narg(event) AS TEXT) IS NULL OR event = sqlc.narg(event))
+  AND (CAST(sqlc.narg(actor) AS TEXT) IS NULL OR actor_username = sqlc.narg(actor))
+  AND (CAST(sqlc.narg(username) AS TEXT) IS NULL OR username = sqlc.narg(username))
+  AND (CAST(sqlc.narg(slug) AS TEXT) IS NULL OR slug = sqlc.narg(slug) OR slug LIKE sqlc.narg(slug) || '/%')
+  AND (CAST(sqlc.narg(since) AS TEXT) IS NULL OR created_at >= sqlc.narg(since))
+  AND (CAST(sqlc.narg(until) AS TEXT) IS NULL OR created_at < sqlc.narg(until))
+ORDER BY id
+LIMIT sqlc.arg(max_entries);
@@ -0,0 +1,48 @@
+-- name: InsertComment :one
+INSERT INTO comments (
+  uid, owner_uid, slug, author_uid, parent_uid, anchor_start, anchor_end, anchor_text, content
+) VALUES (?,?,?,?,?,?,?,?,?)
+RETURNING uid,created_at,updated_at;
+
+-- name: GetCommentsForNode :many
+SELECT c.uid,c.created_at,c.updated_at,c.parent_uid,c.anchor_start,c.anchor_end,c.anchor_text,c.content,c.resolved_at,
+  a.username AS author_username, r.username AS resolved_by_username
+FROM comments AS c
+LEFT JOIN users AS a ON a.uid = c.author_uid
+LEFT JOIN users AS r ON r.uid = c.resolved_by_uid
+WHERE c.owner_uid = (SELECT u.uid FROM users AS u WHERE u.username = sqlc.arg(username) AND u.deleted_at IS NULL)
+  AND c.slug = sqlc.arg(slug)
+ORDER BY c.created_at, c.uid;
+
+-- name: GetCommentByUid :one
+SELECT c.uid,c.slug,c.author_uid,c.parent_uid,o.username AS owner_username
+FROM comments AS c
+INNER JOIN users AS o ON o.uid = c.owner_uid
+WHERE c.uid = ? AND o.deleted_at IS NULL
+LIMIT 1;
+
+-- name: UpdateCommentContent :exec
+UPDATE comments SET content = ?, updated_at = CURRENT_TIMESTAMP WHERE uid = ?;
+
+-- name: UpdateCommentResolved :exec
+UPDATE comments SET resolved_at = ?, resolved_by_uid = ? WHERE uid = ?;
+
+-- name: DeleteComment :exec
+DELETE FROM comments WHERE uid = sqlc.arg(uid) OR parent_uid = sqlc.arg(uid);
+
+-- name: UpdateCommentSlugs :exec
+UPDATE comments
+SET slug = sqlc.arg(new_slug) || substr(slug, length(CAST(sqlc.arg(old_slug) AS TEXT)) + 1)
+WHERE owner_uid = (SELECT uid FROM users WHERE username = sqlc.arg(username) AND deleted_at IS NULL)
+  AND (
+    slug = sqlc.arg(old_slug)
+    OR substr(slug, 1, length(CAST(sqlc.arg(old_slug) AS TEXT)) + 1) = sqlc.arg(old_slug) || '/'
+  );
+
+-- name: DeleteCommentsForSlug :exec
+DELETE FROM comments
+WHERE owner_uid = (SELECT uid FROM users WHERE username = sqlc.arg(username) AND deleted_at IS NULL)
+  AND (
+    slug = sqlc.arg(slug)
+    OR substr(slug, 1, length(CAST(sqlc.arg(slug) AS TEXT)) + 1) = sqlc.arg(slug) || '/'
+  );
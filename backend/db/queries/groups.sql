@@ -0,0 +1,48 @@
+-- name: InsertGroup :one
+INSERT INTO groups (name, owner_uid) VALUES (?,?)
+RETURNING id,created_at,name,owner_uid;
+
+-- name: GetGroupByName :one
+SELECT g.id, g.created_at, g.name, g.owner_uid, u.username AS owner_username
+FROM groups AS g
+LEFT JOIN users AS u ON u.uid = g.owner_uid AND u.deleted_at IS NULL
+WHERE g.name = ?
+LIMIT 1;
+
+-- name: GetGroupsForUser :many
+SELECT g.id, g.created_at, g.name, g.owner_uid, u.username AS owner_username
+FROM groups AS g
+LEFT JOIN users AS u ON u.uid = g.owner_uid AND u.deleted_at IS NULL
+WHERE g.owner_uid = sqlc.arg(user_uid)
+  OR g.id IN (SELECT group_id FROM group_members WHERE group_members.user_uid = sqlc.arg(user_uid))
+ORDER BY g.name;
+
+-- name: GetGroups :many
+SELECT g.id, g.created_at, g.name, g.owner_uid, u.username AS owner_username
+FROM groups AS g
+LEFT JOIN users AS u ON u.uid = g.owner_uid AND u.deleted_at IS NULL
+ORDER BY g.name;
+
+-- name: GetGroupNamesForUsername :many
+SELECT g.name
+FROM group_members AS m
+INNER JOIN groups AS g ON g.id = m.group_id
+INNER JOIN users AS u ON u.uid = m.user_uid
+WHERE u.username = ? AND u.deleted_at IS NULL
+ORDER BY g.name;
+
+-- name: GetGroupMemberUsernames :many
+SELECT u.username
+FROM group_members AS m
+INNER JOIN users AS u ON u.uid = m.user_uid
+WHERE m.group_id = ? AND u.deleted_at IS NULL
+ORDER BY u.username;
+
+-- name: InsertGroupMember :exec
+INSERT INTO group_members (group_id, user_uid) VALUES (?,?);
+
+-- name: DeleteGroupMember :exec
+DELETE FROM group_members WHERE group_id = ? AND user_uid = ?;
+
+-- name: DeleteGroup :exec
+DELETE FROM groups WHERE id = ?;
@@ -0,0 +1,40 @@
+-- name: InsertInvite :one
+INSERT INTO invites (
+  uid, creator_uid, code_hash, max_uses, expires_at, group_id, share_slug, share_mode
+) VALUES (?,?,?,?,?,?,?,?)
+RETURNING uid,created_at,max_uses,use_count,expires_at,share_slug,share_mode;
+
+-- name: GetInvitesByCreator :many
+SELECT i.uid,i.created_at,i.max_uses,i.use_count,i.expires_at,g.name AS group_name,i.share_slug,i.share_mode
+FROM invites AS i
+LEFT JOIN groups AS g ON g.id = i.group_id
+WHERE i.creator_uid = ?
+ORDER BY i.created_at DESC;
+
+-- name: GetInvites :many
+SELECT i.uid,i.created_at,i.max_uses,i.use_count,i.expires_at,g.name AS group_name,i.share_slug,i.share_mode,
+  u.username AS creator_username
+FROM invites AS i
+LEFT JOIN groups AS g ON g.id = i.group_id
+LEFT JOIN users AS u ON u.uid = i.creator_uid
+ORDER BY i.created_at DESC;
+
+-- name: ClaimInvite :one
+UPDATE invites SET use_count = use_count + 1
+WHERE code_hash = ? AND use_count < max_uses AND expires_at > sqlc.arg(now)
+RETURNING uid,creator_uid,group_id,share_slug,share_mode;
+
+-- name: UpdateInviteShareSlugs :exec
+UPDATE invites
+SET share_slug = CAST(sqlc.arg(new_slug) AS TEXT) || substr(share_slug, length(CAST(sqlc.arg(old_slug) AS TEXT)) + 1)
+WHERE creator_uid = (SELECT uid FROM users WHERE username = sqlc.arg(username) AND deleted_at IS NULL)
+  AND (
+    share_slug = sqlc.arg(old_slug)
+    OR substr(share_slug, 1, length(CAST(sqlc.arg(old_slug) AS TEXT)) + 1) = sqlc.arg(old_slug) || '/'
+  );
+
+-- name: DeleteInvite :execrows
+DELETE FROM invites WHERE uid = ? AND creator_uid = ?;
+
+-- name: AdminDeleteInvite :execrows
+DELETE FROM invites WHERE uid = ?;
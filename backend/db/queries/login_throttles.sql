@@ -0,0 +1,12 @@
+-- name: GetLoginThrottles :many
+SELECT key,failures,last_failure_at,locked_until FROM login_throttles;
+
+-- name: UpsertLoginThrottle :exec
+INSERT INTO login_throttles (key, failures, last_failure_at, locked_until) VALUES (?,?,?,?)
+ON CONFLICT (key) DO UPDATE SET
+  failures = excluded.failures,
+  last_failure_at = excluded.last_failure_at,
+  locked_until = excluded.locked_until;
+
+-- name: DeleteLoginThrottle :exec
+DELETE FROM login_throttles WHERE key = ?;
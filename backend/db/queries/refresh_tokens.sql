@@ -0,0 +1,17 @@
+-- name: InsertRefreshToken :exec
+INSERT INTO refresh_tokens (token_hash, family_uid, user_uid, expires_at) VALUES (?,?,?,?);
+
+-- name: GetRefreshToken :one
+SELECT family_uid,user_uid,expires_at,used_at FROM refresh_tokens WHERE token_hash = ?;
+
+-- name: MarkRefreshTokenUsed :execrows
+UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP WHERE token_hash = ? AND used_at IS NULL;
+
+-- name: DeleteRefreshTokenFamily :exec
+DELETE FROM refresh_tokens WHERE family_uid = ?;
+
+-- name: DeleteUserRefreshTokens :exec
+DELETE FROM refresh_tokens WHERE user_uid = ?;
+
+-- name: DeleteExpiredRefreshTokens :exec
+DELETE FROM refresh_tokens WHERE expires_at < ?;
@@ -0,0 +1,33 @@
+-- name: InsertShareLink :one
+INSERT INTO share_links (
+  uid, owner_uid, token_hash, slug, include_descendants, mode, expires_at, password_hash
+) VALUES (?,?,?,?,?,?,?,?)
+RETURNING uid,created_at,slug,include_descendants,mode,expires_at,password_hash,request_count,last_used_at;
+
+-- name: GetShareLinksByOwner :many
+SELECT uid,created_at,slug,include_descendants,mode,expires_at,password_hash,request_count,last_used_at
+FROM share_links
+WHERE owner_uid = ?
+ORDER BY created_at DESC;
+
+-- name: GetShareLinkByTokenHash :one
+SELECT sl.uid,sl.slug,sl.include_descendants,sl.mode,sl.expires_at,sl.password_hash,u.username AS owner_username
+FROM share_links AS sl
+INNER JOIN users AS u ON u.uid = sl.owner_uid
+WHERE sl.token_hash = ? AND u.deleted_at IS NULL
+LIMIT 1;
+
+-- name: IncrementShareLinkRequestCount :exec
+UPDATE share_links SET request_count = request_count + 1, last_used_at = CURRENT_TIMESTAMP WHERE uid = ?;
+
+-- name: UpdateShareLinkSlugs :exec
+UPDATE share_links
+SET slug = sqlc.arg(new_slug) || substr(slug, length(CAST(sqlc.arg(old_slug) AS TEXT)) + 1)
+WHERE owner_uid = (SELECT uid FROM users WHERE username = sqlc.arg(username) AND deleted_at IS NULL)
+  AND (
+    slug = sqlc.arg(old_slug)
+    OR substr(slug, 1, length(CAST(sqlc.arg(old_slug) AS TEXT)) + 1) = sqlc.arg(old_slug) || '/'
+  );
+
+-- name: DeleteShareLink :execrows
+DELETE FROM share_links WHERE uid = ? AND owner_uid = ?;
@@ -0,0 +1,33 @@
+-- name: GetUserTotp :one
+SELECT secret,enabled_at,last_used_step FROM user_totp WHERE user_uid = ? LIMIT 1;
+
+-- name: UpsertPendingUserTotp :exec
+INSERT INTO user_totp (user_uid, secret) VALUES (?,?)
+ON CONFLICT (user_uid) DO UPDATE SET
+  created_at = CURRENT_TIMESTAMP,
+  secret = excluded.secret,
+  enabled_at = NULL,
+  last_used_step = 0;
+
+-- name: EnableUserTotp :exec
+UPDATE user_totp SET enabled_at = CURRENT_TIMESTAMP, last_used_step = ? WHERE user_uid = ?;
+
+-- name: UpdateUserTotpLastUsedStep :execrows
+UPDATE user_totp SET last_used_step = sqlc.arg(step)
+WHERE user_uid = sqlc.arg(user_uid) AND last_used_step < sqlc.arg(step);
+
+-- name: DeleteUserTotp :execrows
+DELETE FROM user_totp WHERE user_uid = ?;
+
+-- name: InsertUserRecoveryCode :exec
+INSERT INTO user_recovery_codes (user_uid, code_hash) VALUES (?,?);
+
+-- name: UseUserRecoveryCode :execrows
+UPDATE user_recovery_codes SET used_at = CURRENT_TIMESTAMP
+WHERE user_uid = ? AND code_hash = ? AND used_at IS NULL;
+
+-- name: CountUnusedUserRecoveryCodes :one
+SELECT COUNT(*) FROM user_recovery_codes WHERE user_uid = ? AND used_at IS NULL;
+
+-- name: DeleteUserRecoveryCodes :exec
+DELETE FROM user_recovery_codes WHERE user_uid = ?;
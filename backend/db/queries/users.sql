@@ -13,16 +13,17 @@ INSERT INTO oidc_users (user_uid, user_sub, provider_name) VALUES((SELECT uid FR
 SELECT uid FROM users WHERE username = ? AND deleted_at IS NULL LIMIT 1;
 
 -- name: GetUserByUsername :one
-SELECT uid,created_at,updated_at,username,name FROM users WHERE username = ? AND deleted_at IS NULL LIMIT 1;
+SELECT uid,created_at,updated_at,username,name,timezone FROM users WHERE username = ? AND deleted_at IS NULL LIMIT 1;
 
 -- name: GetUserByUid :one
-SELECT uid,created_at,updated_at,username,name FROM users WHERE uid = ? AND deleted_at IS NULL LIMIT 1;
+SELECT uid,created_at,updated_at,username,name,is_admin,tokens_valid_after FROM users
+WHERE uid = ? AND deleted_at IS NULL AND disabled_at IS NULL LIMIT 1;
 
 -- name: GetUsernamesLike :many
 SELECT username FROM users WHERE username LIKE ? AND deleted_at IS NULL LIMIT 6;
 
 -- name: GetUserPassword :one
-SELECT uid,password_hash FROM users where username = ? AND deleted_at IS NULL LIMIT 1;
+SELECT uid,password_hash FROM users where username = ? AND deleted_at IS NULL AND disabled_at IS NULL LIMIT 1;
 
 -- name: GetOidcUserUid :one
 SELECT user_uid
@@ -34,12 +35,21 @@ LIMIT 1;
 -- name: UpdateUserPassword :exec
 UPDATE users SET password_hash = ?, updated_at=CURRENT_TIMESTAMP WHERE uid = ?;
 
+-- name: UpdateUserTokensValidAfter :exec
+UPDATE users SET tokens_valid_after = ? WHERE uid = ?;
+
 -- name: UpdateUserPasswordByUsername :exec
 UPDATE users SET password_hash = ?, updated_at=CURRENT_TIMESTAMP WHERE username = ?;
 
 -- name: UpdateUser :exec
 UPDATE users SET name = ?, updated_at=CURRENT_TIMESTAMP WHERE username=?;
 
+-- name: UpdateUserTimezone :exec
+UPDATE users SET timezone = ?, updated_at=CURRENT_TIMESTAMP WHERE username=?;
+
+-- name: GetUserTimezone :one
+SELECT timezone FROM users WHERE username = ? AND deleted_at IS NULL LIMIT 1;
+
 -- name: MarkUserAsDeleted :exec
 UPDATE users SET deleted_at=CURRENT_TIMESTAMP, updated_at=CURRENT_TIMESTAMP WHERE uid = ?;
 
@@ -54,3 +64,32 @@ DELETE FROM users WHERE uid = ?;
 
 -- name: AdminRemoveUserPassword :exec
 UPDATE users SET password_hash = NULL WHERE username = ?;
+
+-- name: AdminGetUsers :many
+SELECT uid,created_at,username,name,is_admin,disabled_at FROM users WHERE deleted_at IS NULL ORDER BY username;
+
+-- name: AdminSetUserAdmin :execrows
+UPDATE users SET is_admin = ?, updated_at=CURRENT_TIMESTAMP WHERE username = ? AND deleted_at IS NULL;
+
+-- name: AdminSetUserDisabled :execrows
+UPDATE users SET disabled_at = ?, updated_at=CURRENT_TIMESTAMP WHERE username = ? AND deleted_at IS NULL;
+
+-- name: UpdateUsername :execrows
+UPDATE users SET username = sqlc.arg(new_username), updated_at=CURRENT_TIMESTAMP
+WHERE uid = sqlc.arg(uid) AND deleted_at IS NULL;
+
+-- name: InsertUsernameRedirect :exec
+INSERT INTO username_redirects (username, user_uid) VALUES (?,?)
+ON CONFLICT (username) DO UPDATE SET user_uid=excluded.user_uid, created_at=CURRENT_TIMESTAMP;
+
+-- name: DeleteUsernameRedirect :exec
+DELETE FROM username_redirects WHERE username = ?;
+
+-- name: GetUsernameRedirect :one
+SELECT u.username
+FROM username_redirects AS r
+INNER JOIN users AS u ON u.uid = r.user_uid
+WHERE r.username = ? AND u.deleted_at IS NULL
+  -- a new user may have since taken the username
+  AND r.username NOT IN (SELECT username FROM users)
+LIMIT 1;
@@ -0,0 +1,13 @@
+-- name: GetWebAuthnCredentialsForUser :many
+SELECT id,created_at,last_used_at,name,credential FROM webauthn_credentials
+WHERE user_uid = ? ORDER BY created_at;
+
+-- name: InsertWebAuthnCredential :exec
+INSERT INTO webauthn_credentials (id, user_uid, name, credential) VALUES (?,?,?,?);
+
+-- name: UpdateWebAuthnCredentialUsage :exec
+UPDATE webauthn_credentials SET credential = ?, last_used_at = CURRENT_TIMESTAMP
+WHERE id = ? AND user_uid = ?;
+
+-- name: DeleteWebAuthnCredential :execrows
+DELETE FROM webauthn_credentials WHERE id = ? AND user_uid = ?;
@@ -0,0 +1,151 @@
+package feed
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// A feed, independent of format.
+type Feed struct {
+	ID      string
+	Title   string
+	Link    string
+	SelfURL string
+	Updated time.Time
+	Author  string
+	Entries []Entry
+}
+
+// A feed entry, independent of format.
+type Entry struct {
+	ID      string
+	Title   string
+	Link    string
+	Updated time.Time
+	Content string
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID      string       `xml:"id"`
+	Title   string       `xml:"title"`
+	Link    atomLink     `xml:"link"`
+	Updated string       `xml:"updated"`
+	Content *atomContent `xml:"content,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description,omitempty"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	AtomLink      atomLink  `xml:"http://www.w3.org/2005/Atom link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// Marshal feed into Atom format, following: RFC4287
+func (f *Feed) MarshalAtom() ([]byte, error) {
+	v := atomFeed{
+		ID:      f.ID,
+		Title:   f.Title,
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: f.Author},
+		Links: []atomLink{
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+			{Href: f.SelfURL, Rel: "self", Type: "application/atom+xml"},
+		},
+		Entries: make([]atomEntry, len(f.Entries)),
+	}
+	for i, entry := range f.Entries {
+		v.Entries[i] = atomEntry{
+			ID:      entry.ID,
+			Title:   entry.Title,
+			Link:    atomLink{Href: entry.Link, Rel: "alternate", Type: "text/html"},
+			Updated: entry.Updated.UTC().Format(time.RFC3339),
+		}
+		if entry.Content != "" {
+			v.Entries[i].Content = &atomContent{Type: "html", Value: entry.Content}
+		}
+	}
+	return marshalWithHeader(v)
+}
+
+// Marshal feed into RSS 2.0 format.
+func (f *Feed) MarshalRSS() ([]byte, error) {
+	v := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Title,
+			LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+			AtomLink: atomLink{
+				Href: f.SelfURL,
+				Rel:  "self",
+				Type: "application/rss+xml",
+			},
+			Items: make([]rssItem, len(f.Entries)),
+		},
+	}
+	for i, entry := range f.Entries {
+		v.Channel.Items[i] = rssItem{
+			Title:       entry.Title,
+			Link:        entry.Link,
+			GUID:        rssGUID{IsPermaLink: false, Value: entry.ID},
+			PubDate:     entry.Updated.UTC().Format(time.RFC1123Z),
+			Description: entry.Content,
+		}
+	}
+	return marshalWithHeader(v)
+}
+
+func marshalWithHeader(v any) ([]byte, error) {
+	b, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
@@ -0,0 +1,65 @@
+package feed
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+)
+
+var testFeed = Feed{
+	ID:      "urn:test",
+	Title:   "leo",
+	Link:    "https://example.com/leo",
+	SelfURL: "https://example.com/api/feed/u/leo.atom",
+	Updated: time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC),
+	Author:  "leo",
+	Entries: []Entry{
+		{
+			ID:      "https://example.com/leo/note",
+			Title:   "Notes & <Things>",
+			Link:    "https://example.com/leo/note",
+			Updated: time.Date(2026, time.October, 17, 12, 0, 0, 0, time.UTC),
+			Content: "<p>hello</p>",
+		},
+	},
+}
+
+func TestFeedMarshalAtom(t *testing.T) {
+	raw, err := testFeed.MarshalAtom()
+	if err != nil {
+		t.Fatalf("unexpected err '%v'", err)
+	}
+	var actual atomFeed
+	if err := xml.Unmarshal(raw, &actual); err != nil {
+		t.Fatalf("unexpected err '%v'", err)
+	}
+	if actual.Updated != "2026-10-18T12:00:00Z" {
+		t.Errorf("actual updated '%v'", actual.Updated)
+	}
+	if len(actual.Entries) != 1 {
+		t.Fatalf("actual entries '%v' expect '1'", len(actual.Entries))
+	}
+	if actual.Entries[0].Title != "Notes & <Things>" || actual.Entries[0].Content.Value != "<p>hello</p>" {
+		t.Errorf("actual entry '%+v'", actual.Entries[0])
+	}
+}
+
+func TestFeedMarshalRSS(t *testing.T) {
+	raw, err := testFeed.MarshalRSS()
+	if err != nil {
+		t.Fatalf("unexpected err '%v'", err)
+	}
+	var actual rssFeed
+	if err := xml.Unmarshal(raw, &actual); err != nil {
+		t.Fatalf("unexpected err '%v'", err)
+	}
+	if actual.Channel.LastBuildDate != "Sun, 18 Oct 2026 12:00:00 +0000" {
+		t.Errorf("actual lastBuildDate '%v'", actual.Channel.LastBuildDate)
+	}
+	if len(actual.Channel.Items) != 1 {
+		t.Fatalf("actual items '%v' expect '1'", len(actual.Channel.Items))
+	}
+	if actual.Channel.Items[0].GUID.Value != "https://example.com/leo/note" {
+		t.Errorf("actual item '%+v'", actual.Channel.Items[0])
+	}
+}
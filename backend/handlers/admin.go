@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/middleware"
+	"github.com/enchant97/note-mark/backend/services"
+	"github.com/enchant97/note-mark/backend/usercache"
+)
+
+func SetupAdminHandler(
+	api huma.API,
+	service services.AdminService,
+	auditService services.AuditService,
+	authProvider *middleware.AuthDetailsProvider,
+) {
+	handler := AdminHandler{
+		service:      service,
+		auditService: auditService,
+		authProvider: authProvider,
+	}
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/api/admin/users",
+		Middlewares: huma.Middlewares{authProvider.AdminRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Admin"},
+		Summary:     "Get all users",
+		OperationID: "AdminGetUsers",
+	}, handler.GetUsers)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodDelete,
+		Path:        "/api/admin/users/{username}",
+		Middlewares: huma.Middlewares{authProvider.AdminRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Admin"},
+		Summary:     "Mark a user as deleted",
+		Description: "User data is removed when the clean command is next run.",
+		OperationID: "AdminDeleteUser",
+	}, handler.DeleteUser)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPut,
+		Path:        "/api/admin/users/{username}/disabled",
+		Middlewares: huma.Middlewares{authProvider.AdminRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Admin"},
+		Summary:     "Disable a user",
+		OperationID: "AdminDisableUser",
+	}, handler.PutUserDisabled)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodDelete,
+		Path:        "/api/admin/users/{username}/disabled",
+		Middlewares: huma.Middlewares{authProvider.AdminRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Admin"},
+		Summary:     "Enable a disabled user",
+		OperationID: "AdminEnableUser",
+	}, handler.DeleteUserDisabled)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPut,
+		Path:        "/api/admin/users/{username}/password",
+		Middlewares: huma.Middlewares{authProvider.AdminRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Admin"},
+		Summary:     "Reset a users password",
+		OperationID: "AdminResetUserPassword",
+	}, handler.PutUserPassword)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPut,
+		Path:        "/api/admin/users/{username}/username",
+		Middlewares: huma.Middlewares{authProvider.AdminRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Admin"},
+		Summary:     "Rename a user",
+		Description: "The old username will redirect to the new one, until it is taken by another user.",
+		OperationID: "AdminRenameUser",
+	}, handler.PutUsername)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodDelete,
+		Path:        "/api/admin/tree-cache",
+		Middlewares: huma.Middlewares{authProvider.AdminRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Admin"},
+		Summary:     "Rebuild the tree cache for all users from storage",
+		OperationID: "AdminClearTreeCache",
+	}, handler.DeleteTreeCache)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodDelete,
+		Path:        "/api/admin/tree-cache/{username}",
+		Middlewares: huma.Middlewares{authProvider.AdminRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Admin"},
+		Summary:     "Rebuild the tree cache for a user from storage",
+		OperationID: "AdminClearUserTreeCache",
+	}, handler.DeleteUserTreeCache)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodDelete,
+		Path:        "/api/admin/trash",
+		Middlewares: huma.Middlewares{authProvider.AdminRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Admin"},
+		Summary:     "Purge the trash for all users",
+		OperationID: "AdminPurgeTrash",
+	}, handler.DeleteTrash)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodDelete,
+		Path:        "/api/admin/trash/{username}",
+		Middlewares: huma.Middlewares{authProvider.AdminRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Admin"},
+		Summary:     "Purge the trash for a user",
+		OperationID: "AdminPurgeUserTrash",
+	}, handler.DeleteUserTrash)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/api/admin/storage",
+		Middlewares: huma.Middlewares{authProvider.AdminRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Admin"},
+		Summary:     "Get storage usage for all users",
+		OperationID: "AdminGetStorageUsage",
+	}, handler.GetStorageUsage)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/api/admin/user-cache",
+		Middlewares: huma.Middlewares{authProvider.AdminRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Admin"},
+		Summary:     "Get hit rates for the cache of authenticated users",
+		OperationID: "AdminGetUserCacheStats",
+	}, handler.GetUserCacheStats)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/api/admin/audit",
+		Middlewares: huma.Middlewares{authProvider.AdminRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Admin"},
+		Summary:     "Get audit log entries",
+		Description: "Entries are returned oldest first, use afterId with the last id returned to get the next page.",
+		OperationID: "AdminGetAuditEntries",
+	}, handler.GetAuditEntries)
+}
+
+type AdminHandler struct {
+	service      services.AdminService
+	auditService services.AuditService
+	authProvider *middleware.AuthDetailsProvider
+}
+
+type AdminGetUsersOutput struct {
+	Body []core.AdminUser
+}
+
+type AdminUserInput struct {
+	UsernamePath
+}
+
+type AdminPutUserPasswordInput struct {
+	UsernamePath
+	Body core.AdminResetPassword
+}
+
+type AdminPutUsernameInput struct {
+	UsernamePath
+	Body core.AdminRenameUser
+}
+
+type AdminGetStorageUsageOutput struct {
+	Body []core.StorageUsage
+}
+
+type AdminGetUserCacheStatsOutput struct {
+	Body usercache.Stats
+}
+
+type AdminGetAuditEntriesInput struct {
+	AfterId  int64     `query:"afterId" minimum:"0"`
+	Event    string    `query:"event" example:"node.write"`
+	Actor    string    `query:"actor" doc:"Username of the user performing the action"`
+	Username string    `query:"username" doc:"Username of the node owner"`
+	Slug     string    `query:"slug" doc:"Only include entries for this node and its descendants"`
+	Since    time.Time `query:"since" doc:"Only include entries at or after this time"`
+	Until    time.Time `query:"until" doc:"Only include entries before this time"`
+	Limit    int64     `query:"limit" minimum:"1" maximum:"1000" default:"100"`
+}
+
+type AdminGetAuditEntriesOutput struct {
+	Body []core.AuditEntry
+}
+
+func adminErrorToHTTPError(err error) error {
+	if errors.Is(err, services.ErrAdminSelfAction) {
+		return huma.Error422UnprocessableEntity("cannot perform this action on your own account")
+	} else if errors.Is(err, services.ErrUsernameInvalid) {
+		return huma.Error422UnprocessableEntity("invalid username")
+	}
+	return toGenericHTTPError(err)
+}
+
+func (h AdminHandler) GetUsers(
+	ctx context.Context,
+	input *struct{},
+) (*AdminGetUsersOutput, error) {
+	users, err := h.service.GetUsers()
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	return &AdminGetUsersOutput{
+		Body: users,
+	}, nil
+}
+
+func (h AdminHandler) DeleteUser(
+	ctx context.Context,
+	input *AdminUserInput,
+) (*struct{}, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	return nil, adminErrorToHTTPError(h.service.DeleteUser(&authenticatedUser, string(input.Username)))
+}
+
+func (h AdminHandler) PutUserDisabled(
+	ctx context.Context,
+	input *AdminUserInput,
+) (*struct{}, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	return nil, adminErrorToHTTPError(
+		h.service.SetUserDisabled(&authenticatedUser, string(input.Username), true),
+	)
+}
+
+func (h AdminHandler) DeleteUserDisabled(
+	ctx context.Context,
+	input *AdminUserInput,
+) (*struct{}, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	return nil, adminErrorToHTTPError(
+		h.service.SetUserDisabled(&authenticatedUser, string(input.Username), false),
+	)
+}
+
+func (h AdminHandler) PutUserPassword(
+	ctx context.Context,
+	input *AdminPutUserPasswordInput,
+) (*struct{}, error) {
+	return nil, toGenericHTTPError(h.service.ResetUserPassword(string(input.Username), input.Body))
+}
+
+func (h AdminHandler) PutUsername(
+	ctx context.Context,
+	input *AdminPutUsernameInput,
+) (*struct{}, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	err := h.service.RenameUser(&authenticatedUser, input.Username, input.Body)
+	if errors.Is(err, core.ErrConflict) {
+		return nil, huma.Error409Conflict("user with that username already exists")
+	}
+	return nil, adminErrorToHTTPError(err)
+}
+
+func (h AdminHandler) DeleteTreeCache(
+	ctx context.Context,
+	input *struct{},
+) (*struct{}, error) {
+	return nil, toGenericHTTPError(h.service.ClearTreeCache())
+}
+
+func (h AdminHandler) DeleteUserTreeCache(
+	ctx context.Context,
+	input *AdminUserInput,
+) (*struct{}, error) {
+	return nil, toGenericHTTPError(h.service.ClearTreeCacheForUser(input.Username))
+}
+
+func (h AdminHandler) DeleteTrash(
+	ctx context.Context,
+	input *struct{},
+) (*struct{}, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	return nil, toGenericHTTPError(h.service.PurgeTrash(&authenticatedUser))
+}
+
+func (h AdminHandler) DeleteUserTrash(
+	ctx context.Context,
+	input *AdminUserInput,
+) (*struct{}, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	return nil, toGenericHTTPError(h.service.PurgeTrashForUser(&authenticatedUser, input.Username))
+}
+
+func (h AdminHandler) GetStorageUsage(
+	ctx context.Context,
+	input *struct{},
+) (*AdminGetStorageUsageOutput, error) {
+	usages, err := h.service.GetStorageUsage()
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	return &AdminGetStorageUsageOutput{
+		Body: usages,
+	}, nil
+}
+
+func (h AdminHandler) GetUserCacheStats(
+	ctx context.Context,
+	input *struct{},
+) (*AdminGetUserCacheStatsOutput, error) {
+	return &AdminGetUserCacheStatsOutput{
+		Body: h.service.GetUserCacheStats(),
+	}, nil
+}
+
+func (h AdminHandler) GetAuditEntries(
+	ctx context.Context,
+	input *AdminGetAuditEntriesInput,
+) (*AdminGetAuditEntriesOutput, error) {
+	filter := core.AuditFilter{
+		AfterId: input.AfterId,
+		Limit:   input.Limit,
+	}
+	if input.Event != "" {
+		event := core.AuditEvent(input.Event)
+		filter.Event = &event
+	}
+	if input.Actor != "" {
+		filter.Actor = &input.Actor
+	}
+	if input.Username != "" {
+		filter.Username = &input.Username
+	}
+	if input.Slug != "" {
+		filter.Slug = &input.Slug
+	}
+	if !input.Since.IsZero() {
+		filter.Since = &input.Since
+	}
+	if !input.Until.IsZero() {
+		filter.Until = &input.Until
+	}
+	entries, err := h.auditService.GetEntries(filter)
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	return &AdminGetAuditEntriesOutput{
+		Body: entries,
+	}, nil
+}
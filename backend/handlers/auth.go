@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/enchant97/note-mark/backend/config"
 	"github.com/enchant97/note-mark/backend/core"
 	"github.com/enchant97/note-mark/backend/middleware"
+	"github.com/enchant97/note-mark/backend/ratelimit"
 	"github.com/enchant97/note-mark/backend/services"
 )
 
@@ -76,7 +78,8 @@ type GetHasSessionOutput struct {
 }
 
 type RequestAccessTokenInput struct {
-	Body core.AccessTokenRequest
+	Body     core.AccessTokenRequest
+	clientIP string
 }
 
 type SetCookieOutput struct {
@@ -92,16 +95,28 @@ type GetUserInfoOutput struct {
 }
 
 func (m *RequestAccessTokenInput) Resolve(ctx huma.Context) []error {
+	m.clientIP = getClientIP(ctx.RemoteAddr())
 	return middleware.ValidateRequestInput(ctx, m.Body)
 }
 
+func accessTokenErrorToHTTPError(err error) error {
+	var errLocked ratelimit.ErrLocked
+	if errors.As(err, &errLocked) {
+		return lockedToHTTPError(errLocked)
+	}
+	if errors.Is(err, core.ErrTotpRequired) {
+		return huma.Error401Unauthorized("two-factor code required")
+	}
+	return huma.Error401Unauthorized("failed to authenticate")
+}
+
 func (h *AuthHandler) PostSessionStart(
 	ctx context.Context,
 	input *RequestAccessTokenInput,
 ) (*SetCookieOutput, error) {
-	at, err := h.service.CreateAccessToken(input.Body)
+	at, err := h.service.CreateAccessToken(input.Body, input.clientIP, false)
 	if err != nil {
-		return nil, huma.Error401Unauthorized("failed to authenticate")
+		return nil, accessTokenErrorToHTTPError(err)
 	}
 	return &SetCookieOutput{
 		SetCookie: h.authProvider.CreateSessionCookie(at),
@@ -136,9 +151,9 @@ func (h *AuthHandler) PostCreateToken(
 	ctx context.Context,
 	input *RequestAccessTokenInput,
 ) (*PostCreateTokenOutput, error) {
-	at, err := h.service.CreateAccessToken(input.Body)
+	at, err := h.service.CreateAccessToken(input.Body, input.clientIP, true)
 	if err != nil {
-		return nil, huma.Error401Unauthorized("failed to authenticate")
+		return nil, accessTokenErrorToHTTPError(err)
 	}
 	return &PostCreateTokenOutput{
 		Body: at,
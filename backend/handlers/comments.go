@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/middleware"
+	"github.com/enchant97/note-mark/backend/services"
+	"github.com/google/uuid"
+)
+
+func SetupCommentsHandler(
+	api huma.API,
+	service services.CommentsService,
+	shareLinksService services.ShareLinksService,
+	authProvider *middleware.AuthDetailsProvider,
+) {
+	handler := CommentsHandler{
+		service:           service,
+		shareLinksService: shareLinksService,
+		authProvider:      authProvider,
+	}
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/api/comments/u/{username}/*",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Comments"},
+		Summary:     "Get comment threads for a note",
+		Description: "Requires at least comment access to the note, which can be given by a share link.",
+		OperationID: "GetComments",
+	}, handler.GetComments)
+	huma.Register(api, huma.Operation{
+		Method:        http.MethodPost,
+		Path:          "/api/comments/u/{username}/*",
+		DefaultStatus: http.StatusCreated,
+		Middlewares:   huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:      defaultSecurityOp,
+		Tags:          []string{"Comments"},
+		Summary:       "Create a comment or reply on a note",
+		Description:   "Requires at least comment access to the note, which can be given by a share link.",
+		OperationID:   "CreateComment",
+	}, handler.PostCreateComment)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPatch,
+		Path:        "/api/comments/{uid}",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Comments"},
+		Summary:     "Update a comment",
+		OperationID: "UpdateComment",
+	}, handler.PatchComment)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodDelete,
+		Path:        "/api/comments/{uid}",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Comments"},
+		Summary:     "Delete a comment and its replies",
+		OperationID: "DeleteComment",
+	}, handler.DeleteComment)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPut,
+		Path:        "/api/comments/{uid}/resolved",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Comments"},
+		Summary:     "Resolve a comment thread",
+		OperationID: "ResolveComment",
+	}, handler.PutCommentResolved)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodDelete,
+		Path:        "/api/comments/{uid}/resolved",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Comments"},
+		Summary:     "Unresolve a comment thread",
+		OperationID: "UnresolveComment",
+	}, handler.DeleteCommentResolved)
+}
+
+type CommentsHandler struct {
+	service           services.CommentsService
+	shareLinksService services.ShareLinksService
+	authProvider      *middleware.AuthDetailsProvider
+}
+
+type CommentUidPath struct {
+	Uid uuid.UUID `path:"uid"`
+}
+
+type GetCommentsInput struct {
+	UsernamePath
+	SlugPath
+	ShareTokenHeaders
+}
+
+type GetCommentsOutput struct {
+	Body []core.Comment
+}
+
+type PostCreateCommentInput struct {
+	UsernamePath
+	SlugPath
+	ShareTokenHeaders
+	Body core.CreateComment
+}
+
+type PostCreateCommentOutput struct {
+	Body core.Comment
+}
+
+type PatchCommentInput struct {
+	CommentUidPath
+	ShareTokenHeaders
+	Body core.UpdateComment
+}
+
+type CommentInput struct {
+	CommentUidPath
+	ShareTokenHeaders
+}
+
+func getValidatedNoteSlug(slug core.NodeSlug) (core.NodeSlug, error) {
+	sanitizedSlug := core.NodeSlug(path.Clean(string(slug)))
+	if nodeType, err := getValidatedNodeType(string(sanitizedSlug)); err != nil {
+		return "", err
+	} else if nodeType != core.NoteNode {
+		return "", huma.Error422UnprocessableEntity("invalid slug")
+	}
+	return sanitizedSlug, nil
+}
+
+func commentErrorToHTTPError(err error) error {
+	if errors.Is(err, services.ErrCommentInvalid) {
+		return huma.Error422UnprocessableEntity("invalid comment, check anchor and parent")
+	}
+	return toGenericHTTPError(err)
+}
+
+func (h CommentsHandler) GetComments(
+	ctx context.Context,
+	input *GetCommentsInput,
+) (*GetCommentsOutput, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	sanitizedSlug, err := getValidatedNoteSlug(input.Slug)
+	if err != nil {
+		return nil, err
+	}
+	shareGrant, err := resolveShareGrant(h.shareLinksService, input.ShareTokenHeaders)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := h.service.GetComments(&authenticatedUser, shareGrant, input.Username, sanitizedSlug)
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	return &GetCommentsOutput{
+		Body: comments,
+	}, nil
+}
+
+func (h CommentsHandler) PostCreateComment(
+	ctx context.Context,
+	input *PostCreateCommentInput,
+) (*PostCreateCommentOutput, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	sanitizedSlug, err := getValidatedNoteSlug(input.Slug)
+	if err != nil {
+		return nil, err
+	}
+	shareGrant, err := resolveShareGrant(h.shareLinksService, input.ShareTokenHeaders)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := h.service.CreateComment(&authenticatedUser, shareGrant, input.Username, sanitizedSlug, input.Body)
+	if err != nil {
+		return nil, commentErrorToHTTPError(err)
+	}
+	return &PostCreateCommentOutput{
+		Body: comment,
+	}, nil
+}
+
+func (h CommentsHandler) PatchComment(
+	ctx context.Context,
+	input *PatchCommentInput,
+) (*struct{}, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	shareGrant, err := resolveShareGrant(h.shareLinksService, input.ShareTokenHeaders)
+	if err != nil {
+		return nil, err
+	}
+	return nil, toGenericHTTPError(h.service.UpdateComment(&authenticatedUser, shareGrant, input.Uid, input.Body))
+}
+
+func (h CommentsHandler) DeleteComment(
+	ctx context.Context,
+	input *CommentInput,
+) (*struct{}, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	shareGrant, err := resolveShareGrant(h.shareLinksService, input.ShareTokenHeaders)
+	if err != nil {
+		return nil, err
+	}
+	return nil, toGenericHTTPError(h.service.DeleteComment(&authenticatedUser, shareGrant, input.Uid))
+}
+
+func (h CommentsHandler) PutCommentResolved(
+	ctx context.Context,
+	input *CommentInput,
+) (*struct{}, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	shareGrant, err := resolveShareGrant(h.shareLinksService, input.ShareTokenHeaders)
+	if err != nil {
+		return nil, err
+	}
+	return nil, commentErrorToHTTPError(h.service.SetCommentResolved(&authenticatedUser, shareGrant, input.Uid, true))
+}
+
+func (h CommentsHandler) DeleteCommentResolved(
+	ctx context.Context,
+	input *CommentInput,
+) (*struct{}, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	shareGrant, err := resolveShareGrant(h.shareLinksService, input.ShareTokenHeaders)
+	if err != nil {
+		return nil, err
+	}
+	return nil, commentErrorToHTTPError(h.service.SetCommentResolved(&authenticatedUser, shareGrant, input.Uid, false))
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/conditional"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/feed"
+	"github.com/enchant97/note-mark/backend/services"
+)
+
+func SetupFeedHandler(
+	api huma.API,
+	service services.FeedService,
+	publicUrl string,
+) {
+	handler := FeedHandler{
+		service:   service,
+		publicUrl: publicUrl,
+	}
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/api/feed/u/{username}.atom",
+		Tags:        []string{"Feed"},
+		Summary:     "Get Atom feed of recently updated public notes",
+		OperationID: "GetAtomFeed",
+	}, handler.GetAtomFeed)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/api/feed/u/{username}.rss",
+		Tags:        []string{"Feed"},
+		Summary:     "Get RSS feed of recently updated public notes",
+		OperationID: "GetRSSFeed",
+	}, handler.GetRSSFeed)
+}
+
+type FeedHandler struct {
+	service   services.FeedService
+	publicUrl string
+}
+
+type GetFeedInput struct {
+	conditional.Params
+	UsernamePath
+	Folder string `query:"folder" doc:"Only include notes under this slug"`
+	Limit  int    `query:"limit" minimum:"1" maximum:"100" default:"20"`
+}
+
+func (h FeedHandler) GetAtomFeed(
+	ctx context.Context,
+	input *GetFeedInput,
+) (*huma.StreamResponse, error) {
+	return h.getFeed(input, "atom", "application/atom+xml; charset=utf-8", (*feed.Feed).MarshalAtom)
+}
+
+func (h FeedHandler) GetRSSFeed(
+	ctx context.Context,
+	input *GetFeedInput,
+) (*huma.StreamResponse, error) {
+	return h.getFeed(input, "rss", "application/rss+xml; charset=utf-8", (*feed.Feed).MarshalRSS)
+}
+
+func (h FeedHandler) getFeed(
+	input *GetFeedInput,
+	extension string,
+	contentType string,
+	marshal func(*feed.Feed) ([]byte, error),
+) (*huma.StreamResponse, error) {
+	var folder *core.NodeSlug
+	if input.Folder != "" {
+		sanitizedSlug := core.NodeSlug(path.Clean(input.Folder))
+		if !core.IsValidNodeSlug(string(sanitizedSlug), core.NoteNode) {
+			return nil, huma.Error422UnprocessableEntity("invalid folder slug")
+		}
+		folder = &sanitizedSlug
+	}
+	// ETag Creation & ConditionalParams handling
+	modTime, err := h.service.GetFeedModTime(input.Username)
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	etagValue := makePersonalETagValue(nil, modTime)
+	if input.HasConditionalParams() {
+		if err := input.PreconditionFailed(etagValue, modTime.Truncate(time.Second)); err != nil {
+			return nil, err
+		}
+	}
+	selfURL := fmt.Sprintf("%s/api/feed/u/%s.%s", h.publicUrl, input.Username, extension)
+	f, err := h.service.GetPublicFeed(input.Username, folder, input.Limit, selfURL)
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	raw, err := marshal(&f)
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	return &huma.StreamResponse{
+		Body: func(ctx huma.Context) {
+			ctx.SetHeader("Cache-Control", "no-cache, public")
+			ctx.SetHeader("ETag", fmt.Sprintf(`"%s"`, etagValue))
+			ctx.SetHeader("Last-Modified", core.TimeIntoHTTPFormat(modTime))
+			ctx.SetHeader("Content-Type", contentType)
+			ctx.BodyWriter().Write(raw)
+		},
+	}, nil
+}
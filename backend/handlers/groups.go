@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/middleware"
+	"github.com/enchant97/note-mark/backend/services"
+)
+
+func SetupGroupsHandler(
+	api huma.API,
+	service services.GroupsService,
+	authProvider *middleware.AuthDetailsProvider,
+) {
+	handler := GroupsHandler{
+		service:      service,
+		authProvider: authProvider,
+	}
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/api/groups",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Groups"},
+		Summary:     "Get groups the current user owns or is a member of",
+		OperationID: "GetGroups",
+	}, handler.GetGroups)
+	huma.Register(api, huma.Operation{
+		Method:        http.MethodPost,
+		Path:          "/api/groups",
+		DefaultStatus: http.StatusCreated,
+		Middlewares:   huma.Middlewares{authProvider.AdminRequiredMiddleware},
+		Security:      defaultSecurityOp,
+		Tags:          []string{"Groups"},
+		Summary:       "Create a group",
+		Description:   "Only admins can create groups, as notes give access to groups by name.",
+		OperationID:   "CreateGroup",
+	}, handler.PostCreateGroup)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodDelete,
+		Path:        "/api/groups/{groupName}",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Groups"},
+		Summary:     "Delete a group",
+		OperationID: "DeleteGroup",
+	}, handler.DeleteGroup)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/api/groups/{groupName}/members",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Groups"},
+		Summary:     "Get members of a group",
+		OperationID: "GetGroupMembers",
+	}, handler.GetGroupMembers)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPut,
+		Path:        "/api/groups/{groupName}/members/{username}",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Groups"},
+		Summary:     "Add a member to a group",
+		OperationID: "AddGroupMember",
+	}, handler.PutGroupMember)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodDelete,
+		Path:        "/api/groups/{groupName}/members/{username}",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Groups"},
+		Summary:     "Remove a member from a group",
+		OperationID: "RemoveGroupMember",
+	}, handler.DeleteGroupMember)
+}
+
+type GroupsHandler struct {
+	service      services.GroupsService
+	authProvider *middleware.AuthDetailsProvider
+}
+
+type GroupNamePath struct {
+	GroupName core.GroupName `path:"groupName" validate:"group_name"`
+}
+
+func (m *GroupNamePath) Resolve(ctx huma.Context) []error {
+	return middleware.ValidateRequestInput(ctx, m)
+}
+
+type GetGroupsOutput struct {
+	Body []core.Group
+}
+
+type PostCreateGroupInput struct {
+	Body core.CreateGroup
+}
+
+type PostCreateGroupOutput struct {
+	Body core.Group
+}
+
+type DeleteGroupInput struct {
+	GroupNamePath
+}
+
+type GetGroupMembersInput struct {
+	GroupNamePath
+}
+
+type GetGroupMembersOutput struct {
+	Body []string
+}
+
+type GroupMemberInput struct {
+	GroupNamePath
+	UsernamePath
+}
+
+func (h GroupsHandler) GetGroups(
+	ctx context.Context,
+	input *struct{},
+) (*GetGroupsOutput, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	groups, err := h.service.GetGroupsForUser(&authenticatedUser)
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	return &GetGroupsOutput{
+		Body: groups,
+	}, nil
+}
+
+func (h GroupsHandler) PostCreateGroup(
+	ctx context.Context,
+	input *PostCreateGroupInput,
+) (*PostCreateGroupOutput, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	group, err := h.service.CreateGroup(&authenticatedUser, input.Body)
+	if err != nil {
+		if errors.Is(err, core.ErrConflict) {
+			return nil, huma.Error409Conflict("group with that name already exists")
+		}
+		return nil, toGenericHTTPError(err)
+	}
+	return &PostCreateGroupOutput{
+		Body: group,
+	}, nil
+}
+
+func (h GroupsHandler) DeleteGroup(
+	ctx context.Context,
+	input *DeleteGroupInput,
+) (*struct{}, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	return nil, toGenericHTTPError(h.service.DeleteGroup(&authenticatedUser, input.GroupName))
+}
+
+func (h GroupsHandler) GetGroupMembers(
+	ctx context.Context,
+	input *GetGroupMembersInput,
+) (*GetGroupMembersOutput, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	members, err := h.service.GetGroupMembers(&authenticatedUser, input.GroupName)
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	return &GetGroupMembersOutput{
+		Body: members,
+	}, nil
+}
+
+func (h GroupsHandler) PutGroupMember(
+	ctx context.Context,
+	input *GroupMemberInput,
+) (*struct{}, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	err := h.service.AddGroupMember(&authenticatedUser, input.GroupName, input.Username)
+	if errors.Is(err, core.ErrConflict) {
+		// already a member
+		return nil, nil
+	}
+	return nil, toGenericHTTPError(err)
+}
+
+func (h GroupsHandler) DeleteGroupMember(
+	ctx context.Context,
+	input *GroupMemberInput,
+) (*struct{}, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	return nil, toGenericHTTPError(
+		h.service.RemoveGroupMember(&authenticatedUser, input.GroupName, input.Username),
+	)
+}
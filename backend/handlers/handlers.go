@@ -11,10 +11,14 @@ import (
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/danielgtaylor/huma/v2/adapters/humachi"
 	"github.com/enchant97/note-mark/backend/config"
+	"github.com/enchant97/note-mark/backend/core"
 	"github.com/enchant97/note-mark/backend/db"
+	"github.com/enchant97/note-mark/backend/importer"
 	core_middleware "github.com/enchant97/note-mark/backend/middleware"
 	"github.com/enchant97/note-mark/backend/services"
+	"github.com/enchant97/note-mark/backend/signingkeys"
 	"github.com/enchant97/note-mark/backend/tree"
+	"github.com/enchant97/note-mark/backend/usercache"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -29,6 +33,7 @@ func SetupHandlers(
 	validate *validator.Validate,
 	appConfig config.AppConfig,
 	dao *db.DAO,
+	keyring *signingkeys.Keyring,
 	tc *tree.TreeController,
 ) (http.Handler, error) {
 	mux := chi.NewRouter()
@@ -49,6 +54,9 @@ func SetupHandlers(
 		//	return respStatus == http.StatusNotFound || respStatus == http.StatusMethodNotAllowed
 		//},
 	}))
+	if appConfig.TrustProxyHeaders {
+		mux.Use(middleware.RealIP)
+	}
 	mux.Use(middleware.Heartbeat("/heartbeat"))
 	mux.Use(cors.Handler(cors.Options{
 		OptionsPassthrough: false,
@@ -73,27 +81,85 @@ func SetupHandlers(
 	}
 	api := humachi.New(mux, config)
 	validatorProvider := core_middleware.ValidatorMiddleware{}.New(validate)
+	userCache := usercache.Cache{}.New(usercache.Options{
+		Size: int(appConfig.UserCache.Size),
+		TTL:  appConfig.UserCache.TTL,
+	})
 	authProvider := core_middleware.AuthDetailsProvider{}.New(
 		api,
 		dao,
-		appConfig.AuthToken.Secret,
+		keyring,
+		&userCache,
 		strings.HasPrefix(appConfig.PublicUrl, "https://"),
 	)
-	api.UseMiddleware(validatorProvider.Provider)
-	api.UseMiddleware(authProvider.ProviderMiddleware)
-	SetupMiscHandler(api, appConfig)
-	SetupAuthHandler(api, services.AuthService{}.New(appConfig, dao, tc), appConfig, &authProvider)
-	SetupUsersHandler(api, services.UsersService{}.New(
+	auditService := services.AuditService{}.New(dao)
+	treeService := services.TreeService{}.New(
+		dao,
+		tc,
+		&auditService,
+	)
+	invitesService := services.InvitesService{}.New(dao, tc, &treeService)
+	passwordPolicy := core.PasswordPolicy{
+		MinLength:    int(appConfig.PasswordPolicy.MinLength),
+		RejectCommon: appConfig.PasswordPolicy.RejectCommon,
+	}
+	usersService := services.UsersService{}.New(
 		dao,
 		tc,
+		&invitesService,
+		&userCache,
+		passwordPolicy,
 		appConfig.EnableInternalSignup,
+		appConfig.InviteOnlySignup,
 		appConfig.EnableInternalLogin,
 		appConfig.EnableAnonymousUserSearch,
-	), appConfig, &authProvider)
-	SetupTreeHandler(api, services.TreeService{}.New(
+	)
+	usernameRedirectProvider := core_middleware.UsernameRedirectMiddleware{}.New(usersService.GetUsernameRedirect)
+	api.UseMiddleware(validatorProvider.Provider)
+	api.UseMiddleware(usernameRedirectProvider.Provider)
+	api.UseMiddleware(authProvider.ProviderMiddleware)
+	SetupMiscHandler(api, appConfig, keyring)
+	totpService := services.TotpService{}.New(dao, &auditService)
+	loginLimiter := services.NewLoginLimiter(appConfig, dao)
+	authService := services.AuthService{}.New(appConfig, dao, keyring, tc, &auditService, &totpService, &userCache, loginLimiter)
+	SetupAuthHandler(api, authService, appConfig, &authProvider)
+	SetupOidcHandler(api, authService, &authProvider)
+	SetupTotpHandler(api, totpService, &authProvider)
+	SetupWebAuthnHandler(
+		api,
+		services.WebAuthnService{}.New(appConfig, dao, &authService, &auditService),
+		&authProvider,
+	)
+	SetupUsersHandler(api, usersService, appConfig, &authProvider)
+	SetupInvitesHandler(api, invitesService, &authProvider)
+	SetupGroupsHandler(api, services.GroupsService{}.New(dao), &authProvider)
+	SetupAdminHandler(api, services.AdminService{}.New(dao, tc, &auditService, &userCache, passwordPolicy), auditService, &authProvider)
+	shareLinksService := services.ShareLinksService{}.New(dao, tc, keyring, loginLimiter)
+	SetupTreeHandler(api, treeService, shareLinksService, int64(appConfig.FileSizeLimit), &authProvider)
+	SetupShareLinksHandler(api, shareLinksService, &authProvider)
+	SetupCommentsHandler(api, services.CommentsService{}.New(dao, &treeService), shareLinksService, &authProvider)
+	renderService := services.RenderService{}.New(tc, appConfig.PublicUrl+"/api")
+	SetupRenderHandler(api, renderService, treeService, &authProvider)
+	SetupFeedHandler(api, services.FeedService{}.New(
+		tc,
+		&renderService,
+		appConfig.PublicUrl,
+	), appConfig.PublicUrl)
+	SetupJournalHandler(api, services.JournalService{}.New(
 		dao,
 		tc,
-	), int64(appConfig.FileSizeLimit), &authProvider)
+		&treeService,
+		appConfig.Journal.SlugPattern,
+	), &authProvider)
+	SetupImportHandler(api, services.ImportService{}.New(
+		tc,
+		&treeService,
+		importer.Limits{
+			MaxFileSize:  int64(appConfig.FileSizeLimit),
+			MaxFiles:     appConfig.ImportMaxFiles,
+			MaxTotalSize: int64(appConfig.ImportExtractedSizeLimit),
+		},
+	), int64(appConfig.ImportSizeLimit), &authProvider)
 	if len(appConfig.StaticPath) != 0 {
 		if _, err := os.Stat(appConfig.StaticPath); errors.Is(err, os.ErrNotExist) {
 			return nil, err
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/importer"
+	"github.com/enchant97/note-mark/backend/middleware"
+	"github.com/enchant97/note-mark/backend/services"
+)
+
+func SetupImportHandler(
+	api huma.API,
+	service services.ImportService,
+	importSizeLimitBytes int64,
+	authProvider *middleware.AuthDetailsProvider,
+) {
+	handler := ImportHandler{
+		service:      service,
+		authProvider: authProvider,
+	}
+	huma.Register(api, huma.Operation{
+		Method:       http.MethodPost,
+		Path:         "/api/import/obsidian/u/{username}",
+		Middlewares:  huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		MaxBodyBytes: importSizeLimitBytes,
+		Security:     defaultSecurityOp,
+		Tags:         []string{"Import"},
+		Summary:      "Import an Obsidian vault from a zip archive",
+		OperationID:  "ImportObsidianVault",
+	}, handler.PostObsidianVault)
+}
+
+type ImportHandler struct {
+	service      services.ImportService
+	authProvider *middleware.AuthDetailsProvider
+}
+
+type PostObsidianVaultInput struct {
+	UsernamePath
+	Slug    string `query:"slug" doc:"Slug of a note to import into, defaults to the root"`
+	RawBody []byte `contentType:"application/zip"`
+}
+
+type PostObsidianVaultOutput struct {
+	Body core.ImportReport
+}
+
+func (h ImportHandler) PostObsidianVault(
+	ctx context.Context,
+	input *PostObsidianVaultInput,
+) (*PostObsidianVaultOutput, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	var parentSlug core.NodeSlug
+	if input.Slug != "" {
+		parentSlug = core.NodeSlug(path.Clean(input.Slug))
+		if !core.IsValidNodeSlug(string(parentSlug), core.NoteNode) {
+			return nil, huma.Error422UnprocessableEntity("invalid slug")
+		}
+	}
+	report, err := h.service.ImportObsidianArchive(
+		&authenticatedUser,
+		input.Username,
+		parentSlug,
+		input.RawBody,
+	)
+	if err != nil {
+		if errors.Is(err, services.ErrImportArchiveInvalid) {
+			return nil, huma.Error422UnprocessableEntity("invalid zip archive")
+		} else if errors.Is(err, importer.ErrVaultTooLarge) {
+			return nil, huma.Error413RequestEntityTooLarge("vault has too many files or is too large")
+		}
+		return nil, toGenericHTTPError(err)
+	}
+	return &PostObsidianVaultOutput{
+		Body: report,
+	}, nil
+}
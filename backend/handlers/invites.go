@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/middleware"
+	"github.com/enchant97/note-mark/backend/services"
+	"github.com/google/uuid"
+)
+
+func SetupInvitesHandler(
+	api huma.API,
+	service services.InvitesService,
+	authProvider *middleware.AuthDetailsProvider,
+) {
+	handler := InvitesHandler{
+		service:      service,
+		authProvider: authProvider,
+	}
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/api/invites",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Invites"},
+		Summary:     "Get invites created by the current user",
+		Description: "Administrators will get every invite.",
+		OperationID: "GetInvites",
+	}, handler.GetInvites)
+	huma.Register(api, huma.Operation{
+		Method:        http.MethodPost,
+		Path:          "/api/invites",
+		DefaultStatus: http.StatusCreated,
+		Middlewares:   huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:      defaultSecurityOp,
+		Tags:          []string{"Invites"},
+		Summary:       "Create an invite",
+		Description:   "The returned code will not be shown again.",
+		OperationID:   "CreateInvite",
+	}, handler.PostCreateInvite)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodDelete,
+		Path:        "/api/invites/{uid}",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Invites"},
+		Summary:     "Revoke an invite",
+		OperationID: "DeleteInvite",
+	}, handler.DeleteInvite)
+}
+
+type InvitesHandler struct {
+	service      services.InvitesService
+	authProvider *middleware.AuthDetailsProvider
+}
+
+type GetInvitesOutput struct {
+	Body []core.Invite
+}
+
+type PostCreateInviteInput struct {
+	Body core.CreateInvite
+}
+
+func (m *PostCreateInviteInput) Resolve(ctx huma.Context) []error {
+	return middleware.ValidateRequestInput(ctx, m)
+}
+
+type PostCreateInviteOutput struct {
+	Body core.CreatedInvite
+}
+
+type DeleteInviteInput struct {
+	Uid uuid.UUID `path:"uid"`
+}
+
+func (h InvitesHandler) GetInvites(
+	ctx context.Context,
+	input *struct{},
+) (*GetInvitesOutput, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	var invites []core.Invite
+	var err error
+	if authenticatedUser.IsAdmin {
+		invites, err = h.service.GetAllInvites()
+	} else {
+		invites, err = h.service.GetInvites(&authenticatedUser)
+	}
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	return &GetInvitesOutput{
+		Body: invites,
+	}, nil
+}
+
+func (h InvitesHandler) PostCreateInvite(
+	ctx context.Context,
+	input *PostCreateInviteInput,
+) (*PostCreateInviteOutput, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	invite, err := h.service.CreateInvite(&authenticatedUser, input.Body)
+	if err != nil {
+		if errors.Is(err, services.ErrInviteExpiryInvalid) {
+			return nil, huma.Error422UnprocessableEntity("expiry must be in the future")
+		}
+		return nil, toGenericHTTPError(err)
+	}
+	return &PostCreateInviteOutput{
+		Body: invite,
+	}, nil
+}
+
+func (h InvitesHandler) DeleteInvite(
+	ctx context.Context,
+	input *DeleteInviteInput,
+) (*struct{}, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	return nil, toGenericHTTPError(h.service.DeleteInvite(&authenticatedUser, input.Uid))
+}
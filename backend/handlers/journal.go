@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/middleware"
+	"github.com/enchant97/note-mark/backend/services"
+)
+
+func SetupJournalHandler(
+	api huma.API,
+	service services.JournalService,
+	authProvider *middleware.AuthDetailsProvider,
+) {
+	handler := JournalHandler{
+		service:      service,
+		authProvider: authProvider,
+	}
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPost,
+		Path:        "/api/journal/u/{username}/today",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Journal"},
+		Summary:     "Get or create today's journal entry",
+		OperationID: "GetOrCreateTodayJournalEntry",
+	}, handler.PostTodayEntry)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/api/journal/u/{username}",
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Journal"},
+		Summary:     "List journal entries by date range",
+		OperationID: "GetJournalEntries",
+	}, handler.GetEntries)
+}
+
+type JournalHandler struct {
+	service      services.JournalService
+	authProvider *middleware.AuthDetailsProvider
+}
+
+type PostTodayEntryInput struct {
+	UsernamePath
+	Template string `query:"template" doc:"Slug of a note to use as a template"`
+}
+
+type PostTodayEntryOutput struct {
+	Status int
+	Body   core.JournalEntry
+}
+
+type GetEntriesInput struct {
+	UsernamePath
+	From string `query:"from" doc:"Start date (inclusive), defaults to 30 days ago" example:"2026-10-01"`
+	To   string `query:"to" doc:"End date (inclusive), defaults to today" example:"2026-10-18"`
+}
+
+type GetEntriesOutput struct {
+	Body []core.JournalEntry
+}
+
+func (h JournalHandler) PostTodayEntry(
+	ctx context.Context,
+	input *PostTodayEntryInput,
+) (*PostTodayEntryOutput, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	var templateSlug *core.NodeSlug
+	if input.Template != "" {
+		sanitizedSlug := core.NodeSlug(path.Clean(input.Template))
+		if !core.IsValidNodeSlug(string(sanitizedSlug), core.NoteNode) {
+			return nil, huma.Error422UnprocessableEntity("invalid template slug")
+		}
+		templateSlug = &sanitizedSlug
+	}
+	entry, created, err := h.service.GetOrCreateTodayEntry(
+		&authenticatedUser,
+		input.Username,
+		templateSlug,
+	)
+	if err != nil {
+		if errors.Is(err, core.ErrSlugInvalid) {
+			return nil, huma.Error500InternalServerError("journal slug pattern is misconfigured")
+		}
+		return nil, toGenericHTTPError(err)
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	return &PostTodayEntryOutput{
+		Status: status,
+		Body:   entry,
+	}, nil
+}
+
+func (h JournalHandler) GetEntries(
+	ctx context.Context,
+	input *GetEntriesInput,
+) (*GetEntriesOutput, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	optionalAuthUser := authDetails.GetOptionalAuthenticatedUser()
+	today, err := h.service.GetTodayForUser(input.Username)
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	to := today
+	if input.To != "" {
+		if to, err = time.ParseInLocation(core.JournalDateFormat, input.To, today.Location()); err != nil {
+			return nil, huma.Error422UnprocessableEntity("invalid 'to' date")
+		}
+	}
+	from := to.AddDate(0, 0, -30)
+	if input.From != "" {
+		if from, err = time.ParseInLocation(core.JournalDateFormat, input.From, today.Location()); err != nil {
+			return nil, huma.Error422UnprocessableEntity("invalid 'from' date")
+		}
+	}
+	entries, err := h.service.GetEntriesForRange(optionalAuthUser, input.Username, from, to)
+	if err != nil {
+		if errors.Is(err, services.ErrJournalRangeInvalid) {
+			return nil, huma.Error422UnprocessableEntity("invalid date range")
+		} else if errors.Is(err, core.ErrSlugInvalid) {
+			return nil, huma.Error500InternalServerError("journal slug pattern is misconfigured")
+		}
+		return nil, toGenericHTTPError(err)
+	}
+	return &GetEntriesOutput{
+		Body: entries,
+	}, nil
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"time"
 
@@ -9,11 +10,13 @@ import (
 	"github.com/danielgtaylor/huma/v2/conditional"
 	"github.com/enchant97/note-mark/backend/config"
 	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/signingkeys"
 )
 
-func SetupMiscHandler(api huma.API, appConfig config.AppConfig) {
+func SetupMiscHandler(api huma.API, appConfig config.AppConfig, keyring *signingkeys.Keyring) {
 	miscHandler := MiscHandler{
 		AppConfig: appConfig,
+		keyring:   keyring,
 		setupTime: time.Now().UTC(),
 	}
 	huma.Register(api, huma.Operation{
@@ -23,6 +26,13 @@ func SetupMiscHandler(api huma.API, appConfig config.AppConfig) {
 		Summary:     "Get api server info",
 		OperationID: "GetServerInfo",
 	}, miscHandler.GetServerInfo)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/.well-known/jwks.json",
+		Tags:        []string{"Miscellaneous"},
+		Summary:     "Get the public keys access tokens are signed with",
+		OperationID: "GetJWKS",
+	}, miscHandler.GetJWKS)
 }
 
 type GetServerInfoOutput struct {
@@ -33,6 +43,7 @@ type GetServerInfoOutput struct {
 
 type MiscHandler struct {
 	AppConfig config.AppConfig
+	keyring   *signingkeys.Keyring
 	setupTime time.Time
 }
 
@@ -45,23 +56,47 @@ func (h MiscHandler) GetServerInfo(
 			return nil, err
 		}
 	}
-	var oidcProvider *core.OidcProviderInfo
-	if h.AppConfig.OIDC != nil {
-		oidcProvider = &core.OidcProviderInfo{
-			DisplayName: h.AppConfig.OIDC.DisplayName,
-			IssuerURL:   h.AppConfig.OIDC.IssuerUrl,
-			ClientID:    h.AppConfig.OIDC.ClientID,
+	oidcProviders := make([]core.OidcProviderInfo, len(h.AppConfig.OidcProviders))
+	for i, provider := range h.AppConfig.OidcProviders {
+		oidcProviders[i] = core.OidcProviderInfo{
+			ProviderName: provider.ProviderName,
+			DisplayName:  provider.DisplayName,
+			IssuerURL:    provider.IssuerUrl,
+			ClientID:     provider.ClientID,
 		}
 	}
+	var oidcProvider *core.OidcProviderInfo
+	if len(oidcProviders) != 0 {
+		oidcProvider = &oidcProviders[0]
+	}
 	return &GetServerInfoOutput{
 		Body: core.ServerInfo{
 			MinSupportedVersion:       "1.0.0",
 			AllowInternalSignup:       h.AppConfig.EnableInternalSignup,
+			InviteOnlySignup:          h.AppConfig.EnableInternalSignup && h.AppConfig.InviteOnlySignup,
+			PasswordMinLength:         h.AppConfig.PasswordPolicy.MinLength,
 			AllowInternalLogin:        h.AppConfig.EnableInternalLogin,
+			AllowWebAuthnLogin:        h.AppConfig.EnableInternalLogin && h.AppConfig.WebAuthn.Enable,
 			EnableAnonymousUserSearch: h.AppConfig.EnableAnonymousUserSearch,
 			OidcProvider:              oidcProvider,
+			OidcProviders:             oidcProviders,
 		},
 		CacheControl: "no-cache, public",
 		LastModified: h.setupTime,
 	}, nil
 }
+
+// Written as a plain JWK set, as some clients reject unknown members such as `$schema`.
+func (h MiscHandler) GetJWKS(ctx context.Context, input *struct{}) (*huma.StreamResponse, error) {
+	raw, err := json.Marshal(h.keyring.JSONWebKeySet())
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	return &huma.StreamResponse{
+		Body: func(ctx huma.Context) {
+			ctx.SetHeader("Cache-Control", "max-age=300, public")
+			ctx.SetHeader("Content-Type", "application/jwk-set+json")
+			ctx.BodyWriter().Write(raw)
+		},
+	}, nil
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/middleware"
+	"github.com/enchant97/note-mark/backend/oidclogin"
+	"github.com/enchant97/note-mark/backend/ratelimit"
+	"github.com/enchant97/note-mark/backend/services"
+)
+
+// Frontend page to send the user to when a login fails.
+const oidcLoginFailedPath = "/auth/login?error=oidc"
+
+func SetupOidcHandler(
+	api huma.API,
+	service services.AuthService,
+	authProvider *middleware.AuthDetailsProvider,
+) {
+	handler := OidcHandler{
+		service:      service,
+		authProvider: authProvider,
+	}
+	huma.Register(api, huma.Operation{
+		Method:        http.MethodGet,
+		Path:          "/api/auth/oidc/login",
+		DefaultStatus: http.StatusFound,
+		Tags:          []string{"Authentication"},
+		Summary:       "Start an OIDC login",
+		Description:   "Redirects to the provider, which will send the user back to the callback.",
+		OperationID:   "StartOidcLogin",
+	}, handler.GetLogin)
+	huma.Register(api, huma.Operation{
+		Method:        http.MethodGet,
+		Path:          services.OidcCallbackPath,
+		DefaultStatus: http.StatusFound,
+		Tags:          []string{"Authentication"},
+		Summary:       "Finish an OIDC login, starting an auth session",
+		OperationID:   "FinishOidcLogin",
+	}, handler.GetCallback)
+}
+
+type OidcHandler struct {
+	service      services.AuthService
+	authProvider *middleware.AuthDetailsProvider
+}
+
+type GetOidcLoginInput struct {
+	Provider string `query:"provider" required:"true" doc:"Provider name"`
+	Redirect string `query:"redirect" doc:"Path to send the user to once logged in"`
+	clientIP string
+}
+
+func (m *GetOidcLoginInput) Resolve(ctx huma.Context) []error {
+	m.clientIP = getClientIP(ctx.RemoteAddr())
+	return nil
+}
+
+type GetOidcLoginOutput struct {
+	Location  string      `header:"Location"`
+	SetCookie http.Cookie `header:"Set-Cookie"`
+}
+
+type GetOidcCallbackInput struct {
+	Code       string `query:"code"`
+	State      string `query:"state"`
+	Error      string `query:"error" doc:"Set by the provider when the login was not completed"`
+	StateCheck string `cookie:"Oidc-State"`
+	clientIP   string
+}
+
+func (m *GetOidcCallbackInput) Resolve(ctx huma.Context) []error {
+	m.clientIP = getClientIP(ctx.RemoteAddr())
+	return nil
+}
+
+type GetOidcCallbackOutput struct {
+	Location  string        `header:"Location"`
+	SetCookie []http.Cookie `header:"Set-Cookie"`
+}
+
+func (h OidcHandler) GetLogin(
+	ctx context.Context,
+	input *GetOidcLoginInput,
+) (*GetOidcLoginOutput, error) {
+	redirect := "/"
+	if core.IsLocalRedirectPath(input.Redirect) {
+		redirect = input.Redirect
+	}
+	state, authURL, err := h.service.BeginOidcLogin(input.Provider, redirect, input.clientIP)
+	if err != nil {
+		var errLocked ratelimit.ErrLocked
+		if errors.As(err, &errLocked) {
+			return nil, lockedToHTTPError(errLocked)
+		} else if errors.Is(err, oidclogin.ErrTooManyPending) {
+			return nil, huma.Error503ServiceUnavailable("too many oidc logins in progress, try again later")
+		}
+		return nil, toGenericHTTPError(err)
+	}
+	return &GetOidcLoginOutput{
+		Location:  authURL,
+		SetCookie: h.authProvider.CreateOidcStateCookie(state, oidclogin.StateTimeout),
+	}, nil
+}
+
+// Failures redirect back to the login page, as the user arrives here from the provider.
+func (h OidcHandler) GetCallback(
+	ctx context.Context,
+	input *GetOidcCallbackInput,
+) (*GetOidcCallbackOutput, error) {
+	clearStateCookie := h.authProvider.CreateClearOidcStateCookie()
+	failed := &GetOidcCallbackOutput{
+		Location:  oidcLoginFailedPath,
+		SetCookie: []http.Cookie{clearStateCookie},
+	}
+	if input.Error != "" {
+		slog.Debug("oidc provider returned an error", "error", input.Error)
+		return failed, nil
+	}
+	// state must come from the same browser that started the login
+	if input.State == "" || input.State != input.StateCheck {
+		return failed, nil
+	}
+	at, redirect, err := h.service.FinishOidcLogin(input.State, input.Code, input.clientIP)
+	if err != nil {
+		if errors.Is(err, core.ErrFeatureDisabled) {
+			return nil, toGenericHTTPError(err)
+		}
+		slog.Warn("oidc login failed", "err", err)
+		failed.Location += "&reason=" + url.QueryEscape(oidcLoginFailureReason(err))
+		return failed, nil
+	}
+	return &GetOidcCallbackOutput{
+		Location:  redirect,
+		SetCookie: []http.Cookie{clearStateCookie, h.authProvider.CreateSessionCookie(at)},
+	}, nil
+}
+
+func oidcLoginFailureReason(err error) string {
+	if errors.Is(err, oidclogin.ErrStateInvalid) {
+		return "expired"
+	} else if errors.Is(err, core.ErrConflict) {
+		return "conflict"
+	} else if errors.Is(err, core.ErrInvalidCredentials) {
+		return "denied"
+	}
+	return "failed"
+}
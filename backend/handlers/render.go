@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/conditional"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/middleware"
+	"github.com/enchant97/note-mark/backend/services"
+)
+
+func SetupRenderHandler(
+	api huma.API,
+	service services.RenderService,
+	treeService services.TreeService,
+	authProvider *middleware.AuthDetailsProvider,
+) {
+	handler := RenderHandler{
+		service:      service,
+		treeService:  treeService,
+		authProvider: authProvider,
+	}
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/api/render/u/{username}/*",
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Node Tree"},
+		Summary:     "Get note rendered as HTML by slug",
+		OperationID: "GetRenderedNoteBySlug",
+	}, handler.GetRenderedNote)
+}
+
+type RenderHandler struct {
+	service      services.RenderService
+	treeService  services.TreeService
+	authProvider *middleware.AuthDetailsProvider
+}
+
+type GetRenderedNoteInput struct {
+	conditional.Params
+	UsernamePath
+	SlugPath
+}
+
+func (h RenderHandler) GetRenderedNote(
+	ctx context.Context,
+	input *GetRenderedNoteInput,
+) (*huma.StreamResponse, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	optionalAuthUser := authDetails.GetOptionalAuthenticatedUser()
+	sanitizedSlug := core.NodeSlug(path.Clean(string(input.Slug)))
+	if nodeType, err := getValidatedNodeType(string(sanitizedSlug)); err != nil {
+		return nil, toGenericHTTPError(err)
+	} else if nodeType != core.NoteNode {
+		return nil, huma.Error422UnprocessableEntity("invalid slug")
+	}
+	// check if has permission
+	if accessMode, err := h.treeService.GetAvailableNodeAccessControlMode(
+		optionalAuthUser,
+		input.Username,
+		sanitizedSlug,
+		false,
+	); err != nil {
+		return nil, toGenericHTTPError(err)
+	} else if accessMode == nil {
+		return nil, huma.Error404NotFound("not found, or you don't have permission")
+	}
+	// ETag handling
+	nodeModTime, err := h.treeService.GetNodeModTime(input.Username, sanitizedSlug)
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	etagValue := makePersonalETagValue(optionalAuthUser, nodeModTime)
+	if input.HasConditionalParams() {
+		if err := input.PreconditionFailed(etagValue, nodeModTime); err != nil {
+			return nil, err
+		}
+	}
+	html, _, err := h.service.RenderNoteNode(input.Username, sanitizedSlug)
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	return &huma.StreamResponse{
+		Body: func(ctx huma.Context) {
+			ctx.SetHeader("X-Content-Type-Options", "nosniff")
+			ctx.SetHeader("Content-Security-Policy", "sandbox; default-src 'none'; img-src * data:; style-src 'unsafe-inline'")
+			ctx.SetHeader("ETag", fmt.Sprintf(`"%s"`, etagValue))
+			ctx.SetHeader("Content-Type", "text/html; charset=utf-8")
+			ctx.BodyWriter().Write(html)
+		},
+	}, nil
+}
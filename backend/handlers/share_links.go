@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/middleware"
+	"github.com/enchant97/note-mark/backend/ratelimit"
+	"github.com/enchant97/note-mark/backend/services"
+	"github.com/google/uuid"
+)
+
+func SetupShareLinksHandler(
+	api huma.API,
+	service services.ShareLinksService,
+	authProvider *middleware.AuthDetailsProvider,
+) {
+	handler := ShareLinksHandler{
+		service:      service,
+		authProvider: authProvider,
+	}
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/api/share-links",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Share Links"},
+		Summary:     "Get share links created by the current user",
+		OperationID: "GetShareLinks",
+	}, handler.GetShareLinks)
+	huma.Register(api, huma.Operation{
+		Method:        http.MethodPost,
+		Path:          "/api/share-links",
+		DefaultStatus: http.StatusCreated,
+		Middlewares:   huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:      defaultSecurityOp,
+		Tags:          []string{"Share Links"},
+		Summary:       "Create a share link",
+		Description:   "The returned token will not be shown again.",
+		OperationID:   "CreateShareLink",
+	}, handler.PostCreateShareLink)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPost,
+		Path:        "/api/share-links/unlock",
+		Tags:        []string{"Share Links"},
+		Summary:     "Unlock a password protected share link",
+		Description: "Gives a short-lived grant, to send as X-Share-Grant along with the share token.",
+		OperationID: "UnlockShareLink",
+	}, handler.PostUnlockShareLink)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodDelete,
+		Path:        "/api/share-links/{uid}",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Share Links"},
+		Summary:     "Revoke a share link",
+		OperationID: "DeleteShareLink",
+	}, handler.DeleteShareLink)
+}
+
+type ShareLinksHandler struct {
+	service      services.ShareLinksService
+	authProvider *middleware.AuthDetailsProvider
+}
+
+type GetShareLinksOutput struct {
+	Body []core.ShareLink
+}
+
+type PostCreateShareLinkInput struct {
+	Body core.CreateShareLink
+}
+
+func (m *PostCreateShareLinkInput) Resolve(ctx huma.Context) []error {
+	return middleware.ValidateRequestInput(ctx, m)
+}
+
+type PostCreateShareLinkOutput struct {
+	Body core.CreatedShareLink
+}
+
+type PostUnlockShareLinkInput struct {
+	ShareToken string `header:"X-Share-Token" required:"true" doc:"Token of the share link to unlock"`
+	Body       core.UnlockShareLink
+	clientIP   string
+}
+
+func (m *PostUnlockShareLinkInput) Resolve(ctx huma.Context) []error {
+	m.clientIP = getClientIP(ctx.RemoteAddr())
+	return nil
+}
+
+type PostUnlockShareLinkOutput struct {
+	Body core.ShareUnlock
+}
+
+type DeleteShareLinkInput struct {
+	Uid uuid.UUID `path:"uid"`
+}
+
+func (h ShareLinksHandler) GetShareLinks(
+	ctx context.Context,
+	input *struct{},
+) (*GetShareLinksOutput, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	links, err := h.service.GetShareLinks(&authenticatedUser)
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	return &GetShareLinksOutput{
+		Body: links,
+	}, nil
+}
+
+func (h ShareLinksHandler) PostCreateShareLink(
+	ctx context.Context,
+	input *PostCreateShareLinkInput,
+) (*PostCreateShareLinkOutput, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	link, err := h.service.CreateShareLink(&authenticatedUser, input.Body)
+	if err != nil {
+		if errors.Is(err, services.ErrShareLinkExpiryInvalid) {
+			return nil, huma.Error422UnprocessableEntity("expiry must be in the future")
+		}
+		return nil, toGenericHTTPError(err)
+	}
+	return &PostCreateShareLinkOutput{
+		Body: link,
+	}, nil
+}
+
+func (h ShareLinksHandler) PostUnlockShareLink(
+	ctx context.Context,
+	input *PostUnlockShareLinkInput,
+) (*PostUnlockShareLinkOutput, error) {
+	unlock, err := h.service.UnlockShareLink(input.ShareToken, input.Body.Password, input.clientIP)
+	if err != nil {
+		var errLocked ratelimit.ErrLocked
+		if errors.As(err, &errLocked) {
+			return nil, lockedToHTTPError(errLocked)
+		} else if errors.Is(err, core.ErrNotFound) {
+			return nil, huma.Error401Unauthorized("invalid or expired share token")
+		} else if errors.Is(err, services.ErrShareLinkNoPassword) {
+			return nil, huma.Error422UnprocessableEntity("share link has no password")
+		} else if errors.Is(err, core.ErrInvalidCredentials) {
+			return nil, huma.Error401Unauthorized("invalid share password")
+		}
+		return nil, toGenericHTTPError(err)
+	}
+	return &PostUnlockShareLinkOutput{
+		Body: unlock,
+	}, nil
+}
+
+func (h ShareLinksHandler) DeleteShareLink(
+	ctx context.Context,
+	input *DeleteShareLinkInput,
+) (*struct{}, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	return nil, toGenericHTTPError(h.service.DeleteShareLink(&authenticatedUser, input.Uid))
+}
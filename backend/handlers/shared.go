@@ -3,10 +3,16 @@ package handlers
 import (
 	"errors"
 	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/enchant97/note-mark/backend/core"
 	"github.com/enchant97/note-mark/backend/middleware"
+	"github.com/enchant97/note-mark/backend/ratelimit"
+	"github.com/enchant97/note-mark/backend/services"
 )
 
 type UsernamePath struct {
@@ -25,6 +31,33 @@ func (m *SlugPath) Resolve(ctx huma.Context) []error {
 	return middleware.ValidateRequestInput(ctx, m)
 }
 
+type ShareTokenHeaders struct {
+	ShareToken string `header:"X-Share-Token" doc:"Token of a share link, giving access to a private node"`
+	ShareGrant string `header:"X-Share-Grant" doc:"Grant from unlocking the share link, when it has a password"`
+}
+
+// Get the access given by a share link, if a token was given.
+func resolveShareGrant(
+	shareLinksService services.ShareLinksService,
+	headers ShareTokenHeaders,
+) (*core.ShareGrant, error) {
+	if headers.ShareToken == "" {
+		return nil, nil
+	}
+	grant, err := shareLinksService.ResolveShareToken(headers.ShareToken, headers.ShareGrant)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return nil, huma.Error401Unauthorized("invalid or expired share token")
+		} else if errors.Is(err, services.ErrSharePasswordRequired) {
+			return nil, huma.Error401Unauthorized("share link must be unlocked with its password")
+		} else if errors.Is(err, core.ErrInvalidCredentials) {
+			return nil, huma.Error401Unauthorized("invalid or expired share grant")
+		}
+		return nil, toGenericHTTPError(err)
+	}
+	return grant, nil
+}
+
 func toGenericHTTPError(err error) error {
 	if err == nil {
 		return nil
@@ -41,7 +74,26 @@ func toGenericHTTPError(err error) error {
 		return huma.Error401Unauthorized("failed to authenticate")
 	} else if errors.Is(err, core.ErrFeatureDisabled) {
 		return huma.Error501NotImplemented("feature currently disabled")
+	} else if errors.Is(err, core.ErrPasswordPolicy) {
+		return huma.Error422UnprocessableEntity(err.Error())
 	}
 	slog.Error("unhandled error detected", "err", err)
 	return huma.Error500InternalServerError("unknown error occurred")
 }
+
+// Convert a rate limit lockout into a 429 with a Retry-After header.
+func lockedToHTTPError(errLocked ratelimit.ErrLocked) error {
+	retryAfter := int(math.Ceil(errLocked.RetryAfter.Seconds()))
+	return huma.ErrorWithHeaders(
+		huma.Error429TooManyRequests("too many failed attempts, try again later"),
+		http.Header{"Retry-After": {strconv.Itoa(retryAfter)}},
+	)
+}
+
+// Get the IP address from a remote address, which may include a port.
+func getClientIP(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
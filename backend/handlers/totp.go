@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/middleware"
+	"github.com/enchant97/note-mark/backend/services"
+)
+
+func SetupTotpHandler(
+	api huma.API,
+	service services.TotpService,
+	authProvider *middleware.AuthDetailsProvider,
+) {
+	handler := TotpHandler{
+		service:      service,
+		authProvider: authProvider,
+	}
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/api/users/{username}/totp",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Users"},
+		Summary:     "Get two-factor status",
+		OperationID: "GetUserTotpStatus",
+	}, handler.GetStatus)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPost,
+		Path:        "/api/users/{username}/totp",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Users"},
+		Summary:     "Start two-factor enrolment",
+		Description: "Generates a new secret, two-factor is not enabled until a code is verified.",
+		OperationID: "StartUserTotpEnrolment",
+	}, handler.PostStartEnrolment)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPost,
+		Path:        "/api/users/{username}/totp/verify",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Users"},
+		Summary:     "Verify two-factor enrolment",
+		Description: "Enables two-factor, the recovery codes returned will not be shown again.",
+		OperationID: "VerifyUserTotpEnrolment",
+	}, handler.PostVerifyEnrolment)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPost,
+		Path:        "/api/users/{username}/totp/disable",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Users"},
+		Summary:     "Disable two-factor",
+		OperationID: "DisableUserTotp",
+	}, handler.PostDisable)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPost,
+		Path:        "/api/users/{username}/totp/recovery-codes",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Users"},
+		Summary:     "Regenerate two-factor recovery codes",
+		Description: "Replaces all existing recovery codes.",
+		OperationID: "RegenerateUserTotpRecoveryCodes",
+	}, handler.PostRegenerateRecoveryCodes)
+}
+
+type TotpHandler struct {
+	service      services.TotpService
+	authProvider *middleware.AuthDetailsProvider
+}
+
+type TotpCodeInput struct {
+	UsernamePath
+	Body core.TotpCode
+}
+
+type GetTotpStatusOutput struct {
+	Body core.TotpStatus
+}
+
+type PostTotpEnrolmentOutput struct {
+	Body core.TotpEnrolment
+}
+
+type TotpRecoveryCodesOutput struct {
+	Body core.TotpRecoveryCodes
+}
+
+func totpErrorToHTTPError(err error) error {
+	if errors.Is(err, core.ErrInvalidCredentials) || errors.Is(err, core.ErrTotpRequired) {
+		return huma.Error403Forbidden("two-factor code invalid")
+	} else if errors.Is(err, core.ErrNotFound) {
+		return huma.Error404NotFound("two-factor has not been setup")
+	} else if errors.Is(err, core.ErrConflict) {
+		return huma.Error409Conflict("two-factor is already enabled")
+	}
+	return toGenericHTTPError(err)
+}
+
+// Get the authenticated user, only allowing them to manage their own two-factor.
+func (h TotpHandler) getCurrentUser(ctx context.Context, username core.Username) (core.AuthenticatedUser, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	if authenticatedUser.Username != string(username) {
+		return core.AuthenticatedUser{}, huma.Error403Forbidden("you do not have permission to update another users account")
+	}
+	return authenticatedUser, nil
+}
+
+func (h TotpHandler) GetStatus(
+	ctx context.Context,
+	input *GetUserByUsername,
+) (*GetTotpStatusOutput, error) {
+	authenticatedUser, err := h.getCurrentUser(ctx, input.Username)
+	if err != nil {
+		return nil, err
+	}
+	status, err := h.service.GetStatus(authenticatedUser.UserUID)
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	return &GetTotpStatusOutput{
+		Body: status,
+	}, nil
+}
+
+func (h TotpHandler) PostStartEnrolment(
+	ctx context.Context,
+	input *GetUserByUsername,
+) (*PostTotpEnrolmentOutput, error) {
+	authenticatedUser, err := h.getCurrentUser(ctx, input.Username)
+	if err != nil {
+		return nil, err
+	}
+	enrolment, err := h.service.StartEnrolment(authenticatedUser)
+	if err != nil {
+		return nil, totpErrorToHTTPError(err)
+	}
+	return &PostTotpEnrolmentOutput{
+		Body: enrolment,
+	}, nil
+}
+
+func (h TotpHandler) PostVerifyEnrolment(
+	ctx context.Context,
+	input *TotpCodeInput,
+) (*TotpRecoveryCodesOutput, error) {
+	authenticatedUser, err := h.getCurrentUser(ctx, input.Username)
+	if err != nil {
+		return nil, err
+	}
+	codes, err := h.service.ConfirmEnrolment(authenticatedUser, input.Body.Code)
+	if err != nil {
+		return nil, totpErrorToHTTPError(err)
+	}
+	return &TotpRecoveryCodesOutput{
+		Body: codes,
+	}, nil
+}
+
+func (h TotpHandler) PostDisable(
+	ctx context.Context,
+	input *TotpCodeInput,
+) (*struct{}, error) {
+	authenticatedUser, err := h.getCurrentUser(ctx, input.Username)
+	if err != nil {
+		return nil, err
+	}
+	return nil, totpErrorToHTTPError(h.service.Disable(authenticatedUser, input.Body.Code))
+}
+
+func (h TotpHandler) PostRegenerateRecoveryCodes(
+	ctx context.Context,
+	input *TotpCodeInput,
+) (*TotpRecoveryCodesOutput, error) {
+	authenticatedUser, err := h.getCurrentUser(ctx, input.Username)
+	if err != nil {
+		return nil, err
+	}
+	codes, err := h.service.RegenerateRecoveryCodes(authenticatedUser, input.Body.Code)
+	if err != nil {
+		return nil, totpErrorToHTTPError(err)
+	}
+	return &TotpRecoveryCodesOutput{
+		Body: codes,
+	}, nil
+}
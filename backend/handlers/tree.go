@@ -22,12 +22,14 @@ import (
 func SetupTreeHandler(
 	api huma.API,
 	service services.TreeService,
+	shareLinksService services.ShareLinksService,
 	fileSizeLimitBytes int64,
 	authProvider *middleware.AuthDetailsProvider,
 ) {
 	handler := TreeHandler{
-		service:      service,
-		authProvider: authProvider,
+		service:           service,
+		shareLinksService: shareLinksService,
+		authProvider:      authProvider,
 	}
 	huma.Register(api, huma.Operation{
 
@@ -38,6 +40,15 @@ func SetupTreeHandler(
 		Summary:     "Get node tree for user",
 		OperationID: "GetNodeTreeForUser",
 	}, handler.GetNodeTreeByUsername)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/api/shared-with-me",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Node Tree"},
+		Summary:     "Get nodes other users have shared with the current user",
+		OperationID: "GetSharedWithMe",
+	}, handler.GetSharedWithMe)
 	huma.Register(api, huma.Operation{
 		Method:      http.MethodGet,
 		Path:        "/api/tree/content/u/{username}/*",
@@ -49,7 +60,6 @@ func SetupTreeHandler(
 	huma.Register(api, huma.Operation{
 		Method:       http.MethodPut,
 		Path:         "/api/tree/content/u/{username}/*",
-		Middlewares:  huma.Middlewares{authProvider.AuthRequiredMiddleware},
 		MaxBodyBytes: fileSizeLimitBytes,
 		Security:     defaultSecurityOp,
 		Tags:         []string{"Node Tree"},
@@ -95,12 +105,14 @@ func SetupTreeHandler(
 }
 
 type TreeHandler struct {
-	service      services.TreeService
-	authProvider *middleware.AuthDetailsProvider
+	service           services.TreeService
+	shareLinksService services.ShareLinksService
+	authProvider      *middleware.AuthDetailsProvider
 }
 
 type GetNodeTreeByUsernameInput struct {
 	conditional.Params
+	ShareTokenHeaders
 	UsernamePath
 }
 
@@ -110,13 +122,19 @@ type GetNodeTreeByUsernameOutput struct {
 	Body core.NodeTree
 }
 
+type GetSharedWithMeOutput struct {
+	Body []core.SharedNode
+}
+
 type GetNodeContentInput struct {
 	conditional.Params
+	ShareTokenHeaders
 	UsernamePath
 	SlugPath
 }
 
 type PutNodeContentInput struct {
+	ShareTokenHeaders
 	UsernamePath
 	SlugPath
 	RawBody []byte
@@ -169,25 +187,47 @@ func makePersonalETagValue(authenticatedUser *core.AuthenticatedUser, modTime ti
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
+// Make a "personal" ETag, that also varies by the share link used.
+//
+// Requires to be wrapped in "" when used in a HTTP Header.
+func makeShareETagValue(
+	authenticatedUser *core.AuthenticatedUser,
+	optionalShareGrant *core.ShareGrant,
+	modTime time.Time,
+) string {
+	etagValue := makePersonalETagValue(authenticatedUser, modTime)
+	if optionalShareGrant == nil {
+		return etagValue
+	}
+	h := sha256.New()
+	h.Write([]byte(etagValue))
+	h.Write(optionalShareGrant.LinkUid[:])
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
 func (h TreeHandler) GetNodeTreeByUsername(
 	ctx context.Context,
 	input *GetNodeTreeByUsernameInput,
 ) (*GetNodeTreeByUsernameOutput, error) {
 	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
 	optionalAuthUser := authDetails.GetOptionalAuthenticatedUser()
+	shareGrant, err := resolveShareGrant(h.shareLinksService, input.ShareTokenHeaders)
+	if err != nil {
+		return nil, err
+	}
 	// ETag Creation & ConditionalParams handling
 	treeModTime, err := h.service.GetTreeModTime(input.Username)
 	if err != nil {
 		return nil, toGenericHTTPError(err)
 	}
-	etagValue := makePersonalETagValue(optionalAuthUser, treeModTime)
+	etagValue := makeShareETagValue(optionalAuthUser, shareGrant, treeModTime)
 	if input.HasConditionalParams() {
 		if err := input.PreconditionFailed(etagValue, treeModTime); err != nil {
 			return nil, err
 		}
 	}
 	// Get actual nodeTree
-	nodeTree, err := h.service.GetTreeForUser(optionalAuthUser, input.Username)
+	nodeTree, err := h.service.GetTreeForUser(optionalAuthUser, shareGrant, input.Username)
 	if err != nil {
 		return nil, toGenericHTTPError(err)
 	}
@@ -197,6 +237,21 @@ func (h TreeHandler) GetNodeTreeByUsername(
 	}, nil
 }
 
+func (h TreeHandler) GetSharedWithMe(
+	ctx context.Context,
+	input *struct{},
+) (*GetSharedWithMeOutput, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	sharedNodes, err := h.service.GetNodesSharedWithUser(&authenticatedUser)
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	return &GetSharedWithMeOutput{
+		Body: sharedNodes,
+	}, nil
+}
+
 func getValidatedNodeType(fullSlug string) (core.NodeType, error) {
 	var nodeType core.NodeType
 	if path.Ext(fullSlug) == "" {
@@ -217,10 +272,15 @@ func (h TreeHandler) GetNodeContent(
 	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
 	optionalAuthUser := authDetails.GetOptionalAuthenticatedUser()
 	sanitizedSlug := core.NodeSlug(path.Clean(string(input.Slug)))
+	shareGrant, err := resolveShareGrant(h.shareLinksService, input.ShareTokenHeaders)
+	if err != nil {
+		return nil, err
+	}
 	// check if has permission
 	// (specific permission does not matter, as all modes are "read" permitted)
-	if accessMode, err := h.service.GetAvailableNodeAccessControlMode(
+	if accessMode, err := h.service.GetAvailableNodeAccessControlModeWithShare(
 		optionalAuthUser,
+		shareGrant,
 		input.Username,
 		sanitizedSlug,
 		false,
@@ -239,7 +299,7 @@ func (h TreeHandler) GetNodeContent(
 	if err != nil {
 		return nil, toGenericHTTPError(err)
 	}
-	etagValue := makePersonalETagValue(optionalAuthUser, nodeModTime)
+	etagValue := makeShareETagValue(optionalAuthUser, shareGrant, nodeModTime)
 	if input.HasConditionalParams() {
 		if err := input.PreconditionFailed(etagValue, nodeModTime); err != nil {
 			return nil, err
@@ -287,11 +347,20 @@ func (h TreeHandler) PutNodeContent(
 	input *PutNodeContentInput,
 ) (*struct{}, error) {
 	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
-	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	optionalAuthUser := authDetails.GetOptionalAuthenticatedUser()
 	sanitizedSlug := core.NodeSlug(path.Clean(string(input.Slug)))
+	shareGrant, err := resolveShareGrant(h.shareLinksService, input.ShareTokenHeaders)
+	if err != nil {
+		return nil, err
+	}
+	// anonymous users can only write using a share link
+	if optionalAuthUser == nil && shareGrant == nil {
+		return nil, huma.Error401Unauthorized("authentication is required but none was provided")
+	}
 	// access control check
-	if acMode, err := h.service.GetAvailableNodeAccessControlMode(
-		&authenticatedUser,
+	if acMode, err := h.service.GetAvailableNodeAccessControlModeWithShare(
+		optionalAuthUser,
+		shareGrant,
 		input.Username,
 		sanitizedSlug,
 		true,
@@ -301,11 +370,32 @@ func (h TreeHandler) PutNodeContent(
 		return nil, huma.Error403Forbidden("you don't have permission")
 	}
 	// update node
-	if _, err := getValidatedNodeType(string(sanitizedSlug)); err != nil {
+	nodeType, err := getValidatedNodeType(string(sanitizedSlug))
+	if err != nil {
 		return nil, toGenericHTTPError(err)
 	}
+	// only the owner can change access control, not those given write access by a share link
+	isOwner := optionalAuthUser != nil && optionalAuthUser.Username == string(input.Username)
+	if shareGrant != nil && !isOwner && nodeType == core.NoteNode {
+		if changed, err := h.service.DoesContentChangeAccessControl(
+			input.Username,
+			sanitizedSlug,
+			input.RawBody,
+		); errors.Is(err, core.ErrParsingContent) {
+			return nil, huma.Error422UnprocessableEntity("invalid frontmatter")
+		} else if err != nil {
+			return nil, toGenericHTTPError(err)
+		} else if changed {
+			return nil, huma.Error403Forbidden("only the owner can change access control")
+		}
+	}
 	r := bytes.NewReader(input.RawBody)
-	return nil, toGenericHTTPError(h.service.UpdateNodeContent(input.Username, sanitizedSlug, r))
+	return nil, toGenericHTTPError(h.service.UpdateNodeContent(
+		core.NewAuditActor(optionalAuthUser, shareGrant),
+		input.Username,
+		sanitizedSlug,
+		r,
+	))
 }
 
 func (h TreeHandler) PutNoteNodeFrontmatter(
@@ -333,7 +423,12 @@ func (h TreeHandler) PutNoteNodeFrontmatter(
 	}
 	// update frontmatter
 	return nil, toGenericHTTPError(
-		h.service.UpdateNoteNodeFrontmatter(input.Username, sanitizedSlug, input.Body),
+		h.service.UpdateNoteNodeFrontmatter(
+			core.NewAuditActor(&authenticatedUser, nil),
+			input.Username,
+			sanitizedSlug,
+			input.Body,
+		),
 	)
 }
 
@@ -375,7 +470,12 @@ func (h TreeHandler) PostRenameNode(
 		return nil, huma.Error422UnprocessableEntity("invalid slug")
 	}
 	return nil, toGenericHTTPError(
-		h.service.RenameNode(input.Username, sanitizedSlug, sanitizedNewSlug),
+		h.service.RenameNode(
+			core.NewAuditActor(&authenticatedUser, nil),
+			input.Username,
+			sanitizedSlug,
+			sanitizedNewSlug,
+		),
 	)
 }
 
@@ -405,6 +505,7 @@ func (h TreeHandler) PostMoveNodeToTrash(
 	timestampSlug = strings.Replace(timestampSlug, ".", "-", 1) // ensure path is a valid slug
 	sanitizedNewSlug := path.Join(".trash/", timestampSlug, sanitizedSlug)
 	if err := h.service.RenameNode(
+		core.NewAuditActor(&authenticatedUser, nil),
 		input.Username,
 		core.NodeSlug(sanitizedSlug),
 		core.NodeSlug(sanitizedNewSlug),
@@ -421,8 +522,8 @@ func (h TreeHandler) DeleteNode(
 	input *DeleteNodeInput,
 ) (*struct{}, error) {
 	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
-	currentUsername := authDetails.MustGetAuthenticatedUser().Username
-	if currentUsername != string(input.Username) {
+	authenticatedUser := authDetails.MustGetAuthenticatedUser()
+	if authenticatedUser.Username != string(input.Username) {
 		return nil, huma.Error403Forbidden("you don't have permission")
 	}
 	sanitizedSlug := path.Clean(string(input.Slug))
@@ -431,6 +532,10 @@ func (h TreeHandler) DeleteNode(
 		return nil, toGenericHTTPError(err)
 	}
 	return nil, toGenericHTTPError(
-		h.service.DeleteNode(input.Username, core.NodeSlug(sanitizedSlug)),
+		h.service.DeleteNode(
+			core.NewAuditActor(&authenticatedUser, nil),
+			input.Username,
+			core.NodeSlug(sanitizedSlug),
+		),
 	)
 }
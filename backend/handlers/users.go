@@ -125,6 +125,8 @@ func (h UsersHandler) PostCreateUser(
 	if user, err := h.service.CreateUserWithPassword(input.Body); err != nil {
 		if errors.Is(err, core.ErrFeatureDisabled) {
 			return nil, huma.Error403Forbidden("user signup has been disabled by the administrator")
+		} else if errors.Is(err, services.ErrInviteRequired) || errors.Is(err, services.ErrInviteInvalid) {
+			return nil, huma.Error403Forbidden("a valid invite code is required")
 		} else if errors.Is(err, core.ErrConflict) {
 			return nil, huma.Error409Conflict("user with that username already exists")
 		} else {
@@ -164,6 +166,9 @@ func (h UsersHandler) PutCurrentUser(
 		return nil, huma.Error403Forbidden("you do not have permission to update another users account")
 	}
 	if err := h.service.UpdateUserByUsername(string(input.Username), input.Body); err != nil {
+		if errors.Is(err, core.ErrInvalidTimezone) {
+			return nil, huma.Error422UnprocessableEntity("unknown timezone")
+		}
 		return nil, toGenericHTTPError(err)
 	} else {
 		return nil, nil
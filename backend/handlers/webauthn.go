@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/middleware"
+	"github.com/enchant97/note-mark/backend/passkeys"
+	"github.com/enchant97/note-mark/backend/ratelimit"
+	"github.com/enchant97/note-mark/backend/services"
+)
+
+func SetupWebAuthnHandler(
+	api huma.API,
+	service services.WebAuthnService,
+	authProvider *middleware.AuthDetailsProvider,
+) {
+	handler := WebAuthnHandler{
+		service:      service,
+		authProvider: authProvider,
+	}
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPost,
+		Path:        "/api/auth/webauthn/register/begin",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Authentication"},
+		Summary:     "Begin registering a WebAuthn credential",
+		OperationID: "BeginWebAuthnRegistration",
+	}, handler.PostBeginRegistration)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPost,
+		Path:        "/api/auth/webauthn/register/finish",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Authentication"},
+		Summary:     "Finish registering a WebAuthn credential",
+		Description: "Body is the JSON encoded PublicKeyCredential returned by the browser.",
+		OperationID: "FinishWebAuthnRegistration",
+	}, handler.PostFinishRegistration)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/api/auth/webauthn/credentials",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Authentication"},
+		Summary:     "Get WebAuthn credentials",
+		OperationID: "GetWebAuthnCredentials",
+	}, handler.GetCredentials)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodDelete,
+		Path:        "/api/auth/webauthn/credentials/{credentialId}",
+		Middlewares: huma.Middlewares{authProvider.AuthRequiredMiddleware},
+		Security:    defaultSecurityOp,
+		Tags:        []string{"Authentication"},
+		Summary:     "Delete a WebAuthn credential",
+		OperationID: "DeleteWebAuthnCredential",
+	}, handler.DeleteCredential)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPost,
+		Path:        "/api/auth/webauthn/login/begin",
+		Tags:        []string{"Authentication"},
+		Summary:     "Begin a WebAuthn login",
+		OperationID: "BeginWebAuthnLogin",
+	}, handler.PostBeginLogin)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPost,
+		Path:        "/api/auth/webauthn/login/finish",
+		Tags:        []string{"Authentication"},
+		Summary:     "Finish a WebAuthn login, returning an access token",
+		Description: "Body is the JSON encoded PublicKeyCredential returned by the browser.",
+		OperationID: "FinishWebAuthnLogin",
+	}, handler.PostFinishLogin)
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPost,
+		Path:        "/api/auth/webauthn/login/finish-session",
+		Hidden:      true,
+		Tags:        []string{"Authentication"},
+		Summary:     "Finish a WebAuthn login, starting an auth session",
+		OperationID: "FinishWebAuthnLoginSession",
+	}, handler.PostFinishLoginSession)
+}
+
+type WebAuthnHandler struct {
+	service      services.WebAuthnService
+	authProvider *middleware.AuthDetailsProvider
+}
+
+type PostWebAuthnRegistrationOutput struct {
+	Body core.WebAuthnRegistration
+}
+
+type PostFinishWebAuthnRegistrationInput struct {
+	SessionId string `query:"sessionId" required:"true"`
+	Name      string `query:"name" maxLength:"64" doc:"Name to identify the credential by"`
+	Body      core.PublicKeyCredentialJSON
+	RawBody   []byte
+}
+
+type WebAuthnCredentialOutput struct {
+	Body core.WebAuthnCredential
+}
+
+type GetWebAuthnCredentialsOutput struct {
+	Body []core.WebAuthnCredential
+}
+
+type DeleteWebAuthnCredentialInput struct {
+	CredentialId string `path:"credentialId" maxLength:"1366"`
+}
+
+type PostBeginWebAuthnLoginInput struct {
+	clientIP string
+}
+
+func (m *PostBeginWebAuthnLoginInput) Resolve(ctx huma.Context) []error {
+	m.clientIP = getClientIP(ctx.RemoteAddr())
+	return nil
+}
+
+type PostBeginWebAuthnLoginOutput struct {
+	Body core.WebAuthnLogin
+}
+
+type PostFinishWebAuthnLoginInput struct {
+	SessionId string `query:"sessionId" required:"true"`
+	Body      core.PublicKeyCredentialJSON
+	RawBody   []byte
+	clientIP  string
+}
+
+func (m *PostFinishWebAuthnLoginInput) Resolve(ctx huma.Context) []error {
+	m.clientIP = getClientIP(ctx.RemoteAddr())
+	return nil
+}
+
+func webAuthnErrorToHTTPError(err error) error {
+	var errLocked ratelimit.ErrLocked
+	if errors.Is(err, core.ErrFeatureDisabled) {
+		return huma.Error403Forbidden("webauthn has been disabled by the administrator")
+	} else if errors.As(err, &errLocked) {
+		return lockedToHTTPError(errLocked)
+	} else if errors.Is(err, passkeys.ErrTooManySessions) {
+		return huma.Error503ServiceUnavailable("too many webauthn ceremonies in progress, try again later")
+	} else if errors.Is(err, services.ErrWebAuthnCeremony) {
+		return huma.Error400BadRequest("failed to verify credential")
+	}
+	return toGenericHTTPError(err)
+}
+
+func webAuthnLoginErrorToHTTPError(err error) error {
+	if errors.Is(err, core.ErrFeatureDisabled) {
+		return webAuthnErrorToHTTPError(err)
+	}
+	return huma.Error401Unauthorized("failed to authenticate")
+}
+
+func (h WebAuthnHandler) PostBeginRegistration(
+	ctx context.Context,
+	input *struct{},
+) (*PostWebAuthnRegistrationOutput, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	registration, err := h.service.BeginRegistration(authDetails.MustGetAuthenticatedUser())
+	if err != nil {
+		return nil, webAuthnErrorToHTTPError(err)
+	}
+	return &PostWebAuthnRegistrationOutput{
+		Body: registration,
+	}, nil
+}
+
+func (h WebAuthnHandler) PostFinishRegistration(
+	ctx context.Context,
+	input *PostFinishWebAuthnRegistrationInput,
+) (*WebAuthnCredentialOutput, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	credential, err := h.service.FinishRegistration(
+		authDetails.MustGetAuthenticatedUser(),
+		input.SessionId,
+		input.Name,
+		input.RawBody,
+	)
+	if err != nil {
+		return nil, webAuthnErrorToHTTPError(err)
+	}
+	return &WebAuthnCredentialOutput{
+		Body: credential,
+	}, nil
+}
+
+func (h WebAuthnHandler) GetCredentials(
+	ctx context.Context,
+	input *struct{},
+) (*GetWebAuthnCredentialsOutput, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	credentials, err := h.service.GetCredentials(authDetails.MustGetAuthenticatedUser())
+	if err != nil {
+		return nil, toGenericHTTPError(err)
+	}
+	return &GetWebAuthnCredentialsOutput{
+		Body: credentials,
+	}, nil
+}
+
+func (h WebAuthnHandler) DeleteCredential(
+	ctx context.Context,
+	input *DeleteWebAuthnCredentialInput,
+) (*struct{}, error) {
+	authDetails, _ := h.authProvider.TryGetAuthDetails(ctx)
+	return nil, toGenericHTTPError(
+		h.service.DeleteCredential(authDetails.MustGetAuthenticatedUser(), input.CredentialId),
+	)
+}
+
+func (h WebAuthnHandler) PostBeginLogin(
+	ctx context.Context,
+	input *PostBeginWebAuthnLoginInput,
+) (*PostBeginWebAuthnLoginOutput, error) {
+	login, err := h.service.BeginLogin(input.clientIP)
+	if err != nil {
+		return nil, webAuthnErrorToHTTPError(err)
+	}
+	return &PostBeginWebAuthnLoginOutput{
+		Body: login,
+	}, nil
+}
+
+func (h WebAuthnHandler) PostFinishLogin(
+	ctx context.Context,
+	input *PostFinishWebAuthnLoginInput,
+) (*PostCreateTokenOutput, error) {
+	at, err := h.service.FinishLogin(input.SessionId, input.RawBody, input.clientIP)
+	if err != nil {
+		return nil, webAuthnLoginErrorToHTTPError(err)
+	}
+	return &PostCreateTokenOutput{
+		Body: at,
+	}, nil
+}
+
+func (h WebAuthnHandler) PostFinishLoginSession(
+	ctx context.Context,
+	input *PostFinishWebAuthnLoginInput,
+) (*SetCookieOutput, error) {
+	at, err := h.service.FinishLogin(input.SessionId, input.RawBody, input.clientIP)
+	if err != nil {
+		return nil, webAuthnLoginErrorToHTTPError(err)
+	}
+	return &SetCookieOutput{
+		SetCookie: h.authProvider.CreateSessionCookie(at),
+	}, nil
+}
@@ -0,0 +1,640 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/render"
+	"github.com/enchant97/note-mark/backend/tree"
+	"go.yaml.in/yaml/v4"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Where attachments found at the root of a vault are placed,
+// as assets must belong to a note.
+const rootAttachmentsName = "attachments"
+
+var (
+	invalidSlugCharsRegex = regexp.MustCompile(`[^0-9a-zA-Z_ -]+`)
+	wikiLinkRegex         = regexp.MustCompile(`(!?)\[\[([^\[\]\n]+)\]\]`)
+	markdownLinkRegex     = regexp.MustCompile(`(!?)\[([^\]\n]*)\]\((?:<([^>\n]+)>|([^()\s]+))\)`)
+	embedSizeRegex        = regexp.MustCompile(`^\d+(?:x\d+)?$`)
+	fenceRegex            = regexp.MustCompile("^ {0,3}(```|~~~)")
+	skipVaultFilesRegex   = []*regexp.Regexp{
+		regexp.MustCompile(`^[Tt]humbs.db$`),
+		regexp.MustCompile(`^~\$`),
+		regexp.MustCompile(`^__MACOSX$`),
+	}
+	imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg", ".bmp", ".avif"}
+)
+
+var ErrVaultTooLarge = errors.New("vault has too many files or is too large")
+
+type Limits struct {
+	// larger files are skipped
+	MaxFileSize int64
+	// larger vaults are rejected before anything is written
+	MaxFiles     int
+	MaxTotalSize int64
+}
+
+// Imports an Obsidian vault into a users notes.
+type ObsidianImporter struct {
+	tc     *tree.TreeController
+	limits Limits
+}
+
+func (i ObsidianImporter) New(tc *tree.TreeController, limits Limits) ObsidianImporter {
+	return ObsidianImporter{
+		tc:     tc,
+		limits: limits,
+	}
+}
+
+// Import a vault into a users notes, placing everything under parentSlug (blank for the root).
+//
+// Existing nodes are never overwritten, they are reported as skipped instead,
+// errors with `ErrVaultTooLarge` when the vault is over the limits.
+func (i *ObsidianImporter) ImportVault(
+	vault fs.FS,
+	username core.Username,
+	parentSlug core.NodeSlug,
+) (core.ImportReport, error) {
+	if _, err := i.tc.TryGetNodeTreeForUser(username); err != nil {
+		return core.ImportReport{}, err
+	}
+	plan, err := planVault(vault, parentSlug)
+	if err != nil {
+		return core.ImportReport{}, err
+	}
+	if err := checkVaultLimits(vault, plan, i.limits); err != nil {
+		return core.ImportReport{}, err
+	}
+	report := core.ImportReport{
+		Renamed: plan.renamed,
+		Skipped: plan.skipped,
+	}
+	// the cache is saved once at the end, as saving it is slow for large trees
+	batch := i.tc.NewWriteBatch(username)
+	err = i.writePlan(vault, username, plan, &batch, &report)
+	if flushErr := batch.Flush(); err == nil {
+		err = flushErr
+	}
+	return report, err
+}
+
+// Write every planned item that does not already exist, adding the outcome to the report.
+func (i *ObsidianImporter) writePlan(
+	vault fs.FS,
+	username core.Username,
+	plan vaultPlan,
+	batch *tree.WriteBatch,
+	report *core.ImportReport,
+) error {
+	for _, item := range plan.items {
+		if _, err := i.tc.TryGetNode(username, item.fullSlug); err == nil {
+			report.Skipped = append(report.Skipped, core.ImportSkipped{Path: item.path, Reason: "already exists"})
+			continue
+		} else if !errors.Is(err, core.ErrNotFound) {
+			return err
+		}
+		content, err := i.readVaultFile(vault, item.path)
+		if errors.Is(err, errFileTooLarge) {
+			report.Skipped = append(report.Skipped, core.ImportSkipped{Path: item.path, Reason: "file too large"})
+			continue
+		} else if err != nil {
+			return err
+		}
+		slog.Debug("import node", "username", username, "path", item.path, "slug", item.fullSlug)
+		if item.nodeType == core.NoteNode {
+			content, unresolved := plan.convertNote(username, item.path, content)
+			content = ensureNoteTitle(content, item.title)
+			for _, target := range unresolved {
+				report.Skipped = append(report.Skipped, core.ImportSkipped{
+					Path:   item.path,
+					Reason: "unresolved link: " + target,
+				})
+			}
+			if err := batch.WriteNoteNode(item.fullSlug, bytes.NewReader(content)); err != nil {
+				return err
+			}
+			report.Notes++
+		} else {
+			if err := batch.WriteAssetNode(item.fullSlug, bytes.NewReader(content)); err != nil {
+				return err
+			}
+			report.Assets++
+		}
+	}
+	return nil
+}
+
+var errFileTooLarge = errors.New("file too large")
+
+func (i *ObsidianImporter) readVaultFile(vault fs.FS, filePath string) ([]byte, error) {
+	f, err := vault.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	content, err := io.ReadAll(io.LimitReader(f, i.limits.MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > i.limits.MaxFileSize {
+		return nil, errFileTooLarge
+	}
+	return content, nil
+}
+
+// Check a planned vault is within the limits, files that will be skipped for their size are not counted.
+//
+// Sizes come from the vault, for zip archives reads are also checked against these.
+func checkVaultLimits(vault fs.FS, plan vaultPlan, limits Limits) error {
+	if len(plan.items) > limits.MaxFiles {
+		return ErrVaultTooLarge
+	}
+	var totalSize int64
+	for _, item := range plan.items {
+		info, err := fs.Stat(vault, item.path)
+		if err != nil {
+			return err
+		}
+		if info.Size() <= limits.MaxFileSize {
+			totalSize += info.Size()
+		}
+		if totalSize > limits.MaxTotalSize {
+			return ErrVaultTooLarge
+		}
+	}
+	return nil
+}
+
+// A vault file selected for import.
+type vaultItem struct {
+	path     string
+	fullSlug core.NodeSlug
+	nodeType core.NodeType
+	// original name of a renamed note, used as its title
+	title string
+}
+
+type vaultPlan struct {
+	root    core.NodeSlug
+	items   []vaultItem
+	renamed []core.ImportRenamed
+	skipped []core.ImportSkipped
+	slugs   map[string]core.NodeSlug
+	// lookups use lowercase keys, like Obsidian's link resolution
+	notesByPath  map[string]string
+	notesByName  map[string][]string
+	assetsByPath map[string]string
+	assetsByName map[string][]string
+}
+
+// Work out where every file in a vault will be placed, without writing anything.
+func planVault(vault fs.FS, parentSlug core.NodeSlug) (vaultPlan, error) {
+	plan := vaultPlan{
+		root:         parentSlug,
+		renamed:      []core.ImportRenamed{},
+		skipped:      []core.ImportSkipped{},
+		slugs:        map[string]core.NodeSlug{},
+		notesByPath:  map[string]string{},
+		notesByName:  map[string][]string{},
+		assetsByPath: map[string]string{},
+		assetsByName: map[string][]string{},
+	}
+	var notePaths, assetPaths []string
+	err := fs.WalkDir(vault, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == "." {
+			return nil
+		}
+		if reason := skipVaultEntryReason(d); reason != "" {
+			plan.skipped = append(plan.skipped, core.ImportSkipped{Path: p, Reason: reason})
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if isMarkdownFile(p) {
+			notePaths = append(notePaths, p)
+		} else {
+			assetPaths = append(assetPaths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return vaultPlan{}, err
+	}
+	allocator := slugAllocator{
+		root:      parentSlug,
+		allocated: map[slugKey]core.NodeSlug{},
+		used:      map[string]bool{},
+	}
+	noteExists := map[string]bool{}
+	for _, p := range notePaths {
+		noteExists[trimMarkdownExt(p)] = true
+	}
+	for _, p := range notePaths {
+		dir := path.Dir(p)
+		name := path.Base(trimMarkdownExt(p))
+		var fullSlug core.NodeSlug
+		if dir != "." && path.Base(dir) == name && !noteExists[dir] {
+			// a "folder note", which Note Mark stores beside the folder instead
+			fullSlug = allocator.dirSlug(dir)
+		} else {
+			fullSlug = allocator.allocate(allocator.dirSlug(dir), name, false)
+		}
+		plan.addItem(p, name, fullSlug, core.NoteNode)
+	}
+	for _, p := range assetPaths {
+		if strings.TrimPrefix(path.Ext(p), ".") == "" {
+			plan.skipped = append(plan.skipped, core.ImportSkipped{Path: p, Reason: "no file extension"})
+			continue
+		}
+		dir := path.Dir(p)
+		parent := allocator.dirSlug(dir)
+		if parent == "" {
+			parent = allocator.allocate("", rootAttachmentsName, false)
+		}
+		plan.addItem(p, path.Base(p), allocator.allocate(parent, path.Base(p), true), core.AssetNode)
+	}
+	// parents are written before their children
+	slices.SortFunc(plan.items, func(a, b vaultItem) int {
+		return strings.Compare(string(a.fullSlug), string(b.fullSlug))
+	})
+	return plan, nil
+}
+
+func (plan *vaultPlan) addItem(p string, name string, fullSlug core.NodeSlug, nodeType core.NodeType) {
+	if !core.IsValidNodeSlug(string(fullSlug), nodeType) {
+		plan.skipped = append(plan.skipped, core.ImportSkipped{Path: p, Reason: "invalid name"})
+		return
+	}
+	item := vaultItem{
+		path:     p,
+		fullSlug: fullSlug,
+		nodeType: nodeType,
+	}
+	expectedSlug := p
+	if nodeType == core.NoteNode {
+		expectedSlug = trimMarkdownExt(p)
+	}
+	if string(fullSlug) != path.Join(string(plan.root), expectedSlug) {
+		plan.renamed = append(plan.renamed, core.ImportRenamed{Path: p, Slug: fullSlug})
+		if nodeType == core.NoteNode {
+			item.title = name
+		}
+	}
+	plan.items = append(plan.items, item)
+	plan.slugs[p] = fullSlug
+	if nodeType == core.NoteNode {
+		plan.notesByPath[strings.ToLower(trimMarkdownExt(p))] = p
+		key := strings.ToLower(name)
+		plan.notesByName[key] = append(plan.notesByName[key], p)
+	} else {
+		plan.assetsByPath[strings.ToLower(p)] = p
+		key := strings.ToLower(name)
+		plan.assetsByName[key] = append(plan.assetsByName[key], p)
+	}
+}
+
+// Resolve a link target found in a note into the vault path it refers to.
+func (plan *vaultPlan) resolve(fromPath string, target string) (string, bool) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", false
+	}
+	if strings.Contains(target, "/") {
+		candidates := []string{
+			path.Join(path.Dir(fromPath), target),
+			strings.TrimPrefix(path.Clean("/"+target), "/"),
+		}
+		for _, candidate := range candidates {
+			// an exact file name is preferred over a note with the same name
+			if p, exists := plan.assetsByPath[strings.ToLower(candidate)]; exists {
+				return p, true
+			}
+			if p, exists := plan.notesByPath[strings.ToLower(trimMarkdownExt(candidate))]; exists {
+				return p, true
+			}
+		}
+		return "", false
+	}
+	if p, ok := pickClosest(fromPath, plan.assetsByName[strings.ToLower(target)]); ok {
+		return p, true
+	}
+	return pickClosest(fromPath, plan.notesByName[strings.ToLower(trimMarkdownExt(target))])
+}
+
+// Pick a path from the same folder, otherwise the shortest (like Obsidian would).
+func pickClosest(fromPath string, paths []string) (string, bool) {
+	if len(paths) == 0 {
+		return "", false
+	}
+	best := paths[0]
+	for _, p := range paths {
+		if path.Dir(p) == path.Dir(fromPath) {
+			return p, true
+		}
+		if strings.Count(p, "/") < strings.Count(best, "/") {
+			best = p
+		}
+	}
+	return best, true
+}
+
+// Make a link destination pointing at where a vault file was imported.
+func (plan *vaultPlan) linkDestination(username core.Username, vaultPath string, fragment string) string {
+	dest := render.EscapePath("/" + string(username) + "/" + string(plan.slugs[vaultPath]))
+	if fragment != "" {
+		dest += "#" + fragment
+	}
+	return dest
+}
+
+// Convert wikilinks, embeds and relative markdown links into Note Mark links,
+// leaving frontmatter and code untouched.
+//
+// Returns the converted content and any wikilinks that could not be resolved.
+func (plan *vaultPlan) convertNote(
+	username core.Username,
+	fromPath string,
+	content []byte,
+) ([]byte, []string) {
+	unresolved := []string{}
+	convertWikiLink := func(match string) string {
+		parts := wikiLinkRegex.FindStringSubmatch(match)
+		isEmbed := parts[1] == "!"
+		target, alias, hasAlias := strings.Cut(parts[2], "|")
+		target = strings.TrimSuffix(target, `\`)
+		target, heading, _ := strings.Cut(target, "#")
+		fragment := headingFragment(heading)
+		text := strings.TrimSpace(parts[2])
+		if hasAlias {
+			text = strings.TrimSpace(alias)
+		} else if heading != "" {
+			text = strings.TrimSpace(strings.TrimSpace(target) + " " + heading)
+		}
+		if strings.TrimSpace(target) == "" {
+			// link to a heading in the same note
+			return fmt.Sprintf("[%s](#%s)", strings.TrimSpace(heading), fragment)
+		}
+		vaultPath, ok := plan.resolve(fromPath, target)
+		if !ok {
+			unresolved = append(unresolved, strings.TrimSpace(target))
+			return match
+		}
+		if plan.isAsset(vaultPath) {
+			dest := plan.linkDestination(username, vaultPath, "")
+			if !hasAlias || embedSizeRegex.MatchString(strings.TrimSpace(alias)) {
+				text = path.Base(vaultPath)
+			}
+			if isEmbed && slices.Contains(imageExtensions, strings.ToLower(path.Ext(vaultPath))) {
+				return fmt.Sprintf("![%s](%s)", text, dest)
+			}
+			return fmt.Sprintf("[%s](%s)", text, dest)
+		}
+		return fmt.Sprintf("[%s](%s)", text, plan.linkDestination(username, vaultPath, fragment))
+	}
+	convertMarkdownLink := func(match string) string {
+		parts := markdownLinkRegex.FindStringSubmatch(match)
+		dest := parts[3]
+		if dest == "" {
+			dest = parts[4]
+		}
+		if u, err := url.Parse(dest); err != nil || u.IsAbs() || u.Host != "" {
+			return match
+		}
+		destPath, heading, _ := strings.Cut(dest, "#")
+		if destPath == "" || strings.HasPrefix(destPath, "/") {
+			return match
+		}
+		if unescaped, err := url.PathUnescape(destPath); err == nil {
+			destPath = unescaped
+		}
+		vaultPath, ok := plan.resolve(fromPath, destPath)
+		if !ok {
+			if isMarkdownFile(destPath) {
+				unresolved = append(unresolved, destPath)
+			}
+			return match
+		}
+		if plan.isAsset(vaultPath) {
+			return fmt.Sprintf("%s[%s](%s)", parts[1], parts[2], plan.linkDestination(username, vaultPath, ""))
+		}
+		return fmt.Sprintf("[%s](%s)", parts[2], plan.linkDestination(username, vaultPath, heading))
+	}
+	frontMatter, body := splitFrontMatter(content)
+	lines := strings.SplitAfter(string(body), "\n")
+	inFence := ""
+	for i, line := range lines {
+		if fence := fenceRegex.FindStringSubmatch(line); fence != nil {
+			if inFence == "" {
+				inFence = fence[1]
+			} else if inFence == fence[1] {
+				inFence = ""
+			}
+			continue
+		}
+		if inFence != "" {
+			continue
+		}
+		// only even segments are outside of inline code
+		segments := strings.Split(line, "`")
+		for j := 0; j < len(segments); j += 2 {
+			segments[j] = wikiLinkRegex.ReplaceAllStringFunc(segments[j], convertWikiLink)
+			segments[j] = markdownLinkRegex.ReplaceAllStringFunc(segments[j], convertMarkdownLink)
+		}
+		lines[i] = strings.Join(segments, "`")
+	}
+	return append(frontMatter, []byte(strings.Join(lines, ""))...), unresolved
+}
+
+func (plan *vaultPlan) isAsset(vaultPath string) bool {
+	_, exists := plan.assetsByPath[strings.ToLower(vaultPath)]
+	return exists
+}
+
+type slugKey struct {
+	parent  core.NodeSlug
+	name    string
+	isAsset bool
+}
+
+// Allocates unique valid slugs, ensuring the same name always maps to the same slug.
+type slugAllocator struct {
+	root      core.NodeSlug
+	allocated map[slugKey]core.NodeSlug
+	// lowercase, so names only differing by case do not clash on disk
+	used map[string]bool
+}
+
+func (a *slugAllocator) allocate(parent core.NodeSlug, name string, isAsset bool) core.NodeSlug {
+	key := slugKey{parent: parent, name: name, isAsset: isAsset}
+	if fullSlug, exists := a.allocated[key]; exists {
+		return fullSlug
+	}
+	base, ext := name, ""
+	if isAsset {
+		ext = path.Ext(name)
+		base = strings.TrimSuffix(name, ext)
+		ext = "." + sanitizeSlugPart(strings.TrimPrefix(ext, "."))
+	}
+	base = sanitizeSlugPart(base)
+	fullSlug := core.NodeSlug(path.Join(string(parent), base+ext))
+	for n := 2; a.used[strings.ToLower(string(fullSlug))]; n++ {
+		fullSlug = core.NodeSlug(path.Join(string(parent), fmt.Sprintf("%s-%d%s", base, n, ext)))
+	}
+	a.allocated[key] = fullSlug
+	a.used[strings.ToLower(string(fullSlug))] = true
+	return fullSlug
+}
+
+// Get the note slug for a vault directory.
+func (a *slugAllocator) dirSlug(dir string) core.NodeSlug {
+	if dir == "." {
+		return a.root
+	}
+	return a.allocate(a.dirSlug(path.Dir(dir)), path.Base(dir), false)
+}
+
+// Convert a file or folder name into a valid slug part,
+// e.g. "Café (draft)" becomes "Cafe -draft".
+func sanitizeSlugPart(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	name = invalidSlugCharsRegex.ReplaceAllString(b.String(), "-")
+	name = strings.Trim(name, " -")
+	if name == "" {
+		return "untitled"
+	}
+	return name
+}
+
+// Make a fragment for a heading, Obsidian block references are dropped.
+func headingFragment(heading string) string {
+	heading = strings.TrimSpace(heading)
+	if heading == "" || strings.HasPrefix(heading, "^") {
+		return ""
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
+
+func skipVaultEntryReason(d fs.DirEntry) string {
+	if strings.HasPrefix(d.Name(), ".") {
+		return "hidden file"
+	}
+	for _, r := range skipVaultFilesRegex {
+		if r.MatchString(d.Name()) {
+			return "system file"
+		}
+	}
+	if !d.IsDir() && !d.Type().IsRegular() {
+		return "not a regular file"
+	}
+	return ""
+}
+
+func isMarkdownFile(p string) bool {
+	return strings.EqualFold(path.Ext(p), ".md")
+}
+
+func trimMarkdownExt(p string) string {
+	if isMarkdownFile(p) {
+		return p[:len(p)-len(".md")]
+	}
+	return p
+}
+
+// Split YAML frontmatter from the rest of a note.
+func splitFrontMatter(content []byte) ([]byte, []byte) {
+	if !bytes.HasPrefix(content, []byte("---\n")) && !bytes.HasPrefix(content, []byte("---\r\n")) {
+		return []byte{}, content
+	}
+	firstLineEnd := bytes.IndexByte(content, '\n') + 1
+	rest := content[firstLineEnd:]
+	for offset := 0; offset < len(rest); {
+		lineEnd := bytes.IndexByte(rest[offset:], '\n')
+		var line []byte
+		if lineEnd == -1 {
+			line = rest[offset:]
+			lineEnd = len(rest) - offset
+		} else {
+			line = rest[offset : offset+lineEnd]
+			lineEnd++
+		}
+		if string(bytes.TrimRight(line, "\r")) == "---" {
+			end := firstLineEnd + offset + lineEnd
+			return slices.Clone(content[:end]), content[end:]
+		}
+		offset += lineEnd
+	}
+	return []byte{}, content
+}
+
+// Give a note a title, unless one has already been set.
+//
+// Other frontmatter fields are kept as-is.
+func ensureNoteTitle(content []byte, title string) []byte {
+	if title == "" {
+		return content
+	}
+	rawFm, body := splitFrontMatter(content)
+	fields := map[string]any{}
+	if len(rawFm) != 0 {
+		inner := bytes.TrimPrefix(bytes.TrimSpace(rawFm), []byte("---"))
+		inner = bytes.TrimSuffix(inner, []byte("---"))
+		if err := yaml.Unmarshal(inner, &fields); err != nil || fields == nil {
+			// leave unparsable frontmatter alone
+			return content
+		}
+		if existing, ok := fields["title"].(string); ok && existing != "" {
+			return content
+		}
+	}
+	fields["title"] = title
+	newFm, err := yaml.Marshal(fields)
+	if err != nil {
+		return content
+	}
+	var b bytes.Buffer
+	b.WriteString("---\n")
+	b.Write(newFm)
+	b.WriteString("---\n")
+	if len(rawFm) == 0 {
+		b.WriteString("\n")
+	}
+	b.Write(body)
+	return b.Bytes()
+}
@@ -0,0 +1,193 @@
+package importer
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/enchant97/note-mark/backend/core"
+)
+
+func makeTestVault() fstest.MapFS {
+	return fstest.MapFS{
+		"Welcome.md":                   {Data: []byte("# Welcome\n")},
+		"logo.png":                     {Data: []byte("png")},
+		"Projects/Projects.md":         {Data: []byte("folder note\n")},
+		"Projects/Q3 (draft).md":       {Data: []byte("draft\n")},
+		"Projects/assets/chart.PNG":    {Data: []byte("png")},
+		"Areas.md":                     {Data: []byte("areas\n")},
+		"Areas/Areas.md":               {Data: []byte("not a folder note\n")},
+		"Café.md":                      {Data: []byte("coffee\n")},
+		"Cafe.md":                      {Data: []byte("also coffee\n")},
+		"v1.2 plan.md":                 {Data: []byte("plan\n")},
+		"LICENSE":                      {Data: []byte("text")},
+		".obsidian/app.json":           {Data: []byte("{}")},
+		"Projects/.hidden.md":          {Data: []byte("hidden\n")},
+		"Thumbs.db":                    {Data: []byte("")},
+		"Projects/assets/chart.PNG.md": {Data: []byte("chart notes\n")},
+	}
+}
+
+func TestPlanVault(t *testing.T) {
+	plan, err := planVault(makeTestVault(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path   string
+		expect core.NodeSlug
+	}{
+		{"Welcome.md", "Welcome"},
+		{"logo.png", "attachments/logo.png"},
+		{"Projects/Projects.md", "Projects"},
+		{"Projects/Q3 (draft).md", "Projects/Q3 -draft"},
+		{"Projects/assets/chart.PNG", "Projects/assets/chart.PNG"},
+		{"Projects/assets/chart.PNG.md", "Projects/assets/chart-PNG"},
+		{"Areas.md", "Areas"},
+		{"Areas/Areas.md", "Areas/Areas"},
+		{"Cafe.md", "Cafe"},
+		{"Café.md", "Cafe-2"},
+		{"v1.2 plan.md", "v1-2 plan"},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := plan.slugs[tt.path]
+			if actual != tt.expect {
+				t.Errorf("actual '%v' expect '%v' (path '%s')", actual, tt.expect, tt.path)
+			}
+		})
+	}
+	skipped := []string{}
+	for _, s := range plan.skipped {
+		skipped = append(skipped, s.Path)
+	}
+	slices.Sort(skipped)
+	expectSkipped := []string{".obsidian", "LICENSE", "Projects/.hidden.md", "Thumbs.db"}
+	if !slices.Equal(skipped, expectSkipped) {
+		t.Errorf("actual '%v' expect '%v'", skipped, expectSkipped)
+	}
+	if len(plan.renamed) != 6 {
+		t.Errorf("actual '%v' expect '%v'", len(plan.renamed), 6)
+	}
+}
+
+func TestPlanVaultWithParentSlug(t *testing.T) {
+	plan, err := planVault(makeTestVault(), "imported")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := plan.slugs["logo.png"]; actual != "imported/logo.png" {
+		t.Errorf("actual '%v' expect '%v'", actual, "imported/logo.png")
+	}
+	if actual := plan.slugs["Projects/Q3 (draft).md"]; actual != "imported/Projects/Q3 -draft" {
+		t.Errorf("actual '%v' expect '%v'", actual, "imported/Projects/Q3 -draft")
+	}
+}
+
+func TestCheckVaultLimits(t *testing.T) {
+	vault := makeTestVault()
+	plan, err := planVault(vault, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		limits    Limits
+		expectErr bool
+	}{
+		{Limits{MaxFileSize: 100, MaxFiles: 100, MaxTotalSize: 1000}, false},
+		{Limits{MaxFileSize: 100, MaxFiles: len(plan.items), MaxTotalSize: 1000}, false},
+		{Limits{MaxFileSize: 100, MaxFiles: len(plan.items) - 1, MaxTotalSize: 1000}, true},
+		{Limits{MaxFileSize: 100, MaxFiles: 100, MaxTotalSize: 10}, true},
+		// files too large to import are not counted
+		{Limits{MaxFileSize: 1, MaxFiles: 100, MaxTotalSize: 10}, false},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			err := checkVaultLimits(vault, plan, tt.limits)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("actual '%v' expect error '%v'", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestConvertNote(t *testing.T) {
+	plan, err := planVault(makeTestVault(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		content    string
+		expect     string
+		unresolved []string
+	}{
+		{"see [[Welcome]]", "see [Welcome](/leo/Welcome)", []string{}},
+		{"see [[welcome|home]]", "see [home](/leo/Welcome)", []string{}},
+		{"see [[Q3 (draft)#Next Steps]]", "see [Q3 (draft) Next Steps](/leo/Projects/Q3%20-draft#next-steps)", []string{}},
+		{"see [[#Top]]", "see [Top](#top)", []string{}},
+		{"![[logo.png]]", "![logo.png](/leo/attachments/logo.png)", []string{}},
+		{"![[chart.PNG|300]]", "![chart.PNG](/leo/Projects/assets/chart.PNG)", []string{}},
+		{"![[Welcome]]", "[Welcome](/leo/Welcome)", []string{}},
+		{"[[Missing]]", "[[Missing]]", []string{"Missing"}},
+		{"[draft](Q3%20(draft).md)", "[draft](Q3%20(draft).md)", []string{}},
+		{"[draft](<Q3 (draft).md>)", "[draft](/leo/Projects/Q3%20-draft)", []string{}},
+		{"![](assets/chart.PNG)", "![](/leo/Projects/assets/chart.PNG)", []string{}},
+		{"[site](https://example.com)", "[site](https://example.com)", []string{}},
+		{"`[[Welcome]]` [[Welcome]]", "`[[Welcome]]` [Welcome](/leo/Welcome)", []string{}},
+		{"```\n[[Welcome]]\n```\n", "```\n[[Welcome]]\n```\n", []string{}},
+		{"---\nrelated: [[Welcome]]\n---\n[[Welcome]]", "---\nrelated: [[Welcome]]\n---\n[Welcome](/leo/Welcome)", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual, unresolved := plan.convertNote("leo", "Projects/Projects.md", []byte(tt.content))
+			if string(actual) != tt.expect {
+				t.Errorf("actual '%v' expect '%v'", string(actual), tt.expect)
+			}
+			if !slices.Equal(unresolved, tt.unresolved) {
+				t.Errorf("actual '%v' expect '%v'", unresolved, tt.unresolved)
+			}
+		})
+	}
+}
+
+func TestSanitizeSlugPart(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect string
+	}{
+		{"My Note", "My Note"},
+		{"Café (draft)", "Cafe -draft"},
+		{"a/b", "a-b"},
+		{"???", "untitled"},
+		{"2026-10-19", "2026-10-19"},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := sanitizeSlugPart(tt.name)
+			if actual != tt.expect {
+				t.Errorf("actual '%v' expect '%v'", actual, tt.expect)
+			}
+		})
+	}
+}
+
+func TestEnsureNoteTitle(t *testing.T) {
+	tests := []struct {
+		content string
+		title   string
+		expect  string
+	}{
+		{"body\n", "", "body\n"},
+		{"body\n", "Q3 (draft)", "---\ntitle: Q3 (draft)\n---\n\nbody\n"},
+		{"---\ntags: [a]\n---\nbody\n", "Q3", "---\ntags:\n    - a\ntitle: Q3\n---\nbody\n"},
+		{"---\ntitle: Kept\n---\nbody\n", "Q3", "---\ntitle: Kept\n---\nbody\n"},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := string(ensureNoteTitle([]byte(tt.content), tt.title))
+			if actual != tt.expect {
+				t.Errorf("actual '%v' expect '%v'", actual, tt.expect)
+			}
+		})
+	}
+}
@@ -6,34 +6,42 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/enchant97/note-mark/backend/core"
 	"github.com/enchant97/note-mark/backend/db"
+	"github.com/enchant97/note-mark/backend/signingkeys"
+	"github.com/enchant97/note-mark/backend/usercache"
+	"github.com/google/uuid"
 )
 
 const (
 	AuthDetailsProviderContextKey = "AuthDetails"
 	AuthSessionTokenCookieName    = "Auth-Session-Token"
+	OidcStateCookieName           = "Oidc-State"
 )
 
 type AuthDetailsProvider struct {
 	api       huma.API
 	dao       *db.DAO
-	jwtSecret []byte
+	keyring   *signingkeys.Keyring
+	userCache *usercache.Cache
 	usesHTTPS bool
 }
 
 func (p AuthDetailsProvider) New(
 	api huma.API,
 	dao *db.DAO,
-	jwtSecret []byte,
+	keyring *signingkeys.Keyring,
+	userCache *usercache.Cache,
 	usesHTTPS bool,
 ) AuthDetailsProvider {
 	return AuthDetailsProvider{
 		api:       api,
 		dao:       dao,
-		jwtSecret: jwtSecret,
+		keyring:   keyring,
+		userCache: userCache,
 		usesHTTPS: usesHTTPS,
 	}
 }
@@ -52,7 +60,7 @@ func (p AuthDetailsProvider) ProviderMiddleware(ctx huma.Context, next func(huma
 			authValue = strings.TrimPrefix(authHeader, "Bearer ")
 		}
 		// process chosen token
-		if userUID, err := core.ParseAuthenticationToken(authValue, p.jwtSecret); err != nil {
+		if userUID, issuedAt, err := core.ParseAuthenticationToken(authValue, p.keyring); err != nil {
 			// token could not be parsed
 			if cookieErr == nil {
 				p.clearSessionCookie(ctx)
@@ -61,24 +69,22 @@ func (p AuthDetailsProvider) ProviderMiddleware(ctx huma.Context, next func(huma
 			return
 		} else {
 			// token parsed, now validate user is still valid
-			// TODO add in-memory caching to this query with ttl
-			if user, err := p.dao.Queries.GetUserByUid(context.Background(), userUID); err != nil {
-				if errors.Is(core.WrapDbError(err), core.ErrNotFound) {
-					if cookieErr == nil {
-						p.clearSessionCookie(ctx)
-					}
-					huma.WriteErr(p.api, ctx, http.StatusUnauthorized, "invalid authentication token given")
-				} else {
-					log.Panicln(err)
+			if user, err := p.userCache.GetOrLoad(userUID, p.loadUser); err != nil {
+				log.Panicln(err)
+			} else if user.Deleted || issuedAt.Before(user.TokensValidAfter) {
+				if cookieErr == nil {
+					p.clearSessionCookie(ctx)
 				}
+				huma.WriteErr(p.api, ctx, http.StatusUnauthorized, "invalid authentication token given")
 				return
 			} else {
 				ctx = huma.WithValue(
 					ctx,
 					AuthDetailsProviderContextKey,
 					core.AuthenticationDetails{}.New(&core.AuthenticatedUser{
-						UserUID:  user.Uid,
+						UserUID:  userUID,
 						Username: user.Username,
+						IsAdmin:  user.IsAdmin,
 					}))
 				next(ctx)
 				return
@@ -89,6 +95,21 @@ func (p AuthDetailsProvider) ProviderMiddleware(ctx huma.Context, next func(huma
 	next(ctx)
 }
 
+// Get a user for the cache, users that are deleted or disabled are marked as deleted.
+func (p AuthDetailsProvider) loadUser(userUID uuid.UUID) (usercache.Entry, error) {
+	user, err := core.WrapDbErrorWithValue(p.dao.Queries.GetUserByUid(context.Background(), userUID))
+	if errors.Is(err, core.ErrNotFound) {
+		return usercache.Entry{Deleted: true}, nil
+	} else if err != nil {
+		return usercache.Entry{}, err
+	}
+	return usercache.Entry{
+		Username:         user.Username,
+		IsAdmin:          user.IsAdmin,
+		TokensValidAfter: user.TokensValidAfter.Time,
+	}, nil
+}
+
 // Ensure a specific route(s) has been given valid authentication
 func (p AuthDetailsProvider) AuthRequiredMiddleware(ctx huma.Context, next func(huma.Context)) {
 	if authDetails, ok := ctx.Context().Value(AuthDetailsProviderContextKey).(core.AuthenticationDetails); !ok {
@@ -104,6 +125,25 @@ func (p AuthDetailsProvider) AuthRequiredMiddleware(ctx huma.Context, next func(
 	next(ctx)
 }
 
+// Ensure a specific route(s) has been given valid authentication for an administrator
+func (p AuthDetailsProvider) AdminRequiredMiddleware(ctx huma.Context, next func(huma.Context)) {
+	if authDetails, ok := ctx.Context().Value(AuthDetailsProviderContextKey).(core.AuthenticationDetails); !ok {
+		huma.WriteErr(p.api, ctx, http.StatusInternalServerError, "cannot currently process authentication")
+		return
+	} else {
+		if !authDetails.IsAuthenticated() {
+			ctx.SetHeader("WWW-Authenticate", "Bearer")
+			huma.WriteErr(p.api, ctx, http.StatusUnauthorized, "authentication is required but none was provided")
+			return
+		}
+		if !authDetails.MustGetAuthenticatedUser().IsAdmin {
+			huma.WriteErr(p.api, ctx, http.StatusForbidden, "administrator access is required")
+			return
+		}
+	}
+	next(ctx)
+}
+
 // Try and get the current authentication details from a given context
 func (p AuthDetailsProvider) TryGetAuthDetails(ctx context.Context) (core.AuthenticationDetails, bool) {
 	v, ok := ctx.Value(AuthDetailsProviderContextKey).(core.AuthenticationDetails)
@@ -120,6 +160,27 @@ func (p *AuthDetailsProvider) getEmptyAuthCooke() http.Cookie {
 	}
 }
 
+// Create a cookie binding an OIDC login to the browser that started it.
+//
+// Uses lax same-site, as it must be sent when the provider redirects back.
+func (p *AuthDetailsProvider) CreateOidcStateCookie(state string, maxAge time.Duration) http.Cookie {
+	return http.Cookie{
+		HttpOnly: true,
+		Secure:   p.usesHTTPS,
+		Name:     OidcStateCookieName,
+		Value:    state,
+		Path:     "/api/auth/oidc",
+		MaxAge:   int(maxAge.Seconds()),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (p *AuthDetailsProvider) CreateClearOidcStateCookie() http.Cookie {
+	cookie := p.CreateOidcStateCookie("", 0)
+	cookie.MaxAge = -1
+	return cookie
+}
+
 func (p *AuthDetailsProvider) clearSessionCookie(ctx huma.Context) {
 	cookie := p.CreateClearSessionCookie()
 	ctx.AppendHeader("Set-Cookie", cookie.String())
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/enchant97/note-mark/backend/core"
+)
+
+const usernamePathParam = "{username}"
+
+// Gets the current username for a renamed user, from one of their old usernames.
+type UsernameRedirectFunc func(username core.Username) (core.Username, bool)
+
+type UsernameRedirectMiddleware struct {
+	getRedirect UsernameRedirectFunc
+}
+
+func (m UsernameRedirectMiddleware) New(getRedirect UsernameRedirectFunc) UsernameRedirectMiddleware {
+	return UsernameRedirectMiddleware{
+		getRedirect: getRedirect,
+	}
+}
+
+// Use as a global middleware to redirect reads using an old username, after a user has been renamed.
+func (m UsernameRedirectMiddleware) Provider(ctx huma.Context, next func(huma.Context)) {
+	if ctx.Method() != http.MethodGet && ctx.Method() != http.MethodHead {
+		next(ctx)
+		return
+	}
+	// only paths where the username is the first parameter can be rewritten
+	prefix, _, found := strings.Cut(ctx.Operation().Path, usernamePathParam)
+	if !found || strings.Contains(prefix, "{") {
+		next(ctx)
+		return
+	}
+	username := ctx.Param("username")
+	u := ctx.URL()
+	suffix, found := strings.CutPrefix(u.EscapedPath(), prefix+username)
+	if !found {
+		next(ctx)
+		return
+	}
+	newUsername, found := m.getRedirect(core.Username(username))
+	if !found {
+		next(ctx)
+		return
+	}
+	location := prefix + string(newUsername) + suffix
+	if u.RawQuery != "" {
+		location += "?" + u.RawQuery
+	}
+	ctx.SetHeader("Location", location)
+	ctx.SetStatus(http.StatusMovedPermanently)
+}
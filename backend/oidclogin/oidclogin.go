@@ -0,0 +1,247 @@
+package oidclogin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var ErrStateInvalid = errors.New("oidc login state is invalid or has expired")
+var ErrUnknownProvider = errors.New("unknown oidc provider")
+var ErrTooManyPending = errors.New("too many oidc logins in progress")
+
+const StateTimeout = 10 * time.Minute
+
+// logins kept in memory at once, so unfinished logins can't use up memory
+const defaultMaxPending = 10000
+
+// A configured provider, identified by its name.
+type Provider struct {
+	Name         string
+	Provider     *oidc.Provider
+	Verifier     *oidc.IDTokenVerifier
+	ClientID     string
+	ClientSecret string
+	// claims needed besides preferred_username, fetched from userinfo when missing from the id token
+	Claims []string
+}
+
+// The user authenticated by a provider.
+type Identity struct {
+	ProviderName      string
+	Subject           string
+	PreferredUsername string
+	// from the id token, with any missing ones from userinfo
+	Claims map[string]any
+}
+
+type pending struct {
+	providerName  string
+	codeVerifier  string
+	nonce         string
+	redirectAfter string
+	expires       time.Time
+}
+
+// Performs authorization code logins with PKCE,
+// keeping the state between the login and callback steps in memory.
+type Flows struct {
+	redirectURL string
+	providers   map[string]Provider
+	mutex       *sync.Mutex
+	pending     map[string]pending
+	maxPending  int
+	now         func() time.Time
+}
+
+// Create flows for the given providers, redirectURL is where providers send the user back to.
+func (f Flows) New(redirectURL string, providers []Provider) Flows {
+	f = Flows{
+		redirectURL: redirectURL,
+		providers:   make(map[string]Provider, len(providers)),
+		mutex:       &sync.Mutex{},
+		pending:     map[string]pending{},
+		maxPending:  defaultMaxPending,
+		now:         time.Now,
+	}
+	for _, provider := range providers {
+		f.providers[provider.Name] = provider
+	}
+	return f
+}
+
+// Start a login, returning the state and the provider url to send the user to.
+//
+// redirectAfter is kept with the state, for where to go once logged in,
+// errors with `ErrTooManyPending` when too many logins are in progress.
+func (f *Flows) Begin(providerName string, redirectAfter string) (string, string, error) {
+	provider, exists := f.providers[providerName]
+	if !exists {
+		return "", "", ErrUnknownProvider
+	}
+	state, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	codeVerifier := oauth2.GenerateVerifier()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	// expired state is only removed once the limit is reached
+	if len(f.pending) >= f.maxPending {
+		f.removeExpired()
+		if len(f.pending) >= f.maxPending {
+			return "", "", ErrTooManyPending
+		}
+	}
+	f.pending[state] = pending{
+		providerName:  providerName,
+		codeVerifier:  codeVerifier,
+		nonce:         nonce,
+		redirectAfter: redirectAfter,
+		expires:       f.now().Add(StateTimeout),
+	}
+	authURL := f.oauth2Config(provider).AuthCodeURL(
+		state,
+		oidc.Nonce(nonce),
+		oauth2.S256ChallengeOption(codeVerifier),
+	)
+	return state, authURL, nil
+}
+
+// Finish a login using the state and code the provider returned the user with.
+//
+// Returns the identity from the verified ID token and where to go once logged in,
+// errors with `ErrStateInvalid` when the state is unknown or expired.
+func (f *Flows) Finish(ctx context.Context, state string, code string) (Identity, string, error) {
+	p, err := f.takePending(state)
+	if err != nil {
+		return Identity{}, "", err
+	}
+	provider := f.providers[p.providerName]
+	token, err := f.oauth2Config(provider).Exchange(ctx, code, oauth2.VerifierOption(p.codeVerifier))
+	if err != nil {
+		return Identity{}, "", err
+	}
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, "", errors.New("oidc token response is missing an id token")
+	}
+	idToken, err := provider.Verifier.Verify(ctx, rawIdToken)
+	if err != nil {
+		return Identity{}, "", err
+	}
+	if idToken.Nonce != p.nonce {
+		return Identity{}, "", errors.New("oidc id token nonce does not match")
+	}
+	if idToken.AccessTokenHash != "" {
+		if err := idToken.VerifyAccessToken(token.AccessToken); err != nil {
+			return Identity{}, "", err
+		}
+	}
+	claims := map[string]any{}
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, "", err
+	}
+	// some providers only give profile claims from the userinfo endpoint
+	if _, exists := claims["preferred_username"]; !exists || hasMissingClaims(claims, provider.Claims) {
+		userInfo, err := provider.Provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+		if err != nil {
+			return Identity{}, "", err
+		}
+		if userInfo.Subject != idToken.Subject {
+			return Identity{}, "", errors.New("oidc userinfo subject does not match id token")
+		}
+		userInfoClaims := map[string]any{}
+		if err := userInfo.Claims(&userInfoClaims); err != nil {
+			return Identity{}, "", err
+		}
+		for name, value := range userInfoClaims {
+			if _, exists := claims[name]; !exists {
+				claims[name] = value
+			}
+		}
+	}
+	preferredUsername, _ := claims["preferred_username"].(string)
+	if preferredUsername == "" {
+		return Identity{}, "", errors.New("oidc 'preferred_username' is blank or missing")
+	}
+	return Identity{
+		ProviderName:      p.providerName,
+		Subject:           idToken.Subject,
+		PreferredUsername: preferredUsername,
+		Claims:            claims,
+	}, p.redirectAfter, nil
+}
+
+func hasMissingClaims(claims map[string]any, names []string) bool {
+	for _, name := range names {
+		if _, exists := claims[name]; !exists {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Flows) oauth2Config(provider Provider) *oauth2.Config {
+	endpoint := provider.Provider.Endpoint()
+	if provider.ClientSecret == "" {
+		// public clients only identify themselves with the client id
+		endpoint.AuthStyle = oauth2.AuthStyleInParams
+	}
+	return &oauth2.Config{
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		Endpoint:     endpoint,
+		RedirectURL:  f.redirectURL,
+		Scopes:       []string{oidc.ScopeOpenID, "profile"},
+	}
+}
+
+// Get and remove pending state, so it can only be used once.
+func (f *Flows) takePending(state string) (pending, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	p, exists := f.pending[state]
+	if !exists {
+		return pending{}, ErrStateInvalid
+	}
+	delete(f.pending, state)
+	if f.isExpired(p) {
+		return pending{}, ErrStateInvalid
+	}
+	return p, nil
+}
+
+func (f *Flows) isExpired(p pending) bool {
+	return p.expires.Before(f.now())
+}
+
+// Remove expired state.
+//
+// Assumes mutex has been locked.
+func (f *Flows) removeExpired() {
+	for state, p := range f.pending {
+		if f.isExpired(p) {
+			delete(f.pending, state)
+		}
+	}
+}
+
+func randomString() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
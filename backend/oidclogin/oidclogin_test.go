@@ -0,0 +1,294 @@
+package oidclogin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID    = "note-mark"
+	testRedirectURL = "https://notes.example.com/api/auth/oidc/callback"
+	testSubject     = "user-sub"
+)
+
+type testAuthorization struct {
+	codeChallenge string
+	nonce         string
+}
+
+// A minimal OIDC issuer, only supporting the authorization code flow with PKCE.
+type testIssuer struct {
+	t              *testing.T
+	server         *httptest.Server
+	key            *rsa.PrivateKey
+	authorizations map[string]testAuthorization
+	// claims added to the id token, when preferred_username is missing it comes from userinfo
+	idTokenClaims map[string]any
+	// replaces the nonce given during authorization
+	nonce string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &testIssuer{
+		t:              t,
+		key:            key,
+		authorizations: map[string]testAuthorization{},
+		idTokenClaims:  map[string]any{"preferred_username": "leo"},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", issuer.handleDiscovery)
+	mux.HandleFunc("GET /jwks", issuer.handleJWKS)
+	mux.HandleFunc("POST /token", issuer.handleToken)
+	mux.HandleFunc("GET /userinfo", issuer.handleUserInfo)
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (i *testIssuer) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		i.t.Error(err)
+	}
+}
+
+func (i *testIssuer) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	i.writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                i.server.URL,
+		"authorization_endpoint":                i.server.URL + "/authorize",
+		"token_endpoint":                        i.server.URL + "/token",
+		"jwks_uri":                              i.server.URL + "/jwks",
+		"userinfo_endpoint":                     i.server.URL + "/userinfo",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (i *testIssuer) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	i.writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(i.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.E)).Bytes()),
+		}},
+	})
+}
+
+// Act as the user approving a login, returning the code.
+func (i *testIssuer) authorize(authURL string) string {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("redirect_uri") != testRedirectURL {
+		i.t.Fatalf("unexpected authorization request '%s'", authURL)
+	}
+	code := rand.Text()
+	i.authorizations[code] = testAuthorization{
+		codeChallenge: query.Get("code_challenge"),
+		nonce:         query.Get("nonce"),
+	}
+	return code
+}
+
+func (i *testIssuer) handleToken(w http.ResponseWriter, r *http.Request) {
+	authorization, exists := i.authorizations[r.PostFormValue("code")]
+	delete(i.authorizations, r.PostFormValue("code"))
+	challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !exists || base64.RawURLEncoding.EncodeToString(challenge[:]) != authorization.codeChallenge {
+		i.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	nonce := authorization.nonce
+	if i.nonce != "" {
+		nonce = i.nonce
+	}
+	claims := jwt.MapClaims{
+		"iss":   i.server.URL,
+		"sub":   testSubject,
+		"aud":   testClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": nonce,
+	}
+	for k, v := range i.idTokenClaims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+	idToken, err := token.SignedString(i.key)
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	i.writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "access-" + testSubject,
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     idToken,
+	})
+}
+
+func (i *testIssuer) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer access-"+testSubject {
+		i.writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+	i.writeJSON(w, http.StatusOK, map[string]any{
+		"sub":                testSubject,
+		"preferred_username": "userinfo-leo",
+		"groups":             []string{"staff"},
+	})
+}
+
+func makeTestFlows(t *testing.T, issuer *testIssuer) Flows {
+	provider, err := oidc.NewProvider(context.Background(), issuer.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Flows{}.New(testRedirectURL, []Provider{{
+		Name:     "test",
+		Provider: provider,
+		Verifier: provider.Verifier(&oidc.Config{ClientID: testClientID}),
+		ClientID: testClientID,
+		Claims:   []string{"groups"},
+	}})
+}
+
+func TestFlows(t *testing.T) {
+	issuer := newTestIssuer(t)
+	flows := makeTestFlows(t, issuer)
+	cases := []struct {
+		IdTokenClaims    map[string]any
+		ExpectedUsername string
+		ExpectedGroups   any
+		RedirectAfter    string
+		ExpectedRedirect string
+	}{
+		{map[string]any{"preferred_username": "leo", "groups": "editors"}, "leo", "editors", "/leo/notes", "/leo/notes"},
+		{map[string]any{"preferred_username": "leo"}, "leo", []any{"staff"}, "", ""},
+		{map[string]any{}, "userinfo-leo", []any{"staff"}, "", ""},
+	}
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			issuer.idTokenClaims = c.IdTokenClaims
+			state, authURL, err := flows.Begin("test", c.RedirectAfter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			identity, redirectAfter, err := flows.Finish(context.Background(), state, issuer.authorize(authURL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if identity.ProviderName != "test" || identity.Subject != testSubject {
+				t.Errorf("actual '%v' expect '%v'", identity, testSubject)
+			}
+			if identity.PreferredUsername != c.ExpectedUsername {
+				t.Errorf("actual '%v' expect '%v'", identity.PreferredUsername, c.ExpectedUsername)
+			}
+			if !reflect.DeepEqual(identity.Claims["groups"], c.ExpectedGroups) {
+				t.Errorf("actual '%v' expect '%v'", identity.Claims["groups"], c.ExpectedGroups)
+			}
+			if redirectAfter != c.ExpectedRedirect {
+				t.Errorf("actual '%v' expect '%v'", redirectAfter, c.ExpectedRedirect)
+			}
+		})
+	}
+}
+
+func TestFlowsRejects(t *testing.T) {
+	issuer := newTestIssuer(t)
+	flows := makeTestFlows(t, issuer)
+
+	if _, _, err := flows.Begin("unknown", ""); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("actual '%v' expect '%v'", err, ErrUnknownProvider)
+	}
+
+	// state can only be used once
+	state, authURL, _ := flows.Begin("test", "")
+	if _, _, err := flows.Finish(context.Background(), state, issuer.authorize(authURL)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := flows.Finish(context.Background(), state, issuer.authorize(authURL)); !errors.Is(err, ErrStateInvalid) {
+		t.Errorf("actual '%v' expect '%v'", err, ErrStateInvalid)
+	}
+
+	// unknown state
+	if _, _, err := flows.Finish(context.Background(), "unknown", issuer.authorize(authURL)); !errors.Is(err, ErrStateInvalid) {
+		t.Errorf("actual '%v' expect '%v'", err, ErrStateInvalid)
+	}
+
+	// code issued for another login, so the code verifier does not match
+	state, _, _ = flows.Begin("test", "")
+	_, otherAuthURL, _ := flows.Begin("test", "")
+	if _, _, err := flows.Finish(context.Background(), state, issuer.authorize(otherAuthURL)); err == nil {
+		t.Errorf("actual '%v' expect error", err)
+	}
+
+	// replayed id token, with a different nonce
+	issuer.nonce = "replayed"
+	state, authURL, _ = flows.Begin("test", "")
+	if _, _, err := flows.Finish(context.Background(), state, issuer.authorize(authURL)); err == nil {
+		t.Errorf("actual '%v' expect error", err)
+	}
+	issuer.nonce = ""
+
+	// id token for another client
+	issuer.idTokenClaims = map[string]any{"preferred_username": "leo", "aud": "other-client"}
+	state, authURL, _ = flows.Begin("test", "")
+	if _, _, err := flows.Finish(context.Background(), state, issuer.authorize(authURL)); err == nil {
+		t.Errorf("actual '%v' expect error", err)
+	}
+	issuer.idTokenClaims = map[string]any{"preferred_username": "leo"}
+
+	// expired state
+	state, authURL, _ = flows.Begin("test", "")
+	flows.now = func() time.Time { return time.Now().Add(StateTimeout + time.Minute) }
+	if _, _, err := flows.Finish(context.Background(), state, issuer.authorize(authURL)); !errors.Is(err, ErrStateInvalid) {
+		t.Errorf("actual '%v' expect '%v'", err, ErrStateInvalid)
+	}
+}
+
+func TestFlowsMaxPending(t *testing.T) {
+	issuer := newTestIssuer(t)
+	flows := makeTestFlows(t, issuer)
+	flows.maxPending = 2
+	for range 2 {
+		if _, _, err := flows.Begin("test", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := flows.Begin("test", ""); !errors.Is(err, ErrTooManyPending) {
+		t.Errorf("actual '%v' expect '%v'", err, ErrTooManyPending)
+	}
+	// expired state makes room for new logins
+	flows.now = func() time.Time { return time.Now().Add(StateTimeout + time.Minute) }
+	if _, _, err := flows.Begin("test", ""); err != nil {
+		t.Errorf("actual '%v' expect '%v'", err, nil)
+	}
+	if len(flows.pending) != 1 {
+		t.Errorf("actual '%v' expect '%v'", len(flows.pending), 1)
+	}
+}
@@ -0,0 +1,255 @@
+package passkeys
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSessionInvalid  = errors.New("webauthn session is invalid or has expired")
+	ErrTooManySessions = errors.New("too many webauthn sessions in progress")
+)
+
+const (
+	sessionTimeout = 5 * time.Minute
+	// sessions kept in memory at once, so unfinished ceremonies can't use up memory
+	defaultMaxSessions = 10000
+)
+
+type Options struct {
+	// domain credentials are scoped to, usually the host of the public url
+	RPID          string
+	RPDisplayName string
+	// origins the browser is allowed to perform ceremonies from
+	RPOrigins []string
+}
+
+// A user taking part in a ceremony, identified to authenticators by their uid.
+type User struct {
+	Uid         uuid.UUID
+	Username    string
+	DisplayName string
+	Credentials []webauthn.Credential
+}
+
+func (u User) WebAuthnID() []byte {
+	return u.Uid[:]
+}
+
+func (u User) WebAuthnName() string {
+	return u.Username
+}
+
+func (u User) WebAuthnDisplayName() string {
+	if u.DisplayName == "" {
+		return u.Username
+	}
+	return u.DisplayName
+}
+
+func (u User) WebAuthnCredentials() []webauthn.Credential {
+	return u.Credentials
+}
+
+// Get the user owning a credential during a login, userUid comes from the authenticator.
+type UserLookup func(userUid uuid.UUID) (User, error)
+
+type session struct {
+	data webauthn.SessionData
+	// registrations are bound to the user that started them
+	userUid uuid.UUID
+}
+
+// Performs registration and login ceremonies,
+// keeping the state between the begin and finish steps in memory.
+type Ceremonies struct {
+	webAuthn    *webauthn.WebAuthn
+	mutex       *sync.Mutex
+	sessions    map[string]session
+	maxSessions int
+	now         func() time.Time
+}
+
+func (c Ceremonies) New(options Options) (Ceremonies, error) {
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          options.RPID,
+		RPDisplayName: options.RPDisplayName,
+		RPOrigins:     options.RPOrigins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: sessionTimeout, TimeoutUVD: sessionTimeout},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: sessionTimeout, TimeoutUVD: sessionTimeout},
+		},
+	})
+	if err != nil {
+		return Ceremonies{}, err
+	}
+	return Ceremonies{
+		webAuthn:    webAuthn,
+		mutex:       &sync.Mutex{},
+		sessions:    map[string]session{},
+		maxSessions: defaultMaxSessions,
+		now:         time.Now,
+	}, nil
+}
+
+// Start registering a new discoverable credential, existing credentials are excluded.
+func (c *Ceremonies) BeginRegistration(user User) (string, *protocol.CredentialCreation, error) {
+	creation, data, err := c.webAuthn.BeginRegistration(
+		user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(webauthn.Credentials(user.Credentials).CredentialDescriptors()),
+	)
+	if err != nil {
+		return "", nil, err
+	}
+	sessionId, err := c.saveSession(session{data: *data, userUid: user.Uid})
+	return sessionId, creation, err
+}
+
+// Finish registering a credential, rawResponse is the JSON encoded `PublicKeyCredential`.
+//
+// errors with `ErrSessionInvalid` when the session is unknown, expired or for another user.
+func (c *Ceremonies) FinishRegistration(sessionId string, user User, rawResponse []byte) (*webauthn.Credential, error) {
+	s, err := c.takeSession(sessionId)
+	if err != nil {
+		return nil, err
+	}
+	if s.userUid != user.Uid {
+		return nil, ErrSessionInvalid
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(rawResponse)
+	if err != nil {
+		return nil, err
+	}
+	return c.webAuthn.CreateCredential(user, s.data, parsed)
+}
+
+// Start a login, when user is nil any discoverable credential for this site can be used.
+func (c *Ceremonies) BeginLogin(user *User) (string, *protocol.CredentialAssertion, error) {
+	var assertion *protocol.CredentialAssertion
+	var data *webauthn.SessionData
+	var err error
+	if user == nil {
+		assertion, data, err = c.webAuthn.BeginDiscoverableLogin()
+	} else {
+		assertion, data, err = c.webAuthn.BeginLogin(*user)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	sessionId, err := c.saveSession(session{data: *data})
+	return sessionId, assertion, err
+}
+
+// Finish a login, rawResponse is the JSON encoded `PublicKeyCredential`.
+//
+// Returns the user and the credential used, with its updated sign count,
+// errors with `ErrSessionInvalid` when the session is unknown or expired.
+func (c *Ceremonies) FinishLogin(
+	sessionId string,
+	rawResponse []byte,
+	lookup UserLookup,
+) (User, *webauthn.Credential, error) {
+	s, err := c.takeSession(sessionId)
+	if err != nil {
+		return User{}, nil, err
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(rawResponse)
+	if err != nil {
+		return User{}, nil, err
+	}
+	if len(s.data.UserID) != 0 {
+		userUid, err := uuid.FromBytes(s.data.UserID)
+		if err != nil {
+			return User{}, nil, err
+		}
+		user, err := lookup(userUid)
+		if err != nil {
+			return User{}, nil, err
+		}
+		credential, err := c.webAuthn.ValidateLogin(user, s.data, parsed)
+		return user, credential, err
+	}
+	var user User
+	_, credential, err := c.webAuthn.ValidatePasskeyLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		userUid, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+		user, err = lookup(userUid)
+		return user, err
+	}, s.data, parsed)
+	return user, credential, err
+}
+
+// Store a session, expired sessions are only removed once the limit is reached.
+//
+// errors with `ErrTooManySessions` when the limit is reached with none expired.
+func (c *Ceremonies) saveSession(s session) (string, error) {
+	rawId := make([]byte, 32)
+	if _, err := rand.Read(rawId); err != nil {
+		return "", err
+	}
+	sessionId := base64.RawURLEncoding.EncodeToString(rawId)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.sessions) >= c.maxSessions {
+		c.removeExpired()
+		if len(c.sessions) >= c.maxSessions {
+			return "", ErrTooManySessions
+		}
+	}
+	c.sessions[sessionId] = s
+	return sessionId, nil
+}
+
+// Get and remove a session, so it can only be used once.
+func (c *Ceremonies) takeSession(sessionId string) (session, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	s, exists := c.sessions[sessionId]
+	if !exists {
+		return session{}, ErrSessionInvalid
+	}
+	delete(c.sessions, sessionId)
+	if c.isExpired(s) {
+		return session{}, ErrSessionInvalid
+	}
+	return s, nil
+}
+
+func (c *Ceremonies) isExpired(s session) bool {
+	return s.data.Expires.Before(c.now())
+}
+
+// Remove expired sessions.
+//
+// Assumes mutex has been locked.
+func (c *Ceremonies) removeExpired() {
+	for sessionId, s := range c.sessions {
+		if c.isExpired(s) {
+			delete(c.sessions, sessionId)
+		}
+	}
+}
+
+// Encode a credential id for use in urls.
+func EncodeCredentialId(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+func DecodeCredentialId(v string) ([]byte, error) {
+	id, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credential id: %w", err)
+	}
+	return id, nil
+}
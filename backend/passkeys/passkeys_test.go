@@ -0,0 +1,267 @@
+package passkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/google/uuid"
+)
+
+const (
+	testRPID   = "notes.example.com"
+	testOrigin = "https://notes.example.com"
+)
+
+// A minimal software authenticator, producing "none" attestations with a P-256 key.
+type testAuthenticator struct {
+	t            *testing.T
+	origin       string
+	credentialId []byte
+	key          *ecdsa.PrivateKey
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newTestAuthenticator(t *testing.T, origin string) *testAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialId := make([]byte, 16)
+	rand.Read(credentialId)
+	return &testAuthenticator{t: t, origin: origin, credentialId: credentialId, key: key}
+}
+
+func (a *testAuthenticator) clientData(ceremonyType string, challenge []byte) []byte {
+	clientData, err := json.Marshal(map[string]string{
+		"type":      ceremonyType,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    a.origin,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return clientData
+}
+
+func (a *testAuthenticator) authData(flags byte, attestedCredential []byte) []byte {
+	rpIdHash := sha256.Sum256([]byte(testRPID))
+	authData := append(rpIdHash[:], flags)
+	authData = binary.BigEndian.AppendUint32(authData, a.signCount)
+	return append(authData, attestedCredential...)
+}
+
+func (a *testAuthenticator) create(challenge []byte, userHandle []byte) []byte {
+	a.userHandle = userHandle
+	publicKey, err := webauthncbor.Marshal(map[int]any{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	attested := make([]byte, 16) // blank aaguid
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialId)))
+	attested = append(attested, a.credentialId...)
+	attested = append(attested, publicKey...)
+	// user present, user verified, attested credential data
+	attestationObject, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(0x01|0x04|0x40, attested),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.response(map[string]string{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(a.clientData("webauthn.create", challenge)),
+		"attestationObject": base64.RawURLEncoding.EncodeToString(attestationObject),
+	})
+}
+
+func (a *testAuthenticator) get(challenge []byte) []byte {
+	a.signCount++
+	clientData := a.clientData("webauthn.get", challenge)
+	authData := a.authData(0x01|0x04, nil)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.response(map[string]string{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+		"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+		"signature":         base64.RawURLEncoding.EncodeToString(signature),
+		"userHandle":        base64.RawURLEncoding.EncodeToString(a.userHandle),
+	})
+}
+
+func (a *testAuthenticator) response(response map[string]string) []byte {
+	id := base64.RawURLEncoding.EncodeToString(a.credentialId)
+	raw, err := json.Marshal(map[string]any{
+		"id":       id,
+		"rawId":    id,
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return raw
+}
+
+func makeTestCeremonies(t *testing.T) Ceremonies {
+	ceremonies, err := Ceremonies{}.New(Options{
+		RPID:          testRPID,
+		RPDisplayName: "Note Mark",
+		RPOrigins:     []string{testOrigin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ceremonies
+}
+
+func registerTestCredential(t *testing.T, ceremonies *Ceremonies, authenticator *testAuthenticator, user *User) {
+	sessionId, creation, err := ceremonies.BeginRegistration(*user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := ceremonies.FinishRegistration(
+		sessionId,
+		*user,
+		authenticator.create(creation.Response.Challenge, user.WebAuthnID()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Credentials = append(user.Credentials, *credential)
+}
+
+func TestCeremonies(t *testing.T) {
+	ceremonies := makeTestCeremonies(t)
+	authenticator := newTestAuthenticator(t, testOrigin)
+	user := User{Uid: uuid.New(), Username: "leo"}
+	registerTestCredential(t, &ceremonies, authenticator, &user)
+	lookup := func(userUid uuid.UUID) (User, error) {
+		if userUid != user.Uid {
+			return User{}, errors.New("unknown user")
+		}
+		return user, nil
+	}
+	for _, withUser := range []bool{false, true} {
+		t.Run("", func(t *testing.T) {
+			var optionalUser *User
+			if withUser {
+				optionalUser = &user
+			}
+			sessionId, assertion, err := ceremonies.BeginLogin(optionalUser)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actualUser, credential, err := ceremonies.FinishLogin(
+				sessionId,
+				authenticator.get(assertion.Response.Challenge),
+				lookup,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actualUser.Uid != user.Uid {
+				t.Errorf("actual '%v' expect '%v'", actualUser.Uid, user.Uid)
+			}
+			if credential.Authenticator.SignCount != authenticator.signCount {
+				t.Errorf("actual '%v' expect '%v'", credential.Authenticator.SignCount, authenticator.signCount)
+			}
+			user.Credentials[0] = *credential
+		})
+	}
+}
+
+func TestCeremoniesRejects(t *testing.T) {
+	ceremonies := makeTestCeremonies(t)
+	authenticator := newTestAuthenticator(t, testOrigin)
+	user := User{Uid: uuid.New(), Username: "leo"}
+	registerTestCredential(t, &ceremonies, authenticator, &user)
+	lookup := func(userUid uuid.UUID) (User, error) { return user, nil }
+
+	// session can only be used once
+	sessionId, assertion, _ := ceremonies.BeginLogin(nil)
+	response := authenticator.get(assertion.Response.Challenge)
+	if _, _, err := ceremonies.FinishLogin(sessionId, response, lookup); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ceremonies.FinishLogin(sessionId, response, lookup); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("actual '%v' expect '%v'", err, ErrSessionInvalid)
+	}
+
+	// wrong origin
+	sessionId, assertion, _ = ceremonies.BeginLogin(nil)
+	phishing := *authenticator
+	phishing.origin = "https://notes.example.org"
+	if _, _, err := ceremonies.FinishLogin(sessionId, phishing.get(assertion.Response.Challenge), lookup); err == nil {
+		t.Errorf("actual '%v' expect error", err)
+	}
+
+	// unknown credential
+	sessionId, assertion, _ = ceremonies.BeginLogin(nil)
+	unknown := newTestAuthenticator(t, testOrigin)
+	unknown.userHandle = user.WebAuthnID()
+	if _, _, err := ceremonies.FinishLogin(sessionId, unknown.get(assertion.Response.Challenge), lookup); err == nil {
+		t.Errorf("actual '%v' expect error", err)
+	}
+
+	// expired session
+	sessionId, assertion, _ = ceremonies.BeginLogin(nil)
+	ceremonies.now = func() time.Time { return time.Now().Add(sessionTimeout + time.Minute) }
+	if _, _, err := ceremonies.FinishLogin(sessionId, authenticator.get(assertion.Response.Challenge), lookup); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("actual '%v' expect '%v'", err, ErrSessionInvalid)
+	}
+
+	// registration bound to the user that started it
+	ceremonies.now = time.Now
+	sessionId, creation, _ := ceremonies.BeginRegistration(user)
+	other := User{Uid: uuid.New(), Username: "other"}
+	second := newTestAuthenticator(t, testOrigin)
+	if _, err := ceremonies.FinishRegistration(
+		sessionId,
+		other,
+		second.create(creation.Response.Challenge, other.WebAuthnID()),
+	); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("actual '%v' expect '%v'", err, ErrSessionInvalid)
+	}
+}
+
+func TestCeremoniesMaxSessions(t *testing.T) {
+	ceremonies := makeTestCeremonies(t)
+	ceremonies.maxSessions = 2
+	for range 2 {
+		if _, _, err := ceremonies.BeginLogin(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := ceremonies.BeginLogin(nil); !errors.Is(err, ErrTooManySessions) {
+		t.Errorf("actual '%v' expect '%v'", err, ErrTooManySessions)
+	}
+	// expired sessions make room for new ones
+	ceremonies.now = func() time.Time { return time.Now().Add(sessionTimeout + time.Minute) }
+	if _, _, err := ceremonies.BeginLogin(nil); err != nil {
+		t.Errorf("actual '%v' expect '%v'", err, nil)
+	}
+	if len(ceremonies.sessions) != 1 {
+		t.Errorf("actual '%v' expect '%v'", len(ceremonies.sessions), 1)
+	}
+}
@@ -0,0 +1,354 @@
+package publish
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/render"
+	"github.com/enchant97/note-mark/backend/tree"
+)
+
+//go:embed templates/*
+var templatesFS embed.FS
+
+//go:embed templates/style.css
+var siteStyle []byte
+
+var pageTemplate = template.Must(template.ParseFS(templatesFS, "templates/page.html"))
+
+// Written into every published site,
+// so only directories created by publishing are ever replaced.
+const siteMarkerName = ".note-mark-publish"
+
+var ErrOutputNotSite = errors.New("output path is a non-empty directory that was not created by publishing")
+
+// A node selected for publishing.
+type siteNode struct {
+	FullSlug    core.NodeSlug
+	Title       string
+	Type        core.NodeType
+	Placeholder bool
+	Children    []siteNode
+}
+
+type navLink struct {
+	Href     string
+	Title    string
+	Current  bool
+	Children []navLink
+}
+
+type pageData struct {
+	SiteTitle  string
+	Title      string
+	RootHref   string
+	StyleHref  string
+	Navigation []navLink
+	Content    template.HTML
+}
+
+// Publishes public notes into a self-contained static HTML site.
+type SitePublisher struct {
+	tc *tree.TreeController
+}
+
+func (p SitePublisher) New(tc *tree.TreeController) SitePublisher {
+	return SitePublisher{
+		tc: tc,
+	}
+}
+
+// Publish a users public notes into the output directory.
+//
+// The site is built into a temporary directory first,
+// replacing any existing output once complete.
+//
+// errors with `ErrOutputNotSite` when the existing output is not a previously published site.
+func (p *SitePublisher) PublishUser(username core.Username, outputPath string) error {
+	outputPath, err := filepath.Abs(outputPath)
+	if err != nil {
+		return err
+	}
+	nodeTree, err := p.tc.TryGetNodeTreeForUser(username)
+	if err != nil {
+		return err
+	}
+	nodes := selectPublicSiteNodes(nodeTree)
+	published := map[core.NodeSlug]core.NodeType{}
+	walkSiteNodes(nodes, func(node siteNode) {
+		if !node.Placeholder {
+			published[node.FullSlug] = node.Type
+		}
+	})
+	if err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm); err != nil {
+		return err
+	}
+	buildPath, err := os.MkdirTemp(filepath.Dir(outputPath), ".publish-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(buildPath)
+	builder := siteBuilder{
+		tc:        p.tc,
+		username:  username,
+		buildPath: buildPath,
+		nodes:     nodes,
+		published: published,
+	}
+	builder.renderer = render.MarkdownRenderer{}.New(builder.resolveLink)
+	if err := builder.build(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(buildPath, siteMarkerName), nil, 0o644); err != nil {
+		return err
+	}
+	if err := checkReplaceableOutput(outputPath); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(outputPath); err != nil {
+		return err
+	}
+	if err := os.Rename(buildPath, outputPath); err != nil {
+		return err
+	}
+	// temporary directories are created with restricted permissions
+	return os.Chmod(outputPath, 0o755)
+}
+
+// Select the public nodes to publish in a stable order.
+func selectPublicSiteNodes(nodeTree core.NodeTree) []siteNode {
+	return selectSiteNodes(tree.FilteredNodeTree(nodeTree, nil), "")
+}
+
+// Select nodes to publish in a stable order, skipping any trash.
+func selectSiteNodes(nodeTree core.NodeTree, parentSlug core.NodeSlug) []siteNode {
+	nodes := []siteNode{}
+	for slug, node := range nodeTree {
+		if parentSlug == "" && slug == ".trash" {
+			continue
+		}
+		fullSlug := slug
+		if parentSlug != "" {
+			fullSlug = core.NodeSlug(path.Join(string(parentSlug), string(slug)))
+		}
+		n := siteNode{
+			FullSlug:    fullSlug,
+			Title:       string(slug),
+			Type:        node.Type,
+			Placeholder: node.Placeholder,
+		}
+		if node.NoteNodeFields != nil {
+			if node.FrontMatter.Title != "" {
+				n.Title = node.FrontMatter.Title
+			}
+			n.Children = selectSiteNodes(node.Children, fullSlug)
+		}
+		nodes = append(nodes, n)
+	}
+	slices.SortFunc(nodes, func(a, b siteNode) int {
+		return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+	})
+	return nodes
+}
+
+func walkSiteNodes(nodes []siteNode, fn func(node siteNode)) {
+	for _, node := range nodes {
+		fn(node)
+		walkSiteNodes(node.Children, fn)
+	}
+}
+
+type siteBuilder struct {
+	tc        *tree.TreeController
+	renderer  render.MarkdownRenderer
+	username  core.Username
+	buildPath string
+	nodes     []siteNode
+	published map[core.NodeSlug]core.NodeType
+}
+
+func (b *siteBuilder) build() error {
+	if err := os.WriteFile(filepath.Join(b.buildPath, "style.css"), siteStyle, 0o644); err != nil {
+		return err
+	}
+	if err := b.writePage("", string(b.username), nil); err != nil {
+		return err
+	}
+	var err error
+	walkSiteNodes(b.nodes, func(node siteNode) {
+		if err != nil || node.Placeholder {
+			return
+		}
+		if node.Type == core.NoteNode {
+			err = b.buildNote(node)
+		} else {
+			err = b.copyAsset(node)
+		}
+	})
+	return err
+}
+
+func (b *siteBuilder) buildNote(node siteNode) error {
+	slog.Debug("publish note", "username", b.username, "slug", node.FullSlug)
+	r, err := b.tc.GetNoteNodeContent(b.username, node.FullSlug)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	content, _, err := b.renderer.RenderNote(b.username, node.FullSlug, raw)
+	if err != nil {
+		return err
+	}
+	return b.writePage(node.FullSlug, node.Title, content)
+}
+
+func (b *siteBuilder) copyAsset(node siteNode) error {
+	slog.Debug("publish asset", "username", b.username, "slug", node.FullSlug)
+	r, err := b.tc.GetAssetNodeContent(b.username, node.FullSlug)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	dst := filepath.Join(b.buildPath, filepath.FromSlash(string(node.FullSlug)))
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// Write a page into `<slug>/index.html`, use a blank slug for the site index.
+func (b *siteBuilder) writePage(fullSlug core.NodeSlug, title string, content []byte) error {
+	pageDir := filepath.Join(b.buildPath, filepath.FromSlash(string(fullSlug)))
+	if err := os.MkdirAll(pageDir, os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(pageDir, "index.html"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return pageTemplate.Execute(f, pageData{
+		SiteTitle:  string(b.username),
+		Title:      title,
+		RootHref:   relativeHref(fullSlug, "", false),
+		StyleHref:  relativeHref(fullSlug, "style.css", true),
+		Navigation: b.makeNavigation(b.nodes, fullSlug),
+		Content:    template.HTML(content),
+	})
+}
+
+func (b *siteBuilder) makeNavigation(nodes []siteNode, currentSlug core.NodeSlug) []navLink {
+	links := []navLink{}
+	for _, node := range nodes {
+		if node.Type != core.NoteNode {
+			continue
+		}
+		link := navLink{
+			Title:    node.Title,
+			Current:  node.FullSlug == currentSlug,
+			Children: b.makeNavigation(node.Children, currentSlug),
+		}
+		// placeholders have no page to link to
+		if !node.Placeholder {
+			link.Href = relativeHref(currentSlug, node.FullSlug, false)
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+// Rewrite internal links to point at published pages and assets.
+// Links to anything not published are left untouched.
+func (b *siteBuilder) resolveLink(username core.Username, fullSlug core.NodeSlug, dest string) string {
+	absPath, fragment, isAsset, ok := render.ResolveLinkPath(username, fullSlug, dest)
+	if !ok {
+		return dest
+	}
+	targetSlug, found := strings.CutPrefix(absPath, "/"+string(b.username)+"/")
+	if !found {
+		return dest
+	}
+	if _, exists := b.published[core.NodeSlug(targetSlug)]; !exists {
+		return dest
+	}
+	href := relativeHref(fullSlug, core.NodeSlug(targetSlug), isAsset)
+	if fragment != "" {
+		href += "#" + fragment
+	}
+	return href
+}
+
+// Make a relative href from the page of one slug to another slug.
+// Note pages are directories, so will end with a trailing slash.
+func relativeHref(fromSlug core.NodeSlug, toSlug core.NodeSlug, isFile bool) string {
+	var fromParts, toParts []string
+	if fromSlug != "" {
+		fromParts = strings.Split(string(fromSlug), "/")
+	}
+	if toSlug != "" {
+		toParts = strings.Split(string(toSlug), "/")
+	}
+	common := 0
+	for common < len(fromParts) && common < len(toParts) && fromParts[common] == toParts[common] {
+		common++
+	}
+	href := strings.Repeat("../", len(fromParts)-common)
+	if rest := toParts[common:]; len(rest) != 0 {
+		href += render.EscapePath(strings.Join(rest, "/"))
+		if !isFile {
+			href += "/"
+		}
+	}
+	if href == "" {
+		return "./"
+	}
+	return href
+}
+
+// Ensure an output path is usable before publishing starts.
+func ValidateOutputPath(outputPath string) error {
+	if outputPath == "" {
+		return errors.New("output path must not be empty")
+	}
+	if info, err := os.Stat(outputPath); err == nil && !info.IsDir() {
+		return errors.New("output path must be a directory")
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return checkReplaceableOutput(outputPath)
+}
+
+// Check the output can be replaced, it must be missing, empty or a previously published site.
+func checkReplaceableOutput(outputPath string) error {
+	entries, err := os.ReadDir(outputPath)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(entries) == 0) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(outputPath, siteMarkerName)); errors.Is(err, os.ErrNotExist) {
+		return ErrOutputNotSite
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package publish
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/enchant97/note-mark/backend/core"
+)
+
+func TestRelativeHref(t *testing.T) {
+	tests := []struct {
+		fromSlug core.NodeSlug
+		toSlug   core.NodeSlug
+		isFile   bool
+		expect   string
+	}{
+		{"", "", false, "./"},
+		{"", "notes", false, "notes/"},
+		{"", "style.css", true, "style.css"},
+		{"notes", "notes", false, "./"},
+		{"notes", "notes/child", false, "child/"},
+		{"notes/child", "notes", false, "../"},
+		{"notes/child", "", false, "../../"},
+		{"notes/child", "style.css", true, "../../style.css"},
+		{"notes/child", "notes/child/image.png", true, "image.png"},
+		{"notes/child", "other/My Note", false, "../../other/My%20Note/"},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := relativeHref(tt.fromSlug, tt.toSlug, tt.isFile)
+			if actual != tt.expect {
+				t.Errorf("actual '%v' expect '%v' (from '%s' to '%s')", actual, tt.expect, tt.fromSlug, tt.toSlug)
+			}
+		})
+	}
+}
+
+func TestSelectPublicSiteNodes(t *testing.T) {
+	makeNote := func(ac *core.AccessControl, children core.NodeTree) *core.Node {
+		return &core.Node{
+			Type: core.NoteNode,
+			NoteNodeFields: &core.NoteNodeFields{
+				FrontMatter: core.FrontMatter{AccessControl: ac},
+				Children:    children,
+			},
+		}
+	}
+	nodeTree := core.NodeTree{
+		"notes": makeNote(&core.AccessControl{PublicRead: true}, core.NodeTree{
+			"child": makeNote(nil, core.NodeTree{}),
+		}),
+		"secret": makeNote(nil, core.NodeTree{
+			"child": makeNote(nil, core.NodeTree{}),
+		}),
+	}
+	actual := []core.NodeSlug{}
+	walkSiteNodes(selectPublicSiteNodes(nodeTree), func(node siteNode) {
+		actual = append(actual, node.FullSlug)
+	})
+	expect := []core.NodeSlug{"notes", "notes/child"}
+	if !slices.Equal(actual, expect) {
+		t.Errorf("actual '%v' expect '%v'", actual, expect)
+	}
+}
+
+func TestValidateOutputPath(t *testing.T) {
+	root := t.TempDir()
+	makeDir := func(name string, files ...string) string {
+		dirPath := filepath.Join(root, name)
+		if err := os.Mkdir(dirPath, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range files {
+			if err := os.WriteFile(filepath.Join(dirPath, file), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dirPath
+	}
+	filePath := filepath.Join(makeDir("parent", "file.txt"), "file.txt")
+	tests := []struct {
+		outputPath string
+		expectErr  bool
+	}{
+		{"", true},
+		{filepath.Join(root, "missing"), false},
+		{makeDir("empty"), false},
+		{makeDir("site", siteMarkerName, "index.html"), false},
+		{makeDir("documents", "important.txt"), true},
+		{filePath, true},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			err := ValidateOutputPath(tt.outputPath)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("actual '%v' expect error '%v' (path '%s')", err, tt.expectErr, tt.outputPath)
+			}
+		})
+	}
+	if err := ValidateOutputPath(filepath.Join(root, "documents")); !errors.Is(err, ErrOutputNotSite) {
+		t.Errorf("actual '%v' expect '%v'", err, ErrOutputNotSite)
+	}
+}
@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Failures recorded against a single key.
+type Entry struct {
+	Failures      uint
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// Optional persistence for entries, so lockouts survive restarts.
+type Store interface {
+	LoadEntries() (map[string]Entry, error)
+	SaveEntry(key string, entry Entry) error
+	DeleteEntry(key string) error
+}
+
+type Options struct {
+	// failures allowed before a key is locked out
+	MaxFailures uint
+	// lockout after reaching max failures, doubled for every further failure
+	Lockout    time.Duration
+	MaxLockout time.Duration
+	// failures are forgotten when none have happened within this duration
+	Window time.Duration
+}
+
+type ErrLocked struct {
+	RetryAfter time.Duration
+}
+
+func (e ErrLocked) Error() string {
+	return fmt.Sprintf("too many failed attempts, retry after %s", e.RetryAfter)
+}
+
+// Tracks failures per key, locking keys out with exponential backoff.
+type Limiter struct {
+	options Options
+	store   Store
+	mutex   *sync.Mutex
+	entries map[string]Entry
+	now     func() time.Time
+}
+
+// Create a new limiter, loading existing entries if a store is given.
+func (l Limiter) New(options Options, store Store) (Limiter, error) {
+	l = Limiter{
+		options: options,
+		store:   store,
+		mutex:   &sync.Mutex{},
+		entries: map[string]Entry{},
+		now:     time.Now,
+	}
+	if store != nil {
+		entries, err := store.LoadEntries()
+		if err != nil {
+			return Limiter{}, err
+		}
+		for key, entry := range entries {
+			if !l.isExpired(entry) {
+				l.entries[key] = entry
+			}
+		}
+	}
+	return l, nil
+}
+
+// Check whether any of the keys are locked out, returning `ErrLocked` with the longest wait.
+func (l *Limiter) Check(keys ...string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := l.now()
+	var retryAfter time.Duration
+	for _, key := range keys {
+		if entry, exists := l.entries[key]; exists && entry.LockedUntil.After(now) {
+			retryAfter = max(retryAfter, entry.LockedUntil.Sub(now))
+		}
+	}
+	if retryAfter > 0 {
+		return ErrLocked{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Record a failure for each key, returns the keys that are now locked out.
+func (l *Limiter) Fail(keys ...string) []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := l.now()
+	locked := []string{}
+	for _, key := range keys {
+		entry := l.entries[key]
+		if l.isExpired(entry) {
+			entry = Entry{}
+		}
+		entry.Failures++
+		entry.LastFailureAt = now
+		if entry.Failures >= l.options.MaxFailures {
+			entry.LockedUntil = now.Add(l.lockoutFor(entry.Failures))
+			locked = append(locked, key)
+		}
+		l.entries[key] = entry
+		if l.store != nil {
+			if err := l.store.SaveEntry(key, entry); err != nil {
+				slog.Error("failed to save rate limit entry", "key", key, "err", err)
+			}
+		}
+	}
+	l.removeExpired()
+	return locked
+}
+
+// Forget failures for each key, used after a successful attempt.
+func (l *Limiter) Reset(keys ...string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, key := range keys {
+		if _, exists := l.entries[key]; !exists {
+			continue
+		}
+		delete(l.entries, key)
+		if l.store != nil {
+			if err := l.store.DeleteEntry(key); err != nil {
+				slog.Error("failed to delete rate limit entry", "key", key, "err", err)
+			}
+		}
+	}
+}
+
+func (l *Limiter) lockoutFor(failures uint) time.Duration {
+	lockout := l.options.Lockout
+	for i := l.options.MaxFailures; i < failures && lockout < l.options.MaxLockout; i++ {
+		lockout *= 2
+	}
+	return min(lockout, l.options.MaxLockout)
+}
+
+// Whether an entry no longer has any effect.
+func (l *Limiter) isExpired(entry Entry) bool {
+	now := l.now()
+	return !entry.LockedUntil.After(now) && now.Sub(entry.LastFailureAt) > l.options.Window
+}
+
+// Remove expired entries.
+//
+// Assumes mutex has been locked.
+func (l *Limiter) removeExpired() {
+	for key, entry := range l.entries {
+		if !l.isExpired(entry) {
+			continue
+		}
+		delete(l.entries, key)
+		if l.store != nil {
+			if err := l.store.DeleteEntry(key); err != nil {
+				slog.Error("failed to delete rate limit entry", "key", key, "err", err)
+			}
+		}
+	}
+}
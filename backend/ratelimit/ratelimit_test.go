@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type testStore struct {
+	entries map[string]Entry
+}
+
+func (s *testStore) LoadEntries() (map[string]Entry, error) {
+	return s.entries, nil
+}
+
+func (s *testStore) SaveEntry(key string, entry Entry) error {
+	s.entries[key] = entry
+	return nil
+}
+
+func (s *testStore) DeleteEntry(key string) error {
+	delete(s.entries, key)
+	return nil
+}
+
+func makeTestLimiter(t *testing.T, store Store, now *time.Time) Limiter {
+	limiter, err := Limiter{}.New(Options{
+		MaxFailures: 3,
+		Lockout:     time.Minute,
+		MaxLockout:  5 * time.Minute,
+		Window:      15 * time.Minute,
+	}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter.now = func() time.Time { return *now }
+	return limiter
+}
+
+func getTestRetryAfter(err error) time.Duration {
+	var errLocked ErrLocked
+	if errors.As(err, &errLocked) {
+		return errLocked.RetryAfter
+	}
+	return 0
+}
+
+func TestLimiterLockout(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	limiter := makeTestLimiter(t, nil, &now)
+	tests := []struct {
+		advance    time.Duration
+		fail       bool
+		expectWait time.Duration
+	}{
+		{0, true, 0},
+		{0, true, 0},
+		{0, true, time.Minute},
+		{30 * time.Second, false, 30 * time.Second},
+		{30 * time.Second, true, 2 * time.Minute},
+		{2 * time.Minute, true, 4 * time.Minute},
+		{4 * time.Minute, true, 5 * time.Minute},
+		{5 * time.Minute, true, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		now = now.Add(tt.advance)
+		if tt.fail {
+			limiter.Fail("ip:1.2.3.4")
+		}
+		actual := getTestRetryAfter(limiter.Check("ip:1.2.3.4", "user:leo"))
+		if actual != tt.expectWait {
+			t.Errorf("actual '%v' expect '%v'", actual, tt.expectWait)
+		}
+	}
+	limiter.Reset("ip:1.2.3.4")
+	if err := limiter.Check("ip:1.2.3.4"); err != nil {
+		t.Errorf("actual '%v' expect '%v'", err, nil)
+	}
+}
+
+func TestLimiterWindow(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	limiter := makeTestLimiter(t, nil, &now)
+	limiter.Fail("user:leo")
+	limiter.Fail("user:leo")
+	now = now.Add(16 * time.Minute)
+	limiter.Fail("user:leo")
+	if err := limiter.Check("user:leo"); err != nil {
+		t.Errorf("actual '%v' expect '%v'", err, nil)
+	}
+	if actual := limiter.entries["user:leo"].Failures; actual != 1 {
+		t.Errorf("actual '%v' expect '%v'", actual, 1)
+	}
+}
+
+func TestLimiterStore(t *testing.T) {
+	now := time.Now()
+	store := &testStore{entries: map[string]Entry{
+		"user:old": {Failures: 2, LastFailureAt: now.Add(-time.Hour)},
+	}}
+	limiter := makeTestLimiter(t, store, &now)
+	if _, exists := limiter.entries["user:old"]; exists {
+		t.Errorf("actual '%v' expect '%v'", exists, false)
+	}
+	limiter.Fail("user:leo", "ip:1.2.3.4")
+	limiter.Fail("user:leo")
+	limiter.Fail("user:leo")
+	// a restarted limiter should keep the lockout
+	restarted := makeTestLimiter(t, store, &now)
+	if actual := getTestRetryAfter(restarted.Check("user:leo")); actual != time.Minute {
+		t.Errorf("actual '%v' expect '%v'", actual, time.Minute)
+	}
+	restarted.Reset("user:leo")
+	if _, exists := store.entries["user:leo"]; exists {
+		t.Errorf("actual '%v' expect '%v'", exists, false)
+	}
+}
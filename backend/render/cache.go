@@ -0,0 +1,74 @@
+package render
+
+import (
+	"sync"
+	"time"
+
+	"github.com/enchant97/note-mark/backend/core"
+)
+
+type cacheKey struct {
+	username core.Username
+	fullSlug core.NodeSlug
+}
+
+type cacheEntry struct {
+	modTime time.Time
+	html    []byte
+}
+
+// A bounded in-memory cache of rendered notes,
+// entries are only valid while the node modification time matches.
+type RenderCache struct {
+	mutex      *sync.Mutex
+	maxEntries int
+	entries    map[cacheKey]cacheEntry
+	order      []cacheKey
+}
+
+func (c RenderCache) New(maxEntries int) RenderCache {
+	return RenderCache{
+		mutex:      &sync.Mutex{},
+		maxEntries: maxEntries,
+		entries:    map[cacheKey]cacheEntry{},
+		order:      []cacheKey{},
+	}
+}
+
+// Get a cached render, if one exists for the given modification time.
+func (c *RenderCache) Get(
+	username core.Username,
+	fullSlug core.NodeSlug,
+	modTime time.Time,
+) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, exists := c.entries[cacheKey{username, fullSlug}]
+	if !exists || !entry.modTime.Equal(modTime) {
+		return nil, false
+	}
+	return entry.html, true
+}
+
+// Set a render in cache, evicting the oldest entries when full.
+func (c *RenderCache) Set(
+	username core.Username,
+	fullSlug core.NodeSlug,
+	modTime time.Time,
+	html []byte,
+) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	key := cacheKey{username, fullSlug}
+	if _, exists := c.entries[key]; !exists {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = cacheEntry{
+		modTime: modTime,
+		html:    html,
+	}
+	for len(c.order) > c.maxEntries {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
@@ -0,0 +1,161 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/adrg/frontmatter"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var noteLocationContextKey = parser.NewContextKey()
+
+type noteLocation struct {
+	username core.Username
+	fullSlug core.NodeSlug
+}
+
+// Resolves a link destination found inside a note,
+// returning the destination that should be used instead.
+type LinkResolver func(username core.Username, fullSlug core.NodeSlug, dest string) string
+
+// Renders markdown notes into sanitized HTML.
+type MarkdownRenderer struct {
+	md     goldmark.Markdown
+	policy *bluemonday.Policy
+}
+
+// Create a new renderer, rewriting link destinations with the given resolver.
+func (r MarkdownRenderer) New(resolver LinkResolver) MarkdownRenderer {
+	policy := bluemonday.UGCPolicy()
+	policy.AllowAttrs("class").Matching(bluemonday.SpaceSeparatedTokens).OnElements("code", "span", "div")
+	policy.AllowAttrs("type", "checked", "disabled").OnElements("input")
+	return MarkdownRenderer{
+		md: goldmark.New(
+			goldmark.WithExtensions(extension.GFM, extension.Footnote),
+			goldmark.WithParserOptions(
+				parser.WithAutoHeadingID(),
+				parser.WithASTTransformers(util.Prioritized(&linkTransformer{
+					resolver: resolver,
+				}, 100)),
+			),
+		),
+		policy: policy,
+	}
+}
+
+// Render a raw note (including any frontmatter) into sanitized HTML.
+func (r *MarkdownRenderer) RenderNote(
+	username core.Username,
+	fullSlug core.NodeSlug,
+	raw []byte,
+) ([]byte, core.FrontMatter, error) {
+	var fm core.FrontMatter
+	content, err := frontmatter.Parse(bytes.NewReader(raw), &fm)
+	if err != nil {
+		return nil, core.FrontMatter{}, errors.Join(err, core.ErrParsingContent)
+	}
+	ctx := parser.NewContext()
+	ctx.Set(noteLocationContextKey, noteLocation{
+		username: username,
+		fullSlug: fullSlug,
+	})
+	var buf bytes.Buffer
+	if err := r.md.Convert(content, &buf, parser.WithContext(ctx)); err != nil {
+		return nil, core.FrontMatter{}, errors.Join(err, core.ErrParsingContent)
+	}
+	return r.policy.SanitizeBytes(buf.Bytes()), fm, nil
+}
+
+type linkTransformer struct {
+	resolver LinkResolver
+}
+
+func (t *linkTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	location, ok := pc.Get(noteLocationContextKey).(noteLocation)
+	if !ok {
+		return
+	}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.Link:
+			node.Destination = []byte(t.resolver(location.username, location.fullSlug, string(node.Destination)))
+		case *ast.Image:
+			node.Destination = []byte(t.resolver(location.username, location.fullSlug, string(node.Destination)))
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// Resolve a link destination into an absolute app path e.g. `/leo/my-note/image.png`,
+// following how the frontend resolves paths from a note's location.
+//
+// Will return false for destinations that are not internal links, like absolute URLs.
+func ResolveLinkPath(
+	username core.Username,
+	fullSlug core.NodeSlug,
+	dest string,
+) (absPath string, fragment string, isAsset bool, ok bool) {
+	if u, err := url.Parse(dest); err != nil || u.IsAbs() || u.Host != "" {
+		return "", "", false, false
+	}
+	destPath, fragment, _ := strings.Cut(dest, "#")
+	if destPath == "" {
+		return "", "", false, false
+	}
+	if unescaped, err := url.PathUnescape(destPath); err == nil {
+		destPath = unescaped
+	}
+	isAsset = path.Ext(destPath) != ""
+	if strings.HasPrefix(destPath, "/") {
+		absPath = path.Clean(destPath)
+	} else if isAsset {
+		// assets are relative to the note itself
+		absPath = path.Join("/", string(username), string(fullSlug), destPath)
+	} else {
+		// notes are relative to the note's parent, like a browser would
+		absPath = path.Join("/", string(username), path.Dir(string(fullSlug)), destPath)
+	}
+	return absPath, fragment, isAsset, true
+}
+
+// Escape each part of a slash separated path.
+func EscapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// Make a resolver that points asset links towards the content API,
+// matching the behaviour of the frontend renderer.
+//
+// e.g. `https://notemark.example.com/api`
+func APILinkResolver(apiBaseUrl string) LinkResolver {
+	apiBaseUrl = strings.TrimSuffix(apiBaseUrl, "/")
+	return func(username core.Username, fullSlug core.NodeSlug, dest string) string {
+		absPath, fragment, isAsset, ok := ResolveLinkPath(username, fullSlug, dest)
+		if !ok || !isAsset {
+			return dest
+		}
+		resolved := apiBaseUrl + "/tree/content/u" + EscapePath(absPath)
+		if fragment != "" {
+			resolved += "#" + fragment
+		}
+		return resolved
+	}
+}
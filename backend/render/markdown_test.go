@@ -0,0 +1,116 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAPILinkResolver(t *testing.T) {
+	resolver := APILinkResolver("https://example.com/api")
+	tests := []struct {
+		dest   string
+		expect string
+	}{
+		{"image.png", "https://example.com/api/tree/content/u/leo/my-note/child/image.png"},
+		{"../image.png", "https://example.com/api/tree/content/u/leo/my-note/image.png"},
+		{"My Picture.jpg", "https://example.com/api/tree/content/u/leo/my-note/child/My%20Picture.jpg"},
+		{"My%20Picture.jpg", "https://example.com/api/tree/content/u/leo/my-note/child/My%20Picture.jpg"},
+		{"/steve/notes/file.pdf", "https://example.com/api/tree/content/u/steve/notes/file.pdf"},
+		{"file.pdf#page=2", "https://example.com/api/tree/content/u/leo/my-note/child/file.pdf#page=2"},
+		{"https://example.org/image.png", "https://example.org/image.png"},
+		{"//example.org/image.png", "//example.org/image.png"},
+		{"mailto:leo@example.com", "mailto:leo@example.com"},
+		{"/leo/other-note", "/leo/other-note"},
+		{"#heading", "#heading"},
+		{"other-note", "other-note"},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := resolver("leo", "my-note/child", tt.dest)
+			if actual != tt.expect {
+				t.Errorf("actual '%v' expect '%v' (dest '%s')", actual, tt.expect, tt.dest)
+			}
+		})
+	}
+}
+
+func TestMarkdownRendererRenderNote(t *testing.T) {
+	renderer := MarkdownRenderer{}.New(APILinkResolver("https://example.com/api"))
+	tests := []struct {
+		raw        string
+		contains   []string
+		excludes   []string
+		expectedFm string
+	}{
+		{
+			"---\ntitle: Hello\n---\n# Hi\n![pic](pic.png)",
+			[]string{"<h1 id=\"hi\">Hi</h1>", "src=\"https://example.com/api/tree/content/u/leo/note/pic.png\""},
+			[]string{"title: Hello"},
+			"Hello",
+		},
+		{
+			"| a | b |\n|---|---|\n| 1 | 2 |\n\n- [x] done\n\n~~old~~",
+			[]string{"<table>", "<del>old</del>", "checked"},
+			nil,
+			"",
+		},
+		{
+			"<script>alert(1)</script>\n\n[click](javascript:alert(1))\n\n<img src=x onerror=alert(1)>",
+			nil,
+			[]string{"<script", "javascript:", "onerror"},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			html, fm, err := renderer.RenderNote("leo", "note", []byte(tt.raw))
+			if err != nil {
+				t.Fatalf("unexpected err '%v'", err)
+			}
+			for _, v := range tt.contains {
+				if !strings.Contains(string(html), v) {
+					t.Errorf("expected '%s' in '%s'", v, html)
+				}
+			}
+			for _, v := range tt.excludes {
+				if strings.Contains(string(html), v) {
+					t.Errorf("unexpected '%s' in '%s'", v, html)
+				}
+			}
+			if fm.Title != tt.expectedFm {
+				t.Errorf("actual title '%v' expect '%v'", fm.Title, tt.expectedFm)
+			}
+		})
+	}
+}
+
+func TestResolveLinkPath(t *testing.T) {
+	tests := []struct {
+		dest          string
+		expectPath    string
+		expectIsAsset bool
+		expectOk      bool
+	}{
+		{"image.png", "/leo/notes/child/image.png", true, true},
+		{"sibling", "/leo/notes/sibling", false, true},
+		{"child/nested", "/leo/notes/child/nested", false, true},
+		{"../top", "/leo/top", false, true},
+		{"/steve/notes", "/steve/notes", false, true},
+		{"My%20Note", "/leo/notes/My Note", false, true},
+		{"https://example.com", "", false, false},
+		{"#heading", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actualPath, _, actualIsAsset, actualOk := ResolveLinkPath("leo", "notes/child", tt.dest)
+			if actualPath != tt.expectPath || actualIsAsset != tt.expectIsAsset || actualOk != tt.expectOk {
+				t.Errorf(
+					"actual '%v' '%v' '%v' expect '%v' '%v' '%v' (dest '%s')",
+					actualPath, actualIsAsset, actualOk,
+					tt.expectPath, tt.expectIsAsset, tt.expectOk,
+					tt.dest,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/db"
+	"github.com/enchant97/note-mark/backend/tree"
+	"github.com/enchant97/note-mark/backend/usercache"
+	"github.com/google/uuid"
+)
+
+var ErrAdminSelfAction = errors.New("cannot perform action on own account")
+var ErrUsernameInvalid = errors.New("username invalid")
+
+type AdminService struct {
+	dao            *db.DAO
+	tc             *tree.TreeController
+	audit          *AuditService
+	userCache      *usercache.Cache
+	passwordPolicy core.PasswordPolicy
+}
+
+func (s AdminService) New(
+	dao *db.DAO,
+	tc *tree.TreeController,
+	audit *AuditService,
+	userCache *usercache.Cache,
+	passwordPolicy core.PasswordPolicy,
+) AdminService {
+	return AdminService{
+		dao:            dao,
+		tc:             tc,
+		audit:          audit,
+		userCache:      userCache,
+		passwordPolicy: passwordPolicy,
+	}
+}
+
+// Get every user that has not been deleted.
+func (s *AdminService) GetUsers() ([]core.AdminUser, error) {
+	rows, err := s.dao.Queries.AdminGetUsers(context.Background())
+	if err != nil {
+		return nil, core.WrapDbError(err)
+	}
+	users := make([]core.AdminUser, len(rows))
+	for i, row := range rows {
+		users[i] = core.AdminUser{
+			Uid:        row.Uid,
+			CreatedAt:  row.CreatedAt,
+			Username:   row.Username,
+			Name:       core.NullStringToStringPtr(row.Name),
+			IsAdmin:    row.IsAdmin,
+			DisabledAt: nullTimeToTimePtr(row.DisabledAt),
+		}
+	}
+	return users, nil
+}
+
+// Disable or enable a user, disabled users cannot login or use existing tokens.
+func (s *AdminService) SetUserDisabled(
+	authenticatedUser *core.AuthenticatedUser,
+	username string,
+	disabled bool,
+) error {
+	if authenticatedUser.Username == username {
+		return ErrAdminSelfAction
+	}
+	userUid, err := core.WrapDbErrorWithValue(
+		s.dao.Queries.GetUserUidByUsername(context.Background(), username),
+	)
+	if err != nil {
+		return err
+	}
+	disabledAt := sql.NullTime{}
+	if disabled {
+		disabledAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+	count, err := s.dao.Queries.AdminSetUserDisabled(context.Background(), db.AdminSetUserDisabledParams{
+		DisabledAt: disabledAt,
+		Username:   username,
+	})
+	if err != nil {
+		return core.WrapDbError(err)
+	}
+	if count == 0 {
+		return core.ErrNotFound
+	}
+	s.userCache.Invalidate(userUid)
+	return nil
+}
+
+// Mark a user as deleted, their data is removed by the clean command.
+func (s *AdminService) DeleteUser(authenticatedUser *core.AuthenticatedUser, username string) error {
+	if authenticatedUser.Username == username {
+		return ErrAdminSelfAction
+	}
+	userUid, err := core.WrapDbErrorWithValue(
+		s.dao.Queries.GetUserUidByUsername(context.Background(), username),
+	)
+	if err != nil {
+		return err
+	}
+	if err := s.dao.Queries.MarkUserAsDeleted(context.Background(), userUid); err != nil {
+		return core.WrapDbError(err)
+	}
+	s.userCache.Invalidate(userUid)
+	return nil
+}
+
+// Rename a user, their old username will redirect to the new one.
+func (s *AdminService) RenameUser(
+	authenticatedUser *core.AuthenticatedUser,
+	username core.Username,
+	v core.AdminRenameUser,
+) error {
+	userUid, err := RenameUser(s.dao, s.tc, username, v.NewUsername)
+	if err != nil {
+		return err
+	}
+	s.userCache.Invalidate(userUid)
+	s.audit.Record(core.CreateAuditEntry{
+		Event:    core.AuditUserRename,
+		Actor:    core.NewAuditActor(authenticatedUser, nil),
+		Username: &username,
+		Details:  map[string]core.Username{"newUsername": v.NewUsername},
+	})
+	return nil
+}
+
+// Set a new password for a user, without requiring their existing one.
+func (s *AdminService) ResetUserPassword(username string, v core.AdminResetPassword) error {
+	if err := s.passwordPolicy.Check(username, v.NewPassword); err != nil {
+		return err
+	}
+	userUid, err := core.WrapDbErrorWithValue(
+		s.dao.Queries.GetUserUidByUsername(context.Background(), username),
+	)
+	if err != nil {
+		return err
+	}
+	if err := s.dao.Queries.UpdateUserPasswordByUsername(
+		context.Background(),
+		db.UpdateUserPasswordByUsernameParams{
+			Username:     username,
+			PasswordHash: core.HashPassword(v.NewPassword),
+		}); err != nil {
+		return core.WrapDbError(err)
+	}
+	if err := RevokeUserTokens(s.dao, userUid); err != nil {
+		return err
+	}
+	s.userCache.Invalidate(userUid)
+	return nil
+}
+
+// Get hit rates for the cache of users making authenticated requests.
+func (s *AdminService) GetUserCacheStats() usercache.Stats {
+	return s.userCache.Stats()
+}
+
+// Rebuild the tree cache for a single user from storage.
+func (s *AdminService) ClearTreeCacheForUser(username core.Username) error {
+	slog.Info("rebuilding tree cache", "username", username)
+	return s.tc.ReloadUser(username)
+}
+
+// Rebuild the tree cache for every user from storage.
+func (s *AdminService) ClearTreeCache() error {
+	for _, username := range s.tc.GetUsernames() {
+		if err := s.ClearTreeCacheForUser(username); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Permanently delete everything in a users trash.
+func (s *AdminService) PurgeTrashForUser(authenticatedUser *core.AuthenticatedUser, username core.Username) error {
+	if _, err := s.tc.TryGetNodeTreeForUser(username); err != nil {
+		return err
+	}
+	slug := core.NodeSlug(".trash")
+	err := s.tc.DeleteNode(username, slug)
+	if errors.Is(err, core.ErrNotFound) {
+		// trash is already empty
+		return nil
+	} else if err != nil {
+		return err
+	}
+	s.audit.Record(core.CreateAuditEntry{
+		Event:    core.AuditNodeDelete,
+		Actor:    core.NewAuditActor(authenticatedUser, nil),
+		Username: &username,
+		Slug:     &slug,
+	})
+	return nil
+}
+
+// Permanently delete everything in every users trash.
+func (s *AdminService) PurgeTrash(authenticatedUser *core.AuthenticatedUser) error {
+	for _, username := range s.tc.GetUsernames() {
+		slog.Info("delete trash for user", "username", username)
+		if err := s.PurgeTrashForUser(authenticatedUser, username); err != nil && !errors.Is(err, core.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get storage usage for every user.
+func (s *AdminService) GetStorageUsage() ([]core.StorageUsage, error) {
+	usernames := s.tc.GetUsernames()
+	usages := make([]core.StorageUsage, 0, len(usernames))
+	for _, username := range usernames {
+		usage, err := s.tc.GetStorageUsageForUser(username)
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
+
+// Rename a user, keeping the old username as a redirect.
+//
+// Their nodes are moved in storage and access control of every user referencing them is updated.
+// Audit entries are left with the username used at the time.
+func RenameUser(
+	dao *db.DAO,
+	tc *tree.TreeController,
+	username core.Username,
+	newUsername core.Username,
+) (uuid.UUID, error) {
+	if !core.IsValidUsername(string(newUsername)) {
+		return uuid.UUID{}, ErrUsernameInvalid
+	}
+	// ensure the tree is loaded, before the database is locked by the transaction
+	if _, err := tc.TryGetNodeTreeForUser(username); err != nil {
+		return uuid.UUID{}, err
+	}
+	tx, err := dao.DB.BeginTx(context.Background(), &sql.TxOptions{})
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	defer tx.Rollback()
+	q := dao.Queries.WithTx(tx)
+	userUid, err := core.WrapDbErrorWithValue(q.GetUserUidByUsername(context.Background(), string(username)))
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	// removed with the rename, so a stale cache is never loaded if saving the renamed tree fails
+	if err := q.DeleteTreeCacheEntry(context.Background(), string(username)); err != nil {
+		return uuid.UUID{}, core.WrapDbError(err)
+	}
+	if _, err := q.UpdateUsername(context.Background(), db.UpdateUsernameParams{
+		NewUsername: string(newUsername),
+		Uid:         userUid,
+	}); err != nil {
+		return uuid.UUID{}, core.WrapDbError(err)
+	}
+	// the new username may have been a redirect, from when the user was renamed before
+	if err := q.DeleteUsernameRedirect(context.Background(), string(newUsername)); err != nil {
+		return uuid.UUID{}, core.WrapDbError(err)
+	}
+	if err := q.InsertUsernameRedirect(context.Background(), db.InsertUsernameRedirectParams{
+		Username: string(username),
+		UserUid:  userUid,
+	}); err != nil {
+		return uuid.UUID{}, core.WrapDbError(err)
+	}
+	if err := tc.RenameUser(username, newUsername, tx.Commit); err != nil {
+		return uuid.UUID{}, err
+	}
+	return userUid, nil
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/db"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAuditEntriesLimit = 100
+	maxAuditEntriesLimit     = 1000
+)
+
+type AuditService struct {
+	dao *db.DAO
+}
+
+func (s AuditService) New(dao *db.DAO) AuditService {
+	return AuditService{
+		dao: dao,
+	}
+}
+
+// Append an entry to the audit log.
+//
+// The audited action has already happened, so failures are logged instead of returned.
+func (s *AuditService) Record(entry core.CreateAuditEntry) {
+	params := db.InsertAuditEntryParams{
+		Event:         string(entry.Event),
+		ActorUid:      uuidPtrToNullUUID(entry.Actor.UserUid),
+		ActorUsername: core.StringPtrToNullString(entry.Actor.Username),
+		ShareLinkUid:  uuidPtrToNullUUID(entry.Actor.ShareLinkUid),
+	}
+	if entry.Username != nil {
+		params.Username = sql.NullString{String: string(*entry.Username), Valid: true}
+	}
+	if entry.Slug != nil {
+		params.Slug = sql.NullString{String: string(*entry.Slug), Valid: true}
+	}
+	if entry.Details != nil {
+		details, err := json.Marshal(entry.Details)
+		if err != nil {
+			slog.Error("failed to marshal audit details", "event", entry.Event, "err", err)
+		} else {
+			params.Details = sql.NullString{String: string(details), Valid: true}
+		}
+	}
+	if err := s.dao.Queries.InsertAuditEntry(context.Background(), params); err != nil {
+		slog.Error("failed to record audit entry", "event", entry.Event, "err", err)
+	}
+}
+
+// Record a change in access control for a node, if one was made.
+func (s *AuditService) RecordAccessControlChange(
+	actor core.AuditActor,
+	username core.Username,
+	slug core.NodeSlug,
+	oldAc *core.AccessControl,
+	newAc *core.AccessControl,
+) {
+	diff := core.DiffAccessControl(oldAc, newAc)
+	if diff == nil {
+		return
+	}
+	s.Record(core.CreateAuditEntry{
+		Event:    core.AuditAccessControlChange,
+		Actor:    actor,
+		Username: &username,
+		Slug:     &slug,
+		Details:  diff,
+	})
+}
+
+// Get audit entries in the order they were recorded.
+func (s *AuditService) GetEntries(filter core.AuditFilter) ([]core.AuditEntry, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditEntriesLimit
+	} else if limit > maxAuditEntriesLimit {
+		limit = maxAuditEntriesLimit
+	}
+	params := db.GetAuditEntriesParams{
+		AfterID:    filter.AfterId,
+		Actor:      core.StringPtrToNullString(filter.Actor),
+		Username:   core.StringPtrToNullString(filter.Username),
+		Slug:       core.StringPtrToNullString(filter.Slug),
+		Since:      timePtrToSqliteTimestamp(filter.Since),
+		Until:      timePtrToSqliteTimestamp(filter.Until),
+		MaxEntries: limit,
+	}
+	if filter.Event != nil {
+		params.Event = sql.NullString{String: string(*filter.Event), Valid: true}
+	}
+	rows, err := s.dao.Queries.GetAuditEntries(context.Background(), params)
+	if err != nil {
+		return nil, core.WrapDbError(err)
+	}
+	entries := make([]core.AuditEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = core.AuditEntry{
+			Id:            row.ID,
+			CreatedAt:     row.CreatedAt,
+			Event:         core.AuditEvent(row.Event),
+			ActorUid:      nullUUIDToUUIDPtr(row.ActorUid),
+			ActorUsername: core.NullStringToStringPtr(row.ActorUsername),
+			ShareLinkUid:  nullUUIDToUUIDPtr(row.ShareLinkUid),
+			Username:      core.NullStringToStringPtr(row.Username),
+			Slug:          core.NullStringToStringPtr(row.Slug),
+		}
+		if row.Details.Valid {
+			entries[i].Details = json.RawMessage(row.Details.String)
+		}
+	}
+	return entries, nil
+}
+
+func uuidPtrToNullUUID(v *uuid.UUID) uuid.NullUUID {
+	if v == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: *v, Valid: true}
+}
+
+func nullUUIDToUUIDPtr(v uuid.NullUUID) *uuid.UUID {
+	if !v.Valid {
+		return nil
+	}
+	return &v.UUID
+}
+
+// Format time to match how CURRENT_TIMESTAMP is stored, so it can be compared as text.
+func timePtrToSqliteTimestamp(v *time.Time) sql.NullString {
+	if v == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: v.UTC().Format(time.DateTime), Valid: true}
+}
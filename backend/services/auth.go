@@ -4,73 +4,270 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/enchant97/note-mark/backend/config"
 	"github.com/enchant97/note-mark/backend/core"
 	"github.com/enchant97/note-mark/backend/db"
+	"github.com/enchant97/note-mark/backend/oidclogin"
+	"github.com/enchant97/note-mark/backend/ratelimit"
+	"github.com/enchant97/note-mark/backend/signingkeys"
 	"github.com/enchant97/note-mark/backend/tree"
+	"github.com/enchant97/note-mark/backend/usercache"
 	"github.com/google/uuid"
 	"golang.org/x/oauth2"
 )
 
+// Where OIDC providers send the user back to, relative to the public url.
+const OidcCallbackPath = "/api/auth/oidc/callback"
+
+// Limits for starting OIDC logins, every begin counts, not only failed ones.
+var oidcLoginBeginLimits = ratelimit.Options{
+	MaxFailures: 30,
+	Lockout:     time.Minute,
+	MaxLockout:  15 * time.Minute,
+	Window:      time.Minute,
+}
+
 type AuthService struct {
 	appConfig    config.AppConfig
 	dao          *db.DAO
+	keyring      *signingkeys.Keyring
 	tc           *tree.TreeController
-	OidcProvider *oidc.Provider
-	OidcVerifier *oidc.IDTokenVerifier
+	audit        *AuditService
+	totp         *TotpService
+	userCache    *usercache.Cache
+	loginLimiter *ratelimit.Limiter
+	// keyed by provider name
+	oidcProviders map[string]oidcProvider
+	oidcLogins    *oidclogin.Flows
+	// limits begins per client IP, as each one keeps state in memory
+	oidcBeginLimiter *ratelimit.Limiter
+}
+
+type oidcProvider struct {
+	config   config.OidcConfig
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+}
+
+func (p oidcProvider) claimMapping() core.OidcClaimMapping {
+	return core.OidcClaimMapping{
+		GroupsClaim:    p.config.GroupsClaim,
+		RequiredGroups: p.config.RequiredGroups,
+		AdminGroups:    p.config.AdminGroups,
+		GroupMappings:  p.config.GroupMappings,
+	}
+}
+
+// Whether any permissions depend on the users groups.
+func (p oidcProvider) hasGroupMapping() bool {
+	return len(p.config.RequiredGroups) != 0 || len(p.config.AdminGroups) != 0 || len(p.config.GroupMappings) != 0
 }
 
 func (s AuthService) New(
 	appConfig config.AppConfig,
 	dao *db.DAO,
+	keyring *signingkeys.Keyring,
 	tc *tree.TreeController,
+	audit *AuditService,
+	totp *TotpService,
+	userCache *usercache.Cache,
+	loginLimiter *ratelimit.Limiter,
 ) AuthService {
-	var oidcProvider *oidc.Provider
-	var oidcVerifier *oidc.IDTokenVerifier
-	if appConfig.OIDC != nil {
-		p, err := oidc.NewProvider(context.Background(), appConfig.OIDC.IssuerUrl)
+	oidcProviders := make(map[string]oidcProvider, len(appConfig.OidcProviders))
+	loginProviders := make([]oidclogin.Provider, 0, len(appConfig.OidcProviders))
+	for _, providerConfig := range appConfig.OidcProviders {
+		p, err := oidc.NewProvider(context.Background(), providerConfig.IssuerUrl)
 		if err != nil {
 			log.Fatal(err)
 		}
-		oidcProvider = p
-		oidcVerifier = p.Verifier(&oidc.Config{ClientID: appConfig.OIDC.ClientID})
+		provider := oidcProvider{
+			config:   providerConfig,
+			provider: p,
+			verifier: p.Verifier(&oidc.Config{ClientID: providerConfig.ClientID}),
+		}
+		oidcProviders[providerConfig.ProviderName] = provider
+		var claims []string
+		if provider.hasGroupMapping() {
+			claims = []string{strings.Split(providerConfig.GroupsClaim, ".")[0]}
+		}
+		loginProviders = append(loginProviders, oidclogin.Provider{
+			Name:         providerConfig.ProviderName,
+			Provider:     provider.provider,
+			Verifier:     provider.verifier,
+			ClientID:     providerConfig.ClientID,
+			ClientSecret: providerConfig.ClientSecret,
+			Claims:       claims,
+		})
+	}
+	oidcLogins := oidclogin.Flows{}.New(appConfig.PublicUrl+OidcCallbackPath, loginProviders)
+	oidcBeginLimiter, err := ratelimit.Limiter{}.New(oidcLoginBeginLimits, nil)
+	if err != nil {
+		log.Fatal(err)
 	}
 	return AuthService{
-		appConfig:    appConfig,
-		dao:          dao,
-		tc:           tc,
-		OidcProvider: oidcProvider,
-		OidcVerifier: oidcVerifier,
+		appConfig:        appConfig,
+		dao:              dao,
+		keyring:          keyring,
+		tc:               tc,
+		audit:            audit,
+		totp:             totp,
+		userCache:        userCache,
+		loginLimiter:     loginLimiter,
+		oidcProviders:    oidcProviders,
+		oidcLogins:       &oidcLogins,
+		oidcBeginLimiter: &oidcBeginLimiter,
 	}
 }
 
-func (s *AuthService) CreateAccessToken(request core.AccessTokenRequest) (core.AccessToken, error) {
+// Create an access token for a user,
+// password grants are rate limited by client IP and username.
+//
+// When withRefreshToken is set a refresh token is also issued,
+// continuing the token family when the refresh token grant was used.
+//
+// errors with `ratelimit.ErrLocked` when too many failed attempts have been made
+// and `core.ErrTotpRequired` when the user has TOTP enabled and no code was given.
+func (s *AuthService) CreateAccessToken(
+	request core.AccessTokenRequest,
+	clientIP string,
+	withRefreshToken bool,
+) (core.AccessToken, error) {
 	var userUid uuid.UUID
+	var familyUid uuid.UUID
 	var err error
-	if request.GrantType == "password" {
+	details := map[string]string{"grantType": request.GrantType, "clientIp": clientIP}
+	if request.GrantType == "refresh_token" {
+		userUid, familyUid, err = s.getUserForRefreshTokenGrant(*request.RefreshTokenGrant, details)
+	} else if request.GrantType == "password" {
+		details["username"] = request.PasswordGrant.Username
+		limitKeys := []string{"ip:" + clientIP, "user:" + request.PasswordGrant.Username}
+		if s.loginLimiter != nil {
+			if err := s.loginLimiter.Check(limitKeys...); err != nil {
+				details["reason"] = "rate_limited"
+				s.audit.Record(core.CreateAuditEntry{Event: core.AuditLoginFailed, Details: details})
+				return core.AccessToken{}, err
+			}
+		}
 		userUid, err = s.getUserForPasswordGrant(*request.PasswordGrant)
+		if s.loginLimiter != nil {
+			s.updateLoginLimiter(limitKeys, err, details)
+		}
 	} else {
-		userUid, err = s.getUserForTokenExchangeGrant(*request.TokenExchangeGrant)
+		userUid, err = s.getUserForTokenExchangeGrant(*request.TokenExchangeGrant, details)
+	}
+	if err != nil {
+		// a missing TOTP code is part of the normal login flow, not a failure
+		if !errors.Is(err, core.ErrFeatureDisabled) && !errors.Is(err, core.ErrTotpRequired) {
+			s.audit.Record(core.CreateAuditEntry{Event: core.AuditLoginFailed, Details: details})
+		}
+		return core.AccessToken{}, err
+	}
+	token, err := s.CreateAccessTokenForUser(userUid, details)
+	if err != nil || !withRefreshToken || s.appConfig.AuthToken.RefreshExpiry == 0 {
+		return token, err
 	}
+	if familyUid == uuid.Nil {
+		familyUid = core.MustNewUID()
+	}
+	token.RefreshToken, err = s.createRefreshToken(userUid, familyUid)
+	if err != nil {
+		return core.AccessToken{}, err
+	}
+	return token, nil
+}
+
+// Create an access token for a user that has already been authenticated,
+// details are recorded in the audit log.
+//
+// errors with `core.ErrInvalidCredentials` when the user is disabled.
+func (s *AuthService) CreateAccessTokenForUser(userUid uuid.UUID, details map[string]string) (core.AccessToken, error) {
+	// disabled accounts cannot obtain new tokens
+	user, err := s.dao.Queries.GetUserByUid(context.Background(), userUid)
 	if err != nil {
+		if errors.Is(core.WrapDbError(err), core.ErrNotFound) {
+			details["reason"] = "disabled"
+			s.audit.Record(core.CreateAuditEntry{
+				Event:   core.AuditLoginFailed,
+				Actor:   core.AuditActor{UserUid: &userUid},
+				Details: details,
+			})
+			return core.AccessToken{}, core.ErrInvalidCredentials
+		}
 		return core.AccessToken{}, err
 	}
 	authenticationData := core.AuthenticatedUser{
-		UserUID: userUid,
+		UserUID:  userUid,
+		Username: user.Username,
 	}
-	if token, err := core.CreateAuthenticationToken(
+	actor := core.NewAuditActor(&authenticationData, nil)
+	s.audit.Record(core.CreateAuditEntry{Event: core.AuditLogin, Actor: actor, Details: details})
+	token, err := core.CreateAuthenticationToken(
 		authenticationData,
-		s.appConfig.AuthToken.Secret,
+		s.keyring,
 		time.Duration(int64(time.Second)*s.appConfig.AuthToken.Expiry),
-	); err != nil {
+	)
+	if err != nil {
 		return core.AccessToken{}, err
-	} else {
-		return token, nil
 	}
+	s.audit.Record(core.CreateAuditEntry{
+		Event:   core.AuditTokenCreated,
+		Actor:   actor,
+		Details: map[string]uint{"expiresIn": token.ExpiresIn},
+	})
+	return token, nil
+}
+
+// Start a server-side OIDC login, returning the state and the provider url to send the user to.
+//
+// redirectAfter is where to send the user once logged in,
+// errors with `ratelimit.ErrLocked` when the client IP has started too many.
+func (s *AuthService) BeginOidcLogin(providerName string, redirectAfter string, clientIP string) (string, string, error) {
+	if len(s.oidcProviders) == 0 {
+		return "", "", core.ErrFeatureDisabled
+	}
+	key := "oidc-ip:" + clientIP
+	if err := s.oidcBeginLimiter.Check(key); err != nil {
+		return "", "", err
+	}
+	s.oidcBeginLimiter.Fail(key)
+	state, authURL, err := s.oidcLogins.Begin(providerName, redirectAfter)
+	if errors.Is(err, oidclogin.ErrUnknownProvider) {
+		return "", "", errors.Join(err, core.ErrNotFound)
+	}
+	return state, authURL, err
+}
+
+// Finish a server-side OIDC login, creating an access token
+// and returning where to send the user.
+func (s *AuthService) FinishOidcLogin(state string, code string, clientIP string) (core.AccessToken, string, error) {
+	if len(s.oidcProviders) == 0 {
+		return core.AccessToken{}, "", core.ErrFeatureDisabled
+	}
+	details := map[string]string{"grantType": "authorization_code", "clientIp": clientIP}
+	identity, redirectAfter, err := s.oidcLogins.Finish(context.Background(), state, code)
+	if err != nil {
+		s.audit.Record(core.CreateAuditEntry{Event: core.AuditLoginFailed, Details: details})
+		return core.AccessToken{}, "", err
+	}
+	details["provider"] = identity.ProviderName
+	provider, err := s.getOidcProvider(identity.ProviderName)
+	if err != nil {
+		return core.AccessToken{}, "", err
+	}
+	userUid, err := s.loginOidcUser(provider, identity.PreferredUsername, identity.Subject, identity.Claims, details)
+	if err != nil {
+		s.audit.Record(core.CreateAuditEntry{Event: core.AuditLoginFailed, Details: details})
+		return core.AccessToken{}, "", err
+	}
+	token, err := s.CreateAccessTokenForUser(userUid, details)
+	return token, redirectAfter, err
 }
 
 func (s *AuthService) GetUserInfoByUsername(username string) (core.UserInfoResponse, error) {
@@ -85,6 +282,26 @@ func (s *AuthService) GetUserInfoByUsername(username string) (core.UserInfoRespo
 	}, nil
 }
 
+// Record the outcome of a password attempt,
+// only failures from wrong credentials count towards a lockout.
+func (s *AuthService) updateLoginLimiter(limitKeys []string, err error, details map[string]string) {
+	if err == nil {
+		// only forget the username, so an IP can't reset itself using a known account
+		s.loginLimiter.Reset(limitKeys[1])
+		return
+	}
+	if !errors.Is(err, core.ErrInvalidCredentials) && !errors.Is(err, core.ErrNotFound) {
+		return
+	}
+	for _, key := range s.loginLimiter.Fail(limitKeys...) {
+		slog.Warn("too many failed logins, locking out", "key", key)
+		s.audit.Record(core.CreateAuditEntry{
+			Event:   core.AuditLoginLockout,
+			Details: map[string]string{"key": key, "username": details["username"], "clientIp": details["clientIp"]},
+		})
+	}
+}
+
 func (s *AuthService) getUserForPasswordGrant(request core.PasswordGrant) (uuid.UUID, error) {
 	if !s.appConfig.EnableInternalLogin {
 		return uuid.Nil, core.ErrFeatureDisabled
@@ -98,40 +315,228 @@ func (s *AuthService) getUserForPasswordGrant(request core.PasswordGrant) (uuid.
 	if !core.DoesPasswordMatchHashed(request.Password, user.PasswordHash) {
 		return uuid.Nil, core.ErrInvalidCredentials
 	}
+	if err := s.totp.CheckLoginCode(user.Uid, request.Code); err != nil {
+		return uuid.Nil, err
+	}
+	// upgrade bcrypt and outdated argon2id hashes, now the password is known
+	if core.DoesPasswordNeedRehash(user.PasswordHash) {
+		if err := s.dao.Queries.UpdateUserPassword(context.Background(), db.UpdateUserPasswordParams{
+			Uid:          user.Uid,
+			PasswordHash: core.HashPassword(request.Password),
+		}); err != nil {
+			slog.Warn("failed to rehash password", "userUid", user.Uid, "err", err)
+		}
+	}
 	return user.Uid, nil
 }
 
-func (s *AuthService) getUserForTokenExchangeGrant(request core.TokenExchangeGrant) (uuid.UUID, error) {
-	type Claims struct {
-		PreferredUsername string `json:"preferred_username"`
+// Use a refresh token, returning the user and token family it belongs to.
+//
+// Tokens are single use, reusing one revokes the whole family
+// as either the client or an attacker holds a stolen token.
+func (s *AuthService) getUserForRefreshTokenGrant(
+	request core.RefreshTokenGrant,
+	details map[string]string,
+) (uuid.UUID, uuid.UUID, error) {
+	if s.appConfig.AuthToken.RefreshExpiry == 0 {
+		return uuid.Nil, uuid.Nil, core.ErrFeatureDisabled
+	}
+	tokenHash := core.HashRefreshToken(request.RefreshToken)
+	token, err := core.WrapDbErrorWithValue(s.dao.Queries.GetRefreshToken(context.Background(), tokenHash))
+	if errors.Is(err, core.ErrNotFound) {
+		details["reason"] = "unknown_refresh_token"
+		return uuid.Nil, uuid.Nil, core.ErrInvalidCredentials
+	} else if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	if token.ExpiresAt.Before(time.Now()) {
+		details["reason"] = "expired_refresh_token"
+		return uuid.Nil, uuid.Nil, core.ErrInvalidCredentials
+	}
+	// marking as used only succeeds once, even with concurrent requests
+	used := token.UsedAt.Valid
+	if !used {
+		count, err := s.dao.Queries.MarkRefreshTokenUsed(context.Background(), tokenHash)
+		if err != nil {
+			return uuid.Nil, uuid.Nil, core.WrapDbError(err)
+		}
+		used = count == 0
+	}
+	if used {
+		slog.Warn("refresh token reused, revoking token family", "userUid", token.UserUid)
+		details["reason"] = "refresh_token_reused"
+		if err := s.dao.Queries.DeleteRefreshTokenFamily(context.Background(), token.FamilyUid); err != nil {
+			return uuid.Nil, uuid.Nil, core.WrapDbError(err)
+		}
+		return uuid.Nil, uuid.Nil, core.ErrInvalidCredentials
+	}
+	return token.UserUid, token.FamilyUid, nil
+}
+
+// Issue a new refresh token in a token family.
+func (s *AuthService) createRefreshToken(userUid uuid.UUID, familyUid uuid.UUID) (string, error) {
+	// whole seconds, as timestamps are compared as text
+	now := time.Now().UTC().Truncate(time.Second)
+	// used tokens are kept until they expire, for reuse detection
+	if err := s.dao.Queries.DeleteExpiredRefreshTokens(context.Background(), now); err != nil {
+		return "", core.WrapDbError(err)
+	}
+	token, tokenHash := core.NewRefreshToken()
+	if err := s.dao.Queries.InsertRefreshToken(context.Background(), db.InsertRefreshTokenParams{
+		TokenHash: tokenHash,
+		FamilyUid: familyUid,
+		UserUid:   userUid,
+		ExpiresAt: now.Add(time.Duration(s.appConfig.AuthToken.RefreshExpiry) * time.Second),
+	}); err != nil {
+		return "", core.WrapDbError(err)
 	}
-	if s.appConfig.OIDC == nil {
+	return token, nil
+}
+
+func (s *AuthService) getUserForTokenExchangeGrant(
+	request core.TokenExchangeGrant,
+	details map[string]string,
+) (uuid.UUID, error) {
+	if len(s.oidcProviders) == 0 {
 		return uuid.Nil, core.ErrFeatureDisabled
 	}
+	provider, err := s.getOidcProvider(request.ProviderName)
+	if err != nil {
+		return uuid.Nil, err
+	}
 	// TODO use ActorToken when available
-	userInfo, err := s.OidcProvider.UserInfo(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{
+	userInfo, err := provider.provider.UserInfo(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{
 		AccessToken: request.SubjectToken,
 	}))
 	if err != nil {
 		return uuid.Nil, err
 	}
-	var claims Claims
+	details["provider"] = provider.config.ProviderName
+	claims := map[string]any{}
 	if err := userInfo.Claims(&claims); err != nil {
 		return uuid.Nil, err
 	}
-	if claims.PreferredUsername == "" {
+	preferredUsername, _ := claims["preferred_username"].(string)
+	if preferredUsername == "" {
 		return uuid.Nil, errors.New("oidc 'preferred_username' is blank or missing")
 	}
-	return s.getOrCreateOidcUser(claims.PreferredUsername, userInfo.Subject)
+	return s.loginOidcUser(provider, preferredUsername, userInfo.Subject, claims, details)
 }
 
-func (s *AuthService) getOrCreateOidcUser(username string, userSub string) (uuid.UUID, error) {
+// Get the user for an OIDC login, creating them when allowed,
+// their admin status and group memberships are refreshed from the groups claim.
+//
+// errors with `core.ErrInvalidCredentials` when the user is not in a required group
+// or does not exist and user creation is disabled.
+func (s *AuthService) loginOidcUser(
+	provider oidcProvider,
+	username string,
+	userSub string,
+	claims map[string]any,
+	details map[string]string,
+) (uuid.UUID, error) {
+	mapping := provider.claimMapping()
+	groups := core.GetOidcClaimGroups(claims, mapping.GroupsClaim)
+	if !mapping.IsAllowed(groups) {
+		details["reason"] = "missing_required_group"
+		return uuid.Nil, core.ErrInvalidCredentials
+	}
+	userUid, err := s.getOrCreateOidcUser(provider.config, username, userSub)
+	if errors.Is(err, core.ErrNotFound) {
+		details["reason"] = "user_creation_disabled"
+		return uuid.Nil, errors.Join(err, core.ErrInvalidCredentials)
+	} else if err != nil {
+		return uuid.Nil, err
+	}
+	if provider.hasGroupMapping() {
+		if err := s.applyOidcPermissions(userUid, mapping.Map(groups)); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	return userUid, nil
+}
+
+// Update a users admin status and group memberships to match their provider groups,
+// groups must already exist as they are never created.
+func (s *AuthService) applyOidcPermissions(userUid uuid.UUID, permissions core.OidcMappedPermissions) error {
+	user, err := core.WrapDbErrorWithValue(s.dao.Queries.GetUserByUid(context.Background(), userUid))
+	if errors.Is(err, core.ErrNotFound) {
+		// disabled users are rejected when creating the token
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if permissions.IsAdmin != nil && *permissions.IsAdmin != user.IsAdmin {
+		if _, err := s.dao.Queries.AdminSetUserAdmin(context.Background(), db.AdminSetUserAdminParams{
+			IsAdmin:  *permissions.IsAdmin,
+			Username: user.Username,
+		}); err != nil {
+			return core.WrapDbError(err)
+		}
+		s.userCache.Invalidate(userUid)
+		slog.Info("updated admin status from oidc groups", "username", user.Username, "isAdmin", *permissions.IsAdmin)
+	}
+	for groupName, isMember := range permissions.Groups {
+		group, err := core.WrapDbErrorWithValue(s.dao.Queries.GetGroupByName(context.Background(), groupName))
+		if errors.Is(err, core.ErrNotFound) {
+			slog.Warn("oidc mapped group does not exist", "group", groupName)
+			continue
+		} else if err != nil {
+			return err
+		}
+		if isMember {
+			err = core.WrapDbError(s.dao.Queries.InsertGroupMember(context.Background(), db.InsertGroupMemberParams{
+				GroupID: group.ID,
+				UserUid: userUid,
+			}))
+			if errors.Is(err, core.ErrConflict) {
+				continue
+			}
+		} else {
+			err = core.WrapDbError(s.dao.Queries.DeleteGroupMember(context.Background(), db.DeleteGroupMemberParams{
+				GroupID: group.ID,
+				UserUid: userUid,
+			}))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get a provider by name, the name can be omitted when only one provider is configured.
+func (s *AuthService) getOidcProvider(providerName string) (oidcProvider, error) {
+	if providerName == "" && len(s.oidcProviders) == 1 {
+		for _, provider := range s.oidcProviders {
+			return provider, nil
+		}
+	}
+	provider, exists := s.oidcProviders[providerName]
+	if !exists {
+		return oidcProvider{}, fmt.Errorf("unknown oidc provider '%s': %w", providerName, core.ErrNotFound)
+	}
+	return provider, nil
+}
+
+// Get the user mapped to the provider subject, creating them when user creation is enabled.
+//
+// errors with `core.ErrNotFound` when the user does not exist and user creation is disabled.
+func (s *AuthService) getOrCreateOidcUser(
+	providerConfig config.OidcConfig,
+	username string,
+	userSub string,
+) (uuid.UUID, error) {
+	providerName := providerConfig.ProviderName
 	userUid, err := s.dao.Queries.GetOidcUserUid(context.Background(), db.GetOidcUserUidParams{
 		UserSub:      userSub,
-		ProviderName: s.appConfig.OIDC.ProviderName,
+		ProviderName: providerName,
 	})
 	err = core.WrapDbError(err)
 	if errors.Is(err, core.ErrNotFound) {
+		if !providerConfig.EnableUserCreation {
+			return uuid.Nil, err
+		}
 		tx, err := s.dao.DB.BeginTx(context.Background(), &sql.TxOptions{})
 		if err != nil {
 			return uuid.Nil, err
@@ -148,14 +553,18 @@ func (s *AuthService) getOrCreateOidcUser(username string, userSub string) (uuid
 		if err := q.InsertOidcUserMapping(context.Background(), db.InsertOidcUserMappingParams{
 			Username:     username,
 			UserSub:      userSub,
-			ProviderName: s.appConfig.OIDC.ProviderName,
+			ProviderName: providerName,
 		}); err != nil {
 			return uuid.Nil, core.WrapDbError(err)
 		}
+		if err := tx.Commit(); err != nil {
+			return uuid.Nil, err
+		}
+		// tree registration writes to the DB, so can only happen once the transaction is done
 		if err := s.tc.RegisterNewUser(core.Username(username)); err != nil && !errors.Is(err, core.ErrConflict) {
 			return uuid.Nil, err
 		}
-		return userUid, tx.Commit()
+		return userUid, nil
 	} else if err != nil {
 		return uuid.Nil, err
 	}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/db"
+	"github.com/enchant97/note-mark/backend/tree"
+	"github.com/google/uuid"
+)
+
+var ErrCommentInvalid = errors.New("comment invalid")
+
+type CommentsService struct {
+	dao         *db.DAO
+	treeService *TreeService
+}
+
+func (s CommentsService) New(dao *db.DAO, treeService *TreeService) CommentsService {
+	return CommentsService{
+		dao:         dao,
+		treeService: treeService,
+	}
+}
+
+// Ensure the user, or a share link they are using, has at least the required mode for a node.
+//
+// Will give a not found error when they do not.
+func (s *CommentsService) requireNodeAccessControlMode(
+	authenticatedUser *core.AuthenticatedUser,
+	optionalShareGrant *core.ShareGrant,
+	username core.Username,
+	fullSlug core.NodeSlug,
+	required core.AccessControlMode,
+) (core.AccessControlMode, error) {
+	acMode, err := s.treeService.GetAvailableNodeAccessControlModeWithShare(
+		authenticatedUser,
+		optionalShareGrant,
+		username,
+		fullSlug,
+		false,
+	)
+	if err != nil {
+		return "", err
+	} else if acMode == nil || !tree.HasAccessControlMode(*acMode, required) {
+		return "", core.ErrNotFound
+	}
+	return *acMode, nil
+}
+
+// Get a comment, ensuring the user has at least the required mode for its node.
+func (s *CommentsService) getCommentWithAccess(
+	authenticatedUser *core.AuthenticatedUser,
+	optionalShareGrant *core.ShareGrant,
+	uid uuid.UUID,
+	required core.AccessControlMode,
+) (db.GetCommentByUidRow, core.AccessControlMode, error) {
+	comment, err := core.WrapDbErrorWithValue(s.dao.Queries.GetCommentByUid(context.Background(), uid))
+	if err != nil {
+		return comment, "", err
+	}
+	acMode, err := s.requireNodeAccessControlMode(
+		authenticatedUser,
+		optionalShareGrant,
+		core.Username(comment.OwnerUsername),
+		core.NodeSlug(comment.Slug),
+		required,
+	)
+	return comment, acMode, err
+}
+
+// Get all comments for a node as threads, requires comment access.
+func (s *CommentsService) GetComments(
+	authenticatedUser *core.AuthenticatedUser,
+	optionalShareGrant *core.ShareGrant,
+	username core.Username,
+	fullSlug core.NodeSlug,
+) ([]core.Comment, error) {
+	if _, err := s.requireNodeAccessControlMode(
+		authenticatedUser,
+		optionalShareGrant,
+		username,
+		fullSlug,
+		core.AccessControlCommentMode,
+	); err != nil {
+		return nil, err
+	}
+	rows, err := s.dao.Queries.GetCommentsForNode(context.Background(), db.GetCommentsForNodeParams{
+		Username: string(username),
+		Slug:     string(fullSlug),
+	})
+	if err != nil {
+		return nil, core.WrapDbError(err)
+	}
+	comments := make([]core.Comment, len(rows))
+	for i, row := range rows {
+		comments[i] = core.Comment{
+			Uid:        row.Uid,
+			CreatedAt:  row.CreatedAt,
+			UpdatedAt:  row.UpdatedAt,
+			Author:     core.NullStringToStringPtr(row.AuthorUsername),
+			Content:    row.Content,
+			ResolvedAt: nullTimeToTimePtr(row.ResolvedAt),
+			ResolvedBy: core.NullStringToStringPtr(row.ResolvedByUsername),
+		}
+		if row.ParentUid.Valid {
+			comments[i].ParentUid = &row.ParentUid.UUID
+		}
+		if row.AnchorStart.Valid && row.AnchorEnd.Valid {
+			comments[i].Anchor = &core.CommentAnchor{
+				Start: row.AnchorStart.Int64,
+				End:   row.AnchorEnd.Int64,
+				Text:  row.AnchorText.String,
+			}
+		}
+	}
+	return core.ThreadComments(comments), nil
+}
+
+// Create a comment, or a reply to one, requires comment access.
+//
+// Replies to a reply will be added to the same thread.
+func (s *CommentsService) CreateComment(
+	authenticatedUser *core.AuthenticatedUser,
+	optionalShareGrant *core.ShareGrant,
+	username core.Username,
+	fullSlug core.NodeSlug,
+	toCreate core.CreateComment,
+) (core.Comment, error) {
+	if _, err := s.requireNodeAccessControlMode(
+		authenticatedUser,
+		optionalShareGrant,
+		username,
+		fullSlug,
+		core.AccessControlCommentMode,
+	); err != nil {
+		return core.Comment{}, err
+	}
+	ownerUid, err := core.WrapDbErrorWithValue(
+		s.dao.Queries.GetUserUidByUsername(context.Background(), string(username)),
+	)
+	if err != nil {
+		return core.Comment{}, err
+	}
+	params := db.InsertCommentParams{
+		Uid:       core.MustNewUID(),
+		OwnerUid:  ownerUid,
+		Slug:      string(fullSlug),
+		AuthorUid: uuid.NullUUID{UUID: authenticatedUser.UserUID, Valid: true},
+		Content:   toCreate.Content,
+	}
+	if toCreate.ParentUid != nil {
+		if toCreate.Anchor != nil {
+			return core.Comment{}, ErrCommentInvalid
+		}
+		parent, err := core.WrapDbErrorWithValue(
+			s.dao.Queries.GetCommentByUid(context.Background(), *toCreate.ParentUid),
+		)
+		if err != nil {
+			return core.Comment{}, err
+		} else if parent.OwnerUsername != string(username) || parent.Slug != string(fullSlug) {
+			return core.Comment{}, core.ErrNotFound
+		}
+		if parent.ParentUid.Valid {
+			params.ParentUid = parent.ParentUid
+		} else {
+			params.ParentUid = uuid.NullUUID{UUID: parent.Uid, Valid: true}
+		}
+	}
+	if toCreate.Anchor != nil {
+		if toCreate.Anchor.End < toCreate.Anchor.Start {
+			return core.Comment{}, ErrCommentInvalid
+		}
+		params.AnchorStart = sql.NullInt64{Int64: toCreate.Anchor.Start, Valid: true}
+		params.AnchorEnd = sql.NullInt64{Int64: toCreate.Anchor.End, Valid: true}
+		params.AnchorText = sql.NullString{String: toCreate.Anchor.Text, Valid: true}
+	}
+	row, err := s.dao.Queries.InsertComment(context.Background(), params)
+	if err != nil {
+		return core.Comment{}, core.WrapDbError(err)
+	}
+	comment := core.Comment{
+		Uid:       row.Uid,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+		Author:    &authenticatedUser.Username,
+		Anchor:    toCreate.Anchor,
+		Content:   toCreate.Content,
+	}
+	if params.ParentUid.Valid {
+		comment.ParentUid = &params.ParentUid.UUID
+	}
+	return comment, nil
+}
+
+// Update the content of a comment, only allowed by the author.
+func (s *CommentsService) UpdateComment(
+	authenticatedUser *core.AuthenticatedUser,
+	optionalShareGrant *core.ShareGrant,
+	uid uuid.UUID,
+	toUpdate core.UpdateComment,
+) error {
+	comment, _, err := s.getCommentWithAccess(authenticatedUser, optionalShareGrant, uid, core.AccessControlCommentMode)
+	if err != nil {
+		return err
+	} else if comment.AuthorUid.UUID != authenticatedUser.UserUID {
+		return core.ErrNotFound
+	}
+	return core.WrapDbError(s.dao.Queries.UpdateCommentContent(context.Background(), db.UpdateCommentContentParams{
+		Content: toUpdate.Content,
+		Uid:     uid,
+	}))
+}
+
+// Delete a comment along with any replies,
+// only allowed by the author or those with write access.
+func (s *CommentsService) DeleteComment(
+	authenticatedUser *core.AuthenticatedUser,
+	optionalShareGrant *core.ShareGrant,
+	uid uuid.UUID,
+) error {
+	comment, acMode, err := s.getCommentWithAccess(authenticatedUser, optionalShareGrant, uid, core.AccessControlCommentMode)
+	if err != nil {
+		return err
+	} else if comment.AuthorUid.UUID != authenticatedUser.UserUID && acMode != core.AccessControlWriteMode {
+		return core.ErrNotFound
+	}
+	return core.WrapDbError(s.dao.Queries.DeleteComment(context.Background(), uid))
+}
+
+// Mark a comment thread as resolved or unresolved, requires comment access.
+func (s *CommentsService) SetCommentResolved(
+	authenticatedUser *core.AuthenticatedUser,
+	optionalShareGrant *core.ShareGrant,
+	uid uuid.UUID,
+	resolved bool,
+) error {
+	comment, _, err := s.getCommentWithAccess(authenticatedUser, optionalShareGrant, uid, core.AccessControlCommentMode)
+	if err != nil {
+		return err
+	} else if comment.ParentUid.Valid {
+		// only whole threads can be resolved
+		return ErrCommentInvalid
+	}
+	params := db.UpdateCommentResolvedParams{Uid: uid}
+	if resolved {
+		params.ResolvedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		params.ResolvedByUid = uuid.NullUUID{UUID: authenticatedUser.UserUID, Valid: true}
+	}
+	return core.WrapDbError(s.dao.Queries.UpdateCommentResolved(context.Background(), params))
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/feed"
+	"github.com/enchant97/note-mark/backend/render"
+	"github.com/enchant97/note-mark/backend/tree"
+)
+
+type FeedService struct {
+	tc            *tree.TreeController
+	renderService *RenderService
+	publicUrl     string
+}
+
+func (s FeedService) New(
+	tc *tree.TreeController,
+	renderService *RenderService,
+	publicUrl string,
+) FeedService {
+	return FeedService{
+		tc:            tc,
+		renderService: renderService,
+		publicUrl:     publicUrl,
+	}
+}
+
+func (s *FeedService) GetFeedModTime(username core.Username) (time.Time, error) {
+	return s.tc.GetTreeModTimeForUser(username)
+}
+
+// Get a feed of the most recently modified notes that are publicly readable.
+//
+// When folder is given, only that note and it's descendants are included.
+func (s *FeedService) GetPublicFeed(
+	username core.Username,
+	folder *core.NodeSlug,
+	limit int,
+	selfURL string,
+) (feed.Feed, error) {
+	nodeTree, err := s.tc.TryGetNodeTreeForUser(username)
+	if err != nil {
+		return feed.Feed{}, core.ErrNotFound
+	}
+	modTime, err := s.tc.GetTreeModTimeForUser(username)
+	if err != nil {
+		return feed.Feed{}, err
+	}
+	type feedNode struct {
+		fullSlug core.NodeSlug
+		node     *core.Node
+	}
+	nodes := []feedNode{}
+	foundFolder := folder == nil
+	var walk func(nodeTree core.NodeTree, parentSlug string)
+	walk = func(nodeTree core.NodeTree, parentSlug string) {
+		for slug, node := range nodeTree {
+			if node.Type != core.NoteNode || (parentSlug == "" && slug == ".trash") {
+				continue
+			}
+			fullSlug := path.Join(parentSlug, string(slug))
+			if folder != nil && fullSlug == string(*folder) {
+				foundFolder = true
+			}
+			if !node.Placeholder && (folder == nil ||
+				fullSlug == string(*folder) ||
+				strings.HasPrefix(fullSlug, string(*folder)+"/")) {
+				nodes = append(nodes, feedNode{core.NodeSlug(fullSlug), node})
+			}
+			walk(node.Children, fullSlug)
+		}
+	}
+	walk(tree.FilteredNodeTree(nodeTree, nil), "")
+	if !foundFolder {
+		return feed.Feed{}, core.ErrNotFound
+	}
+	slices.SortFunc(nodes, func(a, b feedNode) int {
+		return b.node.ModTime.Compare(a.node.ModTime)
+	})
+	if len(nodes) > limit {
+		nodes = nodes[:limit]
+	}
+	title := string(username)
+	link := s.publicUrl + "/" + string(username)
+	if folder != nil {
+		title += " - " + string(*folder)
+		link += "/" + render.EscapePath(string(*folder))
+	}
+	f := feed.Feed{
+		ID:      link,
+		Title:   title,
+		Link:    link,
+		SelfURL: selfURL,
+		Updated: modTime,
+		Author:  string(username),
+		Entries: make([]feed.Entry, len(nodes)),
+	}
+	for i, n := range nodes {
+		entryLink := s.publicUrl + "/" + string(username) + "/" + render.EscapePath(string(n.fullSlug))
+		entryTitle := n.node.FrontMatter.Title
+		if entryTitle == "" {
+			entryTitle = path.Base(string(n.fullSlug))
+		}
+		content, _, err := s.renderService.RenderNoteNode(username, n.fullSlug)
+		if err != nil {
+			// a single note should not break the entire feed
+			slog.Warn("failed to render note for feed", "username", username, "slug", n.fullSlug, "err", err)
+		}
+		f.Entries[i] = feed.Entry{
+			ID:      entryLink,
+			Title:   entryTitle,
+			Link:    entryLink,
+			Updated: n.node.ModTime,
+			Content: string(content),
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/db"
+	"github.com/google/uuid"
+)
+
+type GroupsService struct {
+	dao *db.DAO
+}
+
+func (s GroupsService) New(dao *db.DAO) GroupsService {
+	return GroupsService{
+		dao: dao,
+	}
+}
+
+// Get groups the user owns or is a member of.
+func (s *GroupsService) GetGroupsForUser(authenticatedUser *core.AuthenticatedUser) ([]core.Group, error) {
+	rows, err := s.dao.Queries.GetGroupsForUser(
+		context.Background(),
+		uuid.NullUUID{UUID: authenticatedUser.UserUID, Valid: true},
+	)
+	if err != nil {
+		return nil, core.WrapDbError(err)
+	}
+	groups := make([]core.Group, len(rows))
+	for i, row := range rows {
+		groups[i] = core.Group{
+			CreatedAt: row.CreatedAt,
+			Name:      core.GroupName(row.Name),
+			Owner:     core.NullStringToStringPtr(row.OwnerUsername),
+		}
+	}
+	return groups, nil
+}
+
+// Create a new group, the user will become the owner and first member.
+//
+// Only admins should create groups, otherwise a user could claim the name
+// of a missing group already given access in someone's notes.
+func (s *GroupsService) CreateGroup(
+	authenticatedUser *core.AuthenticatedUser,
+	toCreate core.CreateGroup,
+) (core.Group, error) {
+	tx, err := s.dao.DB.BeginTx(context.Background(), &sql.TxOptions{})
+	if err != nil {
+		return core.Group{}, err
+	}
+	q := s.dao.Queries.WithTx(tx)
+	defer tx.Rollback()
+	row, err := q.InsertGroup(context.Background(), db.InsertGroupParams{
+		Name:     string(toCreate.Name),
+		OwnerUid: uuid.NullUUID{UUID: authenticatedUser.UserUID, Valid: true},
+	})
+	if err != nil {
+		return core.Group{}, core.WrapDbError(err)
+	}
+	if err := q.InsertGroupMember(context.Background(), db.InsertGroupMemberParams{
+		GroupID: row.ID,
+		UserUid: authenticatedUser.UserUID,
+	}); err != nil {
+		return core.Group{}, core.WrapDbError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return core.Group{}, err
+	}
+	return core.Group{
+		CreatedAt: row.CreatedAt,
+		Name:      core.GroupName(row.Name),
+		Owner:     &authenticatedUser.Username,
+	}, nil
+}
+
+// Delete a group, only allowed by the owner.
+func (s *GroupsService) DeleteGroup(
+	authenticatedUser *core.AuthenticatedUser,
+	name core.GroupName,
+) error {
+	group, err := s.getOwnedGroup(authenticatedUser, name)
+	if err != nil {
+		return err
+	}
+	return core.WrapDbError(s.dao.Queries.DeleteGroup(context.Background(), group.ID))
+}
+
+// Get the members of a group, only allowed by the owner or members.
+func (s *GroupsService) GetGroupMembers(
+	authenticatedUser *core.AuthenticatedUser,
+	name core.GroupName,
+) ([]string, error) {
+	group, err := core.WrapDbErrorWithValue(s.dao.Queries.GetGroupByName(context.Background(), string(name)))
+	if err != nil {
+		return nil, err
+	}
+	members, err := core.WrapDbErrorWithValue(
+		s.dao.Queries.GetGroupMemberUsernames(context.Background(), group.ID),
+	)
+	if err != nil {
+		return nil, err
+	}
+	isOwner := group.OwnerUid.Valid && group.OwnerUid.UUID == authenticatedUser.UserUID
+	if !isOwner && !slices.Contains(members, authenticatedUser.Username) {
+		return nil, core.ErrNotFound
+	}
+	return members, nil
+}
+
+// Add a user to a group, only allowed by the owner.
+func (s *GroupsService) AddGroupMember(
+	authenticatedUser *core.AuthenticatedUser,
+	name core.GroupName,
+	username core.Username,
+) error {
+	group, err := s.getOwnedGroup(authenticatedUser, name)
+	if err != nil {
+		return err
+	}
+	userUid, err := core.WrapDbErrorWithValue(
+		s.dao.Queries.GetUserUidByUsername(context.Background(), string(username)),
+	)
+	if err != nil {
+		return err
+	}
+	return core.WrapDbError(s.dao.Queries.InsertGroupMember(context.Background(), db.InsertGroupMemberParams{
+		GroupID: group.ID,
+		UserUid: userUid,
+	}))
+}
+
+// Remove a user from a group, allowed by the owner or by members removing themselves.
+func (s *GroupsService) RemoveGroupMember(
+	authenticatedUser *core.AuthenticatedUser,
+	name core.GroupName,
+	username core.Username,
+) error {
+	var groupID int64
+	if string(username) == authenticatedUser.Username {
+		group, err := core.WrapDbErrorWithValue(s.dao.Queries.GetGroupByName(context.Background(), string(name)))
+		if err != nil {
+			return err
+		}
+		groupID = group.ID
+	} else {
+		group, err := s.getOwnedGroup(authenticatedUser, name)
+		if err != nil {
+			return err
+		}
+		groupID = group.ID
+	}
+	userUid, err := core.WrapDbErrorWithValue(
+		s.dao.Queries.GetUserUidByUsername(context.Background(), string(username)),
+	)
+	if err != nil {
+		return err
+	}
+	return core.WrapDbError(s.dao.Queries.DeleteGroupMember(context.Background(), db.DeleteGroupMemberParams{
+		GroupID: groupID,
+		UserUid: userUid,
+	}))
+}
+
+// Get a group, ensuring the user is the owner.
+func (s *GroupsService) getOwnedGroup(
+	authenticatedUser *core.AuthenticatedUser,
+	name core.GroupName,
+) (db.GetGroupByNameRow, error) {
+	group, err := core.WrapDbErrorWithValue(s.dao.Queries.GetGroupByName(context.Background(), string(name)))
+	if err != nil {
+		return db.GetGroupByNameRow{}, err
+	}
+	if !group.OwnerUid.Valid || group.OwnerUid.UUID != authenticatedUser.UserUID {
+		return db.GetGroupByNameRow{}, core.ErrNotFound
+	}
+	return group, nil
+}
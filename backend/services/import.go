@@ -0,0 +1,99 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/importer"
+	"github.com/enchant97/note-mark/backend/tree"
+)
+
+var ErrImportArchiveInvalid = errors.New("import archive invalid")
+
+type ImportService struct {
+	treeService *TreeService
+	importer    importer.ObsidianImporter
+}
+
+func (s ImportService) New(
+	tc *tree.TreeController,
+	treeService *TreeService,
+	limits importer.Limits,
+) ImportService {
+	return ImportService{
+		treeService: treeService,
+		importer:    importer.ObsidianImporter{}.New(tc, limits),
+	}
+}
+
+// Import an Obsidian vault from a zip archive,
+// placing it under parentSlug (blank for the root).
+func (s *ImportService) ImportObsidianArchive(
+	authenticatedUser *core.AuthenticatedUser,
+	username core.Username,
+	parentSlug core.NodeSlug,
+	archive []byte,
+) (core.ImportReport, error) {
+	// access control check
+	if parentSlug == "" {
+		if authenticatedUser.Username != string(username) {
+			return core.ImportReport{}, core.ErrNotFound
+		}
+	} else if acMode, err := s.treeService.GetAvailableNodeAccessControlMode(
+		authenticatedUser,
+		username,
+		parentSlug,
+		true,
+	); err != nil {
+		return core.ImportReport{}, err
+	} else if acMode == nil || *acMode != core.AccessControlWriteMode {
+		return core.ImportReport{}, core.ErrNotFound
+	}
+	zipReader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return core.ImportReport{}, errors.Join(err, ErrImportArchiveInvalid)
+	}
+	vault, err := findVaultRoot(zipReader)
+	if err != nil {
+		return core.ImportReport{}, errors.Join(err, ErrImportArchiveInvalid)
+	}
+	report, err := s.importer.ImportVault(vault, username, parentSlug)
+	if report.Notes+report.Assets != 0 {
+		entry := core.CreateAuditEntry{
+			Event:    core.AuditNodeWrite,
+			Actor:    core.NewAuditActor(authenticatedUser, nil),
+			Username: &username,
+			Details:  map[string]any{"import": "obsidian", "notes": report.Notes, "assets": report.Assets},
+		}
+		if parentSlug != "" {
+			entry.Slug = &parentSlug
+		}
+		s.treeService.audit.Record(entry)
+	}
+	return report, err
+}
+
+// Archives commonly wrap the vault in a single folder, which is used as the root instead.
+func findVaultRoot(archive fs.FS) (fs.FS, error) {
+	entries, err := fs.ReadDir(archive, ".")
+	if err != nil {
+		return nil, err
+	}
+	var dirs []fs.DirEntry
+	for _, entry := range entries {
+		if entry.Name() == "__MACOSX" {
+			continue
+		}
+		if !entry.IsDir() {
+			return archive, nil
+		}
+		dirs = append(dirs, entry)
+	}
+	if len(dirs) != 1 || dirs[0].Name() == ".obsidian" {
+		return archive, nil
+	}
+	return fs.Sub(archive, dirs[0].Name())
+}
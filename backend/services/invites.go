@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/db"
+	"github.com/enchant97/note-mark/backend/tree"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInviteRequired      = errors.New("invite code required")
+	ErrInviteInvalid       = errors.New("invite code invalid")
+	ErrInviteExpiryInvalid = errors.New("invite expiry invalid")
+)
+
+type InvitesService struct {
+	dao         *db.DAO
+	tc          *tree.TreeController
+	treeService *TreeService
+}
+
+func (s InvitesService) New(dao *db.DAO, tc *tree.TreeController, treeService *TreeService) InvitesService {
+	return InvitesService{
+		dao:         dao,
+		tc:          tc,
+		treeService: treeService,
+	}
+}
+
+// Get invites created by the user.
+func (s *InvitesService) GetInvites(authenticatedUser *core.AuthenticatedUser) ([]core.Invite, error) {
+	rows, err := s.dao.Queries.GetInvitesByCreator(context.Background(), uuid.NullUUID{
+		UUID:  authenticatedUser.UserUID,
+		Valid: true,
+	})
+	if err != nil {
+		return nil, core.WrapDbError(err)
+	}
+	invites := make([]core.Invite, len(rows))
+	for i, row := range rows {
+		invites[i] = core.Invite{
+			Uid:       row.Uid,
+			CreatedAt: row.CreatedAt,
+			MaxUses:   row.MaxUses,
+			UseCount:  row.UseCount,
+			ExpiresAt: row.ExpiresAt,
+			Group:     nullStringToGroupNamePtr(row.GroupName),
+			Share:     nullStringsToInviteShare(row.ShareSlug, row.ShareMode),
+		}
+	}
+	return invites, nil
+}
+
+// Get every invite, including those created from the CLI.
+func (s *InvitesService) GetAllInvites() ([]core.Invite, error) {
+	rows, err := s.dao.Queries.GetInvites(context.Background())
+	if err != nil {
+		return nil, core.WrapDbError(err)
+	}
+	invites := make([]core.Invite, len(rows))
+	for i, row := range rows {
+		invites[i] = core.Invite{
+			Uid:       row.Uid,
+			CreatedAt: row.CreatedAt,
+			MaxUses:   row.MaxUses,
+			UseCount:  row.UseCount,
+			ExpiresAt: row.ExpiresAt,
+			Group:     nullStringToGroupNamePtr(row.GroupName),
+			Share:     nullStringsToInviteShare(row.ShareSlug, row.ShareMode),
+			CreatedBy: core.NullStringToStringPtr(row.CreatorUsername),
+		}
+	}
+	return invites, nil
+}
+
+// Create an invite, when no creator is given it is created by the system.
+//
+// Groups must be owned by the creator, unless they are an admin.
+// Shared notes must belong to the creator, so the system cannot share notes.
+// The code is only ever returned here, as only a hash of it is stored.
+func (s *InvitesService) CreateInvite(
+	optionalCreator *core.AuthenticatedUser,
+	toCreate core.CreateInvite,
+) (core.CreatedInvite, error) {
+	now := time.Now().UTC()
+	expiresAt := now.Add(core.DefaultInviteExpiry)
+	if toCreate.ExpiresAt != nil {
+		if !toCreate.ExpiresAt.After(now) {
+			return core.CreatedInvite{}, ErrInviteExpiryInvalid
+		}
+		expiresAt = toCreate.ExpiresAt.UTC()
+	}
+	maxUses := toCreate.MaxUses
+	if maxUses < 1 {
+		maxUses = 1
+	}
+	var creatorUid uuid.NullUUID
+	if optionalCreator != nil {
+		creatorUid = uuid.NullUUID{UUID: optionalCreator.UserUID, Valid: true}
+	}
+	var groupID sql.NullInt64
+	if toCreate.Group != nil {
+		group, err := core.WrapDbErrorWithValue(s.dao.Queries.GetGroupByName(context.Background(), string(*toCreate.Group)))
+		if err != nil {
+			return core.CreatedInvite{}, err
+		}
+		if optionalCreator != nil && !optionalCreator.IsAdmin &&
+			(!group.OwnerUid.Valid || group.OwnerUid.UUID != optionalCreator.UserUID) {
+			return core.CreatedInvite{}, core.ErrNotFound
+		}
+		groupID = sql.NullInt64{Int64: group.ID, Valid: true}
+	}
+	var shareSlug, shareMode sql.NullString
+	if toCreate.Share != nil {
+		if optionalCreator == nil {
+			return core.CreatedInvite{}, core.ErrNotFound
+		}
+		node, err := s.tc.TryGetNode(core.Username(optionalCreator.Username), toCreate.Share.Slug)
+		if err != nil {
+			return core.CreatedInvite{}, err
+		} else if node.NoteNodeFields == nil {
+			return core.CreatedInvite{}, core.ErrNotFound
+		}
+		shareSlug = sql.NullString{String: string(toCreate.Share.Slug), Valid: true}
+		shareMode = sql.NullString{String: string(toCreate.Share.Mode), Valid: true}
+	}
+	code, codeHash := core.NewInviteCode()
+	row, err := s.dao.Queries.InsertInvite(context.Background(), db.InsertInviteParams{
+		Uid:        core.MustNewUID(),
+		CreatorUid: creatorUid,
+		CodeHash:   codeHash,
+		MaxUses:    maxUses,
+		// stored to the second, as they are compared as text
+		ExpiresAt: expiresAt.Truncate(time.Second),
+		GroupID:   groupID,
+		ShareSlug: shareSlug,
+		ShareMode: shareMode,
+	})
+	if err != nil {
+		return core.CreatedInvite{}, core.WrapDbError(err)
+	}
+	return core.CreatedInvite{
+		Invite: core.Invite{
+			Uid:       row.Uid,
+			CreatedAt: row.CreatedAt,
+			MaxUses:   row.MaxUses,
+			UseCount:  row.UseCount,
+			ExpiresAt: row.ExpiresAt,
+			Group:     toCreate.Group,
+			Share:     nullStringsToInviteShare(row.ShareSlug, row.ShareMode),
+		},
+		Code: code,
+	}, nil
+}
+
+// Revoke an invite, admins can revoke any invite.
+func (s *InvitesService) DeleteInvite(authenticatedUser *core.AuthenticatedUser, uid uuid.UUID) error {
+	var count int64
+	var err error
+	if authenticatedUser.IsAdmin {
+		count, err = s.dao.Queries.AdminDeleteInvite(context.Background(), uid)
+	} else {
+		count, err = s.dao.Queries.DeleteInvite(context.Background(), db.DeleteInviteParams{
+			Uid:        uid,
+			CreatorUid: uuid.NullUUID{UUID: authenticatedUser.UserUID, Valid: true},
+		})
+	}
+	if err != nil {
+		return core.WrapDbError(err)
+	} else if count == 0 {
+		return core.ErrNotFound
+	}
+	return nil
+}
+
+// Count a use of an invite, errors with `ErrInviteInvalid`
+// when it is unknown, expired or has been used up.
+//
+// Intended to be called within the transaction creating the user.
+func (s *InvitesService) claimInvite(q *db.Queries, code string) (db.ClaimInviteRow, error) {
+	row, err := core.WrapDbErrorWithValue(q.ClaimInvite(context.Background(), db.ClaimInviteParams{
+		CodeHash: core.HashInviteCode(code),
+		Now:      time.Now().UTC().Truncate(time.Second),
+	}))
+	if errors.Is(err, core.ErrNotFound) {
+		return db.ClaimInviteRow{}, ErrInviteInvalid
+	}
+	return row, err
+}
+
+// Share the invite's note with a user who signed up using it.
+//
+// The user has already been created, so failures are only logged.
+func (s *InvitesService) applyInviteShare(invite db.ClaimInviteRow, username core.Username) {
+	if !invite.CreatorUid.Valid || !invite.ShareSlug.Valid {
+		return
+	}
+	creator, err := s.dao.Queries.GetUserByUid(context.Background(), invite.CreatorUid.UUID)
+	if err != nil {
+		slog.Warn("invite creator not found, note not shared", "invite", invite.Uid, "err", err)
+		return
+	}
+	slug := core.NodeSlug(invite.ShareSlug.String)
+	node, err := s.tc.TryGetNode(core.Username(creator.Username), slug)
+	if err != nil || node.NoteNodeFields == nil {
+		slog.Warn("invite note not found, note not shared", "invite", invite.Uid, "slug", slug)
+		return
+	}
+	share := core.InviteShare{Slug: slug, Mode: core.AccessControlMode(invite.ShareMode.String)}
+	frontmatter := node.FrontMatter
+	frontmatter.AccessControl = share.AddToAccessControl(frontmatter.AccessControl, username)
+	actor := core.NewAuditActor(&core.AuthenticatedUser{
+		UserUID:  creator.Uid,
+		Username: creator.Username,
+		IsAdmin:  creator.IsAdmin,
+	}, nil)
+	if err := s.treeService.UpdateNoteNodeFrontmatter(actor, core.Username(creator.Username), slug, frontmatter); err != nil {
+		slog.Warn("failed to share invite note", "invite", invite.Uid, "slug", slug, "err", err)
+	}
+}
+
+func nullStringToGroupNamePtr(v sql.NullString) *core.GroupName {
+	if v.Valid {
+		name := core.GroupName(v.String)
+		return &name
+	}
+	return nil
+}
+
+func nullStringsToInviteShare(slug sql.NullString, mode sql.NullString) *core.InviteShare {
+	if slug.Valid && mode.Valid {
+		return &core.InviteShare{
+			Slug: core.NodeSlug(slug.String),
+			Mode: core.AccessControlMode(mode.String),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/adrg/frontmatter"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/db"
+	"github.com/enchant97/note-mark/backend/tree"
+	"go.yaml.in/yaml/v4"
+)
+
+// Maximum amount of days that can be listed at once.
+const MaxJournalRangeDays = 366
+
+var ErrJournalRangeInvalid = errors.New("journal date range invalid")
+
+type JournalService struct {
+	dao         *db.DAO
+	tc          *tree.TreeController
+	treeService *TreeService
+	slugPattern string
+}
+
+func (s JournalService) New(
+	dao *db.DAO,
+	tc *tree.TreeController,
+	treeService *TreeService,
+	slugPattern string,
+) JournalService {
+	return JournalService{
+		dao:         dao,
+		tc:          tc,
+		treeService: treeService,
+		slugPattern: slugPattern,
+	}
+}
+
+// Get the current date for a user, using their timezone.
+func (s *JournalService) GetTodayForUser(username core.Username) (time.Time, error) {
+	timezone, err := core.WrapDbErrorWithValue(
+		s.dao.Queries.GetUserTimezone(context.Background(), string(username)),
+	)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := core.LoadTimezone(core.NullStringToStringPtr(timezone))
+	if err != nil {
+		// an invalid timezone should never block a user
+		loc = time.UTC
+	}
+	return time.Now().In(loc), nil
+}
+
+// Get the journal slug for a specific date, ensuring it is a valid note slug.
+func (s *JournalService) GetSlugForDate(date time.Time) (core.NodeSlug, error) {
+	fullSlug := core.FormatJournalSlug(s.slugPattern, date)
+	if !core.IsValidNodeSlug(string(fullSlug), core.NoteNode) {
+		return "", core.ErrSlugInvalid
+	}
+	return fullSlug, nil
+}
+
+// Get or create the journal entry for today.
+//
+// Returns whether the entry was created.
+func (s *JournalService) GetOrCreateTodayEntry(
+	authenticatedUser *core.AuthenticatedUser,
+	username core.Username,
+	templateSlug *core.NodeSlug,
+) (core.JournalEntry, bool, error) {
+	if _, err := s.tc.TryGetNodeTreeForUser(username); err != nil {
+		return core.JournalEntry{}, false, core.ErrNotFound
+	}
+	today, err := s.GetTodayForUser(username)
+	if err != nil {
+		return core.JournalEntry{}, false, err
+	}
+	fullSlug, err := s.GetSlugForDate(today)
+	if err != nil {
+		return core.JournalEntry{}, false, err
+	}
+	// access control check
+	if acMode, err := s.treeService.GetAvailableNodeAccessControlMode(
+		authenticatedUser,
+		username,
+		fullSlug,
+		true,
+	); err != nil {
+		return core.JournalEntry{}, false, err
+	} else if acMode == nil || *acMode != core.AccessControlWriteMode {
+		return core.JournalEntry{}, false, core.ErrNotFound
+	}
+	if node, err := s.tc.TryGetNode(username, fullSlug); err == nil {
+		return nodeIntoJournalEntry(today, fullSlug, node), false, nil
+	}
+	content, err := s.makeEntryContent(authenticatedUser, username, today, templateSlug)
+	if err != nil {
+		return core.JournalEntry{}, false, err
+	}
+	created, err := s.tc.WriteNoteNodeIfNotExists(username, fullSlug, bytes.NewReader(content))
+	if err != nil {
+		return core.JournalEntry{}, false, err
+	}
+	if created {
+		s.treeService.recordNodeWrite(core.NewAuditActor(authenticatedUser, nil), username, fullSlug, nil)
+	}
+	node, err := s.tc.TryGetNode(username, fullSlug)
+	if err != nil {
+		return core.JournalEntry{}, false, err
+	}
+	return nodeIntoJournalEntry(today, fullSlug, node), created, nil
+}
+
+// Get existing journal entries between two dates (inclusive),
+// only including the entries the user has access to.
+func (s *JournalService) GetEntriesForRange(
+	optionalAuthUser *core.AuthenticatedUser,
+	username core.Username,
+	from time.Time,
+	to time.Time,
+) ([]core.JournalEntry, error) {
+	if to.Before(from) || to.Sub(from) > MaxJournalRangeDays*24*time.Hour {
+		return nil, ErrJournalRangeInvalid
+	}
+	if _, err := s.tc.TryGetNodeTreeForUser(username); err != nil {
+		return nil, core.ErrNotFound
+	}
+	entries := []core.JournalEntry{}
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		fullSlug, err := s.GetSlugForDate(date)
+		if err != nil {
+			return nil, err
+		}
+		node, err := s.tc.TryGetNode(username, fullSlug)
+		if errors.Is(err, core.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if acMode, err := s.treeService.GetAvailableNodeAccessControlMode(
+			optionalAuthUser,
+			username,
+			fullSlug,
+			false,
+		); err != nil || acMode == nil {
+			continue
+		}
+		entries = append(entries, nodeIntoJournalEntry(date, fullSlug, node))
+	}
+	return entries, nil
+}
+
+// Make the initial content for a new journal entry,
+// using the content of a template note when given.
+func (s *JournalService) makeEntryContent(
+	authenticatedUser *core.AuthenticatedUser,
+	username core.Username,
+	date time.Time,
+	templateSlug *core.NodeSlug,
+) ([]byte, error) {
+	dateStr := date.Format(core.JournalDateFormat)
+	var body []byte
+	if templateSlug != nil {
+		if acMode, err := s.treeService.GetAvailableNodeAccessControlMode(
+			authenticatedUser,
+			username,
+			*templateSlug,
+			false,
+		); err != nil {
+			return nil, err
+		} else if acMode == nil {
+			return nil, core.ErrNotFound
+		}
+		r, err := s.tc.GetNoteNodeContent(username, *templateSlug)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		var fm core.FrontMatter
+		rest, err := frontmatter.Parse(r, &fm)
+		if err != nil {
+			return nil, errors.Join(err, core.ErrParsingContent)
+		}
+		body = []byte(strings.ReplaceAll(string(rest), "{{date}}", dateStr))
+	}
+	rawFm, err := yaml.Marshal(&core.FrontMatter{Title: dateStr})
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	b.WriteString("---\n")
+	b.Write(rawFm)
+	b.WriteString("---\n\n")
+	b.Write(body)
+	return b.Bytes(), nil
+}
+
+func nodeIntoJournalEntry(date time.Time, fullSlug core.NodeSlug, node core.Node) core.JournalEntry {
+	entry := core.JournalEntry{
+		Date:    date.Format(core.JournalDateFormat),
+		Slug:    fullSlug,
+		ModTime: node.ModTime,
+	}
+	if node.NoteNodeFields != nil {
+		entry.Title = node.FrontMatter.Title
+	}
+	return entry
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/enchant97/note-mark/backend/config"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/db"
+	"github.com/enchant97/note-mark/backend/ratelimit"
+)
+
+// Create the limiter for failed password attempts, nil when rate limiting is disabled.
+//
+// It is shared between logins and share link passwords.
+func NewLoginLimiter(appConfig config.AppConfig, dao *db.DAO) *ratelimit.Limiter {
+	if !appConfig.LoginRateLimit.Enable {
+		return nil
+	}
+	var store ratelimit.Store
+	if appConfig.LoginRateLimit.Persist {
+		loginThrottleStore := LoginThrottleStore{}.New(dao)
+		store = &loginThrottleStore
+	}
+	limiter, err := ratelimit.Limiter{}.New(ratelimit.Options{
+		MaxFailures: appConfig.LoginRateLimit.MaxFailures,
+		Lockout:     appConfig.LoginRateLimit.Lockout,
+		MaxLockout:  appConfig.LoginRateLimit.MaxLockout,
+		Window:      appConfig.LoginRateLimit.Window,
+	}, store)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &limiter
+}
+
+// Persists login rate limit entries, so lockouts survive restarts.
+type LoginThrottleStore struct {
+	dao *db.DAO
+}
+
+func (s LoginThrottleStore) New(dao *db.DAO) LoginThrottleStore {
+	return LoginThrottleStore{
+		dao: dao,
+	}
+}
+
+func (s *LoginThrottleStore) LoadEntries() (map[string]ratelimit.Entry, error) {
+	rows, err := s.dao.Queries.GetLoginThrottles(context.Background())
+	if err != nil {
+		return nil, core.WrapDbError(err)
+	}
+	entries := make(map[string]ratelimit.Entry, len(rows))
+	for _, row := range rows {
+		entries[row.Key] = ratelimit.Entry{
+			Failures:      uint(row.Failures),
+			LastFailureAt: row.LastFailureAt,
+			LockedUntil:   row.LockedUntil.Time,
+		}
+	}
+	return entries, nil
+}
+
+func (s *LoginThrottleStore) SaveEntry(key string, entry ratelimit.Entry) error {
+	lockedUntil := sql.NullTime{}
+	if !entry.LockedUntil.IsZero() {
+		lockedUntil = sql.NullTime{Time: entry.LockedUntil.UTC(), Valid: true}
+	}
+	return core.WrapDbError(s.dao.Queries.UpsertLoginThrottle(context.Background(), db.UpsertLoginThrottleParams{
+		Key:           key,
+		Failures:      int64(entry.Failures),
+		LastFailureAt: entry.LastFailureAt.UTC(),
+		LockedUntil:   lockedUntil,
+	}))
+}
+
+func (s *LoginThrottleStore) DeleteEntry(key string) error {
+	return core.WrapDbError(s.dao.Queries.DeleteLoginThrottle(context.Background(), key))
+}
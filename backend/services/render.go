@@ -0,0 +1,60 @@
+package services
+
+import (
+	"io"
+	"time"
+
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/render"
+	"github.com/enchant97/note-mark/backend/tree"
+)
+
+const renderCacheMaxEntries = 512
+
+type RenderService struct {
+	tc       *tree.TreeController
+	renderer render.MarkdownRenderer
+	cache    *render.RenderCache
+}
+
+func (s RenderService) New(tc *tree.TreeController, apiBaseUrl string) RenderService {
+	cache := render.RenderCache{}.New(renderCacheMaxEntries)
+	return RenderService{
+		tc:       tc,
+		renderer: render.MarkdownRenderer{}.New(render.APILinkResolver(apiBaseUrl)),
+		cache:    &cache,
+	}
+}
+
+// Render a note node into sanitized HTML, returning the node modification time.
+//
+// Renders are cached until the node is modified.
+func (s *RenderService) RenderNoteNode(
+	username core.Username,
+	fullSlug core.NodeSlug,
+) ([]byte, time.Time, error) {
+	node, err := s.tc.TryGetNode(username, fullSlug)
+	if err != nil {
+		return nil, time.Time{}, err
+	} else if node.Type != core.NoteNode {
+		return nil, time.Time{}, core.ErrNotFound
+	}
+	if html, ok := s.cache.Get(username, fullSlug, node.ModTime); ok {
+		return html, node.ModTime, nil
+	}
+	r, err := s.tc.GetNoteNodeContent(username, fullSlug)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer r.Close()
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	html, _, err := s.renderer.RenderNote(username, fullSlug, raw)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	s.cache.Set(username, fullSlug, node.ModTime, html)
+	return html, node.ModTime, nil
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/db"
+	"github.com/enchant97/note-mark/backend/ratelimit"
+	"github.com/enchant97/note-mark/backend/signingkeys"
+	"github.com/enchant97/note-mark/backend/tree"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrShareLinkExpiryInvalid = errors.New("share link expiry invalid")
+	ErrSharePasswordRequired  = errors.New("share link password required")
+	ErrShareLinkNoPassword    = errors.New("share link has no password")
+)
+
+// How long an unlocked share link can be used before the password is needed again.
+const shareUnlockExpiry = time.Hour
+
+type ShareLinksService struct {
+	dao             *db.DAO
+	tc              *tree.TreeController
+	keyring         *signingkeys.Keyring
+	passwordLimiter *ratelimit.Limiter
+}
+
+func (s ShareLinksService) New(
+	dao *db.DAO,
+	tc *tree.TreeController,
+	keyring *signingkeys.Keyring,
+	passwordLimiter *ratelimit.Limiter,
+) ShareLinksService {
+	return ShareLinksService{
+		dao:             dao,
+		tc:              tc,
+		keyring:         keyring,
+		passwordLimiter: passwordLimiter,
+	}
+}
+
+// Get all share links created by the user.
+func (s *ShareLinksService) GetShareLinks(authenticatedUser *core.AuthenticatedUser) ([]core.ShareLink, error) {
+	rows, err := s.dao.Queries.GetShareLinksByOwner(context.Background(), authenticatedUser.UserUID)
+	if err != nil {
+		return nil, core.WrapDbError(err)
+	}
+	links := make([]core.ShareLink, len(rows))
+	for i, row := range rows {
+		links[i] = core.ShareLink{
+			Uid:                row.Uid,
+			CreatedAt:          row.CreatedAt,
+			Slug:               core.NodeSlug(row.Slug),
+			IncludeDescendants: row.IncludeDescendants,
+			Mode:               core.AccessControlMode(row.Mode),
+			ExpiresAt:          nullTimeToTimePtr(row.ExpiresAt),
+			HasPassword:        row.PasswordHash != nil,
+			RequestCount:       row.RequestCount,
+			LastUsedAt:         nullTimeToTimePtr(row.LastUsedAt),
+		}
+	}
+	return links, nil
+}
+
+// Create a share link for one of the user's own nodes.
+//
+// The token is only ever returned here, as only a hash of it is stored.
+func (s *ShareLinksService) CreateShareLink(
+	authenticatedUser *core.AuthenticatedUser,
+	toCreate core.CreateShareLink,
+) (core.CreatedShareLink, error) {
+	if _, err := s.tc.TryGetNode(core.Username(authenticatedUser.Username), toCreate.Slug); err != nil {
+		return core.CreatedShareLink{}, err
+	}
+	expiresAt := sql.NullTime{}
+	if toCreate.ExpiresAt != nil {
+		if !toCreate.ExpiresAt.After(time.Now()) {
+			return core.CreatedShareLink{}, ErrShareLinkExpiryInvalid
+		}
+		expiresAt = sql.NullTime{Time: toCreate.ExpiresAt.UTC(), Valid: true}
+	}
+	var passwordHash []byte
+	if toCreate.Password != nil {
+		passwordHash = core.HashPassword(*toCreate.Password)
+	}
+	token, tokenHash := core.NewShareToken()
+	row, err := s.dao.Queries.InsertShareLink(context.Background(), db.InsertShareLinkParams{
+		Uid:                core.MustNewUID(),
+		OwnerUid:           authenticatedUser.UserUID,
+		TokenHash:          tokenHash,
+		Slug:               string(toCreate.Slug),
+		IncludeDescendants: toCreate.IncludeDescendants,
+		Mode:               string(toCreate.Mode),
+		ExpiresAt:          expiresAt,
+		PasswordHash:       passwordHash,
+	})
+	if err != nil {
+		return core.CreatedShareLink{}, core.WrapDbError(err)
+	}
+	return core.CreatedShareLink{
+		ShareLink: core.ShareLink{
+			Uid:                row.Uid,
+			CreatedAt:          row.CreatedAt,
+			Slug:               core.NodeSlug(row.Slug),
+			IncludeDescendants: row.IncludeDescendants,
+			Mode:               core.AccessControlMode(row.Mode),
+			ExpiresAt:          nullTimeToTimePtr(row.ExpiresAt),
+			HasPassword:        row.PasswordHash != nil,
+			RequestCount:       row.RequestCount,
+			LastUsedAt:         nullTimeToTimePtr(row.LastUsedAt),
+		},
+		Token: token,
+	}, nil
+}
+
+// Revoke one of the user's share links.
+func (s *ShareLinksService) DeleteShareLink(authenticatedUser *core.AuthenticatedUser, uid uuid.UUID) error {
+	count, err := s.dao.Queries.DeleteShareLink(context.Background(), db.DeleteShareLinkParams{
+		Uid:      uid,
+		OwnerUid: authenticatedUser.UserUID,
+	})
+	if err != nil {
+		return core.WrapDbError(err)
+	} else if count == 0 {
+		return core.ErrNotFound
+	}
+	return nil
+}
+
+// Get an unexpired share link by its token.
+func (s *ShareLinksService) getShareLinkByToken(token string) (db.GetShareLinkByTokenHashRow, error) {
+	link, err := core.WrapDbErrorWithValue(
+		s.dao.Queries.GetShareLinkByTokenHash(context.Background(), core.HashShareToken(token)),
+	)
+	if err != nil {
+		return link, err
+	}
+	if link.ExpiresAt.Valid && !link.ExpiresAt.Time.After(time.Now()) {
+		return link, core.ErrNotFound
+	}
+	return link, nil
+}
+
+// Unlock a password protected share link, giving a short-lived grant to use instead of the password.
+//
+// Password attempts are rate limited by link and client IP,
+// erroring with `ratelimit.ErrLocked` when too many have failed.
+func (s *ShareLinksService) UnlockShareLink(
+	token string,
+	password string,
+	clientIP string,
+) (core.ShareUnlock, error) {
+	link, err := s.getShareLinkByToken(token)
+	if err != nil {
+		return core.ShareUnlock{}, err
+	}
+	if link.PasswordHash == nil {
+		return core.ShareUnlock{}, ErrShareLinkNoPassword
+	}
+	if err := s.checkSharePassword(link.Uid, link.PasswordHash, password, clientIP); err != nil {
+		return core.ShareUnlock{}, err
+	}
+	expiresDuration := shareUnlockExpiry
+	if link.ExpiresAt.Valid {
+		expiresDuration = min(expiresDuration, time.Until(link.ExpiresAt.Time))
+	}
+	return core.CreateShareUnlockGrant(link.Uid, s.keyring, expiresDuration)
+}
+
+// Get the access given by a share token, counting it as a request made with the link.
+//
+// Unknown and expired tokens will give a not found error,
+// password protected links also need a grant from `UnlockShareLink`.
+func (s *ShareLinksService) ResolveShareToken(
+	token string,
+	unlockGrant string,
+) (*core.ShareGrant, error) {
+	link, err := s.getShareLinkByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if link.PasswordHash != nil {
+		if unlockGrant == "" {
+			return nil, ErrSharePasswordRequired
+		}
+		if linkUid, err := core.ParseShareUnlockGrant(unlockGrant, s.keyring); err != nil || linkUid != link.Uid {
+			return nil, core.ErrInvalidCredentials
+		}
+	}
+	if err := s.dao.Queries.IncrementShareLinkRequestCount(context.Background(), link.Uid); err != nil {
+		return nil, core.WrapDbError(err)
+	}
+	return &core.ShareGrant{
+		LinkUid:            link.Uid,
+		Owner:              core.Username(link.OwnerUsername),
+		Slug:               core.NodeSlug(link.Slug),
+		IncludeDescendants: link.IncludeDescendants,
+		Mode:               core.AccessControlMode(link.Mode),
+	}, nil
+}
+
+// Check a password for a share link, counting failures towards a lockout.
+func (s *ShareLinksService) checkSharePassword(
+	linkUid uuid.UUID,
+	passwordHash []byte,
+	password string,
+	clientIP string,
+) error {
+	limitKeys := []string{"share:" + linkUid.String(), "share-ip:" + clientIP}
+	if s.passwordLimiter != nil {
+		if err := s.passwordLimiter.Check(limitKeys...); err != nil {
+			return err
+		}
+	}
+	if !core.DoesPasswordMatchHashed(password, passwordHash) {
+		if s.passwordLimiter != nil {
+			for _, key := range s.passwordLimiter.Fail(limitKeys...) {
+				slog.Warn("too many failed share link passwords, locking out", "key", key)
+			}
+		}
+		return core.ErrInvalidCredentials
+	}
+	if s.passwordLimiter != nil {
+		// only forget the link, so an IP can't reset itself using a known password
+		s.passwordLimiter.Reset(limitKeys[0])
+	}
+	return nil
+}
+
+func nullTimeToTimePtr(v sql.NullTime) *time.Time {
+	if v.Valid {
+		return &v.Time
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/db"
+	"github.com/google/uuid"
+)
+
+type TotpService struct {
+	dao   *db.DAO
+	audit *AuditService
+}
+
+func (s TotpService) New(dao *db.DAO, audit *AuditService) TotpService {
+	return TotpService{
+		dao:   dao,
+		audit: audit,
+	}
+}
+
+func (s *TotpService) GetStatus(userUid uuid.UUID) (core.TotpStatus, error) {
+	totp, err := core.WrapDbErrorWithValue(s.dao.Queries.GetUserTotp(context.Background(), userUid))
+	if errors.Is(err, core.ErrNotFound) || (err == nil && !totp.EnabledAt.Valid) {
+		return core.TotpStatus{}, nil
+	} else if err != nil {
+		return core.TotpStatus{}, err
+	}
+	remaining, err := s.dao.Queries.CountUnusedUserRecoveryCodes(context.Background(), userUid)
+	if err != nil {
+		return core.TotpStatus{}, core.WrapDbError(err)
+	}
+	return core.TotpStatus{
+		Enabled:                true,
+		RecoveryCodesRemaining: int(remaining),
+	}, nil
+}
+
+// Start enrolment by generating a new secret,
+// TOTP is not required for login until the enrolment is confirmed.
+//
+// errors with `core.ErrConflict` when TOTP is already enabled.
+func (s *TotpService) StartEnrolment(authenticatedUser core.AuthenticatedUser) (core.TotpEnrolment, error) {
+	if enabled, err := s.isEnabled(authenticatedUser.UserUID); err != nil {
+		return core.TotpEnrolment{}, err
+	} else if enabled {
+		return core.TotpEnrolment{}, core.ErrConflict
+	}
+	secret := core.GenerateTotpSecret()
+	if err := s.dao.Queries.UpsertPendingUserTotp(context.Background(), db.UpsertPendingUserTotpParams{
+		UserUid: authenticatedUser.UserUID,
+		Secret:  secret,
+	}); err != nil {
+		return core.TotpEnrolment{}, core.WrapDbError(err)
+	}
+	return core.TotpEnrolment{
+		Secret:          secret,
+		ProvisioningUri: core.TotpProvisioningURI(authenticatedUser.Username, secret),
+	}, nil
+}
+
+// Confirm a pending enrolment with a code from the authenticator,
+// returns recovery codes which are only ever shown once.
+//
+// errors with `core.ErrNotFound` when enrolment has not been started,
+// `core.ErrConflict` when already enabled and `core.ErrInvalidCredentials` for a wrong code.
+func (s *TotpService) ConfirmEnrolment(
+	authenticatedUser core.AuthenticatedUser,
+	code string,
+) (core.TotpRecoveryCodes, error) {
+	totp, err := core.WrapDbErrorWithValue(s.dao.Queries.GetUserTotp(context.Background(), authenticatedUser.UserUID))
+	if err != nil {
+		return core.TotpRecoveryCodes{}, err
+	}
+	if totp.EnabledAt.Valid {
+		return core.TotpRecoveryCodes{}, core.ErrConflict
+	}
+	step, ok := core.ValidateTotpCode(totp.Secret, code, time.Now(), totp.LastUsedStep)
+	if !ok {
+		return core.TotpRecoveryCodes{}, core.ErrInvalidCredentials
+	}
+	tx, err := s.dao.DB.BeginTx(context.Background(), &sql.TxOptions{})
+	if err != nil {
+		return core.TotpRecoveryCodes{}, err
+	}
+	defer tx.Rollback()
+	q := s.dao.Queries.WithTx(tx)
+	if err := q.EnableUserTotp(context.Background(), db.EnableUserTotpParams{
+		LastUsedStep: step,
+		UserUid:      authenticatedUser.UserUID,
+	}); err != nil {
+		return core.TotpRecoveryCodes{}, core.WrapDbError(err)
+	}
+	codes, err := replaceRecoveryCodes(q, authenticatedUser.UserUID)
+	if err != nil {
+		return core.TotpRecoveryCodes{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return core.TotpRecoveryCodes{}, err
+	}
+	s.audit.Record(core.CreateAuditEntry{
+		Event: core.AuditTotpEnabled,
+		Actor: core.NewAuditActor(&authenticatedUser, nil),
+	})
+	return codes, nil
+}
+
+// Disable TOTP, a valid code or recovery code is required.
+func (s *TotpService) Disable(authenticatedUser core.AuthenticatedUser, code string) error {
+	if err := s.VerifyCode(authenticatedUser.UserUID, code); err != nil {
+		return err
+	}
+	if err := ResetUserTotp(s.dao, authenticatedUser.UserUID); err != nil {
+		return err
+	}
+	s.audit.Record(core.CreateAuditEntry{
+		Event: core.AuditTotpDisabled,
+		Actor: core.NewAuditActor(&authenticatedUser, nil),
+	})
+	return nil
+}
+
+// Replace all recovery codes, a valid code or recovery code is required.
+func (s *TotpService) RegenerateRecoveryCodes(
+	authenticatedUser core.AuthenticatedUser,
+	code string,
+) (core.TotpRecoveryCodes, error) {
+	if err := s.VerifyCode(authenticatedUser.UserUID, code); err != nil {
+		return core.TotpRecoveryCodes{}, err
+	}
+	tx, err := s.dao.DB.BeginTx(context.Background(), &sql.TxOptions{})
+	if err != nil {
+		return core.TotpRecoveryCodes{}, err
+	}
+	defer tx.Rollback()
+	codes, err := replaceRecoveryCodes(s.dao.Queries.WithTx(tx), authenticatedUser.UserUID)
+	if err != nil {
+		return core.TotpRecoveryCodes{}, err
+	}
+	return codes, tx.Commit()
+}
+
+// Verify a code for a user with TOTP enabled, a recovery code is also accepted and then used up.
+//
+// errors with `core.ErrNotFound` when TOTP is not enabled,
+// `core.ErrTotpRequired` when code is blank and `core.ErrInvalidCredentials` for a wrong code.
+func (s *TotpService) VerifyCode(userUid uuid.UUID, code string) error {
+	totp, err := core.WrapDbErrorWithValue(s.dao.Queries.GetUserTotp(context.Background(), userUid))
+	if err != nil {
+		return err
+	}
+	if !totp.EnabledAt.Valid {
+		return core.ErrNotFound
+	}
+	if code == "" {
+		return core.ErrTotpRequired
+	}
+	if step, ok := core.ValidateTotpCode(totp.Secret, code, time.Now(), totp.LastUsedStep); ok {
+		// conditional update, so the same code can't be used twice by concurrent requests
+		count, err := s.dao.Queries.UpdateUserTotpLastUsedStep(context.Background(), db.UpdateUserTotpLastUsedStepParams{
+			Step:    step,
+			UserUid: userUid,
+		})
+		if err != nil {
+			return core.WrapDbError(err)
+		}
+		if count == 0 {
+			return core.ErrInvalidCredentials
+		}
+		return nil
+	}
+	count, err := s.dao.Queries.UseUserRecoveryCode(context.Background(), db.UseUserRecoveryCodeParams{
+		UserUid:  userUid,
+		CodeHash: core.HashRecoveryCode(code),
+	})
+	if err != nil {
+		return core.WrapDbError(err)
+	}
+	if count == 0 {
+		return core.ErrInvalidCredentials
+	}
+	return nil
+}
+
+// Check the code given for a login, passing when the user has not enabled TOTP.
+func (s *TotpService) CheckLoginCode(userUid uuid.UUID, code string) error {
+	err := s.VerifyCode(userUid, code)
+	if errors.Is(err, core.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (s *TotpService) isEnabled(userUid uuid.UUID) (bool, error) {
+	totp, err := core.WrapDbErrorWithValue(s.dao.Queries.GetUserTotp(context.Background(), userUid))
+	if errors.Is(err, core.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return totp.EnabledAt.Valid, nil
+}
+
+// Remove TOTP and recovery codes for a user, used when disabling or when a user is locked out.
+func ResetUserTotp(dao *db.DAO, userUid uuid.UUID) error {
+	tx, err := dao.DB.BeginTx(context.Background(), &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := dao.Queries.WithTx(tx)
+	if _, err := q.DeleteUserTotp(context.Background(), userUid); err != nil {
+		return core.WrapDbError(err)
+	}
+	if err := q.DeleteUserRecoveryCodes(context.Background(), userUid); err != nil {
+		return core.WrapDbError(err)
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(q *db.Queries, userUid uuid.UUID) (core.TotpRecoveryCodes, error) {
+	if err := q.DeleteUserRecoveryCodes(context.Background(), userUid); err != nil {
+		return core.TotpRecoveryCodes{}, core.WrapDbError(err)
+	}
+	codes := core.GenerateRecoveryCodes()
+	for _, code := range codes {
+		if err := q.InsertUserRecoveryCode(context.Background(), db.InsertUserRecoveryCodeParams{
+			UserUid:  userUid,
+			CodeHash: core.HashRecoveryCode(code),
+		}); err != nil {
+			return core.TotpRecoveryCodes{}, core.WrapDbError(err)
+		}
+	}
+	return core.TotpRecoveryCodes{Codes: codes}, nil
+}
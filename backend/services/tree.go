@@ -1,24 +1,31 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io"
 	"path"
+	"reflect"
 	"time"
 
+	"github.com/adrg/frontmatter"
 	"github.com/enchant97/note-mark/backend/core"
 	"github.com/enchant97/note-mark/backend/db"
 	"github.com/enchant97/note-mark/backend/tree"
 )
 
 type TreeService struct {
-	dao *db.DAO
-	tc  *tree.TreeController
+	dao   *db.DAO
+	tc    *tree.TreeController
+	audit *AuditService
 }
 
-func (s TreeService) New(dao *db.DAO, tc *tree.TreeController) TreeService {
+func (s TreeService) New(dao *db.DAO, tc *tree.TreeController, audit *AuditService) TreeService {
 	return TreeService{
-		dao: dao,
-		tc:  tc,
+		dao:   dao,
+		tc:    tc,
+		audit: audit,
 	}
 }
 
@@ -28,18 +35,59 @@ func (s *TreeService) GetTreeModTime(username core.Username) (time.Time, error)
 
 func (s *TreeService) GetTreeForUser(
 	optionalAuthUser *core.AuthenticatedUser,
+	optionalShareGrant *core.ShareGrant,
 	username core.Username,
 ) (core.NodeTree, error) {
 	nodeTree, err := s.tc.TryGetNodeTreeForUser(username)
 	if err != nil {
 		return nil, core.ErrNotFound
 	}
+	if optionalAuthUser != nil && optionalAuthUser.Username == string(username) {
+		return nodeTree, nil
+	}
+	accessor, err := s.GetAccessor(optionalAuthUser)
+	if err != nil {
+		return nil, err
+	}
+	if optionalShareGrant != nil {
+		return tree.FilteredNodeTreeWithShare(nodeTree, accessor, username, *optionalShareGrant), nil
+	}
+	return tree.FilteredNodeTree(nodeTree, accessor), nil
+}
+
+// Get nodes other users have shared with the user.
+func (s *TreeService) GetNodesSharedWithUser(
+	authenticatedUser *core.AuthenticatedUser,
+) ([]core.SharedNode, error) {
+	accessor, err := s.GetAccessor(authenticatedUser)
+	if err != nil {
+		return nil, err
+	}
+	return s.tc.GetNodesSharedWith(*accessor), nil
+}
+
+// Get who is accessing nodes, including the groups they are a member of.
+//
+// Will return nil for anonymous users.
+func (s *TreeService) GetAccessor(optionalAuthUser *core.AuthenticatedUser) (*core.Accessor, error) {
 	if optionalAuthUser == nil {
-		return tree.FilteredNodeTree(nodeTree, nil), nil
-	} else if optionalAuthUser.Username != string(username) {
-		return tree.FilteredNodeTree(nodeTree, &username), nil
+		return nil, nil
 	}
-	return nodeTree, nil
+	groupNames, err := s.dao.Queries.GetGroupNamesForUsername(
+		context.Background(),
+		optionalAuthUser.Username,
+	)
+	if err != nil {
+		return nil, core.WrapDbError(err)
+	}
+	accessor := core.Accessor{
+		Username: core.Username(optionalAuthUser.Username),
+		Groups:   make([]core.GroupName, len(groupNames)),
+	}
+	for i, name := range groupNames {
+		accessor.Groups[i] = core.GroupName(name)
+	}
+	return &accessor, nil
 }
 
 func (s *TreeService) GetNodeModTime(
@@ -69,9 +117,11 @@ func (s *TreeService) GetAvailableNodeAccessControlMode(
 		if optionalAuthUser == nil && ac.PublicRead {
 			acMode = core.AccessControlReadMode
 		} else if optionalAuthUser != nil {
-			if mode, exists := ac.Users[core.Username(optionalAuthUser.Username)]; exists {
-				acMode = mode
+			accessor, err := s.GetAccessor(optionalAuthUser)
+			if err != nil {
+				return nil, err
 			}
+			acMode = tree.GetAccessControlModeForAccessor(ac, *accessor)
 		}
 		if acMode == "" {
 			return nil, nil
@@ -81,6 +131,26 @@ func (s *TreeService) GetAvailableNodeAccessControlMode(
 	return nil, core.ErrNotFound
 }
 
+// Same as GetAvailableNodeAccessControlMode,
+// but also considering the access given by an optional share link.
+func (s *TreeService) GetAvailableNodeAccessControlModeWithShare(
+	optionalAuthUser *core.AuthenticatedUser,
+	optionalShareGrant *core.ShareGrant,
+	username core.Username,
+	fullSlug core.NodeSlug,
+	useParentFallback bool,
+) (*core.AccessControlMode, error) {
+	acMode, err := s.GetAvailableNodeAccessControlMode(optionalAuthUser, username, fullSlug, useParentFallback)
+	if err != nil || optionalShareGrant == nil {
+		return acMode, err
+	}
+	shareMode := optionalShareGrant.ModeForNode(username, fullSlug)
+	if shareMode == "" || (acMode != nil && tree.HasAccessControlMode(*acMode, shareMode)) {
+		return acMode, nil
+	}
+	return &shareMode, nil
+}
+
 func (s *TreeService) GetNodeContent(
 	username core.Username,
 	slug core.NodeSlug,
@@ -93,36 +163,133 @@ func (s *TreeService) GetNodeContent(
 }
 
 func (s *TreeService) UpdateNodeContent(
+	actor core.AuditActor,
 	username core.Username,
 	slug core.NodeSlug,
 	r io.Reader,
 ) error {
 	isNoteNode := path.Ext(string(slug)) == ""
 	if isNoteNode {
-		return s.tc.WriteNoteNode(username, slug, r)
+		oldAc := s.getNodeAccessControl(username, slug)
+		if err := s.tc.WriteNoteNode(username, slug, r); err != nil {
+			return err
+		}
+		s.recordNodeWrite(actor, username, slug, oldAc)
+		return nil
 	}
-	return s.tc.WriteAssetNode(username, slug, r)
+	if err := s.tc.WriteAssetNode(username, slug, r); err != nil {
+		return err
+	}
+	s.recordNodeWrite(actor, username, slug, nil)
+	return nil
 }
 
 func (s *TreeService) UpdateNoteNodeFrontmatter(
+	actor core.AuditActor,
 	username core.Username,
 	slug core.NodeSlug,
 	frontmatter core.FrontMatter,
 ) error {
-	return s.tc.UpdateNoteNodeFrontmatter(username, slug, frontmatter)
+	oldAc := s.getNodeAccessControl(username, slug)
+	if err := s.tc.UpdateNoteNodeFrontmatter(username, slug, frontmatter); err != nil {
+		return err
+	}
+	s.recordNodeWrite(actor, username, slug, oldAc)
+	return nil
 }
 
 func (s *TreeService) RenameNode(
+	actor core.AuditActor,
 	username core.Username,
 	slug core.NodeSlug,
 	newSlug core.NodeSlug,
 ) error {
-	return s.tc.RenameNode(username, slug, newSlug)
+	if err := s.tc.RenameNode(username, slug, newSlug); err != nil {
+		return err
+	}
+	s.audit.Record(core.CreateAuditEntry{
+		Event:    core.AuditNodeRename,
+		Actor:    actor,
+		Username: &username,
+		Slug:     &slug,
+		Details:  map[string]core.NodeSlug{"newSlug": newSlug},
+	})
+	// keep share links, invites and comments pointing at the same node
+	if err := s.dao.Queries.UpdateShareLinkSlugs(context.Background(), db.UpdateShareLinkSlugsParams{
+		NewSlug:  string(newSlug),
+		OldSlug:  string(slug),
+		Username: string(username),
+	}); err != nil {
+		return core.WrapDbError(err)
+	}
+	if err := s.dao.Queries.UpdateInviteShareSlugs(context.Background(), db.UpdateInviteShareSlugsParams{
+		NewSlug:  string(newSlug),
+		OldSlug:  string(slug),
+		Username: string(username),
+	}); err != nil {
+		return core.WrapDbError(err)
+	}
+	return core.WrapDbError(s.dao.Queries.UpdateCommentSlugs(context.Background(), db.UpdateCommentSlugsParams{
+		NewSlug:  string(newSlug),
+		OldSlug:  string(slug),
+		Username: string(username),
+	}))
 }
 
 func (s *TreeService) DeleteNode(
+	actor core.AuditActor,
 	username core.Username,
 	slug core.NodeSlug,
 ) error {
-	return s.tc.DeleteNode(username, slug)
+	if err := s.tc.DeleteNode(username, slug); err != nil {
+		return err
+	}
+	s.audit.Record(core.CreateAuditEntry{
+		Event:    core.AuditNodeDelete,
+		Actor:    actor,
+		Username: &username,
+		Slug:     &slug,
+	})
+	return core.WrapDbError(s.dao.Queries.DeleteCommentsForSlug(context.Background(), db.DeleteCommentsForSlugParams{
+		Username: string(username),
+		Slug:     string(slug),
+	}))
+}
+
+// Whether new note content would change the access control set directly on a node.
+func (s *TreeService) DoesContentChangeAccessControl(
+	username core.Username,
+	slug core.NodeSlug,
+	content []byte,
+) (bool, error) {
+	var fm core.FrontMatter
+	if _, err := frontmatter.Parse(bytes.NewReader(content), &fm); err != nil {
+		return false, errors.Join(err, core.ErrParsingContent)
+	}
+	return !reflect.DeepEqual(fm.AccessControl, s.getNodeAccessControl(username, slug)), nil
+}
+
+// Get the access control set directly on a node, nil if node or access control does not exist.
+func (s *TreeService) getNodeAccessControl(username core.Username, slug core.NodeSlug) *core.AccessControl {
+	node, err := s.tc.TryGetNode(username, slug)
+	if err != nil || node.NoteNodeFields == nil {
+		return nil
+	}
+	return node.FrontMatter.AccessControl
+}
+
+// Record a node write and any change to its access control.
+func (s *TreeService) recordNodeWrite(
+	actor core.AuditActor,
+	username core.Username,
+	slug core.NodeSlug,
+	oldAc *core.AccessControl,
+) {
+	s.audit.Record(core.CreateAuditEntry{
+		Event:    core.AuditNodeWrite,
+		Actor:    actor,
+		Username: &username,
+		Slug:     &slug,
+	})
+	s.audit.RecordAccessControlChange(actor, username, slug, oldAc, s.getNodeAccessControl(username, slug))
 }
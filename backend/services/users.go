@@ -2,17 +2,26 @@ package services
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"log/slog"
+	"time"
 
 	"github.com/enchant97/note-mark/backend/core"
 	"github.com/enchant97/note-mark/backend/db"
 	"github.com/enchant97/note-mark/backend/tree"
+	"github.com/enchant97/note-mark/backend/usercache"
+	"github.com/google/uuid"
 )
 
 type UsersService struct {
 	dao                       *db.DAO
 	tc                        *tree.TreeController
+	invites                   *InvitesService
+	userCache                 *usercache.Cache
+	passwordPolicy            core.PasswordPolicy
 	enableInternalSignup      bool
+	inviteOnlySignup          bool
 	enableInternalLogin       bool
 	enableAnonymousUserSearch bool
 }
@@ -20,27 +29,58 @@ type UsersService struct {
 func (s UsersService) New(
 	dao *db.DAO,
 	tc *tree.TreeController,
+	invites *InvitesService,
+	userCache *usercache.Cache,
+	passwordPolicy core.PasswordPolicy,
 	enableInternalSignup bool,
+	inviteOnlySignup bool,
 	enableInternalLogin bool,
 	enableAnonymousUserSearch bool,
 ) UsersService {
 	return UsersService{
 		dao:                       dao,
 		tc:                        tc,
+		invites:                   invites,
+		userCache:                 userCache,
+		passwordPolicy:            passwordPolicy,
 		enableInternalSignup:      enableInternalSignup,
+		inviteOnlySignup:          inviteOnlySignup,
 		enableInternalLogin:       enableInternalLogin,
 		enableAnonymousUserSearch: enableAnonymousUserSearch,
 	}
 }
 
+// Create a user, claiming the invite when one is given.
+//
+// When signup is invite only, a valid invite is required.
 func (s *UsersService) CreateUserWithPassword(
 	toCreate core.CreateUserWithPassword,
 ) (core.User, error) {
 	if !s.enableInternalSignup {
 		return core.User{}, core.ErrFeatureDisabled
 	}
+	if s.inviteOnlySignup && toCreate.InviteCode == nil {
+		return core.User{}, ErrInviteRequired
+	}
+	if err := s.passwordPolicy.Check(toCreate.Username, toCreate.Password); err != nil {
+		return core.User{}, err
+	}
+	tx, err := s.dao.DB.BeginTx(context.Background(), &sql.TxOptions{})
+	if err != nil {
+		return core.User{}, err
+	}
+	q := s.dao.Queries.WithTx(tx)
+	defer tx.Rollback()
+	var invite *db.ClaimInviteRow
+	if toCreate.InviteCode != nil {
+		row, err := s.invites.claimInvite(q, *toCreate.InviteCode)
+		if err != nil {
+			return core.User{}, err
+		}
+		invite = &row
+	}
 	v, err := core.WrapDbErrorWithValue(
-		s.dao.Queries.InsertUserWithPassword(
+		q.InsertUserWithPassword(
 			context.Background(),
 			db.InsertUserWithPasswordParams{
 				Uid:          core.MustNewUID(),
@@ -52,9 +92,23 @@ func (s *UsersService) CreateUserWithPassword(
 	if err != nil {
 		return core.User{}, err
 	}
+	if invite != nil && invite.GroupID.Valid {
+		if err := q.InsertGroupMember(context.Background(), db.InsertGroupMemberParams{
+			GroupID: invite.GroupID.Int64,
+			UserUid: v.Uid,
+		}); err != nil {
+			return core.User{}, core.WrapDbError(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return core.User{}, err
+	}
 	if err := s.tc.RegisterNewUser(core.Username(toCreate.Username)); err != nil && !errors.Is(err, core.ErrConflict) {
 		return core.User{}, err
 	}
+	if invite != nil {
+		s.invites.applyInviteShare(*invite, core.Username(toCreate.Username))
+	}
 	return core.User{
 		ModTime: core.ModTime{
 			CreatedAt: v.CreatedAt,
@@ -83,14 +137,61 @@ func (s *UsersService) GetUserByUsername(username string) (core.User, error) {
 		Uid:      v.Uid,
 		Username: v.Username,
 		Name:     core.NullStringToStringPtr(v.Name),
+		Timezone: core.NullStringToStringPtr(v.Timezone),
 	}, nil
 }
 
+// Get the current username for a renamed user from an old username,
+// returning false when no user was renamed from it.
+func (s *UsersService) GetUsernameRedirect(username core.Username) (core.Username, bool) {
+	// avoid the database for users that exist
+	if s.tc.HasUser(username) {
+		return "", false
+	}
+	newUsername, err := core.WrapDbErrorWithValue(
+		s.dao.Queries.GetUsernameRedirect(context.Background(), string(username)),
+	)
+	if err != nil {
+		if !errors.Is(err, core.ErrNotFound) {
+			slog.Warn("failed to get username redirect", "username", username, "err", err)
+		}
+		return "", false
+	}
+	return core.Username(newUsername), true
+}
+
 func (s *UsersService) UpdateUserByUsername(username string, toUpdate core.UpdateUser) error {
-	return core.WrapDbError(s.dao.Queries.UpdateUser(context.Background(), db.UpdateUserParams{
+	if toUpdate.Timezone != nil {
+		if _, err := core.LoadTimezone(toUpdate.Timezone); err != nil {
+			return err
+		}
+	}
+	tx, err := s.dao.DB.BeginTx(context.Background(), &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	q := s.dao.Queries.WithTx(tx)
+	defer tx.Rollback()
+	if err := q.UpdateUser(context.Background(), db.UpdateUserParams{
 		Username: username,
 		Name:     core.StringPtrToNullString(toUpdate.Name),
-	}))
+	}); err != nil {
+		return core.WrapDbError(err)
+	}
+	// timezone is only changed when given, blank will unset it
+	if toUpdate.Timezone != nil {
+		timezone := toUpdate.Timezone
+		if *timezone == "" {
+			timezone = nil
+		}
+		if err := q.UpdateUserTimezone(context.Background(), db.UpdateUserTimezoneParams{
+			Username: username,
+			Timezone: core.StringPtrToNullString(timezone),
+		}); err != nil {
+			return core.WrapDbError(err)
+		}
+	}
+	return tx.Commit()
 }
 
 func (s *UsersService) UpdateUserPasswordByUsername(
@@ -111,14 +212,42 @@ func (s *UsersService) UpdateUserPasswordByUsername(
 	if ok := core.DoesPasswordMatchHashed(v.ExistingPassword, user.PasswordHash); !ok {
 		return core.ErrInvalidCredentials
 	}
-	return core.WrapDbError(
-		s.dao.Queries.UpdateUserPasswordByUsername(
-			context.Background(),
-			db.UpdateUserPasswordByUsernameParams{
-				Username:     username,
-				PasswordHash: core.HashPassword(v.NewPassword),
-			}),
-	)
+	if err := s.passwordPolicy.Check(username, v.NewPassword); err != nil {
+		return err
+	}
+	if err := s.dao.Queries.UpdateUserPasswordByUsername(
+		context.Background(),
+		db.UpdateUserPasswordByUsernameParams{
+			Username:     username,
+			PasswordHash: core.HashPassword(v.NewPassword),
+		}); err != nil {
+		return core.WrapDbError(err)
+	}
+	// clients holding the old password should have to login again
+	if err := RevokeUserTokens(s.dao, user.Uid); err != nil {
+		return err
+	}
+	s.userCache.Invalidate(user.Uid)
+	return nil
+}
+
+// Revoke a user's refresh tokens and any access tokens issued before now.
+//
+// Running servers only notice revoked access tokens once the user is invalidated from their cache.
+func RevokeUserTokens(dao *db.DAO, userUid uuid.UUID) error {
+	if err := dao.Queries.DeleteUserRefreshTokens(context.Background(), userUid); err != nil {
+		return core.WrapDbError(err)
+	}
+	// access tokens only record the second they were issued in,
+	// so any issued during the rest of this second are also revoked
+	validAfter := time.Now().UTC().Truncate(time.Second).Add(time.Second)
+	return core.WrapDbError(dao.Queries.UpdateUserTokensValidAfter(
+		context.Background(),
+		db.UpdateUserTokensValidAfterParams{
+			TokensValidAfter: sql.NullTime{Time: validAfter, Valid: true},
+			Uid:              userUid,
+		},
+	))
 }
 
 func (s *UsersService) GetUsernameSearch(username string) ([]string, error) {
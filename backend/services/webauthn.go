@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/enchant97/note-mark/backend/config"
+	"github.com/enchant97/note-mark/backend/core"
+	"github.com/enchant97/note-mark/backend/db"
+	"github.com/enchant97/note-mark/backend/passkeys"
+	"github.com/enchant97/note-mark/backend/ratelimit"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrWebAuthnCeremony      = errors.New("webauthn ceremony failed")
+	ErrWebAuthnCloneDetected = errors.New("webauthn authenticator may have been cloned")
+)
+
+const defaultWebAuthnCredentialName = "Passkey"
+
+// Limits for starting ceremonies, every begin counts, not only failed ones.
+var webAuthnBeginLimits = ratelimit.Options{
+	MaxFailures: 30,
+	Lockout:     time.Minute,
+	MaxLockout:  15 * time.Minute,
+	Window:      time.Minute,
+}
+
+type WebAuthnService struct {
+	dao        *db.DAO
+	auth       *AuthService
+	audit      *AuditService
+	ceremonies *passkeys.Ceremonies
+	// limits begins per client IP or user, as each one keeps a session in memory
+	beginLimiter *ratelimit.Limiter
+}
+
+func (s WebAuthnService) New(
+	appConfig config.AppConfig,
+	dao *db.DAO,
+	auth *AuthService,
+	audit *AuditService,
+) WebAuthnService {
+	var ceremonies *passkeys.Ceremonies
+	var beginLimiter *ratelimit.Limiter
+	if appConfig.EnableInternalLogin && appConfig.WebAuthn.Enable {
+		publicUrl, err := url.Parse(appConfig.PublicUrl)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rpId := appConfig.WebAuthn.RPID
+		if rpId == "" {
+			rpId = publicUrl.Hostname()
+		}
+		rpOrigins := appConfig.WebAuthn.RPOrigins
+		if len(rpOrigins) == 0 {
+			rpOrigins = []string{publicUrl.Scheme + "://" + publicUrl.Host}
+		}
+		c, err := passkeys.Ceremonies{}.New(passkeys.Options{
+			RPID:          rpId,
+			RPDisplayName: core.AppName,
+			RPOrigins:     rpOrigins,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		ceremonies = &c
+		limiter, err := ratelimit.Limiter{}.New(webAuthnBeginLimits, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		beginLimiter = &limiter
+	}
+	return WebAuthnService{
+		dao:          dao,
+		auth:         auth,
+		audit:        audit,
+		ceremonies:   ceremonies,
+		beginLimiter: beginLimiter,
+	}
+}
+
+// Start registering a credential for the user.
+//
+// errors with `ratelimit.ErrLocked` when the user has started too many.
+func (s *WebAuthnService) BeginRegistration(authenticatedUser core.AuthenticatedUser) (core.WebAuthnRegistration, error) {
+	if s.ceremonies == nil {
+		return core.WebAuthnRegistration{}, core.ErrFeatureDisabled
+	}
+	if err := s.countBegin("user:" + authenticatedUser.UserUID.String()); err != nil {
+		return core.WebAuthnRegistration{}, err
+	}
+	user, err := s.getPasskeyUser(authenticatedUser.UserUID)
+	if err != nil {
+		return core.WebAuthnRegistration{}, err
+	}
+	sessionId, creation, err := s.ceremonies.BeginRegistration(user)
+	if err != nil {
+		return core.WebAuthnRegistration{}, err
+	}
+	return core.WebAuthnRegistration{
+		SessionId: sessionId,
+		Options:   creation,
+	}, nil
+}
+
+// Finish registering a credential, rawResponse is the JSON encoded `PublicKeyCredential`.
+//
+// errors with `ErrWebAuthnCeremony` when the response could not be verified.
+func (s *WebAuthnService) FinishRegistration(
+	authenticatedUser core.AuthenticatedUser,
+	sessionId string,
+	name string,
+	rawResponse []byte,
+) (core.WebAuthnCredential, error) {
+	if s.ceremonies == nil {
+		return core.WebAuthnCredential{}, core.ErrFeatureDisabled
+	}
+	user, err := s.getPasskeyUser(authenticatedUser.UserUID)
+	if err != nil {
+		return core.WebAuthnCredential{}, err
+	}
+	credential, err := s.ceremonies.FinishRegistration(sessionId, user, rawResponse)
+	if err != nil {
+		return core.WebAuthnCredential{}, errors.Join(ErrWebAuthnCeremony, err)
+	}
+	rawCredential, err := json.Marshal(credential)
+	if err != nil {
+		return core.WebAuthnCredential{}, err
+	}
+	if name == "" {
+		name = defaultWebAuthnCredentialName
+	}
+	if err := s.dao.Queries.InsertWebAuthnCredential(context.Background(), db.InsertWebAuthnCredentialParams{
+		ID:         credential.ID,
+		UserUid:    authenticatedUser.UserUID,
+		Name:       name,
+		Credential: rawCredential,
+	}); err != nil {
+		return core.WebAuthnCredential{}, core.WrapDbError(err)
+	}
+	credentialId := passkeys.EncodeCredentialId(credential.ID)
+	s.audit.Record(core.CreateAuditEntry{
+		Event:   core.AuditWebAuthnAdded,
+		Actor:   core.NewAuditActor(&authenticatedUser, nil),
+		Details: map[string]string{"credentialId": credentialId, "name": name},
+	})
+	credentials, err := s.GetCredentials(authenticatedUser)
+	if err != nil {
+		return core.WebAuthnCredential{}, err
+	}
+	for _, c := range credentials {
+		if c.Id == credentialId {
+			return c, nil
+		}
+	}
+	return core.WebAuthnCredential{}, core.ErrNotFound
+}
+
+func (s *WebAuthnService) GetCredentials(authenticatedUser core.AuthenticatedUser) ([]core.WebAuthnCredential, error) {
+	rows, err := s.dao.Queries.GetWebAuthnCredentialsForUser(context.Background(), authenticatedUser.UserUID)
+	if err != nil {
+		return nil, core.WrapDbError(err)
+	}
+	credentials := make([]core.WebAuthnCredential, len(rows))
+	for i, row := range rows {
+		credentials[i] = core.WebAuthnCredential{
+			Id:         passkeys.EncodeCredentialId(row.ID),
+			Name:       row.Name,
+			CreatedAt:  row.CreatedAt,
+			LastUsedAt: nullTimeToTimePtr(row.LastUsedAt),
+		}
+	}
+	return credentials, nil
+}
+
+func (s *WebAuthnService) DeleteCredential(authenticatedUser core.AuthenticatedUser, credentialId string) error {
+	id, err := passkeys.DecodeCredentialId(credentialId)
+	if err != nil {
+		return core.ErrNotFound
+	}
+	count, err := s.dao.Queries.DeleteWebAuthnCredential(context.Background(), db.DeleteWebAuthnCredentialParams{
+		ID:      id,
+		UserUid: authenticatedUser.UserUID,
+	})
+	if err != nil {
+		return core.WrapDbError(err)
+	}
+	if count == 0 {
+		return core.ErrNotFound
+	}
+	s.audit.Record(core.CreateAuditEntry{
+		Event:   core.AuditWebAuthnRemoved,
+		Actor:   core.NewAuditActor(&authenticatedUser, nil),
+		Details: map[string]string{"credentialId": credentialId},
+	})
+	return nil
+}
+
+// Start a login, any discoverable credential for this site can be used.
+//
+// Credentials are never listed for a username, so accounts with passkeys can't be enumerated,
+// errors with `ratelimit.ErrLocked` when the client IP has started too many.
+func (s *WebAuthnService) BeginLogin(clientIP string) (core.WebAuthnLogin, error) {
+	if s.ceremonies == nil {
+		return core.WebAuthnLogin{}, core.ErrFeatureDisabled
+	}
+	if err := s.countBegin("ip:" + clientIP); err != nil {
+		return core.WebAuthnLogin{}, err
+	}
+	sessionId, assertion, err := s.ceremonies.BeginLogin(nil)
+	if err != nil {
+		return core.WebAuthnLogin{}, err
+	}
+	return core.WebAuthnLogin{
+		SessionId: sessionId,
+		Options:   assertion,
+	}, nil
+}
+
+// Finish a login and create an access token, rawResponse is the JSON encoded `PublicKeyCredential`.
+//
+// errors with `ErrWebAuthnCeremony` when the response could not be verified,
+// also joined with `ErrWebAuthnCloneDetected` when the sign count went backwards.
+func (s *WebAuthnService) FinishLogin(sessionId string, rawResponse []byte, clientIP string) (core.AccessToken, error) {
+	if s.ceremonies == nil {
+		return core.AccessToken{}, core.ErrFeatureDisabled
+	}
+	details := map[string]string{"grantType": "webauthn", "clientIp": clientIP}
+	user, credential, err := s.ceremonies.FinishLogin(sessionId, rawResponse, s.getPasskeyUser)
+	if err != nil {
+		s.audit.Record(core.CreateAuditEntry{Event: core.AuditLoginFailed, Details: details})
+		return core.AccessToken{}, errors.Join(ErrWebAuthnCeremony, err)
+	}
+	details["username"] = user.Username
+	details["credentialId"] = passkeys.EncodeCredentialId(credential.ID)
+	if credential.Authenticator.CloneWarning {
+		slog.Warn("rejected passkey login, authenticator may have been cloned", "username", user.Username)
+		details["reason"] = "clone_warning"
+		s.audit.Record(core.CreateAuditEntry{Event: core.AuditLoginFailed, Details: details})
+		return core.AccessToken{}, errors.Join(ErrWebAuthnCeremony, ErrWebAuthnCloneDetected)
+	}
+	// keep the sign count, so cloned authenticators can be detected on a later login
+	rawCredential, err := json.Marshal(credential)
+	if err != nil {
+		return core.AccessToken{}, err
+	}
+	if err := s.dao.Queries.UpdateWebAuthnCredentialUsage(context.Background(), db.UpdateWebAuthnCredentialUsageParams{
+		Credential: rawCredential,
+		ID:         credential.ID,
+		UserUid:    user.Uid,
+	}); err != nil {
+		return core.AccessToken{}, core.WrapDbError(err)
+	}
+	return s.auth.CreateAccessTokenForUser(user.Uid, details)
+}
+
+// Count a ceremony being started, erroring with `ratelimit.ErrLocked` when there have been too many.
+func (s *WebAuthnService) countBegin(key string) error {
+	if err := s.beginLimiter.Check(key); err != nil {
+		return err
+	}
+	s.beginLimiter.Fail(key)
+	return nil
+}
+
+// Get a user with their credentials, disabled and deleted users are not found.
+func (s *WebAuthnService) getPasskeyUser(userUid uuid.UUID) (passkeys.User, error) {
+	user, err := core.WrapDbErrorWithValue(s.dao.Queries.GetUserByUid(context.Background(), userUid))
+	if err != nil {
+		return passkeys.User{}, err
+	}
+	rows, err := s.dao.Queries.GetWebAuthnCredentialsForUser(context.Background(), userUid)
+	if err != nil {
+		return passkeys.User{}, core.WrapDbError(err)
+	}
+	credentials := make([]webauthn.Credential, len(rows))
+	for i, row := range rows {
+		if err := json.Unmarshal(row.Credential, &credentials[i]); err != nil {
+			return passkeys.User{}, err
+		}
+	}
+	return passkeys.User{
+		Uid:         user.Uid,
+		Username:    user.Username,
+		DisplayName: user.Name.String,
+		Credentials: credentials,
+	}, nil
+}
@@ -0,0 +1,354 @@
+package signingkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrNoKeys = errors.New("no signing keys or secret available")
+var ErrUnknownAlgorithm = errors.New("unknown signing key algorithm")
+var ErrUnknownKey = errors.New("unknown signing key id")
+
+const (
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmRS256 = "RS256"
+	// only used with a shared secret, never listed in the JWKS
+	AlgorithmHS256 = "HS256"
+)
+
+const (
+	pemType          = "PRIVATE KEY"
+	pemAlgorithm     = "Algorithm"
+	pemCreatedAt     = "Created-At"
+	pemRetiredAt     = "Retired-At"
+	rsaKeySize       = 2048
+	keyFileExtension = ".pem"
+)
+
+// A private key for signing tokens, identified by its id.
+type Key struct {
+	ID        string
+	Algorithm string
+	CreatedAt time.Time
+	// set once a newer key has replaced this one
+	RetiredAt *time.Time
+	signer    crypto.Signer
+}
+
+// Generate a new key using one of the supported asymmetric algorithms.
+func Generate(algorithm string, now time.Time) (Key, error) {
+	var signer crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgorithmEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	case AlgorithmRS256:
+		signer, err = rsa.GenerateKey(rand.Reader, rsaKeySize)
+	default:
+		return Key{}, fmt.Errorf("%w '%s'", ErrUnknownAlgorithm, algorithm)
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{
+		ID:        strings.ToLower(rand.Text()),
+		Algorithm: algorithm,
+		CreatedAt: now.UTC().Truncate(time.Second),
+		signer:    signer,
+	}, nil
+}
+
+// Whether every token signed by the key has expired,
+// meaning it is no longer needed for verification.
+func (k Key) IsExpired(maxTokenAge time.Duration, now time.Time) bool {
+	return k.RetiredAt != nil && k.RetiredAt.Add(maxTokenAge).Before(now)
+}
+
+func (k Key) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// A JSON Web Key, following: RFC7517 & RFC8037
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// Get the public part of the key.
+func (k Key) JSONWebKey() JSONWebKey {
+	jwk := JSONWebKey{
+		KeyID:     k.ID,
+		Algorithm: k.Algorithm,
+		Use:       "sig",
+	}
+	switch public := k.signer.Public().(type) {
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	}
+	return jwk
+}
+
+// Stores keys as PEM files in a directory, one file per key.
+type Store struct {
+	dirPath string
+}
+
+func (s Store) New(dirPath string) Store {
+	return Store{dirPath: dirPath}
+}
+
+// Load all keys, newest first.
+func (s *Store) Load() ([]Key, error) {
+	entries, err := os.ReadDir(s.dirPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Key{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	keys := make([]Key, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != keyFileExtension {
+			continue
+		}
+		key, err := s.load(entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("cannot load signing key '%s': %w", entry.Name(), err)
+		}
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b Key) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		// keys created within the same second, the retired one is older
+		if a.RetiredAt == nil && b.RetiredAt != nil {
+			return -1
+		} else if a.RetiredAt != nil && b.RetiredAt == nil {
+			return 1
+		}
+		return 0
+	})
+	return keys, nil
+}
+
+func (s *Store) load(fileName string) (Key, error) {
+	raw, err := os.ReadFile(filepath.Join(s.dirPath, fileName))
+	if err != nil {
+		return Key{}, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil || block.Type != pemType {
+		return Key{}, errors.New("not a PEM encoded private key")
+	}
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return Key{}, err
+	}
+	key := Key{
+		ID:        strings.TrimSuffix(fileName, keyFileExtension),
+		Algorithm: block.Headers[pemAlgorithm],
+	}
+	switch private := private.(type) {
+	case ed25519.PrivateKey:
+		key.signer = private
+		if key.Algorithm != AlgorithmEdDSA {
+			return Key{}, ErrUnknownAlgorithm
+		}
+	case *rsa.PrivateKey:
+		key.signer = private
+		if key.Algorithm != AlgorithmRS256 {
+			return Key{}, ErrUnknownAlgorithm
+		}
+	default:
+		return Key{}, ErrUnknownAlgorithm
+	}
+	if key.CreatedAt, err = time.Parse(time.RFC3339, block.Headers[pemCreatedAt]); err != nil {
+		return Key{}, err
+	}
+	if retiredAt, exists := block.Headers[pemRetiredAt]; exists {
+		t, err := time.Parse(time.RFC3339, retiredAt)
+		if err != nil {
+			return Key{}, err
+		}
+		key.RetiredAt = &t
+	}
+	return key, nil
+}
+
+// Write a key, replacing any existing one with the same id.
+func (s *Store) Save(key Key) error {
+	if err := os.MkdirAll(s.dirPath, 0o700); err != nil {
+		return err
+	}
+	raw, err := x509.MarshalPKCS8PrivateKey(key.signer)
+	if err != nil {
+		return err
+	}
+	block := pem.Block{
+		Type: pemType,
+		Headers: map[string]string{
+			pemAlgorithm: key.Algorithm,
+			pemCreatedAt: key.CreatedAt.Format(time.RFC3339),
+		},
+		Bytes: raw,
+	}
+	if key.RetiredAt != nil {
+		block.Headers[pemRetiredAt] = key.RetiredAt.Format(time.RFC3339)
+	}
+	// written to a temporary file first, so a key is never partially written
+	filePath := filepath.Join(s.dirPath, key.ID+keyFileExtension)
+	if err := os.WriteFile(filePath+".tmp", pem.EncodeToMemory(&block), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(filePath+".tmp", filePath)
+}
+
+func (s *Store) Remove(keyID string) error {
+	err := os.Remove(filepath.Join(s.dirPath, keyID+keyFileExtension))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w '%s'", ErrUnknownKey, keyID)
+	}
+	return err
+}
+
+// Generate a new key to sign with, retiring the existing keys
+// and removing those that have expired.
+//
+// Returns the new key and the removed keys.
+func (s *Store) Rotate(algorithm string, maxTokenAge time.Duration, now time.Time) (Key, []Key, error) {
+	keys, err := s.Load()
+	if err != nil {
+		return Key{}, nil, err
+	}
+	newKey, err := Generate(algorithm, now)
+	if err != nil {
+		return Key{}, nil, err
+	}
+	if err := s.Save(newKey); err != nil {
+		return Key{}, nil, err
+	}
+	removed := []Key{}
+	retiredAt := newKey.CreatedAt
+	for _, key := range keys {
+		if key.IsExpired(maxTokenAge, now) {
+			if err := s.Remove(key.ID); err != nil {
+				return Key{}, nil, err
+			}
+			removed = append(removed, key)
+		} else if key.RetiredAt == nil {
+			key.RetiredAt = &retiredAt
+			if err := s.Save(key); err != nil {
+				return Key{}, nil, err
+			}
+		}
+	}
+	return newKey, removed, nil
+}
+
+// Signs tokens with the newest key, verifying with any of the keys.
+//
+// A shared secret can be given for HS256 tokens,
+// used for signing only when there are no keys.
+type Keyring struct {
+	keys   []Key
+	secret []byte
+}
+
+// Create a keyring from keys ordered newest first,
+// errors with `ErrNoKeys` when there are no keys or secret.
+func (k Keyring) New(keys []Key, secret []byte) (Keyring, error) {
+	if len(keys) == 0 && len(secret) == 0 {
+		return Keyring{}, ErrNoKeys
+	}
+	return Keyring{
+		keys:   keys,
+		secret: secret,
+	}, nil
+}
+
+// Sign claims, setting the key id header.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	if len(k.keys) == 0 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.secret)
+	}
+	key := k.keys[0]
+	token := jwt.NewWithClaims(key.signingMethod(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signer)
+}
+
+// Parse and verify a token signed by any of the keys.
+func (k *Keyring) Parse(tokenString string, claims jwt.Claims, options ...jwt.ParserOption) (*jwt.Token, error) {
+	options = append(options, jwt.WithValidMethods(k.algorithms()))
+	return jwt.ParseWithClaims(tokenString, claims, k.verificationKey, options...)
+}
+
+func (k *Keyring) verificationKey(token *jwt.Token) (any, error) {
+	if token.Method.Alg() == AlgorithmHS256 {
+		return k.secret, nil
+	}
+	keyID, _ := token.Header["kid"].(string)
+	for _, key := range k.keys {
+		// algorithm must match, so a public key can't be used as a HMAC secret
+		if key.ID == keyID && key.Algorithm == token.Method.Alg() {
+			return key.signer.Public(), nil
+		}
+	}
+	return nil, fmt.Errorf("%w '%s'", ErrUnknownKey, keyID)
+}
+
+func (k *Keyring) algorithms() []string {
+	algorithms := []string{}
+	if len(k.secret) != 0 {
+		algorithms = append(algorithms, AlgorithmHS256)
+	}
+	for _, key := range k.keys {
+		if !slices.Contains(algorithms, key.Algorithm) {
+			algorithms = append(algorithms, key.Algorithm)
+		}
+	}
+	return algorithms
+}
+
+// Get the public keys, for other services to verify tokens with.
+func (k *Keyring) JSONWebKeySet() JSONWebKeySet {
+	keySet := JSONWebKeySet{Keys: make([]JSONWebKey, len(k.keys))}
+	for i, key := range k.keys {
+		keySet.Keys[i] = key.JSONWebKey()
+	}
+	return keySet
+}
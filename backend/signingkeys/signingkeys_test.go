@@ -0,0 +1,158 @@
+package signingkeys
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func makeTestClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "test",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+}
+
+func getTestKeyIDs(keys []Key) []string {
+	ids := make([]string, len(keys))
+	for i, key := range keys {
+		ids[i] = key.ID
+	}
+	return ids
+}
+
+func TestStoreRotate(t *testing.T) {
+	store := Store{}.New(t.TempDir())
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	maxTokenAge := time.Hour
+
+	first, removed, err := store.Rotate(AlgorithmEdDSA, maxTokenAge, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("actual '%v' expect '%v'", len(removed), 0)
+	}
+	second, _, err := store.Rotate(AlgorithmRS256, maxTokenAge, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, expect := getTestKeyIDs(keys), []string{second.ID, first.ID}; !slices.Equal(actual, expect) {
+		t.Errorf("actual '%v' expect '%v'", actual, expect)
+	}
+	if keys[0].RetiredAt != nil || keys[1].RetiredAt == nil || !keys[1].RetiredAt.Equal(second.CreatedAt) {
+		t.Errorf("actual '%v' expect retired at '%v'", keys[1].RetiredAt, second.CreatedAt)
+	}
+	if keys[0].Algorithm != AlgorithmRS256 || keys[1].Algorithm != AlgorithmEdDSA {
+		t.Errorf("actual '%v' '%v' expect '%v' '%v'", keys[0].Algorithm, keys[1].Algorithm, AlgorithmRS256, AlgorithmEdDSA)
+	}
+
+	// tokens signed by the first key may still be valid
+	third, removed, err := store.Rotate(AlgorithmEdDSA, maxTokenAge, now.Add(maxTokenAge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("actual '%v' expect '%v'", len(removed), 0)
+	}
+	// every token signed by the first key has now expired
+	_, removed, err = store.Rotate(AlgorithmEdDSA, maxTokenAge, now.Add(2*maxTokenAge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, expect := getTestKeyIDs(removed), []string{first.ID}; !slices.Equal(actual, expect) {
+		t.Errorf("actual '%v' expect '%v'", actual, expect)
+	}
+	keys, _ = store.Load()
+	if len(keys) != 3 || keys[2].ID != second.ID || keys[1].ID != third.ID {
+		t.Errorf("actual '%v' expect 3 keys", getTestKeyIDs(keys))
+	}
+
+	if _, _, err := store.Rotate("HS256", maxTokenAge, now); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("actual '%v' expect '%v'", err, ErrUnknownAlgorithm)
+	}
+	if err := store.Remove("unknown"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("actual '%v' expect '%v'", err, ErrUnknownKey)
+	}
+}
+
+func TestKeyringSignAndParse(t *testing.T) {
+	now := time.Now()
+	oldKey, _ := Generate(AlgorithmRS256, now.Add(-time.Hour))
+	newKey, _ := Generate(AlgorithmEdDSA, now)
+	secret := []byte("a secret that is at least 32 bytes long")
+
+	legacy, _ := Keyring{}.New(nil, secret)
+	old, _ := Keyring{}.New([]Key{oldKey}, nil)
+	rotated, _ := Keyring{}.New([]Key{newKey, oldKey}, secret)
+	other, _ := Keyring{}.New([]Key{newKey}, nil)
+
+	tests := []struct {
+		signer *Keyring
+		parser *Keyring
+		valid  bool
+	}{
+		{&legacy, &legacy, true},
+		{&legacy, &rotated, true},
+		{&old, &rotated, true},
+		{&rotated, &rotated, true},
+		{&rotated, &other, true},
+		{&rotated, &legacy, false},
+		{&old, &other, false},
+		{&legacy, &other, false},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			tokenString, err := tt.signer.Sign(makeTestClaims())
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = tt.parser.Parse(tokenString, &jwt.RegisteredClaims{})
+			if (err == nil) != tt.valid {
+				t.Errorf("actual '%v' expect valid '%v'", err, tt.valid)
+			}
+		})
+	}
+
+	if _, err := (Keyring{}).New(nil, nil); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("actual '%v' expect '%v'", err, ErrNoKeys)
+	}
+}
+
+func TestKeyringRejectsAlgorithmConfusion(t *testing.T) {
+	key, _ := Generate(AlgorithmRS256, time.Now())
+	keyring, _ := Keyring{}.New([]Key{key}, nil)
+	// HMAC signed using the public key, which anyone can fetch from the JWKS
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, makeTestClaims())
+	token.Header["kid"] = key.ID
+	tokenString, err := token.SignedString([]byte(key.JSONWebKey().N))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keyring.Parse(tokenString, &jwt.RegisteredClaims{}); err == nil {
+		t.Errorf("actual '%v' expect error", err)
+	}
+}
+
+func TestKeyringJSONWebKeySet(t *testing.T) {
+	edKey, _ := Generate(AlgorithmEdDSA, time.Now())
+	rsaKey, _ := Generate(AlgorithmRS256, time.Now())
+	keyring, _ := Keyring{}.New([]Key{edKey, rsaKey}, []byte("a secret that is at least 32 bytes long"))
+	keySet := keyring.JSONWebKeySet()
+	if len(keySet.Keys) != 2 {
+		t.Fatalf("actual '%v' expect '%v'", len(keySet.Keys), 2)
+	}
+	if jwk := keySet.Keys[0]; jwk.KeyID != edKey.ID || jwk.KeyType != "OKP" || jwk.Curve != "Ed25519" || len(jwk.X) == 0 {
+		t.Errorf("actual '%v' expect Ed25519 key '%v'", jwk, edKey.ID)
+	}
+	if jwk := keySet.Keys[1]; jwk.KeyID != rsaKey.ID || jwk.KeyType != "RSA" || jwk.E != "AQAB" || len(jwk.N) == 0 {
+		t.Errorf("actual '%v' expect RSA key '%v'", jwk, rsaKey.ID)
+	}
+}
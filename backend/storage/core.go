@@ -21,6 +21,10 @@ type StorageController interface {
 	RenameAssetNode(username core.Username, slug string, newSlug string) error
 	DeleteAssetNode(username core.Username, slug string) error
 	DeleteUser(username core.Username) error
+	// Move all of a user's nodes to a new username, errors if the new username has nodes.
+	RenameUser(username core.Username, newUsername core.Username) error
+	// Get the bytes used on storage by a user, node counts are left for the caller to fill.
+	GetUsageForUser(username core.Username) (core.StorageUsage, error)
 	// Discover all nodes for a given username. Will skip over invalid names.
 	DiscoverNodesForUser(username core.Username, fn DiscoverNodesFunc) error
 	// Discover all usernames. Will skip over invalid names.
@@ -308,6 +308,50 @@ func (sc *DiskStorageController) DeleteUser(username core.Username) error {
 	return nil
 }
 
+func (sc *DiskStorageController) RenameUser(username core.Username, newUsername core.Username) error {
+	absPath := filepath.Join(sc.rootPath, string(username))
+	newAbsPath := filepath.Join(sc.rootPath, string(newUsername))
+	if _, err := os.Stat(newAbsPath); err == nil {
+		return errors.Join(core.ErrConflict)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(absPath, newAbsPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return errors.Join(core.ErrNotFound)
+		}
+		return err
+	}
+	return nil
+}
+
+func (sc *DiskStorageController) GetUsageForUser(username core.Username) (core.StorageUsage, error) {
+	usage := core.StorageUsage{Username: username}
+	userPath := filepath.Join(sc.rootPath, string(username))
+	trashPath := filepath.Join(userPath, ".trash")
+	err := filepath.WalkDir(userPath, func(absPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		usage.Bytes += info.Size()
+		if strings.HasPrefix(absPath, trashPath+string(filepath.Separator)) {
+			usage.TrashBytes += info.Size()
+		}
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return usage, nil
+	}
+	return usage, err
+}
+
 func (sc *DiskStorageController) DiscoverNodesForUser(
 	username core.Username,
 	fn DiscoverNodesFunc,
@@ -1,33 +1,156 @@
 package tree
 
 import (
+	"slices"
 	"strings"
 
 	"github.com/enchant97/note-mark/backend/core"
 )
 
 // Filter a node tree by using the AccessControl data.
-func FilteredNodeTree(tree core.NodeTree, username *core.Username) core.NodeTree {
+//
+// Nodes the accessor cannot read are removed,
+// unless they have a readable descendant, in which case a placeholder is kept instead.
+// The trash is always removed, as only the owner can see it.
+// Note nodes are copied, the original tree is never modified.
+func FilteredNodeTree(tree core.NodeTree, accessor *core.Accessor) core.NodeTree {
+	return filteredNodeTree(tree, "", newAccessControl(), func(_ core.NodeSlug, ac core.AccessControl) bool {
+		return canReadWithAccessControl(ac, accessor)
+	})
+}
+
+// Same as FilteredNodeTree, but also including nodes readable through a share link.
+func FilteredNodeTreeWithShare(
+	tree core.NodeTree,
+	accessor *core.Accessor,
+	username core.Username,
+	shareGrant core.ShareGrant,
+) core.NodeTree {
+	return filteredNodeTree(tree, "", newAccessControl(), func(fullSlug core.NodeSlug, ac core.AccessControl) bool {
+		return canReadWithAccessControl(ac, accessor) || shareGrant.ModeForNode(username, fullSlug) != ""
+	})
+}
+
+func filteredNodeTree(
+	tree core.NodeTree,
+	parentSlug core.NodeSlug,
+	parentAc core.AccessControl,
+	canRead func(fullSlug core.NodeSlug, ac core.AccessControl) bool,
+) core.NodeTree {
 	filteredMap := core.NodeTree{}
 	for slug, node := range tree {
-		if filteredNode := FilteredNode(*node, username); filteredNode != nil {
-			filteredMap[slug] = node
+		if parentSlug == "" && slug == ".trash" {
+			continue
+		}
+		fullSlug := slug
+		if parentSlug != "" {
+			fullSlug = parentSlug + "/" + slug
+		}
+		ac := withNodeAccessControl(parentAc, nodeAccessControl(node))
+		isReadable := canRead(fullSlug, ac)
+		if node.NoteNodeFields == nil {
+			if isReadable {
+				filteredMap[slug] = node
+			}
+			continue
+		}
+		children := filteredNodeTree(node.Children, fullSlug, ac, canRead)
+		if isReadable {
+			filteredNode := *node
+			filteredNode.NoteNodeFields = &core.NoteNodeFields{
+				FrontMatter: node.FrontMatter,
+				Children:    children,
+			}
+			filteredMap[slug] = &filteredNode
+		} else if len(children) != 0 {
+			// hide everything about the node, apart from it existing
+			filteredMap[slug] = &core.Node{
+				Slug:        node.Slug,
+				Type:        node.Type,
+				ModTime:     node.ModTime,
+				Placeholder: true,
+				NoteNodeFields: &core.NoteNodeFields{
+					FrontMatter: core.FrontMatter{},
+					Children:    children,
+				},
+			}
 		}
 	}
 	return filteredMap
 }
 
-func FilteredNode(node core.Node, username *core.Username) *core.Node {
-	if node.FrontMatter.AccessControl != nil {
-		if node.FrontMatter.AccessControl.PublicRead {
-			return &node
-		} else if username != nil {
-			if _, exists := node.FrontMatter.AccessControl.Users[*username]; exists {
-				return &node
+// Count note and asset nodes in a tree, skipping the trash.
+func countNodes(tree core.NodeTree) (notes int, assets int) {
+	for slug, node := range tree {
+		if slug == ".trash" {
+			continue
+		}
+		if node.Type == core.AssetNode {
+			assets++
+			continue
+		}
+		notes++
+		childNotes, childAssets := countNodes(node.Children)
+		notes += childNotes
+		assets += childAssets
+	}
+	return notes, assets
+}
+
+// Check whether the accessor can read, denied accessors can't even when the node is public.
+func canReadWithAccessControl(ac core.AccessControl, accessor *core.Accessor) bool {
+	if accessor != nil && isAccessorDenied(ac, *accessor) {
+		return false
+	} else if ac.PublicRead {
+		return true
+	} else if accessor != nil {
+		return GetAccessControlModeForAccessor(ac, *accessor) != ""
+	}
+	return false
+}
+
+// Check whether the accessor, or a group they are a member of, has been denied.
+func isAccessorDenied(ac core.AccessControl, accessor core.Accessor) bool {
+	if ac.Deny == nil {
+		return false
+	}
+	if slices.Contains(ac.Deny.Users, accessor.Username) {
+		return true
+	}
+	for _, groupName := range accessor.Groups {
+		if slices.Contains(ac.Deny.Groups, groupName) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the most permissive mode given to an accessor,
+// either directly or through a group they are a member of.
+// Denied accessors are never given a mode.
+//
+// Will return a blank mode when no access has been given.
+func GetAccessControlModeForAccessor(
+	ac core.AccessControl,
+	accessor core.Accessor,
+) core.AccessControlMode {
+	if isAccessorDenied(ac, accessor) {
+		return ""
+	}
+	var acMode core.AccessControlMode = ""
+	if mode, exists := ac.Users[accessor.Username]; exists {
+		acMode = mode
+	}
+	for _, groupName := range accessor.Groups {
+		if mode, exists := ac.Groups[groupName]; exists {
+			if acMode == "" {
+				acMode = mode
+			} else {
+				acMode = selectMostPermissiveAcMode(acMode, mode)
 			}
 		}
 	}
-	return nil
+	return acMode
 }
 
 // Convert the named access control mode into a number.
@@ -37,12 +160,22 @@ func FilteredNode(node core.Node, username *core.Username) *core.Node {
 func accessControlModeToLevelNumber(mode core.AccessControlMode) uint {
 	switch mode {
 	case core.AccessControlWriteMode:
+		return 2
+	case core.AccessControlCommentMode:
 		return 1
 	default:
 		return 0
 	}
 }
 
+// Check whether a mode permits everything the required mode does.
+func HasAccessControlMode(mode core.AccessControlMode, required core.AccessControlMode) bool {
+	if mode == "" {
+		return false
+	}
+	return accessControlModeToLevelNumber(mode) >= accessControlModeToLevelNumber(required)
+}
+
 func selectMostPermissiveAcMode(
 	mode1 core.AccessControlMode,
 	mode2 core.AccessControlMode,
@@ -55,7 +188,8 @@ func selectMostPermissiveAcMode(
 	return mode2
 }
 
-// Updates base access control in-place with the most permissive rules.
+// Updates base access control in-place with the most permissive rules,
+// keeping all denied users and groups.
 func updateMostPermissivePermissions(acBase *core.AccessControl, newAc core.AccessControl) {
 	if newAc.PublicRead {
 		acBase.PublicRead = true
@@ -68,12 +202,112 @@ func updateMostPermissivePermissions(acBase *core.AccessControl, newAc core.Acce
 			acBase.Users[username] = perm
 		}
 	}
+	for groupName, perm := range newAc.Groups {
+		existingPerm, exists := acBase.Groups[groupName]
+		if exists {
+			acBase.Groups[groupName] = selectMostPermissiveAcMode(perm, existingPerm)
+		} else {
+			acBase.Groups[groupName] = perm
+		}
+	}
+	if newAc.Deny != nil {
+		for _, username := range newAc.Deny.Users {
+			if !slices.Contains(acBase.Deny.Users, username) {
+				acBase.Deny.Users = append(acBase.Deny.Users, username)
+			}
+		}
+		for _, groupName := range newAc.Deny.Groups {
+			if !slices.Contains(acBase.Deny.Groups, groupName) {
+				acBase.Deny.Groups = append(acBase.Deny.Groups, groupName)
+			}
+		}
+	}
+}
+
+// Get a copy of access control with a user's entries moved to their new username,
+// also returning whether any entries were found.
+//
+// When both usernames are given access, the most permissive is kept.
+func renameUserInAccessControl(
+	ac core.AccessControl,
+	username core.Username,
+	newUsername core.Username,
+) (core.AccessControl, bool) {
+	renamed := false
+	if perm, exists := ac.Users[username]; exists {
+		users := make(map[core.Username]core.AccessControlMode, len(ac.Users))
+		for username, perm := range ac.Users {
+			users[username] = perm
+		}
+		delete(users, username)
+		if existingPerm, exists := users[newUsername]; exists {
+			perm = selectMostPermissiveAcMode(perm, existingPerm)
+		}
+		users[newUsername] = perm
+		ac.Users = users
+		renamed = true
+	}
+	if ac.Deny != nil && slices.Contains(ac.Deny.Users, username) {
+		deny := *ac.Deny
+		deny.Users = []core.Username{}
+		for _, deniedUsername := range ac.Deny.Users {
+			if deniedUsername == username {
+				deniedUsername = newUsername
+			}
+			if !slices.Contains(deny.Users, deniedUsername) {
+				deny.Users = append(deny.Users, deniedUsername)
+			}
+		}
+		ac.Deny = &deny
+		renamed = true
+	}
+	return ac, renamed
+}
+
+func newAccessControl() core.AccessControl {
+	return core.AccessControl{
+		PublicRead: false,
+		Users:      make(map[core.Username]core.AccessControlMode),
+		Groups:     make(map[core.GroupName]core.AccessControlMode),
+		Deny:       &core.AccessControlDeny{},
+	}
+}
+
+// Get the access control set on a node, asset nodes will never have one.
+func nodeAccessControl(node *core.Node) *core.AccessControl {
+	if node.NoteNodeFields == nil {
+		return nil
+	}
+	return node.FrontMatter.AccessControl
+}
+
+// Apply the access control of a node onto the permissions from its parents,
+// returning them as a new access control.
+//
+// When the node does not inherit, permissions from its parents are discarded.
+func withNodeAccessControl(
+	parentAc core.AccessControl,
+	nodeAc *core.AccessControl,
+) core.AccessControl {
+	ac := newAccessControl()
+	if nodeAc == nil || nodeAc.Inherits() {
+		updateMostPermissivePermissions(&ac, parentAc)
+	}
+	if nodeAc != nil {
+		updateMostPermissivePermissions(&ac, *nodeAc)
+	}
+	return ac
 }
 
 // Get the complete access control permissions for given node.
 //
-// Will search from top-level down to the given node,
-// selecting the most permissive permissions available.
+// Will search from top-level down to the given node (including itself),
+// following these rules in order of precedence:
+//
+//   - denied users and groups never have access, this applies to all descendants
+//   - a node that does not inherit discards all permissions from its parents,
+//     including denied users and groups
+//   - the most permissive mode is selected when one is given multiple times
 //
 // Enable `useParentFallback` when node may not exist (like when creating a note).
 func GetNodeAccessControl(
@@ -81,39 +315,24 @@ func GetNodeAccessControl(
 	fullSlug core.NodeSlug,
 	useParentFallback bool,
 ) (core.AccessControl, error) {
-	ac := core.AccessControl{
-		PublicRead: false,
-		Users:      make(map[core.Username]core.AccessControlMode),
-	}
+	ac := newAccessControl()
 	slugParts := strings.Split(string(fullSlug), "/")
-	var currentNode *core.Node
-	// handle top-level
-	if node, exists := tree[core.NodeSlug(slugParts[0])]; exists {
-		if node.FrontMatter.AccessControl != nil {
-			updateMostPermissivePermissions(&ac, *node.FrontMatter.AccessControl)
-		}
-		currentNode = node
-	} else {
-		// never use `useParentFallback`, top node has no parent
-		return core.AccessControl{}, core.ErrNotFound
-	}
-	// handle further nodes
-	for _, slugPart := range slugParts[1:] {
-		slugPart := core.NodeSlug(slugPart)
-		if _, exists := currentNode.Children[slugPart]; exists {
-			if currentNode.FrontMatter.AccessControl != nil {
-				updateMostPermissivePermissions(&ac, *currentNode.FrontMatter.AccessControl)
-			}
-			currentNode = currentNode.Children[slugPart]
-		} else {
-			if useParentFallback {
+	currentTree := tree
+	for i, slugPart := range slugParts {
+		node, exists := currentTree[core.NodeSlug(slugPart)]
+		if !exists {
+			// never use `useParentFallback` for top-level, it has no parent
+			if useParentFallback && i != 0 {
 				return ac, nil
 			}
 			return core.AccessControl{}, core.ErrNotFound
 		}
-	}
-	if currentNode == nil && !useParentFallback {
-		return core.AccessControl{}, core.ErrNotFound
+		ac = withNodeAccessControl(ac, nodeAccessControl(node))
+		if node.NoteNodeFields == nil {
+			currentTree = core.NodeTree{}
+		} else {
+			currentTree = node.Children
+		}
 	}
 	return ac, nil
 }
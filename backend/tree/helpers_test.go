@@ -0,0 +1,493 @@
+package tree
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/enchant97/note-mark/backend/core"
+)
+
+func TestGetAccessControlModeForAccessor(t *testing.T) {
+	ac := core.AccessControl{
+		Users: map[core.Username]core.AccessControlMode{
+			"leo": core.AccessControlReadMode,
+		},
+		Groups: map[core.GroupName]core.AccessControlMode{
+			"engineering": core.AccessControlWriteMode,
+			"sales":       core.AccessControlReadMode,
+		},
+	}
+	tests := []struct {
+		accessor core.Accessor
+		expect   core.AccessControlMode
+	}{
+		{core.Accessor{Username: "leo"}, core.AccessControlReadMode},
+		{core.Accessor{Username: "leo", Groups: []core.GroupName{"engineering"}}, core.AccessControlWriteMode},
+		{core.Accessor{Username: "steve", Groups: []core.GroupName{"sales"}}, core.AccessControlReadMode},
+		{core.Accessor{Username: "steve", Groups: []core.GroupName{"sales", "engineering"}}, core.AccessControlWriteMode},
+		{core.Accessor{Username: "steve", Groups: []core.GroupName{"marketing"}}, ""},
+		{core.Accessor{Username: "steve"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := GetAccessControlModeForAccessor(ac, tt.accessor)
+			if actual != tt.expect {
+				t.Errorf("actual '%v' expect '%v'", actual, tt.expect)
+			}
+		})
+	}
+}
+
+func TestGetNodeAccessControlMergesGroups(t *testing.T) {
+	nodeTree := core.NodeTree{
+		"team": &core.Node{
+			Slug: "team",
+			Type: core.NoteNode,
+			NoteNodeFields: &core.NoteNodeFields{
+				FrontMatter: core.FrontMatter{AccessControl: &core.AccessControl{
+					Groups: map[core.GroupName]core.AccessControlMode{"engineering": core.AccessControlReadMode},
+				}},
+				Children: core.NodeTree{
+					"plans": &core.Node{
+						Slug: "plans",
+						Type: core.NoteNode,
+						NoteNodeFields: &core.NoteNodeFields{
+							FrontMatter: core.FrontMatter{},
+							Children:    core.NodeTree{},
+						},
+					},
+				},
+			},
+		},
+	}
+	ac, err := GetNodeAccessControl(nodeTree, "team/plans", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := GetAccessControlModeForAccessor(ac, core.Accessor{
+		Username: "leo",
+		Groups:   []core.GroupName{"engineering"},
+	})
+	if actual != core.AccessControlReadMode {
+		t.Errorf("actual '%v' expect '%v'", actual, core.AccessControlReadMode)
+	}
+	filtered := FilteredNodeTree(nodeTree, &core.Accessor{Username: "leo", Groups: []core.GroupName{"engineering"}})
+	if _, exists := filtered["team"]; !exists {
+		t.Errorf("actual '%v' expect '%v'", exists, true)
+	}
+	filtered = FilteredNodeTree(nodeTree, &core.Accessor{Username: "leo"})
+	if _, exists := filtered["team"]; exists {
+		t.Errorf("actual '%v' expect '%v'", exists, false)
+	}
+}
+
+func makeTestNoteNode(slug core.NodeSlug, ac *core.AccessControl, children ...*core.Node) *core.Node {
+	node := core.Node{
+		Slug: slug,
+		Type: core.NoteNode,
+		NoteNodeFields: &core.NoteNodeFields{
+			FrontMatter: core.FrontMatter{AccessControl: ac},
+			Children:    core.NodeTree{},
+		},
+	}
+	for _, child := range children {
+		node.Children[child.Slug] = child
+	}
+	return &node
+}
+
+func makeTestPrecedenceTree() core.NodeTree {
+	noInherit := false
+	return core.NodeTree{
+		"docs": makeTestNoteNode("docs", &core.AccessControl{
+			PublicRead: true,
+			Users:      map[core.Username]core.AccessControlMode{"leo": core.AccessControlWriteMode},
+			Groups:     map[core.GroupName]core.AccessControlMode{"engineering": core.AccessControlReadMode},
+		},
+			makeTestNoteNode("guides", nil),
+			makeTestNoteNode("private", &core.AccessControl{
+				Inherit: &noInherit,
+				Users:   map[core.Username]core.AccessControlMode{"steve": core.AccessControlReadMode},
+			},
+				makeTestNoteNode("deep", nil),
+			),
+			makeTestNoteNode("internal", &core.AccessControl{
+				Deny: &core.AccessControlDeny{
+					Users:  []core.Username{"leo"},
+					Groups: []core.GroupName{"sales"},
+				},
+			},
+				makeTestNoteNode("regrant", &core.AccessControl{
+					Users: map[core.Username]core.AccessControlMode{"leo": core.AccessControlWriteMode},
+				}),
+				makeTestNoteNode("reset", &core.AccessControl{
+					Inherit: &noInherit,
+					Users:   map[core.Username]core.AccessControlMode{"leo": core.AccessControlReadMode},
+				}),
+			),
+			&core.Node{Slug: "img.png", Type: core.AssetNode},
+		),
+		"team": makeTestNoteNode("team", &core.AccessControl{
+			Users:  map[core.Username]core.AccessControlMode{"steve": core.AccessControlReadMode},
+			Groups: map[core.GroupName]core.AccessControlMode{"sales": core.AccessControlWriteMode},
+		},
+			makeTestNoteNode("locked", &core.AccessControl{
+				Deny: &core.AccessControlDeny{Groups: []core.GroupName{"sales"}},
+			}),
+		),
+	}
+}
+
+func TestGetNodeAccessControlPrecedence(t *testing.T) {
+	nodeTree := makeTestPrecedenceTree()
+	tests := []struct {
+		slug              core.NodeSlug
+		useParentFallback bool
+		accessor          core.Accessor
+		expectMode        core.AccessControlMode
+		expectPublicRead  bool
+	}{
+		// inherited from parent
+		{"docs", false, core.Accessor{Username: "leo"}, core.AccessControlWriteMode, true},
+		{"docs/guides", false, core.Accessor{Username: "leo"}, core.AccessControlWriteMode, true},
+		{"docs/guides", false, core.Accessor{Username: "mia", Groups: []core.GroupName{"engineering"}}, core.AccessControlReadMode, true},
+		{"docs/img.png", false, core.Accessor{Username: "leo"}, core.AccessControlWriteMode, true},
+		// not inheriting
+		{"docs/private", false, core.Accessor{Username: "leo"}, "", false},
+		{"docs/private", false, core.Accessor{Username: "steve"}, core.AccessControlReadMode, false},
+		{"docs/private/deep", false, core.Accessor{Username: "steve"}, core.AccessControlReadMode, false},
+		{"docs/private/deep", false, core.Accessor{Username: "mia", Groups: []core.GroupName{"engineering"}}, "", false},
+		{"docs/private/new", true, core.Accessor{Username: "steve"}, core.AccessControlReadMode, false},
+		{"docs/private/new", true, core.Accessor{Username: "leo"}, "", false},
+		// denied
+		{"docs/internal", false, core.Accessor{Username: "leo"}, "", true},
+		{"docs/internal", false, core.Accessor{Username: "mia", Groups: []core.GroupName{"engineering"}}, core.AccessControlReadMode, true},
+		{"docs/internal", false, core.Accessor{Username: "mia", Groups: []core.GroupName{"engineering", "sales"}}, "", true},
+		{"docs/internal/regrant", false, core.Accessor{Username: "leo"}, "", true},
+		{"docs/internal/reset", false, core.Accessor{Username: "leo"}, core.AccessControlReadMode, false},
+		{"team", false, core.Accessor{Username: "steve", Groups: []core.GroupName{"sales"}}, core.AccessControlWriteMode, false},
+		{"team/locked", false, core.Accessor{Username: "steve", Groups: []core.GroupName{"sales"}}, "", false},
+		{"team/locked", false, core.Accessor{Username: "steve"}, core.AccessControlReadMode, false},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			ac, err := GetNodeAccessControl(nodeTree, tt.slug, tt.useParentFallback)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := GetAccessControlModeForAccessor(ac, tt.accessor); actual != tt.expectMode {
+				t.Errorf("actual '%v' expect '%v' (slug '%s')", actual, tt.expectMode, tt.slug)
+			}
+			if ac.PublicRead != tt.expectPublicRead {
+				t.Errorf("actual '%v' expect '%v' (slug '%s')", ac.PublicRead, tt.expectPublicRead, tt.slug)
+			}
+		})
+	}
+}
+
+func getTestNodePath(nodeTree core.NodeTree, fullSlug core.NodeSlug) *core.Node {
+	node := &core.Node{NoteNodeFields: &core.NoteNodeFields{Children: nodeTree}}
+	for _, slugPart := range strings.Split(string(fullSlug), "/") {
+		if node.NoteNodeFields == nil {
+			return nil
+		}
+		child, exists := node.Children[core.NodeSlug(slugPart)]
+		if !exists {
+			return nil
+		}
+		node = child
+	}
+	return node
+}
+
+func hasTestNodePath(nodeTree core.NodeTree, fullSlug core.NodeSlug) bool {
+	return getTestNodePath(nodeTree, fullSlug) != nil
+}
+
+func TestFilteredNodeTreePrecedence(t *testing.T) {
+	nodeTree := makeTestPrecedenceTree()
+	tests := []struct {
+		accessor *core.Accessor
+		slug     core.NodeSlug
+		expect   bool
+	}{
+		{nil, "docs", true},
+		{nil, "docs/guides", true},
+		{nil, "docs/img.png", true},
+		{nil, "docs/private", false},
+		{nil, "docs/private/deep", false},
+		{nil, "docs/internal/reset", false},
+		{nil, "team", false},
+		{&core.Accessor{Username: "steve"}, "docs/private/deep", true},
+		{&core.Accessor{Username: "steve"}, "team/locked", true},
+		{&core.Accessor{Username: "steve", Groups: []core.GroupName{"sales"}}, "team", true},
+		{&core.Accessor{Username: "steve", Groups: []core.GroupName{"sales"}}, "team/locked", false},
+		{&core.Accessor{Username: "leo"}, "docs/internal/reset", true},
+		{&core.Accessor{Username: "leo"}, "docs/private", false},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			filtered := FilteredNodeTree(nodeTree, tt.accessor)
+			if actual := hasTestNodePath(filtered, tt.slug); actual != tt.expect {
+				t.Errorf("actual '%v' expect '%v' (slug '%s')", actual, tt.expect, tt.slug)
+			}
+		})
+	}
+	if !hasTestNodePath(nodeTree, "docs/private/deep") {
+		t.Errorf("original tree was modified")
+	}
+}
+
+func TestFilteredNodeTreeSurfacesDeepNodes(t *testing.T) {
+	nodeTree := core.NodeTree{
+		"private": makeTestNoteNode("private", &core.AccessControl{
+			Users: map[core.Username]core.AccessControlMode{"steve": core.AccessControlWriteMode},
+		},
+			makeTestNoteNode("projects", nil,
+				makeTestNoteNode("shared", &core.AccessControl{
+					Users: map[core.Username]core.AccessControlMode{"leo": core.AccessControlReadMode},
+				},
+					makeTestNoteNode("child", nil),
+				),
+				makeTestNoteNode("secret", nil),
+			),
+		),
+	}
+	nodeTree["private"].FrontMatter.Title = "Private"
+	filtered := FilteredNodeTree(nodeTree, &core.Accessor{Username: "leo"})
+	tests := []struct {
+		slug              core.NodeSlug
+		expect            bool
+		expectPlaceholder bool
+	}{
+		{"private", true, true},
+		{"private/projects", true, true},
+		{"private/projects/shared", true, false},
+		{"private/projects/shared/child", true, false},
+		{"private/projects/secret", false, false},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			if actual := hasTestNodePath(filtered, tt.slug); actual != tt.expect {
+				t.Fatalf("actual '%v' expect '%v' (slug '%s')", actual, tt.expect, tt.slug)
+			}
+			if !tt.expect {
+				return
+			}
+			node := getTestNodePath(filtered, tt.slug)
+			if node.Placeholder != tt.expectPlaceholder {
+				t.Errorf("actual '%v' expect '%v' (slug '%s')", node.Placeholder, tt.expectPlaceholder, tt.slug)
+			}
+		})
+	}
+	if actual := filtered["private"].FrontMatter.Title; actual != "" {
+		t.Errorf("actual '%v' expect '%v'", actual, "")
+	}
+	if len(FilteredNodeTree(nodeTree, nil)) != 0 {
+		t.Errorf("actual '%v' expect '%v'", len(FilteredNodeTree(nodeTree, nil)), 0)
+	}
+}
+
+func TestFilteredNodeTreeSkipsTrash(t *testing.T) {
+	nodeTree := core.NodeTree{
+		".trash": makeTestNoteNode(".trash", nil,
+			makeTestNoteNode("old", &core.AccessControl{PublicRead: true}),
+		),
+		"notes": makeTestNoteNode("notes", &core.AccessControl{PublicRead: true}),
+	}
+	tests := []struct {
+		accessor *core.Accessor
+		slug     core.NodeSlug
+		expect   bool
+	}{
+		{nil, ".trash", false},
+		{nil, ".trash/old", false},
+		{nil, "notes", true},
+		{&core.Accessor{Username: "steve"}, ".trash", false},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			filtered := FilteredNodeTree(nodeTree, tt.accessor)
+			if actual := hasTestNodePath(filtered, tt.slug); actual != tt.expect {
+				t.Errorf("actual '%v' expect '%v' (slug '%s')", actual, tt.expect, tt.slug)
+			}
+		})
+	}
+	filtered := FilteredNodeTreeWithShare(nodeTree, nil, "leo", core.ShareGrant{
+		Owner: "leo", Mode: core.AccessControlReadMode, Slug: ".trash/old",
+	})
+	if hasTestNodePath(filtered, ".trash") {
+		t.Errorf("actual '%v' expect '%v'", true, false)
+	}
+}
+
+func TestFilteredNodeTreeWithShare(t *testing.T) {
+	nodeTree := makeTestPrecedenceTree()
+	tests := []struct {
+		grant  core.ShareGrant
+		slug   core.NodeSlug
+		expect bool
+	}{
+		{core.ShareGrant{Owner: "leo", Mode: core.AccessControlReadMode, Slug: "team/locked"}, "team/locked", true},
+		{core.ShareGrant{Owner: "leo", Mode: core.AccessControlReadMode, Slug: "team/locked"}, "team", true},
+		{core.ShareGrant{Owner: "steve", Mode: core.AccessControlReadMode, Slug: "team/locked"}, "team", false},
+		{core.ShareGrant{Owner: "leo", Mode: core.AccessControlReadMode, Slug: "docs/private"}, "docs/private", true},
+		{core.ShareGrant{Owner: "leo", Mode: core.AccessControlReadMode, Slug: "docs/private"}, "docs/private/deep", false},
+		{core.ShareGrant{Owner: "leo", Mode: core.AccessControlReadMode, Slug: "docs/private", IncludeDescendants: true}, "docs/private/deep", true},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			filtered := FilteredNodeTreeWithShare(nodeTree, nil, "leo", tt.grant)
+			if actual := hasTestNodePath(filtered, tt.slug); actual != tt.expect {
+				t.Errorf("actual '%v' expect '%v' (slug '%s')", actual, tt.expect, tt.slug)
+			}
+		})
+	}
+}
+
+func TestCanReadWithAccessControl(t *testing.T) {
+	publicAc := core.AccessControl{
+		PublicRead: true,
+		Deny: &core.AccessControlDeny{
+			Users:  []core.Username{"leo"},
+			Groups: []core.GroupName{"sales"},
+		},
+	}
+	privateAc := core.AccessControl{
+		Users: map[core.Username]core.AccessControlMode{"leo": core.AccessControlReadMode},
+	}
+	tests := []struct {
+		ac       core.AccessControl
+		accessor *core.Accessor
+		expect   bool
+	}{
+		{publicAc, nil, true},
+		{publicAc, &core.Accessor{Username: "steve"}, true},
+		{publicAc, &core.Accessor{Username: "leo"}, false},
+		{publicAc, &core.Accessor{Username: "steve", Groups: []core.GroupName{"sales"}}, false},
+		{privateAc, nil, false},
+		{privateAc, &core.Accessor{Username: "leo"}, true},
+		{privateAc, &core.Accessor{Username: "steve"}, false},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := canReadWithAccessControl(tt.ac, tt.accessor)
+			if actual != tt.expect {
+				t.Errorf("actual '%v' expect '%v'", actual, tt.expect)
+			}
+		})
+	}
+}
+
+func TestHasAccessControlMode(t *testing.T) {
+	tests := []struct {
+		mode     core.AccessControlMode
+		required core.AccessControlMode
+		expect   bool
+	}{
+		{core.AccessControlReadMode, core.AccessControlReadMode, true},
+		{core.AccessControlReadMode, core.AccessControlCommentMode, false},
+		{core.AccessControlCommentMode, core.AccessControlReadMode, true},
+		{core.AccessControlCommentMode, core.AccessControlCommentMode, true},
+		{core.AccessControlCommentMode, core.AccessControlWriteMode, false},
+		{core.AccessControlWriteMode, core.AccessControlCommentMode, true},
+		{"", core.AccessControlReadMode, false},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := HasAccessControlMode(tt.mode, tt.required)
+			if actual != tt.expect {
+				t.Errorf("actual '%v' expect '%v'", actual, tt.expect)
+			}
+		})
+	}
+}
+
+func TestCountNodes(t *testing.T) {
+	tree := core.NodeTree{
+		"notes": makeTestNoteNode("notes", nil,
+			makeTestNoteNode("child", nil),
+			&core.Node{Slug: "image.png", Type: core.AssetNode},
+		),
+		".trash": makeTestNoteNode(".trash", nil,
+			makeTestNoteNode("old", nil),
+		),
+	}
+	notes, assets := countNodes(tree)
+	if notes != 2 {
+		t.Errorf("actual '%v' expect '%v'", notes, 2)
+	}
+	if assets != 1 {
+		t.Errorf("actual '%v' expect '%v'", assets, 1)
+	}
+}
+
+func TestRenameUserInAccessControl(t *testing.T) {
+	existing := core.AccessControl{
+		PublicRead: true,
+		Users: map[core.Username]core.AccessControlMode{
+			"leo":   core.AccessControlReadMode,
+			"steve": core.AccessControlWriteMode,
+		},
+		Deny: &core.AccessControlDeny{Users: []core.Username{"leo", "mia"}},
+	}
+	tests := []struct {
+		ac            core.AccessControl
+		username      core.Username
+		newUsername   core.Username
+		expect        core.AccessControl
+		expectRenamed bool
+	}{
+		{
+			existing, "leo", "leonardo",
+			core.AccessControl{
+				PublicRead: true,
+				Users: map[core.Username]core.AccessControlMode{
+					"leonardo": core.AccessControlReadMode,
+					"steve":    core.AccessControlWriteMode,
+				},
+				Deny: &core.AccessControlDeny{Users: []core.Username{"leonardo", "mia"}},
+			},
+			true,
+		},
+		{
+			existing, "leo", "steve",
+			core.AccessControl{
+				PublicRead: true,
+				Users:      map[core.Username]core.AccessControlMode{"steve": core.AccessControlWriteMode},
+				Deny:       &core.AccessControlDeny{Users: []core.Username{"steve", "mia"}},
+			},
+			true,
+		},
+		{
+			existing, "mia", "mya",
+			core.AccessControl{
+				PublicRead: true,
+				Users: map[core.Username]core.AccessControlMode{
+					"leo":   core.AccessControlReadMode,
+					"steve": core.AccessControlWriteMode,
+				},
+				Deny: &core.AccessControlDeny{Users: []core.Username{"leo", "mya"}},
+			},
+			true,
+		},
+		{existing, "alex", "alexander", existing, false},
+		{core.AccessControl{}, "leo", "leonardo", core.AccessControl{}, false},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual, renamed := renameUserInAccessControl(tt.ac, tt.username, tt.newUsername)
+			if !reflect.DeepEqual(actual, tt.expect) {
+				t.Errorf("actual '%v' expect '%v'", actual, tt.expect)
+			}
+			if renamed != tt.expectRenamed {
+				t.Errorf("actual '%v' expect '%v'", renamed, tt.expectRenamed)
+			}
+		})
+	}
+	// the existing access control must not be changed
+	if _, exists := existing.Users["leo"]; !exists || existing.Deny.Users[0] != "leo" {
+		t.Errorf("actual '%v' expect unchanged", existing)
+	}
+}
@@ -0,0 +1,149 @@
+package tree
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/enchant97/note-mark/backend/core"
+)
+
+type sharedIndexEntry struct {
+	owner core.Username
+	slug  core.NodeSlug
+}
+
+// Who a single indexed node gives access to.
+type sharedIndexKeys struct {
+	users  []core.Username
+	groups []core.GroupName
+}
+
+// Reverse index from who access has been given to, to the nodes giving it.
+//
+// Only nodes directly giving access are indexed, not their descendants.
+type sharedIndex struct {
+	users  map[core.Username]map[sharedIndexEntry]struct{}
+	groups map[core.GroupName]map[sharedIndexEntry]struct{}
+	// keys each node is indexed under, by owner and then slug
+	nodes map[core.Username]map[core.NodeSlug]sharedIndexKeys
+}
+
+func newSharedIndex() sharedIndex {
+	return sharedIndex{
+		users:  map[core.Username]map[sharedIndexEntry]struct{}{},
+		groups: map[core.GroupName]map[sharedIndexEntry]struct{}{},
+		nodes:  map[core.Username]map[core.NodeSlug]sharedIndexKeys{},
+	}
+}
+
+// Remove every indexed node of an owner.
+func (i *sharedIndex) removeOwner(owner core.Username) {
+	for slug, keys := range i.nodes[owner] {
+		i.removeEntry(sharedIndexEntry{owner: owner, slug: slug}, keys)
+	}
+	delete(i.nodes, owner)
+}
+
+// Remove a node from under the keys it was indexed with.
+func (i *sharedIndex) removeEntry(entry sharedIndexEntry, keys sharedIndexKeys) {
+	for _, username := range keys.users {
+		entries := i.users[username]
+		delete(entries, entry)
+		if len(entries) == 0 {
+			delete(i.users, username)
+		}
+	}
+	for _, groupName := range keys.groups {
+		entries := i.groups[groupName]
+		delete(entries, entry)
+		if len(entries) == 0 {
+			delete(i.groups, groupName)
+		}
+	}
+}
+
+// Replace the indexed access control for a single node.
+func (i *sharedIndex) updateNode(owner core.Username, fullSlug core.NodeSlug, ac *core.AccessControl) {
+	entry := sharedIndexEntry{owner: owner, slug: fullSlug}
+	if keys, exists := i.nodes[owner][fullSlug]; exists {
+		i.removeEntry(entry, keys)
+		delete(i.nodes[owner], fullSlug)
+		if len(i.nodes[owner]) == 0 {
+			delete(i.nodes, owner)
+		}
+	}
+	if ac == nil {
+		return
+	}
+	keys := sharedIndexKeys{}
+	for username := range ac.Users {
+		if username == owner {
+			continue
+		}
+		if _, exists := i.users[username]; !exists {
+			i.users[username] = map[sharedIndexEntry]struct{}{}
+		}
+		i.users[username][entry] = struct{}{}
+		keys.users = append(keys.users, username)
+	}
+	for groupName := range ac.Groups {
+		if _, exists := i.groups[groupName]; !exists {
+			i.groups[groupName] = map[sharedIndexEntry]struct{}{}
+		}
+		i.groups[groupName][entry] = struct{}{}
+		keys.groups = append(keys.groups, groupName)
+	}
+	if len(keys.users) == 0 && len(keys.groups) == 0 {
+		return
+	}
+	if _, exists := i.nodes[owner]; !exists {
+		i.nodes[owner] = map[core.NodeSlug]sharedIndexKeys{}
+	}
+	i.nodes[owner][fullSlug] = keys
+}
+
+// Re-index every node of an owner.
+func (i *sharedIndex) indexTree(owner core.Username, tree core.NodeTree) {
+	i.removeOwner(owner)
+	var walk func(tree core.NodeTree, parentSlug core.NodeSlug)
+	walk = func(tree core.NodeTree, parentSlug core.NodeSlug) {
+		for slug, node := range tree {
+			if node.NoteNodeFields == nil {
+				continue
+			}
+			fullSlug := slug
+			if parentSlug != "" {
+				fullSlug = parentSlug + "/" + slug
+			}
+			i.updateNode(owner, fullSlug, node.FrontMatter.AccessControl)
+			walk(node.Children, fullSlug)
+		}
+	}
+	walk(tree, "")
+}
+
+// Get the nodes giving access to the accessor, ordered by owner and then slug.
+func (i *sharedIndex) lookup(accessor core.Accessor) []sharedIndexEntry {
+	found := map[sharedIndexEntry]struct{}{}
+	for entry := range i.users[accessor.Username] {
+		found[entry] = struct{}{}
+	}
+	for _, groupName := range accessor.Groups {
+		for entry := range i.groups[groupName] {
+			found[entry] = struct{}{}
+		}
+	}
+	entries := make([]sharedIndexEntry, 0, len(found))
+	for entry := range found {
+		if entry.owner != accessor.Username {
+			entries = append(entries, entry)
+		}
+	}
+	slices.SortFunc(entries, func(a, b sharedIndexEntry) int {
+		if c := strings.Compare(string(a.owner), string(b.owner)); c != 0 {
+			return c
+		}
+		return strings.Compare(string(a.slug), string(b.slug))
+	})
+	return entries
+}
@@ -0,0 +1,87 @@
+package tree
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/enchant97/note-mark/backend/core"
+)
+
+func TestSharedIndex(t *testing.T) {
+	index := newSharedIndex()
+	index.indexTree("steve", makeTestPrecedenceTree())
+	index.indexTree("leo", core.NodeTree{
+		"notes": makeTestNoteNode("notes", &core.AccessControl{
+			Users: map[core.Username]core.AccessControlMode{"leo": core.AccessControlWriteMode},
+		}),
+	})
+	tests := []struct {
+		accessor core.Accessor
+		expect   []sharedIndexEntry
+	}{
+		{core.Accessor{Username: "leo"}, []sharedIndexEntry{
+			{"steve", "docs"},
+			{"steve", "docs/internal/regrant"},
+			{"steve", "docs/internal/reset"},
+		}},
+		{core.Accessor{Username: "steve"}, []sharedIndexEntry{}},
+		{core.Accessor{Username: "mia", Groups: []core.GroupName{"sales", "engineering"}}, []sharedIndexEntry{
+			{"steve", "docs"},
+			{"steve", "team"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			actual := index.lookup(tt.accessor)
+			if !slices.Equal(actual, tt.expect) {
+				t.Errorf("actual '%v' expect '%v'", actual, tt.expect)
+			}
+		})
+	}
+}
+
+func TestSharedIndexUpdates(t *testing.T) {
+	index := newSharedIndex()
+	index.updateNode("steve", "notes", &core.AccessControl{
+		Users: map[core.Username]core.AccessControlMode{"leo": core.AccessControlReadMode},
+	})
+	index.updateNode("steve", "other", &core.AccessControl{
+		Users: map[core.Username]core.AccessControlMode{"leo": core.AccessControlReadMode},
+	})
+	index.updateNode("steve", "notes", &core.AccessControl{PublicRead: true})
+	expect := []sharedIndexEntry{{"steve", "other"}}
+	if actual := index.lookup(core.Accessor{Username: "leo"}); !slices.Equal(actual, expect) {
+		t.Errorf("actual '%v' expect '%v'", actual, expect)
+	}
+	index.removeOwner("steve")
+	if actual := index.lookup(core.Accessor{Username: "leo"}); len(actual) != 0 {
+		t.Errorf("actual '%v' expect '%v'", actual, []sharedIndexEntry{})
+	}
+	if len(index.users) != 0 {
+		t.Errorf("actual '%v' expect '%v'", len(index.users), 0)
+	}
+	if len(index.nodes) != 0 {
+		t.Errorf("actual '%v' expect '%v'", len(index.nodes), 0)
+	}
+}
+
+func TestSharedIndexUpdatesGroups(t *testing.T) {
+	index := newSharedIndex()
+	index.updateNode("steve", "notes", &core.AccessControl{
+		Groups: map[core.GroupName]core.AccessControlMode{"sales": core.AccessControlReadMode},
+	})
+	index.updateNode("steve", "notes", &core.AccessControl{
+		Groups: map[core.GroupName]core.AccessControlMode{"engineering": core.AccessControlReadMode},
+	})
+	expect := []sharedIndexEntry{{"steve", "notes"}}
+	if actual := index.lookup(core.Accessor{Username: "mia", Groups: []core.GroupName{"engineering"}}); !slices.Equal(actual, expect) {
+		t.Errorf("actual '%v' expect '%v'", actual, expect)
+	}
+	if _, exists := index.groups["sales"]; exists {
+		t.Errorf("actual '%v' expect '%v'", exists, false)
+	}
+	index.updateNode("steve", "notes", nil)
+	if len(index.groups) != 0 || len(index.nodes) != 0 {
+		t.Errorf("actual '%v' expect '%v'", len(index.groups)+len(index.nodes), 0)
+	}
+}
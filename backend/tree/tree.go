@@ -7,6 +7,7 @@ import (
 	"io"
 	"log/slog"
 	"path"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -18,18 +19,21 @@ import (
 )
 
 type TreeController struct {
-	sc    storage.StorageController
-	dao   *db.DAO
-	mutex *sync.RWMutex
-	tree  map[core.Username]core.NodeTree
+	sc     storage.StorageController
+	dao    *db.DAO
+	mutex  *sync.RWMutex
+	tree   map[core.Username]core.NodeTree
+	shared *sharedIndex
 }
 
 func (tc TreeController) New(sc storage.StorageController, dao *db.DAO) TreeController {
+	shared := newSharedIndex()
 	return TreeController{
-		sc:    sc,
-		dao:   dao,
-		mutex: &sync.RWMutex{},
-		tree:  map[core.Username]core.NodeTree{},
+		sc:     sc,
+		dao:    dao,
+		mutex:  &sync.RWMutex{},
+		tree:   map[core.Username]core.NodeTree{},
+		shared: &shared,
 	}
 }
 
@@ -67,6 +71,16 @@ func (tc *TreeController) GetTreeModTimeForNode(
 	return node.ModTime, nil
 }
 
+// Get a single node for a user, if one exists.
+func (tc *TreeController) TryGetNode(
+	username core.Username,
+	fullSlug core.NodeSlug,
+) (core.Node, error) {
+	tc.mutex.RLock()
+	defer tc.mutex.RUnlock()
+	return tc.tryGetNodeFromMemory(username, fullSlug)
+}
+
 // Get a node tree for a specific user, will return false if no tree exists.
 func (tc *TreeController) TryGetNodeTreeForUser(username core.Username) (core.NodeTree, error) {
 	tc.mutex.RLock()
@@ -85,6 +99,41 @@ func (tc *TreeController) TryGetNodeTreeForUser(username core.Username) (core.No
 	return nil, err
 }
 
+// Get every node another user has directly given the accessor access to,
+// along with the access they effectively have.
+//
+// Nodes in the trash, or where access has since been denied, are skipped.
+func (tc *TreeController) GetNodesSharedWith(accessor core.Accessor) []core.SharedNode {
+	tc.mutex.RLock()
+	defer tc.mutex.RUnlock()
+	sharedNodes := []core.SharedNode{}
+	for _, entry := range tc.shared.lookup(accessor) {
+		if strings.HasPrefix(string(entry.slug), ".trash/") {
+			continue
+		}
+		ac, err := GetNodeAccessControl(tc.tree[entry.owner], entry.slug, false)
+		if err != nil {
+			continue
+		}
+		acMode := GetAccessControlModeForAccessor(ac, accessor)
+		if acMode == "" {
+			continue
+		}
+		node, err := tc.tryGetNodeFromMemory(entry.owner, entry.slug)
+		if err != nil {
+			continue
+		}
+		sharedNodes = append(sharedNodes, core.SharedNode{
+			Owner:   entry.owner,
+			Slug:    entry.slug,
+			Title:   node.FrontMatter.Title,
+			Mode:    acMode,
+			ModTime: node.ModTime,
+		})
+	}
+	return sharedNodes
+}
+
 // Write a new or update existing note node to tree.
 func (tc *TreeController) WriteNoteNode(
 	username core.Username,
@@ -93,6 +142,41 @@ func (tc *TreeController) WriteNoteNode(
 ) error {
 	tc.mutex.Lock()
 	defer tc.mutex.Unlock()
+	if err := tc.writeNoteNode(username, fullSlug, r); err != nil {
+		return err
+	}
+	return tc.updateCacheFromMemory(username)
+}
+
+// Write a new note node to tree, only when a node does not already exist.
+//
+// Returns whether the note was created.
+func (tc *TreeController) WriteNoteNodeIfNotExists(
+	username core.Username,
+	fullSlug core.NodeSlug,
+	r io.Reader,
+) (bool, error) {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+	if _, err := tc.tryGetNodeFromMemory(username, fullSlug); err == nil {
+		return false, nil
+	} else if !errors.Is(err, core.ErrNotFound) {
+		return false, err
+	}
+	if err := tc.writeNoteNode(username, fullSlug, r); err != nil {
+		return false, err
+	}
+	return true, tc.updateCacheFromMemory(username)
+}
+
+// Write a note node to storage and memory, without updating the cache.
+//
+// Assumes mutex has been locked.
+func (tc *TreeController) writeNoteNode(
+	username core.Username,
+	fullSlug core.NodeSlug,
+	r io.Reader,
+) error {
 	if err := tc.sc.WriteNoteNode(username, string(fullSlug), r); err != nil {
 		return err
 	}
@@ -107,7 +191,8 @@ func (tc *TreeController) WriteNoteNode(
 	}, frontmatter); err != nil {
 		return err
 	}
-	return tc.updateCacheFromMemory(username)
+	tc.shared.updateNode(username, fullSlug, frontmatter.AccessControl)
+	return nil
 }
 
 // Write a new or update existing asset node to tree.
@@ -118,17 +203,64 @@ func (tc *TreeController) WriteAssetNode(
 ) error {
 	tc.mutex.Lock()
 	defer tc.mutex.Unlock()
+	if err := tc.writeAssetNode(username, fullSlug, r); err != nil {
+		return err
+	}
+	return tc.updateCacheFromMemory(username)
+}
+
+// Write an asset node to storage and memory, without updating the cache.
+//
+// Assumes mutex has been locked.
+func (tc *TreeController) writeAssetNode(
+	username core.Username,
+	fullSlug core.NodeSlug,
+	r io.Reader,
+) error {
 	if err := tc.sc.WriteAssetNode(username, string(fullSlug), r); err != nil {
 		return err
 	}
-	if _, err := tc.insertNodeEntryIntoMemory(username, core.NodeEntry{
+	_, err := tc.insertNodeEntryIntoMemory(username, core.NodeEntry{
 		FullSlug: fullSlug,
 		Type:     core.AssetNode,
 		ModTime:  time.Now(),
-	}, core.FrontMatter{}); err != nil {
-		return err
+	}, core.FrontMatter{})
+	return err
+}
+
+// Many writes for a single user, saving the cache once flushed instead of after every write.
+type WriteBatch struct {
+	tc       *TreeController
+	username core.Username
+}
+
+// Start a batch of writes, `WriteBatch.Flush` must be called once finished.
+func (tc *TreeController) NewWriteBatch(username core.Username) WriteBatch {
+	return WriteBatch{
+		tc:       tc,
+		username: username,
 	}
-	return tc.updateCacheFromMemory(username)
+}
+
+// Same as `TreeController.WriteNoteNode`, without saving the cache.
+func (b *WriteBatch) WriteNoteNode(fullSlug core.NodeSlug, r io.Reader) error {
+	b.tc.mutex.Lock()
+	defer b.tc.mutex.Unlock()
+	return b.tc.writeNoteNode(b.username, fullSlug, r)
+}
+
+// Same as `TreeController.WriteAssetNode`, without saving the cache.
+func (b *WriteBatch) WriteAssetNode(fullSlug core.NodeSlug, r io.Reader) error {
+	b.tc.mutex.Lock()
+	defer b.tc.mutex.Unlock()
+	return b.tc.writeAssetNode(b.username, fullSlug, r)
+}
+
+// Save the cache, including every write made in the batch.
+func (b *WriteBatch) Flush() error {
+	b.tc.mutex.Lock()
+	defer b.tc.mutex.Unlock()
+	return b.tc.updateCacheFromMemory(b.username)
 }
 
 func (tc *TreeController) UpdateNoteNodeFrontmatter(
@@ -149,6 +281,7 @@ func (tc *TreeController) UpdateNoteNodeFrontmatter(
 	}, newFrontmatter); err != nil {
 		return err
 	}
+	tc.shared.updateNode(username, fullSlug, newFrontmatter.AccessControl)
 	return tc.updateCacheFromMemory(username)
 }
 
@@ -211,6 +344,7 @@ func (tc *TreeController) RenameNode(
 	if err := tc.tryDeleteFromMemory(username, currentFullSlug); err != nil {
 		return err
 	}
+	tc.shared.indexTree(username, tc.tree[username])
 	// update cache
 	return tc.updateCacheFromMemory(username)
 }
@@ -240,6 +374,7 @@ func (tc *TreeController) DeleteNode(
 	if err := tc.tryDeleteFromMemory(username, fullSlug); err != nil {
 		return err
 	}
+	tc.shared.indexTree(username, tc.tree[username])
 	// update cache
 	return tc.updateCacheFromMemory(username)
 }
@@ -259,6 +394,7 @@ func (tc *TreeController) Reset() error {
 		return err
 	}
 	tc.tree = map[core.Username]core.NodeTree{}
+	*tc.shared = newSharedIndex()
 	return nil
 }
 
@@ -274,6 +410,135 @@ func (tc *TreeController) RegisterNewUser(username core.Username) error {
 	return tc.unsafeRegisterNewUser(username)
 }
 
+// Rename a user, moving their nodes in storage and then
+// updating access control of every user that references them.
+//
+// commit is called once storage has been updated, for updating the DB.
+// If updating any access control or commit errors, storage is changed back.
+//
+// errors with `core.ErrNotFound` if user has no tree.
+func (tc *TreeController) RenameUser(
+	username core.Username,
+	newUsername core.Username,
+	commit func() error,
+) error {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+	if _, exists := tc.tree[newUsername]; exists {
+		return core.ErrConflict
+	}
+	if _, exists := tc.tree[username]; !exists {
+		return core.ErrNotFound
+	}
+	if err := tc.sc.RenameUser(username, newUsername); err != nil {
+		return err
+	}
+	renames := []accessControlRename{}
+	for owner, ownerTree := range tc.tree {
+		if owner == username {
+			// their storage has already been moved
+			owner = newUsername
+		}
+		renames = append(renames, findAccessControlRenames(owner, ownerTree, "", username, newUsername)...)
+	}
+	// put storage back as it was, so the old username is never left in access control
+	undo := func(written []accessControlRename) error {
+		errs := []error{}
+		for _, rename := range written {
+			errs = append(errs, tc.sc.UpdateNoteNodeFrontmatter(
+				rename.owner,
+				string(rename.fullSlug),
+				rename.node.FrontMatter,
+			))
+		}
+		errs = append(errs, tc.sc.RenameUser(newUsername, username))
+		return errors.Join(errs...)
+	}
+	for i, rename := range renames {
+		if err := tc.sc.UpdateNoteNodeFrontmatter(rename.owner, string(rename.fullSlug), rename.frontmatter); err != nil {
+			return errors.Join(err, undo(renames[:i]))
+		}
+	}
+	if err := commit(); err != nil {
+		return errors.Join(err, undo(renames))
+	}
+	tc.tree[newUsername] = tc.tree[username]
+	delete(tc.tree, username)
+	changedOwners := map[core.Username]bool{newUsername: true}
+	for _, rename := range renames {
+		rename.node.FrontMatter = rename.frontmatter
+		rename.node.ModTime = time.Now()
+		changedOwners[rename.owner] = true
+	}
+	*tc.shared = newSharedIndex()
+	for owner, ownerTree := range tc.tree {
+		if changedOwners[owner] {
+			if err := tc.updateCacheFromMemory(owner); err != nil {
+				// a stale cache would bring back the old username, so rebuild from storage instead
+				slog.Warn("failed to update tree cache, removing it", "username", owner, "err", err)
+				if err := tc.dao.Queries.DeleteTreeCacheEntry(context.Background(), string(owner)); err != nil {
+					slog.Error("failed to remove tree cache", "username", owner, "err", err)
+				}
+			}
+		}
+		tc.shared.indexTree(owner, ownerTree)
+	}
+	return nil
+}
+
+// Rebuild the tree for a user from storage, replacing their in-memory tree and DB cache.
+//
+// errors with `core.ErrNotFound` if user has no tree.
+func (tc *TreeController) ReloadUser(username core.Username) error {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+	if _, exists := tc.tree[username]; !exists {
+		return core.ErrNotFound
+	}
+	tc.tree[username] = core.NodeTree{}
+	if err := tc.ingestFromStorage(username); err != nil {
+		return err
+	}
+	tc.shared.indexTree(username, tc.tree[username])
+	return tc.updateCacheFromMemory(username)
+}
+
+// Whether a user has a tree, without creating one.
+func (tc *TreeController) HasUser(username core.Username) bool {
+	tc.mutex.RLock()
+	defer tc.mutex.RUnlock()
+	_, exists := tc.tree[username]
+	return exists
+}
+
+// Get the usernames of every user with a tree.
+func (tc *TreeController) GetUsernames() []core.Username {
+	tc.mutex.RLock()
+	defer tc.mutex.RUnlock()
+	usernames := make([]core.Username, 0, len(tc.tree))
+	for username := range tc.tree {
+		usernames = append(usernames, username)
+	}
+	slices.Sort(usernames)
+	return usernames
+}
+
+// Get storage usage for a user, node counts exclude the trash.
+func (tc *TreeController) GetStorageUsageForUser(username core.Username) (core.StorageUsage, error) {
+	tc.mutex.RLock()
+	defer tc.mutex.RUnlock()
+	tree, exists := tc.tree[username]
+	if !exists {
+		return core.StorageUsage{}, core.ErrNotFound
+	}
+	usage, err := tc.sc.GetUsageForUser(username)
+	if err != nil {
+		return core.StorageUsage{}, err
+	}
+	usage.NoteCount, usage.AssetCount = countNodes(tree)
+	return usage, nil
+}
+
 // Load node tree for every discovered user.
 // Will error if in-memory tree is not in a fresh state.
 //
@@ -283,6 +548,7 @@ func (tc *TreeController) RegisterNewUser(username core.Username) error {
 // 4. Get tree from ingesting from storage (if not in cache)
 // 5. Insert tree into DB cache (if not in cache)
 // 6. Add to in-memory tree
+// 7. Index nodes shared with other users
 func (tc *TreeController) Load() error {
 	tc.mutex.Lock()
 	defer tc.mutex.Unlock()
@@ -315,6 +581,7 @@ func (tc *TreeController) Load() error {
 				return err
 			}
 			tc.tree[username] = cachedTree
+			tc.shared.indexTree(username, cachedTree)
 			return nil
 		} else if !errors.Is(core.WrapDbError(err), core.ErrNotFound) {
 			return err
@@ -324,6 +591,7 @@ func (tc *TreeController) Load() error {
 		if err != nil {
 			return err
 		}
+		tc.shared.indexTree(username, tc.tree[username])
 		// insert entries into cache
 		return tc.updateCacheFromMemory(username)
 	})
@@ -335,12 +603,59 @@ func (tc *TreeController) Load() error {
 // - assumes tree mutex has been locked for writing
 func (tc *TreeController) unsafeRegisterNewUser(username core.Username) error {
 	tc.tree[username] = core.NodeTree{}
+	tc.shared.removeOwner(username)
 	if err := tc.sc.CreateUser(username); err != nil {
 		return err
 	}
 	return tc.updateCacheFromMemory(username)
 }
 
+// A note with access control referencing a user being renamed.
+type accessControlRename struct {
+	owner    core.Username
+	fullSlug core.NodeSlug
+	node     *core.Node
+	// with access control moved to the new username
+	frontmatter core.FrontMatter
+}
+
+// Find notes with access control entries for a user,
+// giving the frontmatter to move them to their new username.
+//
+// Assumes tree mutex has been locked.
+func findAccessControlRenames(
+	owner core.Username,
+	nodeTree core.NodeTree,
+	parentSlug core.NodeSlug,
+	username core.Username,
+	newUsername core.Username,
+) []accessControlRename {
+	renames := []accessControlRename{}
+	for slug, node := range nodeTree {
+		if node.NoteNodeFields == nil {
+			continue
+		}
+		fullSlug := slug
+		if parentSlug != "" {
+			fullSlug = parentSlug + "/" + slug
+		}
+		if node.FrontMatter.AccessControl != nil {
+			if ac, renamed := renameUserInAccessControl(*node.FrontMatter.AccessControl, username, newUsername); renamed {
+				frontmatter := node.FrontMatter
+				frontmatter.AccessControl = &ac
+				renames = append(renames, accessControlRename{
+					owner:       owner,
+					fullSlug:    fullSlug,
+					node:        node,
+					frontmatter: frontmatter,
+				})
+			}
+		}
+		renames = append(renames, findAccessControlRenames(owner, node.Children, fullSlug, username, newUsername)...)
+	}
+	return renames
+}
+
 // Insert or update the tree cache from current tree state in-memory.
 //
 // Assumes tree mutex has been locked for writing.
@@ -0,0 +1,151 @@
+package usercache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A cached user lookup, users that no longer exist are also cached.
+type Entry struct {
+	Username string
+	IsAdmin  bool
+	// access tokens issued before this are revoked
+	TokensValidAfter time.Time
+	// set when the user has been deleted or disabled
+	Deleted bool
+}
+
+type Options struct {
+	// entries kept before the least recently used is evicted, 0 disables caching
+	Size int
+	TTL  time.Duration
+}
+
+type Stats struct {
+	Hits      uint64  `json:"hits"`
+	Misses    uint64  `json:"misses"`
+	Evictions uint64  `json:"evictions"`
+	Size      int     `json:"size"`
+	HitRate   float64 `json:"hitRate" doc:"Fraction of lookups answered from the cache"`
+}
+
+type item struct {
+	uid       uuid.UUID
+	entry     Entry
+	expiresAt time.Time
+}
+
+// A bounded cache of user lookups, entries expire after a TTL
+// or when explicitly invalidated.
+type Cache struct {
+	options Options
+	mutex   *sync.Mutex
+	entries map[uuid.UUID]*list.Element
+	// most recently used first
+	order *list.List
+	// incremented on every invalidation, so a load racing one is not stored
+	generation uint64
+	hits       uint64
+	misses     uint64
+	evictions  uint64
+	now        func() time.Time
+}
+
+func (c Cache) New(options Options) Cache {
+	return Cache{
+		options: options,
+		mutex:   &sync.Mutex{},
+		entries: map[uuid.UUID]*list.Element{},
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// Get a user from the cache, using load when missing or expired.
+func (c *Cache) GetOrLoad(uid uuid.UUID, load func(uuid.UUID) (Entry, error)) (Entry, error) {
+	c.mutex.Lock()
+	if element, exists := c.entries[uid]; exists {
+		cached := element.Value.(*item)
+		if c.now().Before(cached.expiresAt) {
+			c.order.MoveToFront(element)
+			c.hits++
+			c.mutex.Unlock()
+			return cached.entry, nil
+		}
+		c.remove(element)
+	}
+	c.misses++
+	generation := c.generation
+	c.mutex.Unlock()
+
+	entry, err := load(uid)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if generation == c.generation {
+		c.set(uid, entry)
+	}
+	return entry, nil
+}
+
+func (c *Cache) set(uid uuid.UUID, entry Entry) {
+	if c.options.Size <= 0 {
+		return
+	}
+	if element, exists := c.entries[uid]; exists {
+		c.remove(element)
+	}
+	c.entries[uid] = c.order.PushFront(&item{
+		uid:       uid,
+		entry:     entry,
+		expiresAt: c.now().Add(c.options.TTL),
+	})
+	for c.order.Len() > c.options.Size {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+}
+
+func (c *Cache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*item).uid)
+}
+
+// Remove a user, for when they have changed.
+func (c *Cache) Invalidate(uid uuid.UUID) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	if element, exists := c.entries[uid]; exists {
+		c.remove(element)
+	}
+}
+
+func (c *Cache) InvalidateAll() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	c.entries = map[uuid.UUID]*list.Element{}
+	c.order.Init()
+}
+
+func (c *Cache) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stats := Stats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Size:      c.order.Len(),
+	}
+	if lookups := c.hits + c.misses; lookups != 0 {
+		stats.HitRate = float64(c.hits) / float64(lookups)
+	}
+	return stats
+}
@@ -0,0 +1,127 @@
+package usercache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type testLoader struct {
+	users map[uuid.UUID]Entry
+	loads int
+}
+
+func (l *testLoader) load(uid uuid.UUID) (Entry, error) {
+	l.loads++
+	if entry, exists := l.users[uid]; exists {
+		return entry, nil
+	}
+	return Entry{Deleted: true}, nil
+}
+
+func makeTestCache(size int, now *time.Time) Cache {
+	cache := Cache{}.New(Options{Size: size, TTL: time.Minute})
+	cache.now = func() time.Time { return *now }
+	return cache
+}
+
+func TestCacheGetOrLoad(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	leo, steve, deleted := uuid.New(), uuid.New(), uuid.New()
+	loader := testLoader{users: map[uuid.UUID]Entry{
+		leo:   {Username: "leo"},
+		steve: {Username: "steve", IsAdmin: true},
+	}}
+	cache := makeTestCache(2, &now)
+	tests := []struct {
+		advance     time.Duration
+		invalidate  bool
+		uid         uuid.UUID
+		expect      Entry
+		expectLoads int
+	}{
+		{0, false, leo, Entry{Username: "leo"}, 1},
+		{0, false, leo, Entry{Username: "leo"}, 1},
+		{0, false, deleted, Entry{Deleted: true}, 2},
+		{0, false, deleted, Entry{Deleted: true}, 2},
+		// evicts leo, as the least recently used
+		{0, false, steve, Entry{Username: "steve", IsAdmin: true}, 3},
+		{0, false, leo, Entry{Username: "leo"}, 4},
+		{30 * time.Second, false, leo, Entry{Username: "leo"}, 4},
+		// expired
+		{30 * time.Second, false, leo, Entry{Username: "leo"}, 5},
+		{0, true, leo, Entry{Username: "leo"}, 6},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			now = now.Add(tt.advance)
+			if tt.invalidate {
+				cache.Invalidate(tt.uid)
+			}
+			actual, err := cache.GetOrLoad(tt.uid, loader.load)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tt.expect {
+				t.Errorf("actual '%v' expect '%v'", actual, tt.expect)
+			}
+			if loader.loads != tt.expectLoads {
+				t.Errorf("actual '%v' expect '%v' loads", loader.loads, tt.expectLoads)
+			}
+		})
+	}
+	stats := cache.Stats()
+	if stats.Hits != 3 || stats.Misses != 6 || stats.Evictions != 2 || stats.Size != 2 {
+		t.Errorf("actual '%+v' expect 3 hits, 6 misses, 2 evictions, size 2", stats)
+	}
+	if stats.HitRate != 3.0/9.0 {
+		t.Errorf("actual '%v' expect '%v'", stats.HitRate, 3.0/9.0)
+	}
+}
+
+func TestCacheInvalidateDuringLoad(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	uid := uuid.New()
+	cache := makeTestCache(10, &now)
+	// user is deleted while the lookup is in progress
+	entry, _ := cache.GetOrLoad(uid, func(uid uuid.UUID) (Entry, error) {
+		cache.Invalidate(uid)
+		return Entry{Username: "leo"}, nil
+	})
+	if entry.Username != "leo" {
+		t.Errorf("actual '%v' expect '%v'", entry.Username, "leo")
+	}
+	entry, _ = cache.GetOrLoad(uid, func(uid uuid.UUID) (Entry, error) {
+		return Entry{Deleted: true}, nil
+	})
+	if !entry.Deleted {
+		t.Errorf("actual '%v' expect '%v'", entry.Deleted, true)
+	}
+}
+
+func TestCacheLoadErrorNotCached(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	uid := uuid.New()
+	cache := makeTestCache(10, &now)
+	errLoad := errors.New("database unavailable")
+	if _, err := cache.GetOrLoad(uid, func(uuid.UUID) (Entry, error) { return Entry{}, errLoad }); !errors.Is(err, errLoad) {
+		t.Errorf("actual '%v' expect '%v'", err, errLoad)
+	}
+	if stats := cache.Stats(); stats.Size != 0 {
+		t.Errorf("actual '%v' expect '%v'", stats.Size, 0)
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	uid := uuid.New()
+	cache := makeTestCache(0, &now)
+	loader := testLoader{users: map[uuid.UUID]Entry{uid: {Username: "leo"}}}
+	cache.GetOrLoad(uid, loader.load)
+	cache.GetOrLoad(uid, loader.load)
+	if loader.loads != 2 {
+		t.Errorf("actual '%v' expect '%v'", loader.loads, 2)
+	}
+}
@@ -3,7 +3,7 @@ import Header from '~/components/Header';
 import LoadingRing from "./loading/LoadingRing";
 import TreeNavigator from 'solid-tree-navigator';
 import Icon, { FileIcon, FolderIcon } from '~/components/Icon';
-import { useNavigate, useParams } from "@solidjs/router";
+import { useLocation, useNavigate, useParams } from "@solidjs/router";
 import Api from "~/core/api";
 import { NodeEntry, NodeTree } from "~/core/types";
 import SortSelect, { SortChoice } from "./input/SortSelect";
@@ -74,9 +74,21 @@ export default function MainApp(props: ParentProps) {
   const { userInfo } = useSession()
   const { setModal, clearModal } = useModal()
   const navigate = useNavigate()
+  const location = useLocation()
   const [sortChoice, setSortChoice] = createSignal(SortChoice.NAME_ASC)
   const [nodeTree, { mutate: setNodeTree }] = createResource(() => params.username, async (username) => {
-    return await Api.getNodeTree(username)
+    const [user, tree] = await Promise.all([
+      Api.getUserByUsername(username),
+      Api.getNodeTree(username),
+    ])
+    if (user.username !== username) {
+      // user has been renamed, so move to their new username
+      navigate(
+        location.pathname.replace(`/${username}`, `/${user.username}`) + location.search + location.hash,
+        { replace: true },
+      )
+    }
+    return tree
   })
   const notesList = () => {
     if (nodeTree.loading) { return }
@@ -50,6 +50,7 @@ export default function AccessControlEditor(props: {
             onChange={(ev) => setNewUser({ mode: ev.currentTarget.value })}
           >
             <option selected={newUser.mode === "read"} value="read">READ</option>
+            <option selected={newUser.mode === "comment"} value="comment">COMMENT</option>
             <option selected={newUser.mode === "write"} value="write">WRITE</option>
           </select>
           <button
@@ -78,6 +79,7 @@ export default function AccessControlEditor(props: {
                     class="badge badge-outline"
                     classList={{
                       "badge-info": acMode === "read",
+                      "badge-accent": acMode === "comment",
                       "badge-warning": acMode === "write",
                     }}
                   >{acMode.toUpperCase()}</span>
@@ -20,6 +20,8 @@ const updateNoteAction = action(async (where: {
   const acByUser: AccessControlUsers = Object.fromEntries(
     (formData.getAll("accessControlByUser") ?? []).map((v) => v.toString().split(":")))
   const accessControl: AccessControl = {
+    // keep fields not managed by the editor (like groups)
+    ...where.currentFrontmatter.accessControl,
     publicRead: acPublicRead,
     users: acByUser,
   }
@@ -4,6 +4,7 @@ import Api from '~/core/api';
 import { action, useSubmission } from '@solidjs/router';
 import { createEffect, Show } from 'solid-js';
 import AlertBox from '../AlertBox';
+import { useSession } from '~/contexts/SessionProvider';
 
 const updateUserPasswordAction = action(async (where: { username: string }, formData: FormData) => {
   const existingPassword = formData.get("existingPassword")?.toString()
@@ -24,6 +25,7 @@ export default function UpdateUserPasswordModal(props: {
   username: string,
   onClose: () => void,
 }) {
+  const { apiInfo } = useSession()
   const [form, setForm] = createStore({
     existingPassword: "",
     newPassword: "",
@@ -68,6 +70,7 @@ export default function UpdateUserPasswordModal(props: {
               type="password"
               placeholder="e.g. Qwerty@123"
               autocomplete="new-password"
+              minlength={apiInfo()?.passwordMinLength}
               required
             />
           </label>
@@ -94,6 +94,7 @@ export function apiErrorIntoToast(err: ApiError, when: string): Toast {
         type: ToastType.ERROR,
       }
     case HttpErrors.PreconditionFailed:
+    case HttpErrors.UnprocessableEntity:
     case HttpErrors.Conflict:
     case HttpErrors.NotFound:
       return {
@@ -1,4 +1,4 @@
-import { OAuth2AccessTokenRequest, OpenIdUserInfoResponse } from "./auth"
+import { OAuth2AccessTokenRequest, OpenIdUserInfoResponse, WebAuthnCeremony, WebAuthnCredential } from "./auth"
 import type { CreateUserWithPassword, Frontmatter, NodeTree, ServerInfo, UpdateUser, UpdateUserPassword, User } from "./types"
 
 export enum HttpMethods {
@@ -23,6 +23,7 @@ export enum HttpErrors {
   NotFound = 404,
   Conflict = 409,
   PreconditionFailed = 412,
+  UnprocessableEntity = 422,
 
   InternalServerError = 500,
 }
@@ -90,6 +91,40 @@ export default class Api {
     await throwResponseApiErrors(resp)
     return await resp.json()
   }
+  static async authWebAuthnLoginBegin(): Promise<WebAuthnCeremony<PublicKeyCredentialRequestOptionsJSON>> {
+    let resp = await apiFetch("auth/webauthn/login/begin", {
+      method: HttpMethods.POST,
+    })
+    await throwResponseApiErrors(resp)
+    return await resp.json()
+  }
+  static async authWebAuthnLoginFinishSession(sessionId: string, credential: PublicKeyCredential) {
+    let resp = await apiFetch(`auth/webauthn/login/finish-session?sessionId=${encodeURIComponent(sessionId)}`, {
+      method: HttpMethods.POST,
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify(credential.toJSON()),
+    })
+    await throwResponseApiErrors(resp)
+  }
+  static async authWebAuthnRegisterBegin(): Promise<WebAuthnCeremony<PublicKeyCredentialCreationOptionsJSON>> {
+    let resp = await apiFetch("auth/webauthn/register/begin", {
+      method: HttpMethods.POST,
+    })
+    await throwResponseApiErrors(resp)
+    return await resp.json()
+  }
+  static async authWebAuthnRegisterFinish(
+    sessionId: string,
+    credential: PublicKeyCredential,
+  ): Promise<WebAuthnCredential> {
+    let resp = await apiFetch(`auth/webauthn/register/finish?sessionId=${encodeURIComponent(sessionId)}`, {
+      method: HttpMethods.POST,
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify(credential.toJSON()),
+    })
+    await throwResponseApiErrors(resp)
+    return await resp.json()
+  }
   static async authGetUserInfo(): Promise<OpenIdUserInfoResponse> {
     let resp = await apiFetch("auth/o/userinfo", {
       method: HttpMethods.GET,
@@ -198,6 +233,9 @@ export default class Api {
     })
     await throwResponseApiErrors(resp)
   }
+  static makeOidcLoginUrl(providerName: string) {
+    return `${ApiServerBaseUrl}/auth/oidc/login?provider=${encodeURIComponent(providerName)}`
+  }
   static makeAssetUrl(username: string, assetFullSlug: string) {
     return `${ApiServerBaseUrl}/tree/content/u/${username}/${assetFullSlug}`
   }
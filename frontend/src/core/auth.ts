@@ -7,6 +7,8 @@ export interface OpenIdUserInfoResponse {
 export interface OAuth2PasswordGrant {
   username: string
   password: string
+  // TOTP or recovery code, required when two-factor is enabled
+  code?: string
 }
 
 export interface OAuth2TokenExchangeGrant {
@@ -14,6 +16,7 @@ export interface OAuth2TokenExchangeGrant {
   subject_token_type: "urn:ietf:params:oauth:token-type:access_token"
   actor_token?: string
   actor_token_type?: "urn:ietf:params:oauth:token-type:id_token"
+  provider_name?: string
 }
 
 export type OAuth2AccessTokenRequest = OAuth2PasswordGrant & {
@@ -21,3 +24,15 @@ export type OAuth2AccessTokenRequest = OAuth2PasswordGrant & {
 } | OAuth2TokenExchangeGrant & {
   grant_type: "urn:ietf:params:oauth:grant-type:token-exchange"
 }
+
+export interface WebAuthnCeremony<T> {
+  sessionId: string
+  options: { publicKey: T }
+}
+
+export interface WebAuthnCredential {
+  id: string
+  name: string
+  createdAt: string
+  lastUsedAt?: string
+}
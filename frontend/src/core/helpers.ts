@@ -89,6 +89,8 @@ export async function copyToClipboard(content: string) {
 export function accessControlModeToLevelNumber(mode: AccessControlMode): number {
   switch (mode) {
     case "write":
+      return 2
+    case "comment":
       return 1
     default:
       return 0
@@ -100,7 +102,9 @@ export function getAccessControlModeForUser(
   username?: string,
 ): AccessControlMode | null {
   if (username !== undefined) {
-    if (Object.hasOwn(ac.users ?? {}, username)) {
+    if (ac.deny?.users?.includes(username)) {
+      return null
+    } else if (Object.hasOwn(ac.users ?? {}, username)) {
       return ac.users![username]
     } else if (ac.publicRead) {
       return "read"
@@ -1,12 +1,22 @@
 export type Username = string
 
-export type AccessControlMode = "read" | "write"
+export type AccessControlMode = "read" | "comment" | "write"
 
 export type AccessControlUsers = Record<Username, AccessControlMode>
 
+export type AccessControlGroups = Record<string, AccessControlMode>
+
+export interface AccessControlDeny {
+  users?: Username[]
+  groups?: string[]
+}
+
 export interface AccessControl {
+  inherit?: boolean
   publicRead: boolean
   users?: AccessControlUsers
+  groups?: AccessControlGroups
+  deny?: AccessControlDeny
 }
 
 export interface Frontmatter {
@@ -25,6 +35,7 @@ export type NodeTree = Record<NodeSlug, NodeTreeNode>
 export type NodeTreeNode = {
   slug: NodeSlug
   modTime: string // TODO extend from ModTime instead
+  placeholder?: boolean
 } & ({
   type: "note"
   frontmatter: Frontmatter
@@ -41,6 +52,7 @@ export interface NodeEntry {
 }
 
 export interface OidcProviderInfo {
+  providerName: string
   displayName: string
   issuerUrl: string
   clientId: string
@@ -49,15 +61,20 @@ export interface OidcProviderInfo {
 export interface ServerInfo {
   minSupportedVersion: string
   allowInternalSignup: boolean
+  inviteOnlySignup: boolean
+  passwordMinLength: number
   allowInternalLogin: boolean
+  allowWebAuthnLogin: boolean
   enableAnonymousUserSearch: boolean
   oidcProvider?: OidcProviderInfo
+  oidcProviders: OidcProviderInfo[]
 }
 
 export interface CreateUserWithPassword {
   username: string
   name?: string
   password: string
+  inviteCode?: string
 }
 
 export interface UpdateUser {
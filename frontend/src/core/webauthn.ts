@@ -0,0 +1,26 @@
+import Api from "./api"
+import { WebAuthnCredential } from "./auth"
+
+/** Login with any passkey registered for this site, starting a session. */
+export async function loginWithPasskey() {
+  const ceremony = await Api.authWebAuthnLoginBegin()
+  const credential = await navigator.credentials.get({
+    publicKey: PublicKeyCredential.parseRequestOptionsFromJSON(ceremony.options.publicKey),
+  })
+  if (credential === null) {
+    throw new Error("no passkey was selected")
+  }
+  await Api.authWebAuthnLoginFinishSession(ceremony.sessionId, credential as PublicKeyCredential)
+}
+
+/** Register a new passkey for the logged in user. */
+export async function registerPasskey(): Promise<WebAuthnCredential> {
+  const ceremony = await Api.authWebAuthnRegisterBegin()
+  const credential = await navigator.credentials.create({
+    publicKey: PublicKeyCredential.parseCreationOptionsFromJSON(ceremony.options.publicKey),
+  })
+  if (credential === null) {
+    throw new Error("passkey creation was cancelled")
+  }
+  return await Api.authWebAuthnRegisterFinish(ceremony.sessionId, credential as PublicKeyCredential)
+}
@@ -9,7 +9,6 @@ import { render } from "solid-js/web";
 import Signup from "./routes/auth/signup";
 import { RequireApiSetupGuard, RequireAuthGuard, RequireNoAuthGuard, RequireSignupAllowedGuard } from "./components/guards";
 import Login from "./routes/auth/login";
-import OidcCallback from "./routes/auth/oidc-callback";
 import { getTheme, setTheme } from './core/theme-switcher';
 import Home from './routes/(home)';
 import User from './routes/[username]/(user)';
@@ -46,7 +45,6 @@ render(() => (
       <Route component={RequireNoAuthGuard}>
         <Route path="/auth/signup" component={() => <RequireSignupAllowedGuard><Signup /></RequireSignupAllowedGuard>} />
         <Route path="/auth/login" component={Login} />
-        <Route path="/auth/oidc-callback" component={OidcCallback} />
       </Route>
       <Route component={RequireAuthGuard}>
         <Route path="/profile" component={Profile} />
@@ -1,11 +1,11 @@
-import { Show, createResource, createSignal } from 'solid-js';
+import { For, Show, createSignal, onMount } from 'solid-js';
 import { createStore } from "solid-js/store";
-import { A, action, redirect, useAction } from '@solidjs/router';
-import Api from '~/core/api';
+import { A, useSearchParams } from '@solidjs/router';
+import Api, { ApiError, HttpErrors } from '~/core/api';
 import { apiErrorIntoToast, ToastType, useToast } from '~/contexts/ToastProvider';
 import Icon from '~/components/Icon';
-import * as oidcClient from 'openid-client'
-import { OidcVerification } from '~/core/oidc';
+import { OidcProviderInfo } from '~/core/types';
+import { loginWithPasskey } from '~/core/webauthn';
 import LoadingSpin from '~/components/loading/LoadingSpin';
 import { useSession } from '~/contexts/SessionProvider';
 import Header from '~/components/Header';
@@ -13,47 +13,32 @@ import Header from '~/components/Header';
 export default function Login() {
   const { apiInfo, refetchUserInfo } = useSession()
   const { pushToast } = useToast()
-  const [formDetails, setFormDetails] = createStore({ username: "", password: "" })
+  const [searchParams, setSearchParams] = useSearchParams()
+  const [formDetails, setFormDetails] = createStore({ username: "", password: "", code: "" })
   const [loading, setLoading] = createSignal(false)
+  const [codeRequired, setCodeRequired] = createSignal(false)
   const [oidcLoading, setOidcLoading] = createSignal(false)
-  const [oidcDiscovery] = createResource(apiInfo, async (apiInfo) => {
-    if (apiInfo.oidcProvider) {
-      try {
-        return await oidcClient.discovery(
-          new URL(apiInfo.oidcProvider!.issuerUrl),
-          apiInfo.oidcProvider!.clientId,
-        )
-      }
-      catch (e) {
-        pushToast({
-          message: "failed to communicate with authentication provider",
-          type: ToastType.ERROR,
-        })
-        throw e
-      }
-    }
-  })
 
-  const startOidcFlow = useAction(action(async (oidcDiscovery) => {
+  const startOidcFlow = (provider: OidcProviderInfo) => {
     setOidcLoading(true)
-    const state = oidcClient.randomState()
-    const verification = new OidcVerification(
-      oidcClient.randomPKCECodeVerifier(),
-      state,
-    )
-    verification.save()
-    const code_challenge = await oidcClient.calculatePKCECodeChallenge(verification.pkceCodeVerifier)
-    const callbackUrl = (new URL("/auth/oidc-callback", window.location.origin)).toString()
-    const scope = "openid profile"
-    const parameters: Record<string, string> = {
-      redirect_uri: callbackUrl,
-      scope,
-      code_challenge,
-      code_challenge_method: 'S256',
-      state,
+    window.location.assign(Api.makeOidcLoginUrl(provider.providerName))
+  }
+
+  onMount(() => {
+    // set when returning from a failed login with an authentication provider
+    if (searchParams.error === "oidc") {
+      const messages: Record<string, string> = {
+        expired: "login with authentication provider took too long, please try again",
+        conflict: "an account already exists with that username",
+        denied: "your account is not allowed to login",
+      }
+      pushToast({
+        message: messages[searchParams.reason as string] ?? "failed to login with authentication provider",
+        type: ToastType.ERROR,
+      })
+      setSearchParams({ error: undefined, reason: undefined })
     }
-    throw redirect(oidcClient.buildAuthorizationUrl(oidcDiscovery, parameters).href)
-  }))
+  })
   const onSubmit = async (ev: Event) => {
     ev.preventDefault()
     setLoading(true)
@@ -62,10 +47,30 @@ export default function Login() {
         grant_type: "password",
         username: formDetails.username,
         password: formDetails.password,
+        code: codeRequired() ? formDetails.code : undefined,
       })
-      setFormDetails({ password: "" })
+      setFormDetails({ password: "", code: "" })
+      setCodeRequired(false)
       refetchUserInfo()
       console.debug("login flow success")
+    } catch (e) {
+      if (e instanceof ApiError && e.status === HttpErrors.Unauthorized && e.message === "two-factor code required") {
+        setCodeRequired(true)
+        pushToast({ message: "enter your two-factor code", type: ToastType.INFO })
+      } else {
+        pushToast(apiErrorIntoToast(e, "logging-in"))
+      }
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  const onPasskeyClick = async () => {
+    setLoading(true)
+    try {
+      await loginWithPasskey()
+      refetchUserInfo()
+      console.debug("passkey login flow success")
     } catch (e) {
       pushToast(apiErrorIntoToast(e, "logging-in"))
     } finally {
@@ -125,6 +130,19 @@ export default function Login() {
                         required
                       />
                     </label>
+                    <Show when={codeRequired()}>
+                      <label class="input validator">
+                        <Icon name="key" />
+                        <input
+                          value={formDetails.code}
+                          oninput={(ev) => { setFormDetails({ code: ev.currentTarget.value }) }}
+                          type="text"
+                          placeholder="Two-Factor Or Recovery Code"
+                          autocomplete="one-time-code"
+                          required
+                        />
+                      </label>
+                    </Show>
                   </fieldset>
                   <div class="join join-vertical w-full mt-5">
                     <button
@@ -143,19 +161,34 @@ export default function Login() {
                     >Need An Account?</A>}
                   </div>
                 </form>
+                <Show when={apiInfo()?.allowWebAuthnLogin && window.PublicKeyCredential}>
+                  <button
+                    class="btn w-full mt-2"
+                    type="button"
+                    disabled={loading()}
+                    onClick={onPasskeyClick}
+                  >
+                    <Icon name="key" />
+                    Login With Passkey
+                  </button>
+                </Show>
               </Show>
-              <Show when={apiInfo()?.oidcProvider} fallback={<></>}>
+              <Show when={apiInfo()?.oidcProviders?.length} fallback={<></>}>
                 {apiInfo()?.allowInternalLogin && <div class="divider">Or</div>}
-                <button
-                  class="btn"
-                  classList={{ "mt-5": !apiInfo()?.allowInternalLogin }}
-                  type="button"
-                  disabled={oidcDiscovery.loading || oidcDiscovery.error || loading()}
-                  onClick={() => startOidcFlow(oidcDiscovery()!)}
-                >
-                  {oidcDiscovery.loading && <span class="loading loading-spinner"></span>}
-                  Login With {apiInfo()?.oidcProvider?.displayName}
-                </button>
+                <div class="flex flex-col gap-2" classList={{ "mt-5": !apiInfo()?.allowInternalLogin }}>
+                  <For each={apiInfo()?.oidcProviders}>
+                    {(provider) => (
+                      <button
+                        class="btn"
+                        type="button"
+                        disabled={loading()}
+                        onClick={() => startOidcFlow(provider)}
+                      >
+                        Login With {provider.displayName}
+                      </button>
+                    )}
+                  </For>
+                </div>
               </Show>
             </div>
           </div>
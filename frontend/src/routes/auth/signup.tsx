@@ -1,7 +1,8 @@
-import { createSignal } from 'solid-js';
+import { Show, createSignal } from 'solid-js';
 import { createStore } from "solid-js/store";
-import { A, useNavigate } from '@solidjs/router';
+import { A, useNavigate, useSearchParams } from '@solidjs/router';
 import { ToastType, apiErrorIntoToast, useToast } from '~/contexts/ToastProvider';
+import { useSession } from '~/contexts/SessionProvider';
 import Api, { HttpErrors } from '~/core/api';
 import Header from '~/components/Header';
 import Icon from '~/components/Icon';
@@ -9,11 +10,15 @@ import Icon from '~/components/Icon';
 export default function Signup() {
   const { pushToast } = useToast()
   const navigate = useNavigate()
+  const { apiInfo } = useSession()
+  const [searchParams] = useSearchParams()
   const [formDetails, setFormDetails] = createStore({
     username: "",
     password: "",
     passwordConfirm: "",
     name: "",
+    // prefilled when following an invite link
+    inviteCode: (searchParams.invite as string | undefined) ?? "",
   })
   const [loading, setLoading] = createSignal(false)
 
@@ -25,11 +30,14 @@ export default function Signup() {
         username: formDetails.username,
         password: formDetails.password,
         name: formDetails.name || undefined,
+        inviteCode: formDetails.inviteCode || undefined,
       })
       pushToast({ message: "created new account", type: ToastType.SUCCESS })
       navigate("/auth/login")
     } catch (err) {
-      if (err.status === HttpErrors.Forbidden) {
+      if (err.status === HttpErrors.Forbidden && (inviteRequired() || formDetails.inviteCode)) {
+        pushToast({ message: "a valid invite code is required", type: ToastType.ERROR })
+      } else if (err.status === HttpErrors.Forbidden) {
         pushToast({ message: "server is not accepting new accounts", type: ToastType.ERROR })
       } else {
         pushToast(apiErrorIntoToast(err, "creating account"))
@@ -40,6 +48,7 @@ export default function Signup() {
   }
 
   const passwordsMatch = () => formDetails.password === formDetails.passwordConfirm
+  const inviteRequired = () => apiInfo()?.inviteOnlySignup ?? false
 
   return (
     <div class="min-h-screen">
@@ -91,9 +100,13 @@ export default function Signup() {
                     type="password"
                     placeholder="Password"
                     autocomplete="new-password"
+                    minlength={apiInfo()?.passwordMinLength}
                     required
                   />
                 </label>
+                <p class="validator-hint hidden">
+                  Must be at least {apiInfo()?.passwordMinLength} characters
+                </p>
                 <label class="input validator">
                   <Icon name="lock" />
                   <input
@@ -106,6 +119,20 @@ export default function Signup() {
                     required
                   />
                 </label>
+                <Show when={inviteRequired() || formDetails.inviteCode}>
+                  <label class="input validator">
+                    <Icon name="key" />
+                    <input
+                      value={formDetails.inviteCode}
+                      oninput={(ev) => { setFormDetails({ inviteCode: ev.currentTarget.value }) }}
+                      type="text"
+                      placeholder="Invite Code"
+                      autocomplete="off"
+                      maxlength={64}
+                      required={inviteRequired()}
+                    />
+                  </label>
+                </Show>
               </fieldset>
               <div class="join join-vertical w-full mt-5">
                 <button class="btn join-item btn-primary" disabled={!passwordsMatch() || loading()} type="submit">
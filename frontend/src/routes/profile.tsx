@@ -6,10 +6,13 @@ import { User } from '~/core/types';
 import Icon from '~/components/Icon';
 import Header from '~/components/Header';
 import { useSession } from '~/contexts/SessionProvider';
+import { apiErrorIntoToast, ToastType, useToast } from '~/contexts/ToastProvider';
+import { registerPasskey } from '~/core/webauthn';
 
 export default function Profile() {
   const { setModal, clearModal } = useModal()
   const { apiInfo, userInfo, refetchUserInfo } = useSession()
+  const { pushToast } = useToast()
 
   const onUpdateProfileClick = () => {
     // TODO request actual user info (to get modTime, etc...)
@@ -45,6 +48,15 @@ export default function Profile() {
     )
   }
 
+  const onAddPasskeyClick = async () => {
+    try {
+      const credential = await registerPasskey()
+      pushToast({ message: `added passkey '${credential.name}'`, type: ToastType.SUCCESS })
+    } catch (e) {
+      pushToast(apiErrorIntoToast(e, "adding passkey"))
+    }
+  }
+
   return (
     <div class="min-h-screen">
       <Header disableDrawerToggle={true} />
@@ -66,6 +78,12 @@ export default function Profile() {
                   class="btn join-item">
                   Change Password
                 </button>}
+              {apiInfo()?.allowWebAuthnLogin && window.PublicKeyCredential &&
+                <button
+                  onclick={() => onAddPasskeyClick()}
+                  class="btn join-item">
+                  Add Passkey
+                </button>}
             </div>
             <A
               class="btn btn-wide mx-auto mt-4"